@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// accessLogPattern matches the Apache/Nginx common and combined log formats
+var accessLogPattern = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}|-) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// accessLogTimeLayout is the timestamp layout used between the square brackets
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// parseAccessLog parses a combined log format line into a structured record
+func parseAccessLog(line string) (Input, error) {
+	m := accessLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New("not in common or combined log format")
+	}
+
+	ts, err := time.Parse(accessLogTimeLayout, m[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q", m[4])
+	}
+
+	// Emit the timestamp as RFC3339 so the standard coercion converts it to epoch
+	record := Input{
+		"ip": m[1],
+		"ts": ts.Format(time.RFC3339),
+	}
+	setAccessLogField(record, "ident", m[2])
+	setAccessLogField(record, "user", m[3])
+	setAccessLogField(record, "status", m[6])
+	setAccessLogField(record, "bytes", m[7])
+	setAccessLogField(record, "referer", unescapeAccessLog(m[8]))
+	setAccessLogField(record, "user_agent", unescapeAccessLog(m[9]))
+
+	// Split the request line into method, path and protocol
+	request := strings.Fields(unescapeAccessLog(m[5]))
+	switch len(request) {
+	case 3:
+		record["protocol"] = request[2]
+		fallthrough
+	case 2:
+		record["method"] = request[0]
+		record["path"] = request[1]
+	default:
+		setAccessLogField(record, "request", unescapeAccessLog(m[5]))
+	}
+
+	return record, nil
+}
+
+// setAccessLogField sets a field unless its value is empty or the "-" placeholder
+func setAccessLogField(record Input, key, value string) {
+	if value != "" && value != "-" {
+		record[key] = value
+	}
+}
+
+// unescapeAccessLog reverses the escaping of quotes and backslashes in quoted fields,
+// leaving hex escapes such as \x16 as they were logged
+func unescapeAccessLog(s string) string {
+	return accessLogUnescaper.Replace(s)
+}
+
+// accessLogUnescaper replaces escaped quotes and backslashes with their literal form
+var accessLogUnescaper = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccessLog(t *testing.T) {
+	tests := []struct {
+		line string
+		want Input
+	}{
+		{
+			line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			want: Input{
+				"ip": "127.0.0.1", "user": "frank", "ts": "2000-10-10T13:55:36-07:00",
+				"method": "GET", "path": "/apache_pb.gif", "protocol": "HTTP/1.0",
+				"status": "200", "bytes": "2326",
+			},
+		},
+		{
+			line: `10.0.0.2 - - [01/Feb/2024:08:00:00 +0000] "POST /login HTTP/1.1" 302 - "https://example.com/?q=\"x\"" "curl/8.0 \\o/"`,
+			want: Input{
+				"ip": "10.0.0.2", "ts": "2024-02-01T08:00:00Z",
+				"method": "POST", "path": "/login", "protocol": "HTTP/1.1", "status": "302",
+				"referer": `https://example.com/?q="x"`, "user_agent": `curl/8.0 \o/`,
+			},
+		},
+		{
+			// Malformed request lines are kept whole, with hex escapes as logged
+			line: `::1 - - [01/Feb/2024:08:00:00 +0000] "\x16\x03\x01" 400 0 "-" "-"`,
+			want: Input{
+				"ip": "::1", "ts": "2024-02-01T08:00:00Z",
+				"request": `\x16\x03\x01`, "status": "400", "bytes": "0",
+			},
+		},
+		{
+			line: `::1 - - [01/Feb/2024:08:00:00 +0000] "OPTIONS *" 204 0`,
+			want: Input{
+				"ip": "::1", "ts": "2024-02-01T08:00:00Z",
+				"method": "OPTIONS", "path": "*", "status": "204", "bytes": "0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseAccessLog(tt.line)
+		if err != nil {
+			t.Errorf("parseAccessLog(%q) error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAccessLog(%q) = %#v, want %#v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{
+		`not a log line`,
+		`127.0.0.1 - - [10/Oct/2000 13:55:36] "GET / HTTP/1.0" 200 1`,
+		`127.0.0.1 - - [32/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200 1`,
+	} {
+		if _, err := parseAccessLog(line); err == nil {
+			t.Errorf("parseAccessLog(%q) succeeded, want error", line)
+		}
+	}
+}
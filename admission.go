@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errSaturated is returned when a server is transforming as many requests as it may and as
+// many more are waiting
+var errSaturated = errors.New("server is saturated")
+
+// admission limits the requests a server transforms at once, queueing up to a bound of
+// others in turn and turning away the rest. A nil admission admits every request at once.
+type admission struct {
+	slots chan struct{}
+	queue chan struct{}
+}
+
+// newAdmission admits concurrent requests at once and queues up to queued others
+func newAdmission(concurrent, queued int) *admission {
+	return &admission{slots: make(chan struct{}, concurrent), queue: make(chan struct{}, queued)}
+}
+
+// admit waits for a slot to transform a request in, returning the function that frees it.
+// It fails with errSaturated when the queue is full, or with the error of ctx when the
+// request is canceled while queued.
+func (a *admission) admit(ctx context.Context) (func(), error) {
+	if a == nil {
+		return func() {}, nil
+	}
+	release := func() { <-a.slots }
+	select {
+	case a.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	select {
+	case a.queue <- struct{}{}:
+		defer func() { <-a.queue }()
+	default:
+		return nil, errSaturated
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestAdmission(t *testing.T) {
+	a := newAdmission(1, 1)
+	free, err := a.admit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second request queues until the first frees its slot
+	admitted := make(chan error)
+	go func() {
+		free, err := a.admit(context.Background())
+		if err == nil {
+			free()
+		}
+		admitted <- err
+	}()
+	for len(a.queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := a.admit(context.Background()); !errors.Is(err, errSaturated) {
+		t.Errorf("admit with a full queue = %v, want errSaturated", err)
+	}
+	free()
+	if err := <-admitted; err != nil {
+		t.Errorf("queued admit = %v", err)
+	}
+
+	// Canceled requests leave the queue
+	free, _ = a.admit(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.admit(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("canceled admit = %v, want context.DeadlineExceeded", err)
+	}
+	if len(a.queue) != 0 {
+		t.Errorf("%d requests left queued", len(a.queue))
+	}
+	free()
+}
+
+func TestTransformHandlerSaturated(t *testing.T) {
+	a := newAdmission(1, 0)
+	server := httptest.NewServer(newTransformHandler(&transform.Transformer{}, "", 1<<10, a, nil))
+	defer server.Close()
+
+	free, err := a.admit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"a": "1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("saturated request = %d, Retry-After %q, want 429", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	free()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// harPreset extracts the JSON request and response bodies of every entry in a HAR file
+func harPreset(record Input) []Input {
+	var records []Input
+
+	harLog, _ := record["log"].(map[string]interface{})
+	entries, _ := harLog["entries"].([]interface{})
+	for i, e := range entries {
+		entry, _ := e.(map[string]interface{})
+
+		// Request bodies live in request.postData.text
+		request, _ := entry["request"].(map[string]interface{})
+		postData, _ := request["postData"].(map[string]interface{})
+		if text, ok := postData["text"].(string); ok {
+			records = append(records, jsonBodyRecords(text, fmt.Sprintf("entry %d request", i))...)
+		}
+
+		// Response bodies live in response.content.text, optionally base64 encoded
+		response, _ := entry["response"].(map[string]interface{})
+		content, _ := response["content"].(map[string]interface{})
+		if text, ok := content["text"].(string); ok {
+			if content["encoding"] == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(text)
+				if err != nil {
+					warn("invalid-body", "Skipping entry %d response with invalid base64 body", i)
+					continue
+				}
+				text = string(decoded)
+			}
+			records = append(records, jsonBodyRecords(text, fmt.Sprintf("entry %d response", i))...)
+		}
+	}
+
+	return records
+}
+
+// postmanPreset extracts the JSON request bodies and saved example responses of every item
+// in a Postman collection, descending into folders
+func postmanPreset(record Input) []Input {
+	items, _ := record["item"].([]interface{})
+	return postmanItems(items, "")
+}
+
+// postmanItems walks a list of collection items, naming each by its folder path
+func postmanItems(items []interface{}, parent string) []Input {
+	var records []Input
+
+	for _, it := range items {
+		item, _ := it.(map[string]interface{})
+		name, _ := item["name"].(string)
+		if parent != "" {
+			name = parent + "/" + name
+		}
+
+		// Folders hold further items
+		if children, ok := item["item"].([]interface{}); ok {
+			records = append(records, postmanItems(children, name)...)
+			continue
+		}
+
+		request, _ := item["request"].(map[string]interface{})
+		body, _ := request["body"].(map[string]interface{})
+		if raw, ok := body["raw"].(string); ok && body["mode"] == "raw" {
+			records = append(records, jsonBodyRecords(raw, fmt.Sprintf("%q request", name))...)
+		}
+
+		responses, _ := item["response"].([]interface{})
+		for _, r := range responses {
+			response, _ := r.(map[string]interface{})
+			if text, ok := response["body"].(string); ok {
+				records = append(records, jsonBodyRecords(text, fmt.Sprintf("%q response", name))...)
+			}
+		}
+	}
+
+	return records
+}
+
+// jsonBodyRecords decodes a captured body into records: an object becomes one record and an
+// array contributes each of its objects. Empty and non-JSON bodies are skipped.
+func jsonBodyRecords(body, source string) []Input {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		warn("invalid-body", "Skipping %s body that is not JSON", source)
+		return nil
+	}
+
+	switch v := decoded.(type) {
+	case map[string]interface{}:
+		return []Input{v}
+	case []interface{}:
+		var records []Input
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				records = append(records, m)
+			}
+		}
+		return records
+	}
+	warn("invalid-body", "Skipping %s body that is not a JSON object or array", source)
+	return nil
+}
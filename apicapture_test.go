@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHARPreset(t *testing.T) {
+	var har Input
+	err := json.Unmarshal([]byte(`{"log": {"entries": [
+		{"request": {"postData": {"text": "{\"q\": \"a\"}"}}, "response": {"content": {"text": "[{\"id\": 1}, 2, {\"id\": 3}]"}}},
+		{"request": {}, "response": {"content": {"text": "eyJvayI6dHJ1ZX0=", "encoding": "base64"}}},
+		{"request": {"postData": {"text": "  "}}, "response": {"content": {}}}
+	]}}`), &har)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{"q": "a"}, {"id": float64(1)}, {"id": float64(3)}, {"ok": true}}
+	if got := harPreset(har); !reflect.DeepEqual(got, want) {
+		t.Errorf("harPreset = %#v, want %#v", got, want)
+	}
+}
+
+func TestPostmanPreset(t *testing.T) {
+	var collection Input
+	err := json.Unmarshal([]byte(`{"item": [
+		{"name": "users", "item": [
+			{"name": "create", "request": {"body": {"mode": "raw", "raw": "{\"name\": \"ada\"}"}},
+			 "response": [{"body": "{\"id\": \"u1\"}"}, {"body": ""}]}
+		]},
+		{"name": "upload", "request": {"body": {"mode": "formdata", "raw": "{\"ignored\": true}"}}}
+	]}`), &collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{"name": "ada"}, {"id": "u1"}}
+	if got := postmanPreset(collection); !reflect.DeepEqual(got, want) {
+		t.Errorf("postmanPreset = %#v, want %#v", got, want)
+	}
+}
+
+func TestJSONBodyRecords(t *testing.T) {
+	tests := []struct {
+		body string
+		want []Input
+	}{
+		{`{"a": "1"}`, []Input{{"a": "1"}}},
+		{` [{"a": "1"}, "x", {"b": "2"}] `, []Input{{"a": "1"}, {"b": "2"}}},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		if got := jsonBodyRecords(tt.body, "test"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("jsonBodyRecords(%q) = %#v, want %#v", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// archiveKind returns the archive type implied by a file name, or "" for other files
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// matchMember reports whether an archive member name matches the member glob. Patterns
+// without a slash are matched against the base name, others against the full path.
+func matchMember(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	target := name
+	if !strings.Contains(glob, "/") {
+		target = path.Base(name)
+	}
+	ok, err := path.Match(glob, target)
+	return err == nil && ok
+}
+
+// readArchive decodes every regular member of a zip or tar archive matching the glob,
+// skipping members that fail to decode
+func readArchive(name, glob string, opts inputOptions) ([]member, error) {
+	var members []member
+
+	decode := func(memberName string, r io.Reader) {
+		memberName = path.Clean(memberName)
+		if !matchMember(glob, memberName) {
+			return
+		}
+		records, err := readInput(r, opts)
+		if err != nil {
+			warn("archive-member", "Skipping archive member %q: %v", memberName, err)
+			return
+		}
+		members = append(members, member{name: memberName, records: records})
+	}
+
+	if archiveKind(name) == "zip" {
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			decode(f.Name, rc)
+			rc.Close()
+		}
+		return members, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if archiveKind(name) == "tar.gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			decode(hdr.Name, tr)
+		}
+	}
+	return members, nil
+}
+
+// memberSink is a sink that mirrors the members of an archive input
+type memberSink interface {
+	sink
+	// StartMember begins the output member with the given name
+	StartMember(name string) error
+}
+
+// archiveSink writes the output of each input member to a member of the same name in a
+// zip or tar archive
+type archiveSink struct {
+	file   *os.File
+	format string
+
+	zw *zip.Writer
+	gz *gzip.Writer
+	tw *tar.Writer
+
+	// Tar headers need the member size, so tar members are buffered until complete
+	name    string
+	started bool
+	buf     bytes.Buffer
+	current io.Writer
+}
+
+// openArchiveSink creates the output archive, using the format for each member
+func openArchiveSink(name, format string) (*archiveSink, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &archiveSink{file: f, format: format}
+	switch archiveKind(name) {
+	case "zip":
+		s.zw = zip.NewWriter(f)
+	case "tar.gz":
+		s.gz = gzip.NewWriter(f)
+		s.tw = tar.NewWriter(s.gz)
+	default:
+		s.tw = tar.NewWriter(f)
+	}
+	return s, nil
+}
+
+// StartMember finishes the current member and begins a new one
+func (s *archiveSink) StartMember(name string) error {
+	if err := s.finishMember(); err != nil {
+		return err
+	}
+	s.name = name
+	s.started = true
+	if s.zw != nil {
+		w, err := s.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		s.current = w
+		return nil
+	}
+	s.buf.Reset()
+	s.current = &s.buf
+	return nil
+}
+
+// Write appends the output to the current member
+func (s *archiveSink) Write(output Output) error {
+	if !s.started {
+		if err := s.StartMember("output." + outputExtension(s.format)); err != nil {
+			return err
+		}
+	}
+	data, err := encodeOutput(output, s.format)
+	if err != nil {
+		return err
+	}
+	_, err = s.current.Write(data)
+	return err
+}
+
+// finishMember writes the buffered tar member, if any
+func (s *archiveSink) finishMember() error {
+	if !s.started || s.tw == nil {
+		return nil
+	}
+	hdr := &tar.Header{
+		Name:    s.name,
+		Mode:    0644,
+		Size:    int64(s.buf.Len()),
+		ModTime: time.Now(),
+	}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := s.tw.Write(s.buf.Bytes())
+	return err
+}
+
+// Close finishes the archive and closes the file
+func (s *archiveSink) Close() error {
+	err := s.finishMember()
+	if s.zw != nil {
+		if cerr := s.zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if s.tw != nil {
+		if cerr := s.tw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if s.gz != nil {
+		if cerr := s.gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// outputExtension returns the file extension conventionally used for an output format
+func outputExtension(format string) string {
+	switch format {
+	case "ndjson", "yaml", "toml", "msgpack", "cbor", "bson":
+		return format
+	}
+	return "json"
+}
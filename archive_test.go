@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestArchiveKind(t *testing.T) {
+	tests := map[string]string{
+		"in.zip": "zip", "IN.ZIP": "zip", "in.tar": "tar", "in.tar.gz": "tar.gz", "in.tgz": "tar.gz",
+		"in.json": "", "zip": "",
+	}
+	for name, want := range tests {
+		if got := archiveKind(name); got != want {
+			t.Errorf("archiveKind(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestMatchMember(t *testing.T) {
+	tests := []struct {
+		glob, name string
+		want       bool
+	}{
+		{"", "a/b.json", true},
+		{"*", "a/b.json", true},
+		{"*.json", "a/b.json", true},
+		{"*.json", "a/b.txt", false},
+		{"a/*", "a/b.json", true},
+		{"a/*", "c/b.json", false},
+		{"[", "a", false},
+	}
+	for _, tt := range tests {
+		if got := matchMember(tt.glob, tt.name); got != tt.want {
+			t.Errorf("matchMember(%q, %q) = %v, want %v", tt.glob, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "in.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, m := range []struct{ name, body string }{
+		{"a/one.json", `{"n": "1"}`},
+		{"b/two.json", `{"n": "2"}`},
+		{"b/bad.json", `not json`},
+		{"notes.txt", `{"skip": "me"}`},
+	} {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, m.body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Members failing to decode are skipped with a warning
+	members, err := readArchive(name, "*.json", inputOptions{format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []member{
+		{name: "a/one.json", records: []Input{{"n": "1"}}},
+		{name: "b/two.json", records: []Input{{"n": "2"}}},
+	}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("readArchive = %#v, want %#v", members, want)
+	}
+}
+
+func TestArchiveSink(t *testing.T) {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz"} {
+		name := filepath.Join(t.TempDir(), "out"+ext)
+		s, err := openArchiveSink(name, "ndjson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range []string{"a/one.json", "b/two.json"} {
+			if err := s.StartMember(m); err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range []string{"1", "2"} {
+				if err := s.Write(Output{{"n": n}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		if ext == ".zip" {
+			zr, err := zip.OpenReader(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range zr.File {
+				rc, _ := f.Open()
+				data, _ := io.ReadAll(rc)
+				rc.Close()
+				got[f.Name] = string(data)
+			}
+			zr.Close()
+		} else {
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var r io.Reader = f
+			if ext == ".tar.gz" {
+				if r, err = gzip.NewReader(f); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tr := tar.NewReader(r)
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					break
+				}
+				data, _ := io.ReadAll(tr)
+				got[hdr.Name] = string(data)
+			}
+			f.Close()
+		}
+		lines := "[{\"n\":\"1\"}]\n[{\"n\":\"2\"}]\n"
+		if want := map[string]string{"a/one.json": lines, "b/two.json": lines}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: members = %q, want %q", ext, got, want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// avroSchema is the schema Avro output is written with, as set by -avro-schema, or nil to
+// infer it from the records
+var avroSchema avro.Schema
+
+// avroSchemaOut is the file the schema of Avro output is written to, as set by
+// -avro-schema-out
+var avroSchemaOut string
+
+// avroKeyAttribute is the field attribute naming the record key a field is read from, for
+// keys that are not valid Avro names
+const avroKeyAttribute = "key"
+
+// loadAvroSchema parses the Avro schema in a file, returning nil without a file
+func loadAvroSchema(name string) (avro.Schema, error) {
+	if name == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := avro.ParseBytesWithCache(data, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("parsing Avro schema %s: %v", name, err)
+	}
+	if schema.Type() != avro.Record {
+		return nil, fmt.Errorf("Avro schema %s is a %s, want a record", name, schema.Type())
+	}
+	return schema, nil
+}
+
+// avroSink writes the outputs as an Avro object container file. With a schema the records
+// are written as they come, leaving out keys the schema lacks; without one the schema is
+// inferred from every record as by codegen, so the records are held until the sink is
+// closed. Inferred fields are nullable, integers are longs unless a float is met, and
+// fields holding values of conflicting kinds, or only nulls, are strings with the other
+// values as JSON.
+type avroSink struct {
+	w         io.Writer
+	schemaOut string
+	schema    avro.Schema
+	enc       *ocf.Encoder
+	inferred  *schemaNode
+	records   []map[string]interface{}
+}
+
+// newAvroSink returns a sink writing Avro to w with a schema, or inferring one if nil,
+// and writing the schema to schemaOut when set
+func newAvroSink(w io.Writer, schema avro.Schema, schemaOut string) (*avroSink, error) {
+	s := &avroSink{w: w, schemaOut: schemaOut}
+	if schema == nil {
+		s.inferred = &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}
+		return s, nil
+	}
+	return s, s.start(schema)
+}
+
+// start writes the header of the container file
+func (s *avroSink) start(schema avro.Schema) error {
+	if s.schemaOut != "" {
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(s.schemaOut, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("writing Avro schema: %v", err)
+		}
+	}
+	var err error
+	s.schema = schema
+	s.enc, err = ocf.NewEncoderWithSchema(schema, s.w, ocf.WithCodec(ocf.Deflate), ocf.WithSchemaMarshaler(ocf.FullSchemaMarshaler))
+	return err
+}
+
+// Write writes the output as a record, or holds it while the schema is inferred
+func (s *avroSink) Write(output Output) error {
+	value, err := binaryValue(mergeOutput(output))
+	if err != nil {
+		return err
+	}
+	record := value.(map[string]interface{})
+	if s.inferred != nil {
+		mergeObjectSchema(s.inferred, record)
+		s.records = append(s.records, record)
+		return nil
+	}
+	return s.encode(record)
+}
+
+// encode writes a record with the schema of the file
+func (s *avroSink) encode(record map[string]interface{}) error {
+	value, err := avroValue(s.schema, record, false)
+	if err != nil {
+		return fmt.Errorf("encoding Avro: %v", err)
+	}
+	return s.enc.Encode(value)
+}
+
+// Close writes the held records with their inferred schema and ends the file
+func (s *avroSink) Close() error {
+	if s.inferred != nil {
+		schema, err := avro.ParseWithCache(string(inferAvroSchema(s.inferred, "Record")), "", &avro.SchemaCache{})
+		if err != nil {
+			return fmt.Errorf("inferring Avro schema: %v", err)
+		}
+		if err := s.start(schema); err != nil {
+			return err
+		}
+		for _, record := range s.records {
+			if err := s.encode(record); err != nil {
+				return err
+			}
+		}
+	}
+	return s.enc.Close()
+}
+
+// avroNamePattern matches the characters not allowed in Avro names
+var avroNamePattern = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// avroName converts a record key into an Avro name
+func avroName(key string) string {
+	name := avroNamePattern.ReplaceAllString(key, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// inferAvroSchema returns the JSON of the Avro record schema of an object schema. Nested
+// records are named after their path, as in "Record_address".
+func inferAvroSchema(n *schemaNode, name string) json.RawMessage {
+	used := make(map[string]bool)
+	var avroType func(n *schemaNode, name string) interface{}
+	avroRecord := func(n *schemaNode, name string) interface{} {
+		unique := name
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s%d", name, i)
+		}
+		used[unique] = true
+
+		names := uniqueNames(n, avroName)
+		fields := make([]map[string]interface{}, 0, len(n.fields))
+		for _, key := range n.sortedFields() {
+			field := map[string]interface{}{
+				"name":    names[key],
+				"type":    []interface{}{"null", avroType(n.fields[key], unique+"_"+names[key])},
+				"default": nil,
+			}
+			if names[key] != key {
+				field[avroKeyAttribute] = key
+			}
+			fields = append(fields, field)
+		}
+		return map[string]interface{}{"type": "record", "name": unique, "fields": fields}
+	}
+	avroType = func(n *schemaNode, name string) interface{} {
+		switch n.kind {
+		case "bool":
+			return "boolean"
+		case "int":
+			return "long"
+		case "float":
+			return "double"
+		case "bytes":
+			return "bytes"
+		case "array":
+			return map[string]interface{}{"type": "array", "items": []interface{}{"null", avroType(n.elem, name)}}
+		case "object":
+			if len(n.fields) > 0 {
+				return avroRecord(n, name)
+			}
+		}
+		return "string"
+	}
+
+	data, _ := json.Marshal(avroRecord(n, name))
+	return data
+}
+
+// avroValue converts a value to the Go value the encoder expects for a schema. Strings
+// take values of other kinds as JSON unless strict, as when trying the types of a union.
+func avroValue(schema avro.Schema, value interface{}, strict bool) (interface{}, error) {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return avroValue(s.Schema(), value, strict)
+	case *avro.UnionSchema:
+		if value == nil && s.Nullable() {
+			return nil, nil
+		}
+		// The first type holding the value as it is wins over types converting it
+		for _, strict := range []bool{true, false} {
+			for _, t := range s.Types() {
+				if t.Type() == avro.Null {
+					continue
+				}
+				if v, err := avroValue(t, value, strict); err == nil {
+					return map[string]interface{}{avroTypeName(t): v}, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%s does not hold %s", s, avroKind(value))
+	case *avro.RecordSchema:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		record := make(map[string]interface{}, len(s.Fields()))
+		for _, field := range s.Fields() {
+			key := field.Name()
+			if k, ok := field.Prop(avroKeyAttribute).(string); ok {
+				key = k
+			}
+			v, ok := m[key]
+			if !ok && field.HasDefault() {
+				// The encoder writes the default of absent fields
+				continue
+			}
+			var err error
+			if record[field.Name()], err = avroValue(field.Type(), v, strict); err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		return record, nil
+	case *avro.ArraySchema:
+		list, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		items := make([]interface{}, len(list))
+		for i, e := range list {
+			var err error
+			if items[i], err = avroValue(s.Items(), e, strict); err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+		}
+		return items, nil
+	case *avro.MapSchema:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		values := make(map[string]interface{}, len(m))
+		for key, e := range m {
+			var err error
+			if values[key], err = avroValue(s.Values(), e, strict); err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		return values, nil
+	case *avro.EnumSchema:
+		if symbol, ok := value.(string); ok && slices.Contains(s.Symbols(), symbol) {
+			return symbol, nil
+		}
+	case *avro.FixedSchema:
+		if b, ok := value.([]byte); ok && len(b) == s.Size() {
+			return b, nil
+		}
+	case *avro.NullSchema:
+		if value == nil {
+			return nil, nil
+		}
+	case *avro.PrimitiveSchema:
+		if v, ok := avroPrimitive(s.Type(), value, strict); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%s does not hold %s", schema.Type(), avroKind(value))
+}
+
+// avroPrimitive converts a value to a primitive Avro type
+func avroPrimitive(t avro.Type, value interface{}, strict bool) (interface{}, bool) {
+	switch t {
+	case avro.String:
+		if s, ok := value.(string); ok {
+			return s, true
+		}
+		if strict || value == nil {
+			return nil, false
+		}
+		data, err := json.Marshal(value)
+		return string(data), err == nil
+	case avro.Boolean:
+		b, ok := value.(bool)
+		return b, ok
+	case avro.Bytes:
+		b, ok := value.([]byte)
+		return b, ok
+	case avro.Int, avro.Long:
+		var i int64
+		switch v := value.(type) {
+		case int:
+			i = int64(v)
+		case int64:
+			i = v
+		case float64:
+			if v != math.Trunc(v) || math.Abs(v) >= 1<<63 {
+				return nil, false
+			}
+			i = int64(v)
+		default:
+			return nil, false
+		}
+		if t == avro.Long {
+			return i, true
+		}
+		return int32(i), i >= math.MinInt32 && i <= math.MaxInt32
+	case avro.Float, avro.Double:
+		var f float64
+		switch v := value.(type) {
+		case int:
+			f = float64(v)
+		case int64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return nil, false
+		}
+		if t == avro.Float {
+			return float32(f), true
+		}
+		return f, true
+	}
+	return nil, false
+}
+
+// avroTypeName returns the name a union value is keyed by for a type of the union
+func avroTypeName(schema avro.Schema) string {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+	if named, ok := schema.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	name := string(schema.Type())
+	if logical, ok := schema.(avro.LogicalTypeSchema); ok && logical.Logical() != nil {
+		name += "." + string(logical.Logical().Type())
+	}
+	return name
+}
+
+// avroKind describes the kind of a value in errors
+func avroKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", value), "main.")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// readAvro decodes the records of an Avro container file. The decoder keys the values of
+// unions by type, except for primitive types.
+func readAvro(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	dec, err := ocf.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]interface{}
+	for dec.HasNext() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if err := dec.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestAvroSinkInferred(t *testing.T) {
+	var buf bytes.Buffer
+	schemaOut := filepath.Join(t.TempDir(), "schema.avsc")
+	s, err := newAvroSink(&buf, nil, schemaOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, output := range []Output{
+		{{"name": "Ada"}, {"age": 36}, {"geo": map[string]interface{}{"lat": 51.5}}, {"tags": []interface{}{"a", nil}}, {"@id": "x"}},
+		{{"name": "Grace"}, {"age": 85.5}, {"geo_lat": json.RawMessage(`7`)}, {"@id": 3}, {"none": nil}},
+	} {
+		if err := s.Write(output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readAvro(t, buf.Bytes())
+	want := []map[string]interface{}{
+		{"_id": "x", "age": 36.0, "geo": map[string]interface{}{"Record_geo": map[string]interface{}{"lat": 51.5}}, "geo_lat": nil, "name": "Ada", "none": nil, "tags": map[string]interface{}{"array": []interface{}{"a", nil}}},
+		{"_id": "3", "age": 85.5, "geo": nil, "geo_lat": int64(7), "name": "Grace", "none": nil, "tags": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %#v\nwant %#v", got, want)
+	}
+
+	data, err := os.ReadFile(schemaOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := avro.ParseBytesWithCache(data, "", &avro.SchemaCache{})
+	if err != nil {
+		t.Fatalf("parsing the written schema: %v", err)
+	}
+	if !strings.Contains(string(data), `"key": "@id"`) {
+		t.Errorf("schema lacks the key of @id:\n%s", data)
+	}
+	if names := len(schema.(*avro.RecordSchema).Fields()); names != 7 {
+		t.Errorf("schema has %d fields, want 7", names)
+	}
+}
+
+func TestAvroSinkSchema(t *testing.T) {
+	schema, err := avro.ParseWithCache(`{"type": "record", "name": "Order", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["open", "paid"]}},
+		{"name": "total", "type": ["null", "long", "string"]},
+		{"name": "item_count", "type": "long", "key": "item-count", "default": 0},
+		{"name": "customer", "type": ["null", {"type": "record", "name": "Customer", "fields": [{"name": "email", "type": "string"}]}]}
+	]}`, "", &avro.SchemaCache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s, err := newAvroSink(&buf, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, output := range []Output{
+		{{"id": 1}, {"status": "paid"}, {"total": 12}, {"item-count": 3}, {"customer": map[string]interface{}{"email": "ada@example.com", "name": "Ada"}}},
+		{{"id": 2}, {"status": "open"}, {"total": "n/a"}, {"customer": nil}, {"extra": true}},
+	} {
+		if err := s.Write(output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, output := range []Output{
+		{{"id": 3}, {"status": "lost"}},
+		{{"id": 1 << 40}, {"status": "open"}},
+		{{"id": 4}, {"status": "open"}, {"customer": map[string]interface{}{}}},
+	} {
+		if err := s.Write(output); err == nil {
+			t.Errorf("Write(%v) succeeded, want error", output)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readAvro(t, buf.Bytes())
+	want := []map[string]interface{}{
+		{"id": 1, "status": "paid", "total": int64(12), "item_count": int64(3), "customer": map[string]interface{}{"Customer": map[string]interface{}{"email": "ada@example.com"}}},
+		{"id": 2, "status": "open", "total": "n/a", "item_count": int64(0), "customer": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %#v\nwant %#v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"time"
+)
+
+// commitManifest describes a batch committed as a whole by a transactional sink
+type commitManifest struct {
+	CommitID    string   `json:"commit_id"`
+	Table       string   `json:"table,omitempty"`
+	Objects     []string `json:"objects,omitempty"`
+	Records     int      `json:"records"`
+	SHA256      string   `json:"sha256"`
+	CommittedAt string   `json:"committed_at"`
+}
+
+// batchDigest counts the records of a batch and hashes their canonical JSON in order
+type batchDigest struct {
+	records int
+	hash    hash.Hash
+}
+
+// newBatchDigest starts the digest of an empty batch
+func newBatchDigest() *batchDigest {
+	return &batchDigest{hash: sha256.New()}
+}
+
+// add adds a record to the digest
+func (d *batchDigest) add(record map[string]interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	d.hash.Write(data)
+	d.hash.Write([]byte{'\n'})
+	d.records++
+	return nil
+}
+
+// manifest returns the manifest of the batch committed under the commit ID
+func (d *batchDigest) manifest(commitID string) commitManifest {
+	return commitManifest{
+		CommitID:    commitID,
+		Records:     d.records,
+		SHA256:      hex.EncodeToString(d.hash.Sum(nil)),
+		CommittedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// commitSequence numbers the commits of this process
+var commitSequence int
+
+// newCommitID returns an ID for a commit that sorts after the earlier commits of the process
+// and is unique among those of concurrent processes
+func newCommitID() string {
+	commitSequence++
+	return fmt.Sprintf("%s-%d-%06d", time.Now().UTC().Format("20060102T150405Z"), os.Getpid(), commitSequence)
+}
+
+// parseBatchSize parses the batch query parameter of a sink URI, where 0 disables batching
+func parseBatchSize(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid batch size %q", v)
+	}
+	return n, nil
+}
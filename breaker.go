@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// errOutputSkipped is returned by writes a circuit breaker skipped, so that the records are
+// not taken as delivered
+var errOutputSkipped = errors.New("output skipped while the sink is unavailable")
+
+// circuitBreakers publishes the state of the circuit breakers of a server by name in
+// /debug/vars
+var circuitBreakers = expvar.NewMap("circuit_breakers")
+
+// Breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// breaker is a circuit breaker for an output. It opens when the share of failures among the
+// last writes reaches a threshold, and once open lets a single probe write through after a
+// cooldown: the breaker closes if the probe succeeds and opens again if it fails.
+type breaker struct {
+	// mu guards the breaker, whose state is read by /debug/vars while writes go through
+	mu        sync.Mutex
+	threshold float64
+	cooldown  time.Duration
+	now       func() time.Time
+	// results are the outcomes of the last writes, true for failures, as a ring
+	results  []bool
+	next     int
+	failures int
+	state    string
+	openedAt time.Time
+	stats    breakerStats
+}
+
+// breakerStats are the counters of a breaker reported in the run manifest
+type breakerStats struct {
+	State string `json:"state"`
+	// Opened counts the times the breaker opened
+	Opened int `json:"opened"`
+	// Failures counts the writes that failed
+	Failures int `json:"failures"`
+	// Skipped counts the records not written, as their write failed or the breaker was open
+	Skipped int `json:"skipped"`
+}
+
+// newBreaker returns a breaker opening when threshold of the last window writes fail
+func newBreaker(threshold float64, window int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now, results: make([]bool, window), state: breakerClosed}
+}
+
+// publish publishes the state of the breaker under a name in /debug/vars
+func (b *breaker) publish(name string) {
+	circuitBreakers.Set(name, expvar.Func(func() any { return b.report() }))
+}
+
+// allow reports whether a write may go through, moving an open breaker whose cooldown has
+// passed to half-open for a probe
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// A probe is in flight
+		return false
+	}
+	return true
+}
+
+// done records the outcome of a write allowed through, returning the state of the breaker
+// and whether the outcome changed it
+func (b *breaker) done(err error) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.state
+	b.record(err)
+	return b.state, b.state != state
+}
+
+// skip counts records not written
+func (b *breaker) skip(records int) {
+	b.mu.Lock()
+	b.stats.Skipped += records
+	b.mu.Unlock()
+}
+
+// record records the outcome of a write, opening or closing the breaker
+func (b *breaker) record(err error) {
+	if err != nil {
+		b.stats.Failures++
+	}
+	if b.state == breakerHalfOpen {
+		if err != nil {
+			b.open()
+			return
+		}
+		b.state = breakerClosed
+		b.failures, b.next = 0, 0
+		clear(b.results)
+		return
+	}
+
+	if b.results[b.next] {
+		b.failures--
+	}
+	b.results[b.next] = err != nil
+	if err != nil {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.results)
+	if float64(b.failures) >= b.threshold*float64(len(b.results)) {
+		b.open()
+	}
+}
+
+// open opens the breaker
+func (b *breaker) open() {
+	b.state = breakerOpen
+	b.openedAt = b.now()
+	b.stats.Opened++
+}
+
+// report returns the counters of the breaker
+func (b *breaker) report() breakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.State = b.state
+	return stats
+}
+
+// breakerSink writes to a sink through a circuit breaker, so that an unavailable output
+// does not end the run: records whose write fails, or that arrive while the breaker is
+// open, are skipped with a warning and errOutputSkipped
+type breakerSink struct {
+	next    sink
+	breaker *breaker
+	uri     string
+}
+
+// StartMember forwards to the wrapped sink
+func (s *breakerSink) StartMember(name string) error {
+	if ms, ok := s.next.(memberSink); ok {
+		return ms.StartMember(name)
+	}
+	return nil
+}
+
+// Write writes the output unless the breaker is open
+func (s *breakerSink) Write(output Output) error {
+	return s.call(1, func() error { return s.next.Write(output) })
+}
+
+// Flush flushes the wrapped sink unless the breaker is open. Batching sinks keep the
+// outputs they failed to flush for the next flush.
+func (s *breakerSink) Flush() error {
+	if f, ok := s.next.(flushSink); ok {
+		return s.call(0, f.Flush)
+	}
+	return nil
+}
+
+// call makes a call to the wrapped sink writing a number of records through the breaker,
+// counting them as skipped when the call fails or the breaker is open
+func (s *breakerSink) call(records int, fn func() error) error {
+	if !s.breaker.allow() {
+		s.breaker.skip(records)
+		return errOutputSkipped
+	}
+	err := fn()
+	if state, changed := s.breaker.done(err); changed {
+		fmt.Fprintf(os.Stderr, "Circuit breaker of %s is %s\n", redactURI(s.uri), state)
+	}
+	if err != nil {
+		s.breaker.skip(records)
+		warn("sink-unavailable", "Skipping output to %s: %v", redactURI(s.uri), err)
+		return errOutputSkipped
+	}
+	return nil
+}
+
+// Close closes the wrapped sink
+func (s *breakerSink) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakySink fails its writes while down
+type flakySink struct {
+	down    bool
+	written int
+	calls   int
+}
+
+func (s *flakySink) Write(Output) error {
+	s.calls++
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.written++
+	return nil
+}
+
+func (s *flakySink) Close() error { return nil }
+
+func TestBreakerSink(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker(0.5, 4, time.Minute)
+	b.now = func() time.Time { return now }
+	next := &flakySink{}
+	s := &breakerSink{next: next, breaker: b, uri: "redis://cache/0"}
+	write := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := s.Write(Output{{"id": i}}); err != nil && !errors.Is(err, errOutputSkipped) {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	write(3)
+	next.down = true
+	// Two failures among the last four writes open the breaker
+	write(2)
+	if b.state != breakerOpen || next.calls != 5 {
+		t.Fatalf("state %s after %d calls, want open after 5", b.state, next.calls)
+	}
+	write(10)
+	if next.calls != 5 {
+		t.Errorf("an open breaker let %d writes through", next.calls-5)
+	}
+
+	// After the cooldown a failed probe opens the breaker again, and a successful one closes it
+	now = now.Add(time.Minute)
+	write(2)
+	if b.state != breakerOpen || next.calls != 6 {
+		t.Errorf("state %s after %d calls, want open after a failed probe", b.state, next.calls)
+	}
+	now = now.Add(time.Minute)
+	next.down = false
+	write(2)
+	if b.state != breakerClosed || next.written != 5 {
+		t.Errorf("state %s with %d written, want closed with 5", b.state, next.written)
+	}
+
+	want := breakerStats{State: breakerClosed, Opened: 2, Failures: 3, Skipped: 14}
+	if got := b.report(); got != want {
+		t.Errorf("report = %+v, want %+v", got, want)
+	}
+}
+
+func TestBreakerSinkReportsSkipped(t *testing.T) {
+	next := &flakySink{down: true}
+	s := &breakerSink{next: next, breaker: newBreaker(1, 2, time.Minute), uri: "redis://cache/0"}
+	// Both a failed write and one the open breaker turns away are skipped
+	for i := 0; i < 3; i++ {
+		if err := s.Write(Output{{"id": i}}); !errors.Is(err, errOutputSkipped) {
+			t.Errorf("write %d = %v, want errOutputSkipped", i, err)
+		}
+	}
+	if next.calls != 2 {
+		t.Errorf("%d writes went through, want 2 before the breaker opened", next.calls)
+	}
+	next.down = false
+	s.breaker.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if err := s.Write(Output{{"id": 3}}); err != nil {
+		t.Errorf("probe write = %v, want nil", err)
+	}
+}
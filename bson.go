@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBSONDocumentSize bounds the size of a single document read from the stream
+const maxBSONDocumentSize = 64 * 1024 * 1024
+
+// readBSON reads a stream of concatenated BSON documents, as written by mongodump, into records
+func readBSON(r io.Reader) ([]Input, error) {
+	var records []Input
+
+	for {
+		// Each document starts with its total length, including the length itself
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		size := int(int32(binary.LittleEndian.Uint32(header[:])))
+		if size < 5 || size > maxBSONDocumentSize {
+			return nil, fmt.Errorf("invalid BSON document size %d", size)
+		}
+		doc := make([]byte, size)
+		copy(doc, header[:])
+		if _, err := io.ReadFull(r, doc[4:]); err != nil {
+			return nil, err
+		}
+
+		m, err := decodeBSONDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", len(records)+1, err)
+		}
+		records = append(records, Input(m))
+	}
+
+	return records, nil
+}
+
+// decodeBSONDocument decodes a complete BSON document into a map. Timestamps become
+// RFC3339 strings so the standard coercion converts them to epoch, ObjectIDs become hex
+// strings, and numbers and binary data become strings like the rest of the input.
+func decodeBSONDocument(doc []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	err := walkBSONDocument(doc, func(name string, value interface{}) {
+		m[name] = value
+	})
+	return m, err
+}
+
+// decodeBSONArray decodes a BSON array, which is a document keyed by element index
+func decodeBSONArray(doc []byte) ([]interface{}, error) {
+	var l []interface{}
+	err := walkBSONDocument(doc, func(_ string, value interface{}) {
+		l = append(l, value)
+	})
+	return l, err
+}
+
+// walkBSONDocument decodes each element of a BSON document and passes it to fn,
+// skipping null, undefined and min/max key values
+func walkBSONDocument(doc []byte, fn func(name string, value interface{})) error {
+	if len(doc) < 5 || int(binary.LittleEndian.Uint32(doc)) != len(doc) || doc[len(doc)-1] != 0 {
+		return errors.New("malformed document")
+	}
+
+	b := doc[4 : len(doc)-1]
+	for len(b) > 0 {
+		kind := b[0]
+		name, rest, err := readBSONCString(b[1:])
+		if err != nil {
+			return err
+		}
+		value, n, err := decodeBSONValue(kind, rest)
+		if err != nil {
+			return fmt.Errorf("field %q: %v", name, err)
+		}
+		if value != nil {
+			fn(name, value)
+		}
+		b = rest[n:]
+	}
+	return nil
+}
+
+// decodeBSONValue decodes a value of the given element type, returning it and the number of
+// bytes consumed; a nil value means the element carries no data
+func decodeBSONValue(kind byte, b []byte) (interface{}, int, error) {
+	switch kind {
+	case 0x01: // double
+		if len(b) < 8 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		return strconv.FormatFloat(f, 'f', -1, 64), 8, nil
+	case 0x02, 0x0D, 0x0E: // string, JavaScript code, symbol
+		s, n, err := readBSONString(b)
+		return s, n, err
+	case 0x03, 0x04: // embedded document, array
+		if len(b) < 4 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		size := int(int32(binary.LittleEndian.Uint32(b)))
+		if size < 5 || size > len(b) {
+			return nil, 0, errors.New("invalid embedded document size")
+		}
+		if kind == 0x04 {
+			l, err := decodeBSONArray(b[:size])
+			return l, size, err
+		}
+		m, err := decodeBSONDocument(b[:size])
+		return m, size, err
+	case 0x05: // binary
+		if len(b) < 5 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		size := int(int32(binary.LittleEndian.Uint32(b)))
+		if size < 0 || 5+size > len(b) {
+			return nil, 0, errors.New("invalid binary size")
+		}
+		return base64.StdEncoding.EncodeToString(b[5 : 5+size]), 5 + size, nil
+	case 0x06, 0x0A, 0x7F, 0xFF: // undefined, null, max key, min key
+		return nil, 0, nil
+	case 0x07: // ObjectID
+		if len(b) < 12 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return hex.EncodeToString(b[:12]), 12, nil
+	case 0x08: // boolean
+		if len(b) < 1 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return strconv.FormatBool(b[0] != 0), 1, nil
+	case 0x09: // UTC datetime in milliseconds
+		if len(b) < 8 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		ms := int64(binary.LittleEndian.Uint64(b))
+		return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano), 8, nil
+	case 0x0B: // regular expression: pattern and options cstrings
+		pattern, rest, err := readBSONCString(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		options, rest, err := readBSONCString(rest)
+		if err != nil {
+			return nil, 0, err
+		}
+		return "/" + pattern + "/" + options, len(b) - len(rest), nil
+	case 0x10: // int32
+		if len(b) < 4 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(b))), 10), 4, nil
+	case 0x11: // timestamp: increment in the low word, seconds in the high word
+		if len(b) < 8 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		seconds := binary.LittleEndian.Uint32(b[4:])
+		return time.Unix(int64(seconds), 0).UTC().Format(time.RFC3339), 8, nil
+	case 0x12: // int64
+		if len(b) < 8 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(b)), 10), 8, nil
+	case 0x13: // decimal128
+		if len(b) < 16 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return formatDecimal128(binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b)), 16, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported BSON type 0x%02x", kind)
+}
+
+// readBSONCString reads a NUL terminated string, returning it and the remaining bytes
+func readBSONCString(b []byte) (string, []byte, error) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), b[i+1:], nil
+		}
+	}
+	return "", nil, errors.New("unterminated cstring")
+}
+
+// readBSONString reads a length prefixed, NUL terminated string
+func readBSONString(b []byte) (string, int, error) {
+	if len(b) < 4 {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	size := int(int32(binary.LittleEndian.Uint32(b)))
+	if size < 1 || 4+size > len(b) || b[4+size-1] != 0 {
+		return "", 0, errors.New("invalid string size")
+	}
+	return string(b[4 : 4+size-1]), 4 + size, nil
+}
+
+// formatDecimal128 formats an IEEE 754-2008 decimal128 value, given as its high and low
+// 64-bit words, as a decimal string
+func formatDecimal128(high, low uint64) string {
+	sign := ""
+	if high>>63 == 1 {
+		sign = "-"
+	}
+
+	// Special values are flagged in the combination field
+	switch {
+	case high&0x7C00000000000000 == 0x7C00000000000000:
+		return "NaN"
+	case high&0x7800000000000000 == 0x7800000000000000:
+		return sign + "Infinity"
+	}
+
+	var exponent int
+	coefficient := new(big.Int)
+	if (high>>61)&3 == 3 {
+		// The coefficient would exceed 113 bits, which is non-canonical and treated as zero
+		exponent = int((high>>47)&0x3FFF) - 6176
+	} else {
+		exponent = int((high>>49)&0x3FFF) - 6176
+		coefficient.SetUint64(high & 0x1FFFFFFFFFFFF)
+		coefficient.Lsh(coefficient, 64)
+		coefficient.Or(coefficient, new(big.Int).SetUint64(low))
+	}
+
+	// Place the decimal point according to the exponent
+	digits := coefficient.String()
+	switch {
+	case coefficient.Sign() == 0 && exponent >= 0:
+		return sign + "0"
+	case exponent >= 0:
+		return sign + digits + strings.Repeat("0", exponent)
+	case -exponent < len(digits):
+		point := len(digits) + exponent
+		return sign + digits[:point] + "." + digits[point:]
+	default:
+		return sign + "0." + strings.Repeat("0", -exponent-len(digits)) + digits
+	}
+}
+
+// encodeBSON encodes the output as a BSON document with keys in order, one per record, so
+// that a stream of outputs can be loaded with mongorestore. Timestamps, which the
+// transformer emits as int64 epoch seconds, become UTC datetimes, ints become int32 or int64
+// and floats doubles. ObjectIDs were decoded into hex strings, so an "_id" holding 24 hex
+// digits becomes an ObjectID again.
+func encodeBSON(output Output) ([]byte, error) {
+	return appendBSONDocument(nil, mergeOutput(output), true)
+}
+
+// appendBSONDocument appends the BSON document of a map, with ObjectID _id fields when id
+func appendBSONDocument(b []byte, m map[string]interface{}, id bool) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for _, k := range keys {
+		if strings.IndexByte(k, 0) >= 0 {
+			return nil, fmt.Errorf("key %q holds a NUL byte", k)
+		}
+		var err error
+		if b, err = appendBSONElement(b, k, m[k], id && k == "_id"); err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b, nil
+}
+
+// appendBSONElement appends an element of a document, with a 24 hex digit string as an
+// ObjectID when id
+func appendBSONElement(b []byte, name string, value interface{}, id bool) ([]byte, error) {
+	element := func(kind byte) []byte {
+		b = append(b, kind)
+		b = append(b, name...)
+		return append(b, 0)
+	}
+	switch v := value.(type) {
+	case nil:
+		return element(0x0A), nil
+	case string:
+		if oid, err := hex.DecodeString(v); id && err == nil && len(oid) == 12 {
+			return append(element(0x07), oid...), nil
+		}
+		b = element(0x02)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(v)+1))
+		b = append(b, v...)
+		return append(b, 0), nil
+	case bool:
+		if v {
+			return append(element(0x08), 1), nil
+		}
+		return append(element(0x08), 0), nil
+	case int:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return binary.LittleEndian.AppendUint32(element(0x10), uint32(int32(v))), nil
+		}
+		return binary.LittleEndian.AppendUint64(element(0x12), uint64(v)), nil
+	case int64:
+		return binary.LittleEndian.AppendUint64(element(0x09), uint64(v*1000)), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(element(0x01), math.Float64bits(v)), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return appendBSONElement(b, name, int(i), false)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendBSONElement(b, name, f, false)
+	case []byte:
+		b = binary.LittleEndian.AppendUint32(element(0x05), uint32(len(v)))
+		b = append(b, 0x00)
+		return append(b, v...), nil
+	case json.RawMessage:
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		var decoded interface{}
+		if err := dec.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		return appendBSONElement(b, name, decoded, id)
+	case map[string]interface{}:
+		return appendBSONDocument(element(0x03), v, false)
+	case []interface{}:
+		return appendBSONArray(element(0x04), v)
+	}
+	return nil, fmt.Errorf("unsupported type %T", value)
+}
+
+// appendBSONArray appends the BSON document of a list, keyed by element index
+func appendBSONArray(b []byte, l []interface{}) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for i, item := range l {
+		var err error
+		if b, err = appendBSONElement(b, strconv.Itoa(i), item, false); err != nil {
+			return nil, fmt.Errorf("[%d]: %v", i, err)
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b, nil
+}
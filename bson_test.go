@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// bsonDoc builds a BSON document from raw elements, each a type byte, cstring name and value
+func bsonDoc(elements ...[]byte) []byte {
+	body := bytes.Join(elements, nil)
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)+1))
+	doc = append(doc, body...)
+	return append(doc, 0)
+}
+
+// bsonElement builds a BSON element of the given type
+func bsonElement(kind byte, name string, value []byte) []byte {
+	e := append([]byte{kind}, name...)
+	e = append(e, 0)
+	return append(e, value...)
+}
+
+// bsonStringValue encodes a length prefixed BSON string
+func bsonStringValue(s string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1))
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+func TestReadBSON(t *testing.T) {
+	oid := []byte{0x50, 0x7f, 0x1f, 0x77, 0xbc, 0xf8, 0x6c, 0xd7, 0x99, 0x43, 0x90, 0x11}
+	first := bsonDoc(
+		bsonElement(0x07, "_id", oid),
+		bsonElement(0x02, "name", bsonStringValue("ada")),
+		bsonElement(0x10, "age", binary.LittleEndian.AppendUint32(nil, uint32(36))),
+		bsonElement(0x12, "big", binary.LittleEndian.AppendUint64(nil, uint64(1)<<40)),
+		bsonElement(0x01, "score", binary.LittleEndian.AppendUint64(nil, 0x3FF8000000000000)),
+		bsonElement(0x08, "ok", []byte{1}),
+		bsonElement(0x09, "at", binary.LittleEndian.AppendUint64(nil, uint64(1609459200123))),
+		bsonElement(0x0A, "none", nil),
+		bsonElement(0x03, "sub", bsonDoc(bsonElement(0x02, "k", bsonStringValue("v")))),
+		bsonElement(0x04, "list", bsonDoc(
+			bsonElement(0x02, "0", bsonStringValue("a")),
+			bsonElement(0x10, "1", binary.LittleEndian.AppendUint32(nil, uint32(0xFFFFFFFF))),
+		)),
+		bsonElement(0x05, "bin", append(binary.LittleEndian.AppendUint32(nil, 3), 0x00, 'a', 'b', 'c')),
+		bsonElement(0x0B, "re", []byte("^a\x00i\x00")),
+	)
+	second := bsonDoc(bsonElement(0x02, "name", bsonStringValue("grace")))
+
+	records, err := readBSON(bytes.NewReader(append(first, second...)))
+	if err != nil {
+		t.Fatalf("readBSON error: %v", err)
+	}
+	want := []Input{
+		{
+			"_id":   "507f1f77bcf86cd799439011",
+			"name":  "ada",
+			"age":   "36",
+			"big":   "1099511627776",
+			"score": "1.5",
+			"ok":    "true",
+			"at":    "2021-01-01T00:00:00.123Z",
+			"sub":   map[string]interface{}{"k": "v"},
+			"list":  []interface{}{"a", "-1"},
+			"bin":   "YWJj",
+			"re":    "/^a/i",
+		},
+		{"name": "grace"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readBSON = %#v, want %#v", records, want)
+	}
+}
+
+func TestReadBSONErrors(t *testing.T) {
+	valid := bsonDoc(bsonElement(0x02, "name", bsonStringValue("ada")))
+	tests := map[string][]byte{
+		"truncated document": valid[:len(valid)-2],
+		"short size":         {4, 0, 0, 0},
+		"unterminated name":  bsonDoc([]byte{0x02, 'n', 'a'}),
+		"bad string size":    bsonDoc(bsonElement(0x02, "s", []byte{0xFF, 0, 0, 0, 'x', 0})),
+		"unsupported type":   bsonDoc(bsonElement(0x20, "x", nil)),
+	}
+	for name, data := range tests {
+		if _, err := readBSON(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: readBSON succeeded, want error", name)
+		}
+	}
+}
+
+func TestFormatDecimal128(t *testing.T) {
+	const positive = 0x3040000000000000 // exponent 0
+	tests := []struct {
+		high, low uint64
+		want      string
+	}{
+		{positive, 1, "1"},
+		{positive, 0, "0"},
+		{positive - 3<<49, 1, "0.001"},
+		{positive - 2<<49 | 1<<63, 1234567, "-12345.67"},
+		{positive + 3<<49, 12, "12000"},
+		{positive - 5<<49, 0, "0.00000"},
+		{positive - 1<<49, 1 << 63, "922337203685477580.8"},
+		{positive | 1, 0, "18446744073709551616"},
+		{0x7800000000000000, 0, "Infinity"},
+		{0xF800000000000000, 0, "-Infinity"},
+		{0x7C00000000000000, 0, "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatDecimal128(tt.high, tt.low); got != tt.want {
+			t.Errorf("formatDecimal128(%#x, %#x) = %q, want %q", tt.high, tt.low, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeBSON(t *testing.T) {
+	output := Output{
+		{"_id": "507f1f77bcf86cd799439011", "name": "ada", "at": int64(1609459200)},
+		{"age": 36, "big": 1 << 40, "score": 1.5, "ok": true, "none": nil, "n": json.Number("7")},
+		{"sub": map[string]interface{}{"_id": "507f1f77bcf86cd799439011"}, "list": []interface{}{"a", 2}, "bin": []byte("abc")},
+	}
+	data, err := encodeOutput(output, "bson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := messageBody(data, "bson"); !bytes.Equal(body, data) {
+		t.Error("messageBody altered the BSON document")
+	}
+
+	// A document decodes back to the values it was encoded from, with only the top-level
+	// _id as an ObjectID
+	records, err := readBSON(bytes.NewReader(append(data, data...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Input{
+		"_id":   "507f1f77bcf86cd799439011",
+		"name":  "ada",
+		"at":    "2021-01-01T00:00:00Z",
+		"age":   "36",
+		"big":   "1099511627776",
+		"score": "1.5",
+		"ok":    "true",
+		"n":     "7",
+		"sub":   map[string]interface{}{"_id": "507f1f77bcf86cd799439011"},
+		"list":  []interface{}{"a", "2"},
+		"bin":   "YWJj",
+	}
+	if len(records) != 2 || !reflect.DeepEqual(records[0], want) {
+		t.Errorf("decoded %#v, want two of %#v", records, want)
+	}
+	if !bytes.Contains(data, []byte("\x07_id\x00\x50\x7f")) || !bytes.Contains(data, []byte("\x02_id\x00")) {
+		t.Error("_id fields were not encoded as an ObjectID at the top level and a string below")
+	}
+
+	if _, err := encodeBSON(Output{{"c": make(chan int)}}); err == nil {
+		t.Error("encodeBSON of a channel succeeded, want error")
+	}
+}
+
+func TestChangeStreamPreset(t *testing.T) {
+	ns := map[string]interface{}{"db": "shop", "coll": "orders"}
+	key := map[string]interface{}{"_id": "1"}
+	events := []Input{
+		{"operationType": "insert", "ns": ns, "clusterTime": "2021-01-01T00:00:00Z", "documentKey": key, "fullDocument": map[string]interface{}{"_id": "1", "total": "9"}},
+		{"operationType": "update", "ns": ns, "documentKey": key, "updateDescription": map[string]interface{}{"updatedFields": map[string]interface{}{"total": "10"}}},
+		{"operationType": "delete", "ns": ns, "documentKey": key},
+		{"operationType": "drop", "ns": ns},
+		{"_id": "2", "total": "3"},
+	}
+	got, err := applyPreset("changestream", events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{
+		{"_id": "1", "total": "9", "__op__": "insert", "__ns__": "shop.orders", "__cluster_time__": "2021-01-01T00:00:00Z"},
+		{"_id": "1", "total": "10", "__op__": "update", "__ns__": "shop.orders"},
+		{"_id": "1", "__op__": "delete", "__ns__": "shop.orders"},
+		{"_id": "2", "total": "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changestream = %v, want %v", got, want)
+	}
+}
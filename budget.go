@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// nodeMemory is the memory estimated for each node of a request's document: the decoded
+// value, its map or list entry and its transformed copy
+const nodeMemory = 200
+
+// errBudgetBusy is returned when a request waited too long for memory to be released by
+// the requests before it
+var errBudgetBusy = errors.New("server memory budget is in use")
+
+// memoryBudget bounds the memory estimated for the requests a server transforms at once.
+// Requests wait in turn for their estimate to fit, for up to wait. A nil memoryBudget
+// admits every request.
+type memoryBudget struct {
+	sem   *semaphore.Weighted
+	limit int64
+	wait  time.Duration
+}
+
+// newMemoryBudget returns a budget of limit bytes, or nil for a limit of 0
+func newMemoryBudget(limit int64, wait time.Duration) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryBudget{sem: semaphore.NewWeighted(limit), limit: limit, wait: wait}
+}
+
+// requestMemory estimates the memory of transforming a document of size bytes decoded into
+// a value
+func requestMemory(size int, value interface{}) int64 {
+	return int64(size) + int64(countNodes(value))*nodeMemory
+}
+
+// countNodes counts the values in a decoded value, itself included
+func countNodes(value interface{}) int {
+	n := 1
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, e := range v {
+			n += countNodes(e)
+		}
+	case Input:
+		for _, e := range v {
+			n += countNodes(e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			n += countNodes(e)
+		}
+	}
+	return n
+}
+
+// reservation is the memory a request holds in a budget
+type reservation struct {
+	b      *memoryBudget
+	memory int64
+}
+
+// reserve waits for a request's memory to fit in the budget, returning its reservation.
+// Requests larger than the whole budget fail at once; others fail with errBudgetBusy when
+// they wait longer than the budget's wait.
+func (b *memoryBudget) reserve(ctx context.Context, memory int64) (*reservation, error) {
+	r := &reservation{b: b}
+	if err := r.resize(ctx, memory); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// resize changes the memory of a reservation once it is better known, waiting for more to
+// fit as reserve does or releasing what is no longer needed. The reservation is unchanged
+// when it fails.
+func (r *reservation) resize(ctx context.Context, memory int64) error {
+	b := r.b
+	if b == nil {
+		return nil
+	}
+	if memory > b.limit {
+		return fmt.Errorf("request needs an estimated %d bytes, more than the memory budget of %d", memory, b.limit)
+	}
+	if memory < r.memory {
+		b.sem.Release(r.memory - memory)
+	} else if memory > r.memory && !b.sem.TryAcquire(memory-r.memory) {
+		ctx, cancel := context.WithTimeout(ctx, b.wait)
+		defer cancel()
+		if err := b.sem.Acquire(ctx, memory-r.memory); err != nil {
+			return errBudgetBusy
+		}
+	}
+	r.memory = memory
+	return nil
+}
+
+// release returns the memory of a reservation to the budget
+func (r *reservation) release() {
+	if r.b != nil && r.memory > 0 {
+		r.b.sem.Release(r.memory)
+		r.memory = 0
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	record := Input{"a": "x", "b": []interface{}{"y", map[string]interface{}{"c": "z"}}}
+	if n := countNodes(record); n != 6 {
+		t.Errorf("countNodes = %d, want 6", n)
+	}
+	if m := requestMemory(30, record); m != 30+6*nodeMemory {
+		t.Errorf("requestMemory = %d", m)
+	}
+
+	b := newMemoryBudget(1000, 10*time.Millisecond)
+	reserved, err := b.reserve(context.Background(), 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.reserve(context.Background(), 600); !errors.Is(err, errBudgetBusy) {
+		t.Errorf("reserving past the budget = %v, want errBudgetBusy", err)
+	}
+	reserved.release()
+	if reserved, err := b.reserve(context.Background(), 600); err != nil {
+		t.Errorf("reserving released memory = %v", err)
+	} else {
+		reserved.release()
+	}
+
+	// Reservations grow while memory is free and shrink to release it
+	reserved, err = b.reserve(context.Background(), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reserved.resize(context.Background(), 900); err != nil {
+		t.Errorf("growing a reservation = %v", err)
+	}
+	if _, err := b.reserve(context.Background(), 200); !errors.Is(err, errBudgetBusy) {
+		t.Errorf("reserving past a grown reservation = %v, want errBudgetBusy", err)
+	}
+	if err := reserved.resize(context.Background(), 1001); err == nil || reserved.memory != 900 {
+		t.Errorf("growing a reservation past the budget = %v with %d bytes held, want an error and 900", err, reserved.memory)
+	}
+	if err := reserved.resize(context.Background(), 500); err != nil {
+		t.Errorf("shrinking a reservation = %v", err)
+	}
+	if other, err := b.reserve(context.Background(), 500); err != nil {
+		t.Errorf("reserving memory a reservation released = %v", err)
+	} else {
+		other.release()
+	}
+	reserved.release()
+	if _, err := b.reserve(context.Background(), 1001); err == nil || errors.Is(err, errBudgetBusy) {
+		t.Errorf("reserving more than the budget = %v, want an error at once", err)
+	}
+
+	// A nil budget admits everything
+	if _, err := newMemoryBudget(0, 0).reserve(context.Background(), 1<<40); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransformHandlerMemoryBudget(t *testing.T) {
+	budget := newMemoryBudget(10*nodeMemory, 10*time.Millisecond)
+	server := httptest.NewServer(newTransformHandler(&transform.Transformer{}, "", 1<<10, newAdmission(4, 16), budget))
+	defer server.Close()
+	post := func(body string) *http.Response {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := post(`{"a": "1"}`); resp.StatusCode != 200 {
+		t.Errorf("small request = %d, want 200", resp.StatusCode)
+	}
+	if resp := post(`{"a": ["1", "2", "3", "4", "5", "6", "7", "8", "9", "10"]}`); resp.StatusCode != 413 {
+		t.Errorf("request larger than the budget = %d, want 413", resp.StatusCode)
+	}
+
+	// Requests wait for the memory held by others
+	reserved, err := budget.reserve(context.Background(), 9*nodeMemory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := post(`{"a": "1"}`); resp.StatusCode != 503 || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("request over a busy budget = %d, Retry-After %q, want 503", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// Bodies wait for the memory of their Content-Length before they are read
+	if err := reserved.resize(context.Background(), 10*nodeMemory); err != nil {
+		t.Fatal(err)
+	}
+	if resp := post(`{"a": "1"}`); resp.StatusCode != 503 {
+		t.Errorf("request over a full budget = %d, want 503", resp.StatusCode)
+	}
+	reserved.release()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborDecMode decodes CBOR times as RFC3339 strings, so that the timestamp coercion
+// applies to them as to JSON strings, and the tags it does not know as their content
+var cborDecMode, _ = cbor.DecOptions{
+	TimeTagToAny:         cbor.TimeTagToRFC3339Nano,
+	UnrecognizedTagToAny: cbor.UnrecognizedTagContentToAny,
+}.DecMode()
+
+// cborEncMode encodes in the deterministic encoding of RFC 8949, with sorted keys and the
+// shortest floats
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// readCBOR reads a sequence of CBOR data items, each a map decoded into a record. Numbers
+// and booleans become strings like the rest of the input, byte strings standard base64
+// strings and bignums decimal strings
+func readCBOR(r io.Reader) ([]Input, error) {
+	var records []Input
+	dec := cborDecMode.NewDecoder(r)
+	for i := 1; ; i++ {
+		var item interface{}
+		if err := dec.Decode(&item); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, fmt.Errorf("CBOR data item %d: %v", i, err)
+		}
+		record, ok := cborToJSON(item, true).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("CBOR data item %d is not a map", i)
+		}
+		records = append(records, record)
+	}
+}
+
+// cborToJSON converts a decoded CBOR value into the value JSON decodes the same data into,
+// with numbers and booleans as strings when scalarsAsStrings is set
+func cborToJSON(v interface{}, scalarsAsStrings bool) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		// Keys other than text strings are written as text
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = cborToJSON(e, scalarsAsStrings)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = cborToJSON(e, scalarsAsStrings)
+		}
+		return v
+	case uint64:
+		if scalarsAsStrings {
+			return strconv.FormatUint(v, 10)
+		}
+		return float64(v)
+	case int64:
+		if scalarsAsStrings {
+			return strconv.FormatInt(v, 10)
+		}
+		return float64(v)
+	case big.Int:
+		return v.String()
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			// JSON has no infinities or NaN
+			return fmt.Sprint(v)
+		}
+		if scalarsAsStrings {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return v
+	case bool:
+		if scalarsAsStrings {
+			return strconv.FormatBool(v)
+		}
+		return v
+	}
+	return v
+}
+
+// encodeCBOR encodes the output of a record as a CBOR array of maps, the same structure as
+// its JSON, so that the encodings of successive records form a CBOR sequence. The values of
+// B and BS descriptors become byte strings with -raw-binary.
+func encodeCBOR(output Output) ([]byte, error) {
+	value, err := binaryValue(output)
+	if err != nil {
+		return nil, err
+	}
+	return cborEncMode.Marshal(value)
+}
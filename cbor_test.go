@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestReadCBOR(t *testing.T) {
+	// Times are written as tag 0 RFC3339 strings
+	em, err := cbor.EncOptions{Time: cbor.TimeRFC3339, TimeTag: cbor.EncTagRequired}.EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream []byte
+	for _, item := range []interface{}{
+		map[interface{}]interface{}{
+			"name":    " ada ",
+			"n":       uint64(7),
+			"neg":     int64(-2),
+			"ratio":   1.5,
+			"created": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			"blob":    []byte{0, 1},
+			"big":     new(big.Int).Lsh(big.NewInt(1), 70),
+			"tags":    []interface{}{"a", cbor.Tag{Number: 32, Content: "https://example.com"}, uint64(3)},
+			uint64(1): true,
+		},
+		map[string]interface{}{"id": "2"},
+	} {
+		data, err := em.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, data...)
+	}
+
+	records, err := readInput(bytes.NewReader(stream), inputOptions{format: "cbor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{
+		{
+			"name":    " ada ",
+			"n":       "7",
+			"neg":     "-2",
+			"ratio":   "1.5",
+			"created": "2024-01-01T00:00:00Z",
+			"blob":    "AAE=",
+			"big":     "1180591620717411303424",
+			"tags":    []interface{}{"a", "https://example.com", "3"},
+			"1":       "true",
+		},
+		{"id": "2"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %#v, want %#v", records, want)
+	}
+
+	if _, err := readInput(bytes.NewReader([]byte{0x83, 1}), inputOptions{format: "cbor"}); err == nil {
+		t.Error("reading a truncated array succeeded")
+	}
+	if _, err := readInput(bytes.NewReader([]byte{0x01}), inputOptions{format: "cbor"}); err == nil {
+		t.Error("reading an integer as a record succeeded")
+	}
+}
+
+func TestEncodeCBOR(t *testing.T) {
+	output := Output{
+		{"created": int64(1704067200), "price": 2.5},
+		{"raw": json.RawMessage(`{"x": [1, null]}`), "blob": []byte{0, 1}},
+	}
+	data, err := encodeOutput(output, "cbor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[interface{}]interface{}{"created": uint64(1704067200), "price": 2.5},
+		map[interface{}]interface{}{"raw": map[interface{}]interface{}{"x": []interface{}{uint64(1), nil}}, "blob": []byte{0, 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %#v, want %#v", got, want)
+	}
+	if body := messageBody(data, "cbor"); !bytes.Equal(body, data) {
+		t.Errorf("messageBody = %x, want %x", body, data)
+	}
+}
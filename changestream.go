@@ -0,0 +1,44 @@
+package main
+
+// changeStreamPreset unwraps MongoDB change stream events into the documents they change.
+// Inserts, replaces and updates opened with fullDocument: "updateLookup" hold the whole
+// document; other updates become the document key with the updated fields, and deletes the
+// document key alone. Records that are not events, such as documents of a dump, pass through.
+func changeStreamPreset(record Input) []Input {
+	op, ok := record["operationType"].(string)
+	if !ok {
+		return []Input{record}
+	}
+
+	out := make(Input)
+	if doc, ok := record["fullDocument"].(map[string]interface{}); ok {
+		for k, v := range doc {
+			out[k] = v
+		}
+	} else if key, ok := record["documentKey"].(map[string]interface{}); ok {
+		for k, v := range key {
+			out[k] = v
+		}
+		update, _ := record["updateDescription"].(map[string]interface{})
+		fields, _ := update["updatedFields"].(map[string]interface{})
+		for k, v := range fields {
+			out[k] = v
+		}
+	} else {
+		// Drops, renames and invalidations change no document
+		warn("unsupported-event", "Skipping %s change stream event without a document", op)
+		return nil
+	}
+
+	// Event metadata uses reserved names that cannot clash with document fields
+	out["__op__"] = op
+	if ns, ok := record["ns"].(map[string]interface{}); ok {
+		db, _ := ns["db"].(string)
+		coll, _ := ns["coll"].(string)
+		out["__ns__"] = db + "." + coll
+	}
+	if t, ok := record["clusterTime"]; ok {
+		out["__cluster_time__"] = t
+	}
+	return []Input{out}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hiddenFlags are the flags left out of the usage message, as they are meant for testing
+var hiddenFlags = map[string]bool{}
+
+// errChaos is the error of the faults injected by -chaos flags
+var errChaos = errors.New("chaos: injected fault")
+
+// chaos injects faults into a run, as set by the hidden -chaos flags, so that users can
+// check how their dead-letter queues, retries and checkpoints cope with failures before
+// a production incident does. Faults are drawn from a seeded source, so that a run with the
+// same seed and input fails the same way. A nil chaos injects nothing.
+type chaos struct {
+	decodeFailure float64
+	sinkError     float64
+	latency       time.Duration
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// activeChaos is the fault injector of the current run, if any -chaos flag is set
+var activeChaos *chaos
+
+// chaosFlags registers the hidden -chaos flags, returning a function that builds the
+// fault injector once the flags are parsed, or nil if none injects faults
+func chaosFlags() func() (*chaos, error) {
+	seed := flag.Uint64("chaos-seed", 1, "seed of the faults injected by the -chaos flags")
+	decodeFailure := flag.Float64("chaos-decode-failure", 0, "share of input documents and stream messages that fail to decode, such as 0.1")
+	sinkError := flag.Float64("chaos-sink-error", 0, "share of writes and flushes to the output that fail")
+	latency := flag.Duration("chaos-latency", 0, "longest random delay added to each write to the output")
+	for _, name := range []string{"chaos-seed", "chaos-decode-failure", "chaos-sink-error", "chaos-latency"} {
+		hiddenFlags[name] = true
+	}
+
+	return func() (*chaos, error) {
+		if *decodeFailure < 0 || *decodeFailure > 1 || *sinkError < 0 || *sinkError > 1 || *latency < 0 {
+			return nil, fmt.Errorf("-chaos-decode-failure and -chaos-sink-error must be between 0 and 1, and -chaos-latency not negative")
+		}
+		if *decodeFailure == 0 && *sinkError == 0 && *latency == 0 {
+			return nil, nil
+		}
+		return &chaos{
+			decodeFailure: *decodeFailure,
+			sinkError:     *sinkError,
+			latency:       *latency,
+			rand:          rand.New(rand.NewPCG(*seed, 0)),
+		}, nil
+	}
+}
+
+// String describes the faults injected
+func (c *chaos) String() string {
+	var faults []string
+	if c.decodeFailure > 0 {
+		faults = append(faults, fmt.Sprintf("%g of decodes failing", c.decodeFailure))
+	}
+	if c.sinkError > 0 {
+		faults = append(faults, fmt.Sprintf("%g of output writes failing", c.sinkError))
+	}
+	if c.latency > 0 {
+		faults = append(faults, fmt.Sprintf("output writes delayed up to %s", c.latency))
+	}
+	return strings.Join(faults, ", ")
+}
+
+// fail reports whether a fault of a probability strikes
+func (c *chaos) fail(probability float64) bool {
+	if c == nil || probability == 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < probability
+}
+
+// decodeError returns an error for the input documents that fail to decode
+func (c *chaos) decodeError() error {
+	if c != nil && c.fail(c.decodeFailure) {
+		return fmt.Errorf("%w: decode failure", errChaos)
+	}
+	return nil
+}
+
+// delay returns the random delay of a write to the output
+func (c *chaos) delay() time.Duration {
+	if c == nil || c.latency == 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rand.Int64N(int64(c.latency) + 1))
+}
+
+// chaosSink delays writes to a sink and fails some of them
+type chaosSink struct {
+	next  sink
+	chaos *chaos
+}
+
+// StartMember forwards to the wrapped sink
+func (s *chaosSink) StartMember(name string) error {
+	if ms, ok := s.next.(memberSink); ok {
+		return ms.StartMember(name)
+	}
+	return nil
+}
+
+// Write writes the output after a delay, unless an injected error strikes
+func (s *chaosSink) Write(output Output) error {
+	time.Sleep(s.chaos.delay())
+	if s.chaos.fail(s.chaos.sinkError) {
+		return fmt.Errorf("%w: sink error", errChaos)
+	}
+	return s.next.Write(output)
+}
+
+// Flush flushes the wrapped sink, unless an injected error strikes
+func (s *chaosSink) Flush() error {
+	f, ok := s.next.(flushSink)
+	if !ok {
+		return nil
+	}
+	if s.chaos.fail(s.chaos.sinkError) {
+		return fmt.Errorf("%w: sink error", errChaos)
+	}
+	return f.Flush()
+}
+
+// Close closes the wrapped sink
+func (s *chaosSink) Close() error {
+	return s.next.Close()
+}
+
+// usage prints the usage message of the command line without its hidden flags
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestChaosSink(t *testing.T) {
+	run := func(seed uint64) []bool {
+		c := &chaos{sinkError: 0.3, latency: time.Millisecond, rand: rand.New(rand.NewPCG(seed, 0))}
+		s := &chaosSink{next: &flakySink{}, chaos: c}
+		var failed []bool
+		for i := 0; i < 100; i++ {
+			err := s.Write(Output{{"id": i}})
+			if err != nil && !errors.Is(err, errChaos) {
+				t.Fatalf("Write = %v, want an injected fault", err)
+			}
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first := run(7)
+	failures := 0
+	for _, failed := range first {
+		if failed {
+			failures++
+		}
+	}
+	if failures < 15 || failures > 45 {
+		t.Errorf("%d of 100 writes failed, want about 30", failures)
+	}
+	// The same seed fails the same writes
+	again := run(7)
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("write %d failed differently with the same seed", i)
+		}
+	}
+}
+
+func TestChaosDecodeError(t *testing.T) {
+	var none *chaos
+	if err := none.decodeError(); err != nil || none.delay() != 0 {
+		t.Errorf("nil chaos injected a fault: %v", err)
+	}
+	always := &chaos{decodeFailure: 1, rand: rand.New(rand.NewPCG(1, 0))}
+	if err := always.decodeError(); !errors.Is(err, errChaos) {
+		t.Errorf("decodeError = %v, want an injected fault", err)
+	}
+}
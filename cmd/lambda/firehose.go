@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// firehoseResponse is the response of a Kinesis Data Firehose transformation function. It
+// leaves out the metadata of events.KinesisFirehoseResponse, whose empty partition and
+// table settings Firehose would reject.
+type firehoseResponse struct {
+	Records []firehoseRecord `json:"records"`
+}
+
+// firehoseRecord is the transformation result of a Firehose record
+type firehoseRecord struct {
+	RecordID string `json:"recordId"`
+	Result   string `json:"result"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// handleFirehose transforms the records of a Firehose data-transformation event. Each
+// record's data, which Firehose base64-encodes, is a JSON record and becomes the lines of
+// compact JSON of the records it transforms to. Blank records and records that transform
+// to nothing are Dropped, records that fail to decode or transform ProcessingFailed, so
+// that Firehose sends them to its error output.
+func (h *handler) handleFirehose(event events.KinesisFirehoseEvent) firehoseResponse {
+	resp := firehoseResponse{Records: make([]firehoseRecord, len(event.Records))}
+	for i, record := range event.Records {
+		resp.Records[i] = firehoseRecord{RecordID: record.RecordID, Result: events.KinesisFirehoseTransformedStateDropped}
+		if len(bytes.TrimSpace(record.Data)) == 0 {
+			continue
+		}
+		outputs, err := h.transform(record.Data)
+		if err != nil {
+			log.Printf("Failed record %s: %v", record.RecordID, err)
+			resp.Records[i].Result = events.KinesisFirehoseTransformedStateProcessingFailed
+			continue
+		}
+		var data []byte
+		for _, output := range outputs {
+			if len(output) == 0 {
+				continue
+			}
+			line, err := json.Marshal(output)
+			if err != nil {
+				log.Printf("Failed record %s: %v", record.RecordID, err)
+				data = nil
+				resp.Records[i].Result = events.KinesisFirehoseTransformedStateProcessingFailed
+				break
+			}
+			data = append(append(data, line...), '\n')
+		}
+		if data != nil {
+			resp.Records[i].Result = events.KinesisFirehoseTransformedStateOk
+			resp.Records[i].Data = data
+		}
+	}
+	return resp
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHandleFirehose(t *testing.T) {
+	h, err := newHandler("", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	event := `{"invocationId": "i", "deliveryStreamArn": "arn:aws:firehose:eu-west-1:1:deliverystream/s", "region": "eu-west-1", "records": [
+		{"recordId": "1", "data": "` + data(`{"name": " a ", "n": {"N": "1"}}`) + `"},
+		{"recordId": "2", "data": "` + data(`not json`) + `"},
+		{"recordId": "3", "data": "` + data(`{"n": 1}`) + `"},
+		{"recordId": "4", "data": "` + data(` `) + `"},
+		{"recordId": "5", "data": "` + data(`{}`) + `"}
+	]}`
+	got, err := h.handle(context.Background(), json.RawMessage(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := firehoseResponse{Records: []firehoseRecord{
+		{RecordID: "1", Result: "Ok", Data: []byte(`[{"n":1},{"name":"a"}]` + "\n")},
+		{RecordID: "2", Result: "ProcessingFailed"},
+		{RecordID: "3", Result: "ProcessingFailed"},
+		{RecordID: "4", Result: "Dropped"},
+		{RecordID: "5", Result: "Dropped"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handle = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// handler transforms the records of Lambda events
+type handler struct {
+	tr transform.Transformer
+}
+
+// newHandler configures a handler with a rules file, a coercion order and strict mode
+func newHandler(rulesPath, coerce string, strict bool) (*handler, error) {
+	h := &handler{tr: transform.Transformer{Strict: strict, OnWarning: func(w transform.Warning) {
+		// Lambda sends stderr to CloudWatch Logs
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w.Message)
+	}}}
+	var err error
+	if coerce != "" {
+		if h.tr.Coercions, err = transform.ParseCoercionOrder(coerce); err != nil {
+			return nil, err
+		}
+	}
+	if rulesPath != "" {
+		if h.tr.Rules, err = transform.LoadRules(rulesPath); err != nil {
+			return nil, fmt.Errorf("loading rules: %v", err)
+		}
+	}
+	return h, nil
+}
+
+// handle transforms an event. API Gateway proxy events, of REST and HTTP APIs alike, carry
+// the record as their body and get a proxy response with the JSON array of the records it
+// transforms to, or an {"error": ...} body. Kinesis Data Firehose events get a result per
+// record, as handled by handleFirehose. Any other event is itself the record, and the
+// array is returned as it is.
+func (h *handler) handle(ctx context.Context, event json.RawMessage) (interface{}, error) {
+	var firehose events.KinesisFirehoseEvent
+	if err := json.Unmarshal(event, &firehose); err == nil && firehose.DeliveryStreamArn != "" {
+		return h.handleFirehose(firehose), nil
+	}
+
+	var proxy struct {
+		RequestContext  json.RawMessage `json:"requestContext"`
+		Body            string          `json:"body"`
+		IsBase64Encoded bool            `json:"isBase64Encoded"`
+	}
+	if err := json.Unmarshal(event, &proxy); err == nil && proxy.RequestContext != nil {
+		body := []byte(proxy.Body)
+		if proxy.IsBase64Encoded {
+			var err error
+			if body, err = base64.StdEncoding.DecodeString(proxy.Body); err != nil {
+				return errorResponse(http.StatusBadRequest, fmt.Errorf("decoding body: %v", err)), nil
+			}
+		}
+		outputs, err := h.transform(body)
+		var badRecord *recordError
+		if errors.As(err, &badRecord) {
+			return errorResponse(http.StatusBadRequest, err), nil
+		} else if err != nil {
+			return errorResponse(http.StatusInternalServerError, err), nil
+		}
+		data, err := json.Marshal(outputs)
+		if err != nil {
+			return errorResponse(http.StatusInternalServerError, err), nil
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       string(data),
+		}, nil
+	}
+	return h.transform(event)
+}
+
+// recordError reports a record the handler cannot transform
+type recordError struct {
+	err error
+}
+
+// Error returns the reason the record cannot be transformed
+func (e *recordError) Error() string {
+	return e.err.Error()
+}
+
+// transform decodes a JSON record and transforms it into the records to return
+func (h *handler) transform(data []byte) ([]transform.Output, error) {
+	var record transform.Input
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, &recordError{fmt.Errorf("decoding record: %v", err)}
+	}
+	if record == nil {
+		return nil, &recordError{errors.New("record is not a JSON object")}
+	}
+	outputs, err := h.tr.TransformRecord(record)
+	var strictErr *transform.StrictError
+	if errors.As(err, &strictErr) {
+		return nil, &recordError{err}
+	}
+	return outputs, err
+}
+
+// errorResponse returns an API Gateway proxy response with an {"error": ...} body
+func errorResponse(status int, err error) events.APIGatewayProxyResponse {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(data),
+	}
+}
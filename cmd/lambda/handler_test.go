@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestHandle(t *testing.T) {
+	h, err := newHandler("", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Direct invocations return the records
+	got, err := h.handle(ctx, json.RawMessage(`{"name": " a ", "n": {"N": "1"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []transform.Output{{{"n": 1}, {"name": "a"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handle = %#v, want %#v", got, want)
+	}
+	if _, err := h.handle(ctx, json.RawMessage(`{"n": 1}`)); err == nil {
+		t.Error("handle of a record with problems succeeded in strict mode")
+	}
+
+	// API Gateway events get proxy responses
+	tests := []struct {
+		event  string
+		status int
+		body   string
+	}{
+		{`{"requestContext": {}, "body": "{\"name\": \" a \"}"}`, 200, `[[{"name":"a"}]]`},
+		{`{"requestContext": {}, "body": "eyJuYW1lIjogImIifQ==", "isBase64Encoded": true}`, 200, `[[{"name":"b"}]]`},
+		{`{"requestContext": {}, "body": "{\"n\": 1}"}`, 400, ``},
+		{`{"requestContext": {}, "body": "[1]"}`, 400, ``},
+		{`{"requestContext": {}, "body": "%%", "isBase64Encoded": true}`, 400, ``},
+	}
+	for _, tt := range tests {
+		got, err := h.handle(ctx, json.RawMessage(tt.event))
+		if err != nil {
+			t.Errorf("handle(%s) error: %v", tt.event, err)
+			continue
+		}
+		resp, ok := got.(events.APIGatewayProxyResponse)
+		if !ok || resp.StatusCode != tt.status || (tt.body != "" && resp.Body != tt.body) {
+			t.Errorf("handle(%s) = %+v, want %d %s", tt.event, got, tt.status, tt.body)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Command lambda runs the transformer as an AWS Lambda function on the provided.al2023
+// runtime, invoked directly, behind API Gateway or as the data-transformation function of
+// a Kinesis Data Firehose stream. Build the bootstrap binary with
+//
+//	GOOS=linux GOARCH=arm64 go build -tags lambda.norpc -o bootstrap ./cmd/lambda
+//
+// The function is configured by environment variables: TRANSFORM_RULES names a rules file
+// deployed with the function, TRANSFORM_COERCE sets the coercion order and
+// TRANSFORM_STRICT=true rejects records with problems.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	h, err := newHandler(os.Getenv("TRANSFORM_RULES"), os.Getenv("TRANSFORM_COERCE"), os.Getenv("TRANSFORM_STRICT") == "true")
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	lambda.Start(h.handle)
+}
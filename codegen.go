@@ -0,0 +1,556 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// timeFieldPattern matches the names of integer fields that hold epoch timestamps, with a
+// case-sensitive camelCase "At" suffix so that names like "lat" or "format" do not match
+var timeFieldPattern = regexp.MustCompile(`(?i:time|date|timestamp|_at|_on|created|updated|modified|expires)$|[a-z]At$`)
+
+// goInitialisms lists the words written in upper case in Go identifiers
+var goInitialisms = map[string]bool{
+	"api": true, "cpu": true, "css": true, "dns": true, "html": true, "http": true, "https": true,
+	"id": true, "ip": true, "json": true, "sql": true, "ttl": true, "uid": true, "uri": true,
+	"url": true, "utc": true, "uuid": true, "xml": true,
+}
+
+// schemaNode is the schema inferred for a value: its kind is one of "string", "int",
+// "float", "bool", "bytes", "time", "array", "object" or "any", and empty for values only
+// ever null
+type schemaNode struct {
+	kind   string
+	elem   *schemaNode
+	fields map[string]*schemaNode
+	// seen counts the objects a field appeared in and samples the objects observed
+	seen    int
+	samples int
+}
+
+// optional reports whether a field is missing from some of the objects holding it
+func (n *schemaNode) optional(parent *schemaNode) bool {
+	return n.seen < parent.samples
+}
+
+// sortedFields returns the field names of an object schema in order
+func (n *schemaNode) sortedFields() []string {
+	names := make([]string, 0, len(n.fields))
+	for name := range n.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// codegenGenerators maps the codegen languages to their generators
+var codegenGenerators = map[string]func(w io.Writer, schema *schemaNode, typeName string, opts codegenOptions) error{
+	"go":         generateGo,
+	"sql":        generateSQL,
+	"typescript": generateTypeScript,
+}
+
+// codegenOptions holds the language specific codegen flags
+type codegenOptions struct {
+	pkg     string
+	zod     bool
+	dialect string
+	table   string
+}
+
+// runCodegenCommand runs "codegen", which infers a schema from sample transformed output
+// read from files or stdin and prints type definitions for it
+func runCodegenCommand(args []string) {
+	fs := flag.NewFlagSet("codegen", flag.ExitOnError)
+	lang := fs.String("lang", "go", "language to generate: "+strings.Join(codegenLanguages(), ", "))
+	typeName := fs.String("type", "Record", "name of the generated record type")
+	var opts codegenOptions
+	fs.StringVar(&opts.pkg, "package", "main", "Go package name")
+	fs.StringVar(&opts.dialect, "dialect", "postgres", "SQL dialect: "+strings.Join(sqlDialectNames(), ", "))
+	fs.StringVar(&opts.table, "table", "records", "SQL table name")
+	fs.BoolVar(&opts.zod, "zod", false, "TypeScript: generate zod schemas and infer the types from them")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s codegen [flags] [sample output files]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	generate, ok := codegenGenerators[*lang]
+	if !ok {
+		log.Fatalf("error generating code: unsupported language %q", *lang)
+	}
+
+	schema := &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}
+	if fs.NArg() == 0 {
+		if err := inferSchema(schema, os.Stdin); err != nil {
+			log.Fatalf("error reading samples: %v", err)
+		}
+	}
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatalf("error reading samples: %v", err)
+		}
+		err = inferSchema(schema, f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("error reading samples from %s: %v", name, err)
+		}
+	}
+	if schema.samples == 0 {
+		log.Fatalf("error generating code: no sample records")
+	}
+	resolveTimeFields(schema)
+
+	if err := generate(os.Stdout, schema, *typeName, opts); err != nil {
+		log.Fatalf("error generating code: %v", err)
+	}
+}
+
+// codegenLanguages returns the supported codegen languages in order
+func codegenLanguages() []string {
+	var names []string
+	for name := range codegenGenerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inferSchema merges the records of a stream of transformed outputs into schema. Outputs
+// may be pretty-printed arrays or NDJSON lines, as written to stdout by the json and ndjson
+// formats; warnings go to stderr and are not part of the stream.
+func inferSchema(schema *schemaNode, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var output []map[string]interface{}
+		if err := dec.Decode(&output); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		mergeObjectSchema(schema, mergeOutput(output))
+	}
+}
+
+// mergeObjectSchema merges an object into an object schema
+func mergeObjectSchema(n *schemaNode, m map[string]interface{}) {
+	n.samples++
+	for k, v := range m {
+		field, ok := n.fields[k]
+		if !ok {
+			field = &schemaNode{}
+			n.fields[k] = field
+		}
+		field.seen++
+		mergeValueSchema(field, v)
+	}
+}
+
+// mergeValueSchema merges a value into a schema, widening ints to floats and conflicting
+// kinds to "any". Values may be decoded JSON or the Go values of a transformed output.
+func mergeValueSchema(n *schemaNode, v interface{}) {
+	var kind string
+	switch v := v.(type) {
+	case string:
+		kind = "string"
+	case bool:
+		kind = "bool"
+	case json.Number:
+		kind = "int"
+		if _, err := v.Int64(); err != nil {
+			kind = "float"
+		}
+	case int, int64:
+		kind = "int"
+	case float64:
+		kind = "float"
+	case []byte:
+		kind = "bytes"
+	case []interface{}:
+		kind = "array"
+	case map[string]interface{}:
+		kind = "object"
+	case nil:
+		return
+	}
+
+	switch {
+	case n.kind == "":
+		n.kind = kind
+	case n.kind == kind:
+	case n.kind == "int" && kind == "float", n.kind == "float" && kind == "int":
+		n.kind = "float"
+	default:
+		n.kind = "any"
+		return
+	}
+
+	switch v := v.(type) {
+	case []interface{}:
+		if n.elem == nil {
+			n.elem = &schemaNode{}
+		}
+		for _, item := range v {
+			mergeValueSchema(n.elem, item)
+		}
+	case map[string]interface{}:
+		if n.fields == nil {
+			n.fields = make(map[string]*schemaNode)
+		}
+		mergeObjectSchema(n, v)
+	}
+}
+
+// resolveTimeFields marks integer fields with timestamp-like names as epoch times
+func resolveTimeFields(n *schemaNode) {
+	for name, field := range n.fields {
+		if field.kind == "int" && timeFieldPattern.MatchString(name) {
+			field.kind = "time"
+		}
+		resolveTimeFields(field)
+	}
+	if n.elem != nil {
+		resolveTimeFields(n.elem)
+	}
+}
+
+// exportedName converts a field name into an exported identifier like "CreatedAt" or
+// "UserID", splitting words at punctuation and camelCase boundaries
+func exportedName(name string) string {
+	var words []string
+	var word []rune
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			words, word = append(words, string(word)), nil
+			continue
+		case unicode.IsUpper(r) && len(word) > 0 && unicode.IsLower(word[len(word)-1]):
+			words, word = append(words, string(word)), nil
+		}
+		word = append(word, r)
+	}
+	words = append(words, string(word))
+
+	var b strings.Builder
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		if goInitialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		b.WriteRune(unicode.ToUpper(r[0]))
+		b.WriteString(string(r[1:]))
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "X" + s
+	}
+	return s
+}
+
+// uniqueNames assigns each field of an object schema a distinct identifier
+func uniqueNames(n *schemaNode, convert func(string) string) map[string]string {
+	names := make(map[string]string, len(n.fields))
+	used := make(map[string]bool, len(n.fields))
+	for _, field := range n.sortedFields() {
+		name := convert(field)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", convert(field), i)
+		}
+		used[name] = true
+		names[field] = name
+	}
+	return names
+}
+
+// generateGo writes Go struct definitions with json tags for the schema. Epoch fields use
+// an Epoch type wrapping time.Time that reads and writes epoch seconds.
+func generateGo(w io.Writer, schema *schemaNode, typeName string, opts codegenOptions) error {
+	var types []string
+	usesTime := false
+	var goType func(n *schemaNode, name string) string
+	goStruct := func(n *schemaNode, name string) {
+		var b strings.Builder
+		if n == schema {
+			fmt.Fprintf(&b, "// %s is a transformed record\n", name)
+		} else {
+			fmt.Fprintf(&b, "// %s is an object nested in a transformed record\n", name)
+		}
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		names := uniqueNames(n, exportedName)
+		for _, field := range n.sortedFields() {
+			f := n.fields[field]
+			t := goType(f, name+names[field])
+			tag := field
+			if f.optional(n) {
+				tag += ",omitempty"
+				if f.kind != "array" && f.kind != "any" {
+					t = "*" + t
+				}
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:%q`\n", names[field], t, tag)
+		}
+		b.WriteString("}\n")
+		types = append(types, b.String())
+	}
+	goType = func(n *schemaNode, name string) string {
+		switch n.kind {
+		case "string":
+			return "string"
+		case "int":
+			return "int64"
+		case "float":
+			return "float64"
+		case "bool":
+			return "bool"
+		case "time":
+			usesTime = true
+			return "Epoch"
+		case "array":
+			if n.elem == nil || n.elem.kind == "" {
+				return "[]interface{}"
+			}
+			return "[]" + goType(n.elem, name+"Item")
+		case "object":
+			goStruct(n, name)
+			return name
+		}
+		return "interface{}"
+	}
+	goStruct(schema, typeName)
+
+	// Nested types are generated first, so print the record type first
+	for i, j := 0, len(types)-1; i < j; i, j = i+1, j-1 {
+		types[i], types[j] = types[j], types[i]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by transform codegen. DO NOT EDIT.\n\npackage %s\n", opts.pkg)
+	if usesTime {
+		b.WriteString(goEpochType)
+	}
+	for _, t := range types {
+		fmt.Fprintf(&b, "\n%s", t)
+	}
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// goEpochType is the generated Go type for epoch timestamp fields
+const goEpochType = `
+import (
+	"strconv"
+	"time"
+)
+
+// Epoch is a time.Time encoded as epoch seconds
+type Epoch struct {
+	time.Time
+}
+
+// MarshalJSON encodes the time as epoch seconds
+func (e Epoch) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, e.Unix(), 10), nil
+}
+
+// UnmarshalJSON decodes epoch seconds
+func (e *Epoch) UnmarshalJSON(data []byte) error {
+	sec, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	e.Time = time.Unix(sec, 0).UTC()
+	return nil
+}
+`
+
+// tsIdentifierPattern matches property names that need no quoting in TypeScript
+var tsIdentifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsProperty returns a field name as a TypeScript property name, quoting it if needed
+func tsProperty(name string) string {
+	if tsIdentifierPattern.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// generateTypeScript writes TypeScript interfaces for the schema, or zod schemas with types
+// inferred from them. Epoch fields are numbers of seconds.
+func generateTypeScript(w io.Writer, schema *schemaNode, typeName string, opts codegenOptions) error {
+	var decls []string
+	var tsType func(n *schemaNode, name string) string
+	tsObject := func(n *schemaNode, name string) {
+		var b strings.Builder
+		if opts.zod {
+			fmt.Fprintf(&b, "export const %sSchema = z.object({\n", name)
+		} else {
+			fmt.Fprintf(&b, "export interface %s {\n", name)
+		}
+		names := uniqueNames(n, exportedName)
+		for _, field := range n.sortedFields() {
+			f := n.fields[field]
+			t := tsType(f, name+names[field])
+			if f.kind == "time" {
+				b.WriteString("  /** Epoch seconds */\n")
+			}
+			switch {
+			case opts.zod && f.optional(n):
+				fmt.Fprintf(&b, "  %s: %s.optional(),\n", tsProperty(field), t)
+			case opts.zod:
+				fmt.Fprintf(&b, "  %s: %s,\n", tsProperty(field), t)
+			case f.optional(n):
+				fmt.Fprintf(&b, "  %s?: %s;\n", tsProperty(field), t)
+			default:
+				fmt.Fprintf(&b, "  %s: %s;\n", tsProperty(field), t)
+			}
+		}
+		if opts.zod {
+			fmt.Fprintf(&b, "});\n\nexport type %s = z.infer<typeof %sSchema>;\n", name, name)
+		} else {
+			b.WriteString("}\n")
+		}
+		decls = append(decls, b.String())
+	}
+	tsType = func(n *schemaNode, name string) string {
+		switch n.kind {
+		case "string":
+			if opts.zod {
+				return "z.string()"
+			}
+			return "string"
+		case "int", "time":
+			if opts.zod {
+				return "z.number().int()"
+			}
+			return "number"
+		case "float":
+			if opts.zod {
+				return "z.number()"
+			}
+			return "number"
+		case "bool":
+			if opts.zod {
+				return "z.boolean()"
+			}
+			return "boolean"
+		case "array":
+			elem := "unknown"
+			if opts.zod {
+				elem = "z.unknown()"
+			}
+			if n.elem != nil && n.elem.kind != "" {
+				elem = tsType(n.elem, name+"Item")
+			}
+			if opts.zod {
+				return "z.array(" + elem + ")"
+			}
+			return elem + "[]"
+		case "object":
+			tsObject(n, name)
+			if opts.zod {
+				return name + "Schema"
+			}
+			return name
+		}
+		if opts.zod {
+			return "z.unknown()"
+		}
+		return "unknown"
+	}
+	tsObject(schema, typeName)
+
+	// Nested schemas are generated first, as zod schemas must be declared before use
+	fmt.Fprint(w, "// Code generated by transform codegen. DO NOT EDIT.\n")
+	if opts.zod {
+		fmt.Fprint(w, "\nimport { z } from \"zod\";\n")
+	}
+	for _, d := range decls {
+		fmt.Fprintf(w, "\n%s", d)
+	}
+	return nil
+}
+
+// sqlDialect holds the column types and identifier quoting of a SQL dialect
+type sqlDialect struct {
+	types map[string]string
+	quote func(name string) string
+}
+
+// sqlDialects lists the supported SQL dialects. Lists, objects and mixed values are stored
+// as JSON and epoch fields as integers, matching the output.
+var sqlDialects = map[string]sqlDialect{
+	"postgres": {
+		types: map[string]string{"string": "TEXT", "int": "BIGINT", "time": "BIGINT", "float": "DOUBLE PRECISION", "bool": "BOOLEAN", "json": "JSONB"},
+		quote: quoteIdent,
+	},
+	"mysql": {
+		types: map[string]string{"string": "TEXT", "int": "BIGINT", "time": "BIGINT", "float": "DOUBLE", "bool": "BOOLEAN", "json": "JSON"},
+		quote: func(name string) string { return "`" + strings.ReplaceAll(name, "`", "``") + "`" },
+	},
+	"sqlite": {
+		types: map[string]string{"string": "TEXT", "int": "INTEGER", "time": "INTEGER", "float": "REAL", "bool": "INTEGER", "json": "TEXT"},
+		quote: quoteIdent,
+	},
+}
+
+// sqlDialectNames returns the supported SQL dialects in order
+func sqlDialectNames() []string {
+	var names []string
+	for name := range sqlDialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// generateSQL writes a CREATE TABLE statement with a column per field of the flattened
+// output. Fields present in every sample are NOT NULL.
+func generateSQL(w io.Writer, schema *schemaNode, typeName string, opts codegenOptions) error {
+	dialect, ok := sqlDialects[opts.dialect]
+	if !ok {
+		return fmt.Errorf("unsupported SQL dialect %q", opts.dialect)
+	}
+
+	fmt.Fprintf(w, "-- Code generated by transform codegen. DO NOT EDIT.\n\nCREATE TABLE %s (\n", dialect.quote(opts.table))
+	fields := schema.sortedFields()
+	for i, field := range fields {
+		f := schema.fields[field]
+		t, ok := dialect.types[f.kind]
+		if !ok {
+			t = dialect.types["json"]
+		}
+		column := dialect.quote(field) + " " + t
+		if !f.optional(schema) {
+			column += " NOT NULL"
+		}
+		if i < len(fields)-1 {
+			column += ","
+		}
+		if f.kind == "time" {
+			column += " -- epoch seconds"
+		}
+		fmt.Fprintf(w, "  %s\n", column)
+	}
+	fmt.Fprint(w, ");\n")
+	return nil
+}
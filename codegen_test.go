@@ -0,0 +1,259 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// codegenSamples are transformed outputs as written by the json and ndjson formats
+const codegenSamples = `[
+  {"id": 1},
+  {"name": "a"},
+  {"score": 1},
+  {"tags": ["x"]},
+  {"items": [{"sku": "s1", "qty": 2}]},
+  {"created_at": 1700000000}
+]
+[{"id": 2},{"score": 1.5},{"items": [{"sku": "s2"}]},{"created_at": 1700000001},{"mixed": "a"}]
+[{"id": 3},{"name": null},{"mixed": 1}]
+`
+
+func TestInferSchema(t *testing.T) {
+	schema := &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}
+	if err := inferSchema(schema, strings.NewReader(codegenSamples)); err != nil {
+		t.Fatal(err)
+	}
+	resolveTimeFields(schema)
+	if schema.samples != 3 {
+		t.Errorf("samples = %d, want 3", schema.samples)
+	}
+
+	tests := []struct {
+		field    string
+		kind     string
+		optional bool
+	}{
+		{"id", "int", false},
+		{"name", "string", true},
+		{"score", "float", true},
+		{"tags", "array", true},
+		{"items", "array", true},
+		{"created_at", "time", true},
+		{"mixed", "any", true},
+	}
+	for _, tt := range tests {
+		f := schema.fields[tt.field]
+		if f == nil {
+			t.Errorf("field %q missing from the schema", tt.field)
+			continue
+		}
+		if f.kind != tt.kind || f.optional(schema) != tt.optional {
+			t.Errorf("field %q = %s (optional %v), want %s (optional %v)", tt.field, f.kind, f.optional(schema), tt.kind, tt.optional)
+		}
+	}
+
+	item := schema.fields["items"].elem
+	if item == nil || item.kind != "object" || item.samples != 2 || item.fields["sku"].optional(item) || !item.fields["qty"].optional(item) {
+		t.Errorf("items element = %+v, want objects with a required sku and optional qty", item)
+	}
+
+	if err := inferSchema(schema, strings.NewReader(`{"id": 1}`)); err == nil {
+		t.Error("inferSchema of an object succeeded, want error")
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := map[string]string{
+		"name":       "Name",
+		"created_at": "CreatedAt",
+		"user-name":  "UserName",
+		"a.b":        "AB",
+		"2fa":        "X2fa",
+		"":           "X",
+		"userId":     "UserID",
+		"api_url":    "APIURL",
+		"HTMLBody":   "HTMLBody",
+		"createdAt":  "CreatedAt",
+	}
+	for in, want := range tests {
+		if got := exportedName(in); got != want {
+			t.Errorf("exportedName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTimeFieldPattern(t *testing.T) {
+	tests := map[string]bool{
+		"created_at": true,
+		"createdAt":  true,
+		"UpdatedOn":  false,
+		"updated_on": true,
+		"timestamp":  true,
+		"StartTime":  true,
+		"lat":        false,
+		"format":     false,
+		"heartbeat":  false,
+		"At":         false,
+	}
+	for name, want := range tests {
+		if got := timeFieldPattern.MatchString(name); got != want {
+			t.Errorf("timeFieldPattern.MatchString(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	n := &schemaNode{fields: map[string]*schemaNode{"user_name": {}, "user-name": {}, "id": {}}}
+	got := uniqueNames(n, exportedName)
+	if got["id"] != "ID" || got["user-name"] != "UserName" || got["user_name"] != "UserName2" {
+		t.Errorf("uniqueNames = %v, want ID, UserName and UserName2", got)
+	}
+}
+
+func TestGenerateGo(t *testing.T) {
+	schema := &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}
+	if err := inferSchema(schema, strings.NewReader(codegenSamples)); err != nil {
+		t.Fatal(err)
+	}
+	resolveTimeFields(schema)
+
+	var b strings.Builder
+	if err := generateGo(&b, schema, "Order", codegenOptions{pkg: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	src := b.String()
+	// Struct tags are written with ' for readability
+	for _, want := range []string{
+		"package orders\n",
+		"type Epoch struct",
+		`type Order struct {
+	CreatedAt *Epoch           'json:"created_at,omitempty"'
+	ID        int64            'json:"id"'
+	Items     []OrderItemsItem 'json:"items,omitempty"'
+	Mixed     interface{}      'json:"mixed,omitempty"'
+	Name      *string          'json:"name,omitempty"'
+	Score     *float64         'json:"score,omitempty"'
+	Tags      []string         'json:"tags,omitempty"'
+}`,
+		`type OrderItemsItem struct {
+	Qty *int64 'json:"qty,omitempty"'
+	Sku string 'json:"sku"'
+}`,
+	} {
+		if want = strings.ReplaceAll(want, "'", "`"); !strings.Contains(src, want) {
+			t.Errorf("generated Go is missing\n%s\nin\n%s", want, src)
+		}
+	}
+	if strings.Index(src, "type Order struct") > strings.Index(src, "type OrderItemsItem struct") {
+		t.Errorf("nested types are printed before the record type:\n%s", src)
+	}
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	schema := &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}
+	samples := `[{"id": 1},{"user-name": "a"},{"created_at": 1700000000},{"items": [{"sku": "s1"}]}]
+[{"id": 2},{"user-name": "b"},{"score": 0.5}]
+`
+	if err := inferSchema(schema, strings.NewReader(samples)); err != nil {
+		t.Fatal(err)
+	}
+	resolveTimeFields(schema)
+
+	tests := []struct {
+		zod  bool
+		want string
+	}{
+		{false, `// Code generated by transform codegen. DO NOT EDIT.
+
+export interface RecordItemsItem {
+  sku: string;
+}
+
+export interface Record {
+  /** Epoch seconds */
+  created_at?: number;
+  id: number;
+  items?: RecordItemsItem[];
+  score?: number;
+  "user-name": string;
+}
+`},
+		{true, `// Code generated by transform codegen. DO NOT EDIT.
+
+import { z } from "zod";
+
+export const RecordItemsItemSchema = z.object({
+  sku: z.string(),
+});
+
+export type RecordItemsItem = z.infer<typeof RecordItemsItemSchema>;
+
+export const RecordSchema = z.object({
+  /** Epoch seconds */
+  created_at: z.number().int().optional(),
+  id: z.number().int(),
+  items: z.array(RecordItemsItemSchema).optional(),
+  score: z.number().optional(),
+  "user-name": z.string(),
+});
+
+export type Record = z.infer<typeof RecordSchema>;
+`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := generateTypeScript(&b, schema, "Record", codegenOptions{zod: tt.zod}); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("generateTypeScript(zod %v) =\n%s\nwant\n%s", tt.zod, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateSQL(t *testing.T) {
+	schema := &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}
+	samples := `[{"id": 1},{"created_at": 1700000000},{"tags": ["a"]},{"ok": true}]
+[{"id": 2},{"score": 0.5},{"ok": false}]
+`
+	if err := inferSchema(schema, strings.NewReader(samples)); err != nil {
+		t.Fatal(err)
+	}
+	resolveTimeFields(schema)
+
+	tests := []struct {
+		dialect, want string
+	}{
+		{"postgres", `CREATE TABLE "orders" (
+  "created_at" BIGINT, -- epoch seconds
+  "id" BIGINT NOT NULL,
+  "ok" BOOLEAN NOT NULL,
+  "score" DOUBLE PRECISION,
+  "tags" JSONB
+);
+`},
+		{"mysql", "CREATE TABLE `orders` (\n  `created_at` BIGINT, -- epoch seconds\n  `id` BIGINT NOT NULL,\n  `ok` BOOLEAN NOT NULL,\n  `score` DOUBLE,\n  `tags` JSON\n);\n"},
+		{"sqlite", `CREATE TABLE "orders" (
+  "created_at" INTEGER, -- epoch seconds
+  "id" INTEGER NOT NULL,
+  "ok" INTEGER NOT NULL,
+  "score" REAL,
+  "tags" TEXT
+);
+`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := generateSQL(&b, schema, "Record", codegenOptions{dialect: tt.dialect, table: "orders"}); err != nil {
+			t.Fatal(err)
+		}
+		want := "-- Code generated by transform codegen. DO NOT EDIT.\n\n" + tt.want
+		if got := b.String(); got != want {
+			t.Errorf("generateSQL(%s) =\n%s\nwant\n%s", tt.dialect, got, want)
+		}
+	}
+
+	if err := generateSQL(&strings.Builder{}, schema, "Record", codegenOptions{dialect: "oracle", table: "orders"}); err == nil {
+		t.Error("generateSQL with an unknown dialect succeeded, want error")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// csvDelimiter separates the cells of CSV output, as set by -csv-delimiter
+var csvDelimiter = ','
+
+// parseCSVDelimiter parses the value of -csv-delimiter: a single character, or "\t" or
+// "tab" for tab-separated values
+func parseCSVDelimiter(s string) (rune, error) {
+	switch s {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("invalid CSV delimiter %q (want a single character other than a quote or newline)", s)
+	}
+	return r, nil
+}
+
+// csvSink flattens each output into a row of CSV, with dot-notation column names for the
+// keys of nested objects, as in "address.city". Lists are written as JSON in a single cell
+// and nulls as empty cells. As the header names the columns of every record, the rows are
+// held until the sink is closed, with columns in key order.
+type csvSink struct {
+	w         io.Writer
+	delimiter rune
+	rows      []map[string]string
+	columns   map[string]bool
+}
+
+// newCSVSink returns a sink writing CSV to w
+func newCSVSink(w io.Writer, delimiter rune) *csvSink {
+	return &csvSink{w: w, delimiter: delimiter, columns: make(map[string]bool)}
+}
+
+// Write flattens the output into a row
+func (s *csvSink) Write(output Output) error {
+	row := make(map[string]string)
+	for key, value := range mergeOutput(output) {
+		if err := flattenCSV(key, value, row); err != nil {
+			return fmt.Errorf("flattening %q: %v", key, err)
+		}
+	}
+	for column := range row {
+		s.columns[column] = true
+	}
+	s.rows = append(s.rows, row)
+	return nil
+}
+
+// Close writes the header and the rows
+func (s *csvSink) Close() error {
+	columns := make([]string, 0, len(s.columns))
+	for column := range s.columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	w := csv.NewWriter(s.w)
+	w.Comma = s.delimiter
+	if len(columns) > 0 {
+		w.Write(columns)
+	}
+	cells := make([]string, len(columns))
+	for _, row := range s.rows {
+		for i, column := range columns {
+			cells[i] = row[column]
+		}
+		w.Write(cells)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// flattenCSV adds the cells of a value at a column to a row, a cell for each key of nested
+// objects
+func flattenCSV(column string, value interface{}, row map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			row[column] = ""
+		}
+		for key, e := range v {
+			if err := flattenCSV(column+"."+key, e, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.RawMessage:
+		// Passthrough subtrees are flattened like the rest of the record
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		var decoded interface{}
+		if err := dec.Decode(&decoded); err != nil {
+			return err
+		}
+		return flattenCSV(column, decoded, row)
+	case string:
+		row[column] = v
+	case nil:
+		row[column] = ""
+	case []byte:
+		row[column] = base64.StdEncoding.EncodeToString(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		row[column] = string(data)
+	}
+	return nil
+}
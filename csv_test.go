@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCSVSink(t *testing.T) {
+	var buf strings.Builder
+	s := newCSVSink(&buf, ';')
+	for _, output := range []Output{
+		{{"name": "Ada; Countess"}, {"geo": map[string]interface{}{"lat": 51.5, "alt": nil}}, {"tags": []interface{}{"a", 7}}},
+		{{"name": "Grace"}, {"raw": json.RawMessage(`{"id": 12345678901234567890}`)}, {"blob": []byte{0, 1}}, {"ok": true}},
+	} {
+		if err := s.Write(output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `blob;geo.alt;geo.lat;name;ok;raw.id;tags
+;;51.5;"Ada; Countess";;;"[""a"",7]"
+AAE=;;;Grace;true;12345678901234567890;
+`
+	if got := buf.String(); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseCSVDelimiter(t *testing.T) {
+	for s, want := range map[string]rune{",": ',', "tab": '\t', `\t`: '\t', "|": '|', "§": '§'} {
+		if got, err := parseCSVDelimiter(s); err != nil || got != want {
+			t.Errorf("parseCSVDelimiter(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", ";;", `"`, "\n"} {
+		if _, err := parseCSVDelimiter(s); err == nil {
+			t.Errorf("parseCSVDelimiter(%q) succeeded, want error", s)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// deltaSink passes on only the outputs whose content changed since the last run, keyed by
+// a record field. The content hash of each key is kept in a state store.
+type deltaSink struct {
+	next   sink
+	key    string
+	member string
+	state  stateStore
+}
+
+// openDeltaSink wraps a sink with change detection against the state store at path
+func openDeltaSink(next sink, path, key string) (*deltaSink, error) {
+	state, err := openStateStore(path, "delta")
+	if err != nil {
+		return nil, err
+	}
+	return &deltaSink{next: next, key: key, state: state}, nil
+}
+
+// StartMember scopes record keys to an archive member and forwards it to the wrapped sink
+func (s *deltaSink) StartMember(name string) error {
+	s.member = name
+	if ms, ok := s.next.(memberSink); ok {
+		return ms.StartMember(name)
+	}
+	return nil
+}
+
+// Write forwards the output if its content differs from the last one seen for its key.
+// Outputs without the key field are always forwarded.
+func (s *deltaSink) Write(output Output) error {
+	value, ok := mergeOutput(output)[s.key]
+	if !ok {
+		warn("untracked-record", "Record without key %q is not tracked for changes", s.key)
+		return s.next.Write(output)
+	}
+
+	key := fmt.Sprint(value)
+	if s.member != "" {
+		key = s.member + "/" + key
+	}
+	hash, err := outputHash(output)
+	if err != nil {
+		return err
+	}
+	last, ok, err := s.state.Get(key)
+	if err != nil {
+		return err
+	}
+	if ok && last == hash {
+		return nil
+	}
+	// Save the hash only once the output is written, so that a failed or skipped write
+	// is retried by the next run
+	if err := s.next.Write(output); err != nil {
+		return err
+	}
+	return s.state.Put(key, hash)
+}
+
+// Flush saves the hashes seen so far, so that they survive a crash or restart
+func (s *deltaSink) Flush() error {
+	if f, ok := s.next.(flushSink); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.state.Flush()
+}
+
+// Close closes the wrapped sink and the state store
+func (s *deltaSink) Close() error {
+	if err := s.next.Close(); err != nil {
+		s.state.Close()
+		return err
+	}
+	return s.state.Close()
+}
+
+// outputHash returns the SHA-256 of an output's canonical JSON: each map encoded with sorted
+// keys, with the encoded maps sorted, so that the hash is independent of map order
+func outputHash(output Output) (string, error) {
+	parts := make([]string, 0, len(output))
+	for _, m := range output {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(data))
+	}
+	sort.Strings(parts)
+
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
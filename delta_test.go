@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// collectSink records the outputs written to it
+type collectSink struct {
+	outputs []Output
+	members []string
+	closed  bool
+}
+
+func (s *collectSink) Write(output Output) error {
+	s.outputs = append(s.outputs, output)
+	return nil
+}
+
+func (s *collectSink) StartMember(name string) error {
+	s.members = append(s.members, name)
+	return nil
+}
+
+func (s *collectSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestDeltaSink(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	runs := []struct {
+		outputs []Output
+		want    []Output
+	}{
+		{
+			[]Output{{{"id": "1"}, {"v": "a"}}, {{"id": "2"}, {"v": "b"}}, {{"v": "no key"}}},
+			[]Output{{{"id": "1"}, {"v": "a"}}, {{"id": "2"}, {"v": "b"}}, {{"v": "no key"}}},
+		},
+		{
+			// Unchanged records are dropped, whatever the order of their maps
+			[]Output{{{"v": "a"}, {"id": "1"}}, {{"id": "2"}, {"v": "c"}}, {{"id": "3"}}, {{"v": "no key"}}},
+			[]Output{{{"id": "2"}, {"v": "c"}}, {{"id": "3"}}, {{"v": "no key"}}},
+		},
+	}
+	for i, run := range runs {
+		next := &collectSink{}
+		s, err := openDeltaSink(next, state, "id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, output := range run.outputs {
+			if err := s.Write(output); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(next.outputs, run.want) || !next.closed {
+			t.Errorf("run %d: wrote %v (closed %v), want %v", i+1, next.outputs, next.closed, run.want)
+		}
+	}
+}
+
+func TestDeltaSinkMembers(t *testing.T) {
+	next := &collectSink{}
+	s, err := openDeltaSink(next, filepath.Join(t.TempDir(), "state.json"), "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys are scoped to their archive member
+	for _, member := range []string{"a.json", "b.json"} {
+		if err := s.StartMember(member); err != nil {
+			t.Fatal(err)
+		}
+		s.Write(Output{{"id": "1"}})
+		s.Write(Output{{"id": "1"}})
+	}
+	if len(next.outputs) != 2 || !reflect.DeepEqual(next.members, []string{"a.json", "b.json"}) {
+		t.Errorf("wrote %v to members %v, want one output per member", next.outputs, next.members)
+	}
+}
+
+func TestDeltaSinkFailedWrite(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	down := &flakySink{down: true}
+	s, err := openDeltaSink(down, state, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(Output{{"id": "1"}}); err == nil {
+		t.Fatal("write to a failing sink succeeded, want error")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The record was not delivered, so the next run writes it
+	next := &collectSink{}
+	if s, err = openDeltaSink(next, state, "id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(Output{{"id": "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.outputs) != 1 {
+		t.Errorf("wrote %v after a failed write, want the record", next.outputs)
+	}
+}
+
+func TestOpenDeltaSinkInvalidState(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(state, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openDeltaSink(&collectSink{}, state, "id"); err == nil {
+		t.Error("openDeltaSink with an invalid state file succeeded, want error")
+	}
+}
+
+func TestOutputHash(t *testing.T) {
+	a, err := outputHash(Output{{"id": "1"}, {"tags": []interface{}{"x", 2}}, {"b": "2", "a": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := outputHash(Output{{"a": "1", "b": "2"}, {"id": "1"}, {"tags": []interface{}{"x", 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("outputHash depends on map order: %s != %s", a, b)
+	}
+	// The hash is that of the sorted, newline-terminated map encodings, so that it can be
+	// recomputed downstream
+	if want := "784552a97201bf725c915f2a2d3c55f067339713a3533eb65656fe9483bad7f9"; a != want {
+		t.Errorf("outputHash = %s, want %s", a, want)
+	}
+
+	c, _ := outputHash(Output{{"id": "1"}, {"tags": []interface{}{2, "x"}}, {"a": "1", "b": "2"}})
+	if c == a {
+		t.Errorf("outputHash ignores array element order")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// unwrapExtendedJSONDocument unwraps the Extended JSON values of every field of a document
+func unwrapExtendedJSONDocument(doc Input) Input {
+	for k, v := range doc {
+		doc[k] = unwrapExtendedJSON(v)
+	}
+	return doc
+}
+
+// unwrapExtendedJSON recursively replaces MongoDB Extended JSON wrappers with plain values.
+// Dates become RFC3339 strings so that the standard coercion converts them to epoch, while
+// numbers, ObjectIDs and binary data become strings.
+func unwrapExtendedJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if unwrapped, ok := unwrapExtendedJSONValue(v); ok {
+			return unwrapped
+		}
+		for k, item := range v {
+			v[k] = unwrapExtendedJSON(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = unwrapExtendedJSON(item)
+		}
+		return v
+	}
+	return v
+}
+
+// unwrapExtendedJSONValue unwraps a single Extended JSON wrapper object, reporting false
+// when m is an ordinary document
+func unwrapExtendedJSONValue(m map[string]interface{}) (interface{}, bool) {
+	switch len(m) {
+	case 1:
+		for k, v := range m {
+			switch k {
+			case "$oid", "$numberLong", "$numberInt", "$numberDouble", "$numberDecimal", "$symbol":
+				if s, ok := v.(string); ok {
+					return s, true
+				}
+			case "$date":
+				return unwrapExtendedJSONDate(v)
+			case "$binary":
+				// Canonical form: {"$binary": {"base64": "...", "subType": "00"}}
+				if b, ok := v.(map[string]interface{}); ok {
+					if s, ok := b["base64"].(string); ok {
+						return s, true
+					}
+				}
+			case "$timestamp":
+				// {"$timestamp": {"t": seconds, "i": increment}}
+				if ts, ok := v.(map[string]interface{}); ok {
+					if t, ok := ts["t"].(float64); ok {
+						return time.Unix(int64(t), 0).UTC().Format(time.RFC3339), true
+					}
+				}
+			}
+		}
+	case 2:
+		// Legacy binary form: {"$binary": "...", "$type": "00"}
+		if s, ok := m["$binary"].(string); ok {
+			if _, ok := m["$type"]; ok {
+				return s, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// unwrapExtendedJSONDate converts the value of a $date wrapper to an RFC3339 string
+func unwrapExtendedJSONDate(v interface{}) (interface{}, bool) {
+	var ms int64
+	switch d := v.(type) {
+	case string:
+		// Relaxed form: ISO-8601 string
+		return d, true
+	case float64:
+		// Legacy form: milliseconds since epoch
+		ms = int64(d)
+	case map[string]interface{}:
+		// Canonical form: {"$numberLong": "milliseconds"}
+		s, ok := d["$numberLong"].(string)
+		if !ok {
+			return nil, false
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ms = n
+	default:
+		return nil, false
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano), true
+}
+
+// toExtendedJSON wraps the values of an output in canonical Extended JSON wrappers: numbers
+// as $numberInt, $numberLong or $numberDouble and timestamps converted by a Transformer
+// setting TypedTimestamps as $date. ObjectIds and binary values were unwrapped into strings
+// on input and stay strings.
+func toExtendedJSON(output Output) Output {
+	wrapped := make(Output, len(output))
+	for i, m := range output {
+		wrapped[i] = toExtendedJSONValue(m).(map[string]interface{})
+	}
+	return wrapped
+}
+
+// toExtendedJSONValue recursively wraps numbers, timestamps and binary data
+func toExtendedJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = toExtendedJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = toExtendedJSONValue(item)
+		}
+		return l
+	case int:
+		return extendedJSONInt(int64(v))
+	case int64:
+		return extendedJSONInt(v)
+	case transform.Timestamp:
+		return map[string]interface{}{"$date": map[string]interface{}{"$numberLong": strconv.FormatInt(int64(v)*1000, 10)}}
+	case float64:
+		return map[string]interface{}{"$numberDouble": strconv.FormatFloat(v, 'g', -1, 64)}
+	case []byte:
+		return map[string]interface{}{"$binary": map[string]interface{}{"base64": base64.StdEncoding.EncodeToString(v), "subType": "00"}}
+	}
+	return v
+}
+
+// extendedJSONInt wraps an integer as $numberInt when it fits in 32 bits, else $numberLong
+func extendedJSONInt(n int64) map[string]interface{} {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		return map[string]interface{}{"$numberInt": strconv.FormatInt(n, 10)}
+	}
+	return map[string]interface{}{"$numberLong": strconv.FormatInt(n, 10)}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestUnwrapExtendedJSON(t *testing.T) {
+	tests := []struct {
+		in, want interface{}
+	}{
+		{map[string]interface{}{"$oid": "507f1f77bcf86cd799439011"}, "507f1f77bcf86cd799439011"},
+		{map[string]interface{}{"$numberLong": "9007199254740993"}, "9007199254740993"},
+		{map[string]interface{}{"$numberDecimal": "1.10"}, "1.10"},
+		{map[string]interface{}{"$date": "2024-01-02T03:04:05Z"}, "2024-01-02T03:04:05Z"},
+		{map[string]interface{}{"$date": map[string]interface{}{"$numberLong": "1704164645123"}}, "2024-01-02T03:04:05.123Z"},
+		{map[string]interface{}{"$date": float64(1704164645000)}, "2024-01-02T03:04:05Z"},
+		{map[string]interface{}{"$timestamp": map[string]interface{}{"t": float64(1704164645), "i": float64(1)}}, "2024-01-02T03:04:05Z"},
+		{map[string]interface{}{"$binary": map[string]interface{}{"base64": "AQI=", "subType": "00"}}, "AQI="},
+		{map[string]interface{}{"$binary": "AQI=", "$type": "00"}, "AQI="},
+		// Ordinary documents and arrays are unwrapped recursively
+		{
+			map[string]interface{}{"n": map[string]interface{}{"$numberInt": "1"}, "l": []interface{}{map[string]interface{}{"$oid": "a"}}},
+			map[string]interface{}{"n": "1", "l": []interface{}{"a"}},
+		},
+		// Malformed wrappers are left as documents
+		{map[string]interface{}{"$date": true}, map[string]interface{}{"$date": true}},
+		{map[string]interface{}{"$numberLong": float64(1)}, map[string]interface{}{"$numberLong": float64(1)}},
+	}
+	for _, tt := range tests {
+		if got := unwrapExtendedJSON(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unwrapExtendedJSON(%v) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToExtendedJSON(t *testing.T) {
+	output := Output{
+		{"small": 7},
+		{"large": 1 << 40},
+		{"ratio": 0.5},
+		{"created": transform.Timestamp(1704164645)},
+		{"count": int64(3)},
+		{"name": "x", "list": []interface{}{1, "y"}},
+	}
+	want := Output{
+		{"small": map[string]interface{}{"$numberInt": "7"}},
+		{"large": map[string]interface{}{"$numberLong": "1099511627776"}},
+		{"ratio": map[string]interface{}{"$numberDouble": "0.5"}},
+		{"created": map[string]interface{}{"$date": map[string]interface{}{"$numberLong": "1704164645000"}}},
+		{"count": map[string]interface{}{"$numberInt": "3"}},
+		{"name": "x", "list": []interface{}{map[string]interface{}{"$numberInt": "1"}, "y"}},
+	}
+	if got := toExtendedJSON(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("toExtendedJSON = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// emailAddressHeaders are the headers holding address lists, which are flattened to lists
+// of bare addresses
+var emailAddressHeaders = map[string]bool{
+	"from": true, "to": true, "cc": true, "bcc": true, "reply_to": true, "sender": true,
+	"resent_from": true, "resent_to": true, "resent_cc": true,
+}
+
+// emailDateHeaders are the headers holding RFC 5322 dates, which are converted to RFC3339
+// so the standard coercion turns them into epoch
+var emailDateHeaders = map[string]bool{"date": true, "resent_date": true}
+
+// emailWordDecoder decodes RFC 2047 encoded words such as "=?UTF-8?B?...?="
+var emailWordDecoder = mime.WordDecoder{}
+
+// emailPreset normalizes messages from mailbox JSON exports, either Gmail API style messages
+// with a "payload" or generic messages with "headers" and "parts", and a "messages" list of
+// either. Headers become snake_case fields, dates are converted for epoch coercion, address
+// lists are flattened, and quoted-printable or base64 text parts are decoded into body_text
+// and body_html.
+func emailPreset(record Input) []Input {
+	if messages, ok := record["messages"].([]interface{}); ok {
+		var records []Input
+		for _, m := range messages {
+			if msg, ok := m.(map[string]interface{}); ok {
+				records = append(records, emailMessage(msg))
+			}
+		}
+		return records
+	}
+	return []Input{emailMessage(record)}
+}
+
+// emailMessage normalizes a single exported message
+func emailMessage(msg map[string]interface{}) Input {
+	out := make(Input)
+
+	// Gmail API messages nest the MIME structure under "payload"
+	part := msg
+	if payload, ok := msg["payload"].(map[string]interface{}); ok {
+		part = payload
+		for _, key := range []string{"id", "threadId", "snippet"} {
+			if s, ok := msg[key].(string); ok {
+				out[emailFieldName(key)] = s
+			}
+		}
+		if labels, ok := msg["labelIds"].([]interface{}); ok {
+			out["label_ids"] = labels
+		}
+	}
+
+	for name, value := range emailHeaders(part) {
+		field := emailFieldName(name)
+		switch {
+		case emailDateHeaders[field]:
+			if t, err := mail.ParseDate(value); err == nil {
+				out[field] = t.UTC().Format(time.RFC3339)
+				continue
+			}
+			warn("unparseable-header", "Keeping unparseable %s header %q as text", name, value)
+			out[field] = value
+		case emailAddressHeaders[field]:
+			out[field] = emailAddresses(value)
+		default:
+			if decoded, err := emailWordDecoder.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+			out[field] = value
+		}
+	}
+
+	// Collect the decoded text of all text parts
+	var text, html []string
+	emailTextParts(part, func(mediaType, body string) {
+		if mediaType == "text/html" {
+			html = append(html, body)
+		} else {
+			text = append(text, body)
+		}
+	})
+	if len(text) > 0 {
+		out["body_text"] = strings.Join(text, "\n")
+	}
+	if len(html) > 0 {
+		out["body_html"] = strings.Join(html, "\n")
+	}
+
+	return out
+}
+
+// emailHeaders returns the headers of a message or part, given either as a list of
+// {"name", "value"} objects or as a map of names to values
+func emailHeaders(part map[string]interface{}) map[string]string {
+	headers := make(map[string]string)
+	switch h := part["headers"].(type) {
+	case []interface{}:
+		for _, item := range h {
+			header, _ := item.(map[string]interface{})
+			name, _ := header["name"].(string)
+			value, _ := header["value"].(string)
+			if name != "" {
+				// Repeated headers are joined, which keeps address lists parseable
+				if prev, ok := headers[name]; ok {
+					value = prev + ", " + value
+				}
+				headers[name] = value
+			}
+		}
+	case map[string]interface{}:
+		for name, v := range h {
+			if value, ok := v.(string); ok {
+				headers[name] = value
+			}
+		}
+	}
+	return headers
+}
+
+// emailFieldName converts a header name like "Reply-To" or "threadId" to snake_case
+func emailFieldName(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case c == '-':
+			b.WriteByte('_')
+		case c >= 'A' && c <= 'Z':
+			if i > 0 && name[i-1] != '-' && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c + ('a' - 'A'))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// emailAddresses flattens an address list header to the bare addresses it contains,
+// falling back to the raw value when it cannot be parsed
+func emailAddresses(value string) []interface{} {
+	addrs, err := mail.ParseAddressList(value)
+	if err != nil {
+		return []interface{}{value}
+	}
+	list := make([]interface{}, len(addrs))
+	for i, addr := range addrs {
+		list[i] = addr.Address
+	}
+	return list
+}
+
+// emailTextParts walks a part tree and passes the decoded body of every text/plain and
+// text/html part to fn, skipping attachments
+func emailTextParts(part map[string]interface{}, fn func(mediaType, body string)) {
+	headers := make(map[string]string)
+	for name, value := range emailHeaders(part) {
+		headers[strings.ToLower(name)] = value
+	}
+
+	mediaType, _ := part["mimeType"].(string)
+	if mediaType == "" {
+		mediaType, _ = part["content_type"].(string)
+	}
+	if mediaType == "" {
+		mediaType = headers["content-type"]
+	}
+	mediaType, _, _ = mime.ParseMediaType(mediaType)
+
+	if parts, ok := part["parts"].([]interface{}); ok {
+		for _, p := range parts {
+			if child, ok := p.(map[string]interface{}); ok {
+				emailTextParts(child, fn)
+			}
+		}
+		return
+	}
+
+	disposition := strings.ToLower(headers["content-disposition"])
+	if strings.HasPrefix(disposition, "attachment") || (mediaType != "text/plain" && mediaType != "text/html" && mediaType != "") {
+		return
+	}
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+
+	body, err := emailPartBody(part, headers)
+	if err != nil {
+		warn("undecodable-part", "Skipping undecodable %s part: %v", mediaType, err)
+		return
+	}
+	if body != "" {
+		fn(mediaType, body)
+	}
+}
+
+// emailPartBody decodes the body of a part. Gmail API parts carry base64url data under
+// body.data, generic parts a "body" string in their content transfer encoding.
+func emailPartBody(part map[string]interface{}, headers map[string]string) (string, error) {
+	if body, ok := part["body"].(map[string]interface{}); ok {
+		data, _ := body["data"].(string)
+		decoded, err := base64.URLEncoding.DecodeString(data)
+		if err != nil {
+			// Some exporters drop the padding
+			decoded, err = base64.RawURLEncoding.DecodeString(data)
+		}
+		return string(decoded), err
+	}
+
+	body, _ := part["body"].(string)
+	encoding, _ := part["content_transfer_encoding"].(string)
+	if encoding == "" {
+		encoding = headers["content-transfer-encoding"]
+	}
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+		return string(decoded), err
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+		return string(decoded), err
+	}
+	return body, nil
+}
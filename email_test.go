@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEmailPreset(t *testing.T) {
+	tests := []struct {
+		name, message string
+		want          []Input
+	}{
+		{
+			name: "gmail",
+			message: `{"id": "m1", "threadId": "t1", "labelIds": ["INBOX"], "payload": {
+				"headers": [
+					{"name": "From", "value": "Ada <ada@example.com>"},
+					{"name": "To", "value": "bob@example.com"},
+					{"name": "To", "value": "Carol <carol@example.com>"},
+					{"name": "Subject", "value": "=?UTF-8?B?SGVsbG8=?="},
+					{"name": "Date", "value": "Tue, 02 Jan 2024 04:04:05 +0100"}
+				],
+				"mimeType": "multipart/mixed",
+				"parts": [
+					{"mimeType": "text/plain", "body": {"data": "aGk"}},
+					{"mimeType": "text/html", "body": {"data": "PGI-aGk8L2I-"}},
+					{"mimeType": "application/pdf", "body": {"data": "JVBERg=="}}
+				]}}`,
+			want: []Input{{
+				"id": "m1", "thread_id": "t1", "label_ids": []interface{}{"INBOX"},
+				"from":    []interface{}{"ada@example.com"},
+				"to":      []interface{}{"bob@example.com", "carol@example.com"},
+				"subject": "Hello", "date": "2024-01-02T03:04:05Z",
+				"body_text": "hi", "body_html": "<b>hi</b>",
+			}},
+		},
+		{
+			name: "generic list",
+			message: `{"messages": [
+				{"headers": {"Reply-To": "a@example.com", "X-Mailer": "test"},
+				 "parts": [
+					{"content_type": "text/plain; charset=utf-8", "content_transfer_encoding": "quoted-printable", "body": "caf=C3=A9"},
+					{"headers": {"Content-Type": "text/plain", "Content-Transfer-Encoding": "base64"}, "body": "d29y\nbGQ="},
+					{"headers": {"Content-Type": "text/plain", "Content-Disposition": "attachment; filename=a.txt"}, "body": "skipped"}
+				 ]},
+				{"headers": {"Cc": "not an address"}, "body": "plain"}
+			]}`,
+			want: []Input{
+				{"reply_to": []interface{}{"a@example.com"}, "x_mailer": "test", "body_text": "café\nworld"},
+				{"cc": []interface{}{"not an address"}, "body_text": "plain"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		var record Input
+		if err := json.Unmarshal([]byte(tt.message), &record); err != nil {
+			t.Fatal(err)
+		}
+		if got := emailPreset(record); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: emailPreset = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEmailFieldName(t *testing.T) {
+	tests := map[string]string{
+		"Reply-To": "reply_to", "threadId": "thread_id", "MIME-Version": "mime_version",
+		"X-Mailer": "x_mailer", "date": "date",
+	}
+	for name, want := range tests {
+		if got := emailFieldName(name); got != want {
+			t.Errorf("emailFieldName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
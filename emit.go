@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// emitModes are the values of -emit
+var emitModes = []string{"transformed", "both", "events"}
+
+// validateEmitMode checks a value of -emit
+func validateEmitMode(mode string) error {
+	for _, m := range emitModes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported -emit mode %q", mode)
+}
+
+// emitBoth pairs an output with the record it was transformed from, as
+// {"original": ..., "transformed": ...}, for consumers comparing shapes during migrations
+func emitBoth(record Input, output Output) Output {
+	return Output{
+		{"original": map[string]interface{}(record)},
+		{"transformed": mergeOutput(output)},
+	}
+}
+
+// eventOutputs returns the records written for the events of transforming the record with
+// the given index in the run, one per event
+func eventOutputs(record int, events []transform.Event) []Output {
+	outputs := make([]Output, len(events))
+	for i, e := range events {
+		outputs[i] = Output{
+			{"record": record},
+			{"path": e.Path},
+			{"action": e.Action},
+			{"old": e.Old},
+			{"new": e.New},
+		}
+	}
+	return outputs
+}
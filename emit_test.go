@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestEmitBoth(t *testing.T) {
+	record := Input{"name": " Ada ", "address": map[string]interface{}{"city": "London"}}
+	got := emitBoth(record, Output{{"name": "Ada"}, {"city": "London"}})
+	want := Output{
+		{"original": map[string]interface{}{"name": " Ada ", "address": map[string]interface{}{"city": "London"}}},
+		{"transformed": map[string]interface{}{"name": "Ada", "city": "London"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("emitBoth = %v, want %v", got, want)
+	}
+	if err := validateEmitMode("diff"); err == nil {
+		t.Error("validateEmitMode accepted an unknown mode")
+	}
+}
+
+func TestEventOutputs(t *testing.T) {
+	got := eventOutputs(3, []transform.Event{{Path: "name", Action: "trim", Old: " Ada ", New: "Ada"}})
+	want := []Output{{{"record": 3}, {"path": "name"}, {"action": "trim"}, {"old": " Ada "}, {"new": "Ada"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("eventOutputs = %v, want %v", got, want)
+	}
+}
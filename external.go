@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// runExternal implements the external data source protocol of Terraform and Pulumi: it
+// reads the query, a JSON object, from r, transforms it as a single record and writes the
+// result to w as a JSON object of strings. Nested objects are flattened into dotted keys,
+// other non-string values are written as their JSON text and null becomes "".
+func runExternal(tr *transform.Transformer, presetName string, r io.Reader, w io.Writer) error {
+	var query Input
+	if err := json.NewDecoder(r).Decode(&query); err != nil {
+		return fmt.Errorf("decoding query: %v", err)
+	}
+	records, err := applyPreset(presetName, []Input{query})
+	if err != nil {
+		return err
+	}
+	var outputs []Output
+	for _, record := range records {
+		results, err := tr.TransformRecord(record)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, results...)
+	}
+	if len(outputs) != 1 {
+		return fmt.Errorf("query produced %d records, want 1", len(outputs))
+	}
+
+	result := make(map[string]string)
+	flattenStrings(result, "", mergeOutput(outputs[0]))
+	return json.NewEncoder(w).Encode(result)
+}
+
+// flattenStrings adds the values of a map to a flat map of strings, joining the keys of
+// nested maps to the prefix with "."
+func flattenStrings(result map[string]string, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := transform.JoinPath(prefix, k)
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flattenStrings(result, key, v)
+		case string:
+			result[key] = v
+		case nil:
+			result[key] = ""
+		default:
+			data, _ := json.Marshal(v)
+			result[key] = string(data)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestRunExternal(t *testing.T) {
+	var out bytes.Buffer
+	query := `{"name": " web ", "since": "2024-01-01T00:00:00Z", "tags": ["7"], "config": {"region": " eu ", "zone": {"id": "a"}, "empty": {"NULL": "1"}}}`
+	if err := runExternal(&transform.Transformer{}, "", strings.NewReader(query), &out); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("result %s is not a map of strings: %v", out.Bytes(), err)
+	}
+	want := map[string]string{
+		"name":    "web",
+		"since":   "1704067200",
+		"tags":    "[7]",
+		"region":  "eu",
+		"zone.id": "a",
+		"empty":   "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runExternal = %v, want %v", got, want)
+	}
+
+	if err := runExternal(&transform.Transformer{}, "", strings.NewReader(`["not an object"]`), &out); err == nil {
+		t.Error("runExternal accepted a query that is not an object")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fhirPartialDatePattern matches FHIR date and dateTime values without a time part, which
+// may be reduced to a year or year and month
+var fhirPartialDatePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+
+// fhirPreset normalizes FHIR resources: Bundles yield one record per entry resource,
+// extensions become a map keyed by the last segment of their URL, CodeableConcepts and
+// Coding arrays are flattened into lists of code/system pairs, narratives are dropped, and
+// dates are converted to RFC3339 so the standard coercion turns them into epoch. Instants
+// and dateTimes with a time part already are RFC3339.
+func fhirPreset(record Input) []Input {
+	if record["resourceType"] == "Bundle" {
+		var records []Input
+		entries, _ := record["entry"].([]interface{})
+		for _, e := range entries {
+			entry, _ := e.(map[string]interface{})
+			if resource, ok := entry["resource"].(map[string]interface{}); ok {
+				records = append(records, Input(fhirObject(resource)))
+			}
+		}
+		return records
+	}
+	return []Input{Input(fhirObject(record))}
+}
+
+// fhirObject normalizes a resource or complex element
+func fhirObject(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		switch k {
+		case "text":
+			// Skip the XHTML narrative, keeping plain text fields such as CodeableConcept.text
+			if narrative, ok := v.(map[string]interface{}); ok && narrative["div"] != nil {
+				continue
+			}
+		case "extension", "modifierExtension":
+			if list, ok := v.([]interface{}); ok {
+				out[k] = fhirExtensions(list)
+				continue
+			}
+		}
+		if value := fhirValue(k, v); value != nil {
+			out[k] = value
+		}
+	}
+	return out
+}
+
+// fhirValue normalizes the value of the named element, returning nil for null
+func fhirValue(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if codings, ok := v["coding"].([]interface{}); ok {
+			text, _ := v["text"].(string)
+			return fhirCodings(codings, text)
+		}
+		return fhirObject(v)
+	case []interface{}:
+		if key == "coding" {
+			return fhirCodings(v, "")
+		}
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if value := fhirValue(key, item); value != nil {
+				list = append(list, value)
+			}
+		}
+		return list
+	case string:
+		if isFHIRDateElement(key) && fhirPartialDatePattern.MatchString(v) {
+			return fhirDate(v)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return nil
+}
+
+// fhirCodings flattens Coding elements into code/system pairs, carrying the concept text
+// as the display when a coding has none
+func fhirCodings(codings []interface{}, text string) []interface{} {
+	pairs := make([]interface{}, 0, len(codings))
+	for _, c := range codings {
+		coding, _ := c.(map[string]interface{})
+		pair := make(map[string]interface{})
+		for _, field := range []string{"system", "code", "display"} {
+			if s, ok := coding[field].(string); ok {
+				pair[field] = s
+			}
+		}
+		if _, ok := pair["display"]; !ok && text != "" {
+			pair["display"] = text
+		}
+		if len(pair) > 0 {
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) == 0 && text != "" {
+		pairs = append(pairs, map[string]interface{}{"display": text})
+	}
+	return pairs
+}
+
+// fhirExtensions converts an extension list into a map keyed by the last URL segment, with
+// each extension's value[x] or, for complex extensions, their nested extensions as value
+func fhirExtensions(list []interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, e := range list {
+		ext, _ := e.(map[string]interface{})
+		url, _ := ext["url"].(string)
+		if url == "" {
+			continue
+		}
+		name := url[strings.LastIndex(url, "/")+1:]
+
+		var value interface{}
+		if nested, ok := ext["extension"].([]interface{}); ok {
+			value = fhirExtensions(nested)
+		}
+		for k, v := range ext {
+			if strings.HasPrefix(k, "value") {
+				value = fhirValue(strings.TrimPrefix(k, "value"), v)
+			}
+		}
+		if value != nil {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+// isFHIRDateElement reports whether an element name denotes a date or dateTime, either by
+// its suffix (birthDate, onsetDateTime, valueDate) or as one of the common bare names
+func isFHIRDateElement(key string) bool {
+	switch key {
+	case "date", "issued", "recorded", "authoredOn", "start", "end", "lastUpdated", "occurrence", "created":
+		return true
+	}
+	return strings.HasSuffix(key, "Date") || strings.HasSuffix(key, "DateTime")
+}
+
+// fhirDate converts a possibly partial FHIR date to RFC3339 at the start of the period
+func fhirDate(s string) string {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
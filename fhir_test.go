@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFHIRPreset(t *testing.T) {
+	var bundle Input
+	err := json.Unmarshal([]byte(`{"resourceType": "Bundle", "entry": [
+		{"resource": {"resourceType": "Patient", "id": "p1", "active": true, "birthDate": "1815-12",
+			"text": {"status": "generated", "div": "<div>Ada</div>"},
+			"extension": [
+				{"url": "http://hl7.org/fhir/StructureDefinition/patient-birthPlace", "valueString": "London"},
+				{"url": "http://example.org/race", "extension": [{"url": "ombCategory", "valueCode": "2106-3"}]},
+				{"valueString": "no url"}
+			],
+			"maritalStatus": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-MaritalStatus", "code": "M"}], "text": "Married"},
+			"deceasedBoolean": false}},
+		{"resource": {"resourceType": "Observation", "id": "o1", "effectiveDateTime": "2024-01-02T03:04:05Z",
+			"valueQuantity": {"value": 72.5, "unit": "kg"},
+			"code": {"text": "Weight"},
+			"note": [{"text": "plain"}]}},
+		{"fullUrl": "urn:uuid:x"}
+	]}`), &bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{
+		{
+			"resourceType": "Patient", "id": "p1", "active": "true", "birthDate": "1815-12-01T00:00:00Z",
+			"extension": map[string]interface{}{
+				"patient-birthPlace": "London",
+				"race":               map[string]interface{}{"ombCategory": "2106-3"},
+			},
+			"maritalStatus": []interface{}{map[string]interface{}{
+				"system": "http://terminology.hl7.org/CodeSystem/v3-MaritalStatus", "code": "M", "display": "Married",
+			}},
+			"deceasedBoolean": "false",
+		},
+		{
+			"resourceType": "Observation", "id": "o1", "effectiveDateTime": "2024-01-02T03:04:05Z",
+			"valueQuantity": map[string]interface{}{"value": "72.5", "unit": "kg"},
+			"code":          map[string]interface{}{"text": "Weight"},
+			"note":          []interface{}{map[string]interface{}{"text": "plain"}},
+		},
+	}
+	if got := fhirPreset(bundle); !reflect.DeepEqual(got, want) {
+		t.Errorf("fhirPreset = %#v, want %#v", got, want)
+	}
+}
+
+func TestFHIRDate(t *testing.T) {
+	tests := map[string]string{
+		"2024":       "2024-01-01T00:00:00Z",
+		"2024-03":    "2024-03-01T00:00:00Z",
+		"2024-03-15": "2024-03-15T00:00:00Z",
+		"2024-13":    "2024-13",
+	}
+	for in, want := range tests {
+		if got := fhirDate(in); got != want {
+			t.Errorf("fhirDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
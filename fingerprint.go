@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// schemaFingerprintLength is the number of hex digits kept of a schema fingerprint
+const schemaFingerprintLength = 16
+
+// schemaFingerprint returns a short hash of an output's structure: its sorted key paths with
+// the JSON type found at each, so that records of the same shape share a fingerprint whatever
+// their values
+func schemaFingerprint(output Output) string {
+	h := sha256.Sum256([]byte(strings.Join(schemaPaths(output), "\n")))
+	return hex.EncodeToString(h[:])[:schemaFingerprintLength]
+}
+
+// schemaPaths returns the distinct "path:type" entries of an output in sorted order. Nested
+// keys are joined with "." and array elements appear under "path[]".
+func schemaPaths(output Output) []string {
+	seen := make(map[string]bool)
+	collectSchemaPaths(mergeOutput(output), "", seen)
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// collectSchemaPaths adds the entries of a value found at path
+func collectSchemaPaths(v interface{}, path string, seen map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		seen[path+":object"] = true
+		for k, child := range v {
+			if path == "" {
+				collectSchemaPaths(child, k, seen)
+			} else {
+				collectSchemaPaths(child, path+"."+k, seen)
+			}
+		}
+	case []interface{}:
+		seen[path+":array"] = true
+		for _, child := range v {
+			collectSchemaPaths(child, path+"[]", seen)
+		}
+	case string:
+		seen[path+":string"] = true
+	case bool:
+		seen[path+":boolean"] = true
+	case nil:
+		seen[path+":null"] = true
+	case json.RawMessage:
+		seen[path+":raw"] = true
+	case []byte:
+		seen[path+":binary"] = true
+	default:
+		seen[path+":number"] = true
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaPaths(t *testing.T) {
+	output := Output{
+		{"id": "1"},
+		{"address": map[string]interface{}{"city": "x", "geo": map[string]interface{}{"lat": 1.5}}},
+		{"tags": []interface{}{"a", 2, map[string]interface{}{"k": "v"}}},
+		{"seen": int64(1700000000)},
+	}
+	want := []string{
+		":object",
+		"address.city:string",
+		"address.geo.lat:number",
+		"address.geo:object",
+		"address:object",
+		"id:string",
+		"seen:number",
+		"tags:array",
+		"tags[].k:string",
+		"tags[]:number",
+		"tags[]:object",
+		"tags[]:string",
+	}
+	if got := schemaPaths(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaPaths = %q, want %q", got, want)
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	a := schemaFingerprint(Output{{"id": "1"}, {"n": 1}})
+	if len(a) != schemaFingerprintLength {
+		t.Errorf("fingerprint %q has length %d, want %d", a, len(a), schemaFingerprintLength)
+	}
+	if b := schemaFingerprint(Output{{"n": 2.5}, {"id": "other"}}); b != a {
+		t.Errorf("records of the same shape have fingerprints %q and %q", a, b)
+	}
+	if c := schemaFingerprint(Output{{"id": "1"}, {"n": "1"}}); c == a {
+		t.Errorf("records of different types share fingerprint %q", a)
+	}
+}
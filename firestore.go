@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+)
+
+// firestorePreset unwraps Firestore REST documents, either a single document with "fields",
+// a list response with "documents", or a bare map of typed values, into plain records
+func firestorePreset(record Input) []Input {
+	// List responses hold one document per record
+	if docs, ok := record["documents"].([]interface{}); ok {
+		var records []Input
+		for _, d := range docs {
+			if doc, ok := d.(map[string]interface{}); ok {
+				records = append(records, firestoreDocument(doc))
+			}
+		}
+		return records
+	}
+
+	if _, ok := record["fields"].(map[string]interface{}); ok {
+		return []Input{firestoreDocument(record)}
+	}
+	return []Input{firestoreFields(record)}
+}
+
+// firestoreDocument unwraps the fields of a document, keeping its name and timestamps
+func firestoreDocument(doc map[string]interface{}) Input {
+	fields, _ := doc["fields"].(map[string]interface{})
+	record := firestoreFields(fields)
+
+	// Document metadata uses reserved names that cannot clash with user fields
+	for key, meta := range map[string]string{"name": "__name__", "createTime": "__create_time__", "updateTime": "__update_time__"} {
+		if s, ok := doc[key].(string); ok {
+			record[meta] = s
+		}
+	}
+	return record
+}
+
+// firestoreFields unwraps a map of typed Firestore values, dropping nulls
+func firestoreFields(fields map[string]interface{}) Input {
+	record := make(Input)
+	for k, v := range fields {
+		if value := firestoreValue(v); value != nil {
+			record[k] = value
+		}
+	}
+	return record
+}
+
+// firestoreValue converts a Firestore value wrapper like {"stringValue": "x"} to a plain value.
+// Scalars become strings, with timestamps left in RFC3339 form for the standard coercion.
+func firestoreValue(v interface{}) interface{} {
+	wrapper, ok := v.(map[string]interface{})
+	if !ok || len(wrapper) != 1 {
+		warn("invalid-value", "Skipping malformed Firestore value")
+		return nil
+	}
+
+	for kind, value := range wrapper {
+		switch kind {
+		case "stringValue", "integerValue", "timestampValue", "bytesValue", "referenceValue":
+			if s, ok := value.(string); ok {
+				return s
+			}
+			// Integers are strings in the REST API, but accept numbers as well
+			if f, ok := value.(float64); ok {
+				return strconv.FormatFloat(f, 'f', -1, 64)
+			}
+		case "doubleValue":
+			switch d := value.(type) {
+			case float64:
+				return strconv.FormatFloat(d, 'f', -1, 64)
+			case string:
+				// NaN and Infinity are sent as strings
+				return d
+			}
+		case "booleanValue":
+			if b, ok := value.(bool); ok {
+				return strconv.FormatBool(b)
+			}
+		case "nullValue":
+			return nil
+		case "geoPointValue":
+			if p, ok := value.(map[string]interface{}); ok {
+				point := make(map[string]interface{})
+				for _, coord := range []string{"latitude", "longitude"} {
+					if f, ok := p[coord].(float64); ok {
+						point[coord] = strconv.FormatFloat(f, 'f', -1, 64)
+					}
+				}
+				return point
+			}
+		case "mapValue":
+			m, _ := value.(map[string]interface{})
+			fields, _ := m["fields"].(map[string]interface{})
+			return map[string]interface{}(firestoreFields(fields))
+		case "arrayValue":
+			a, _ := value.(map[string]interface{})
+			values, _ := a["values"].([]interface{})
+			list := make([]interface{}, 0, len(values))
+			for _, item := range values {
+				if unwrapped := firestoreValue(item); unwrapped != nil {
+					list = append(list, unwrapped)
+				}
+			}
+			return list
+		}
+		warn("unsupported-type", "Skipping unsupported Firestore value type %q", kind)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFirestorePreset(t *testing.T) {
+	tests := []struct {
+		name   string
+		record Input
+		want   []Input
+	}{
+		{
+			name: "document",
+			record: Input{
+				"name":       "projects/p/databases/(default)/documents/users/ada",
+				"createTime": "2024-01-02T03:04:05Z",
+				"fields": map[string]interface{}{
+					"name":    map[string]interface{}{"stringValue": "Ada"},
+					"age":     map[string]interface{}{"integerValue": "36"},
+					"score":   map[string]interface{}{"doubleValue": 9.5},
+					"active":  map[string]interface{}{"booleanValue": true},
+					"deleted": map[string]interface{}{"nullValue": nil},
+				},
+			},
+			want: []Input{{
+				"__name__":        "projects/p/databases/(default)/documents/users/ada",
+				"__create_time__": "2024-01-02T03:04:05Z",
+				"name":            "Ada", "age": "36", "score": "9.5", "active": "true",
+			}},
+		},
+		{
+			name: "list response",
+			record: Input{"documents": []interface{}{
+				map[string]interface{}{"fields": map[string]interface{}{"n": map[string]interface{}{"integerValue": float64(1)}}},
+				map[string]interface{}{"fields": map[string]interface{}{"n": map[string]interface{}{"integerValue": "2"}}},
+			}},
+			want: []Input{{"n": "1"}, {"n": "2"}},
+		},
+		{
+			name: "bare fields",
+			record: Input{
+				"at":    map[string]interface{}{"timestampValue": "2024-01-02T03:04:05.123Z"},
+				"where": map[string]interface{}{"geoPointValue": map[string]interface{}{"latitude": 51.5, "longitude": -0.12}},
+				"tags": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+					map[string]interface{}{"stringValue": "a"},
+					map[string]interface{}{"nullValue": nil},
+					map[string]interface{}{"doubleValue": "NaN"},
+				}}},
+				"meta": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+					"ref": map[string]interface{}{"referenceValue": "projects/p/databases/(default)/documents/x/y"},
+				}}},
+			},
+			want: []Input{{
+				"at":    "2024-01-02T03:04:05.123Z",
+				"where": map[string]interface{}{"latitude": "51.5", "longitude": "-0.12"},
+				"tags":  []interface{}{"a", "NaN"},
+				"meta":  map[string]interface{}{"ref": "projects/p/databases/(default)/documents/x/y"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		if got := firestorePreset(tt.record); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: firestorePreset = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	records := []Input{{"a": "1"}}
+	if got, err := applyPreset("", records); err != nil || !reflect.DeepEqual(got, records) {
+		t.Errorf("applyPreset without a preset = %v, %v, want the records untouched", got, err)
+	}
+	if _, err := applyPreset("nope", records); err == nil {
+		t.Error("applyPreset with an unknown preset succeeded, want an error")
+	}
+	got, err := applyPreset("firestore", []Input{{"documents": []interface{}{}}, {"a": map[string]interface{}{"stringValue": "x"}}})
+	if want := []Input{{"a": "x"}}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("applyPreset(firestore) = %v, %v, want %v", got, err, want)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// runFmtCommand rewrites JSON files in place in canonical form, exiting with status 1 when
+// any file changed, for use as a pre-commit hook
+func runFmtCommand(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	coerce := fs.String("coerce", "timestamp", "comma-separated coercions tried in order on every string value")
+	rulesPath := fs.String("rules", "", "JSON rules file of actions applied to each file's record")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s fmt [flags] file.json...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Keep the values and nesting of canonical files as they are, so that formatting is
+	// idempotent
+	tr := transform.Transformer{KeepScalars: true, KeepNesting: true, OnWarning: printWarning}
+	var opts inputOptions
+	var err error
+	if tr.Coercions, err = transform.ParseCoercionOrder(*coerce); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if *rulesPath != "" {
+		if tr.Rules, err = transform.LoadRules(*rulesPath); err != nil {
+			log.Fatalf("error loading rules: %v", err)
+		}
+		opts.decoder = &tr
+	}
+
+	changed := false
+	for _, name := range fs.Args() {
+		c, err := formatFile(&tr, name, opts)
+		if err != nil {
+			log.Fatalf("error formatting %s: %v", name, err)
+		}
+		if c {
+			fmt.Println(name)
+			changed = true
+		}
+	}
+	if changed {
+		os.Exit(1)
+	}
+}
+
+// formatFile rewrites a JSON file in canonical form, reporting whether it changed
+func formatFile(tr *transform.Transformer, name string, opts inputOptions) (bool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := formatJSON(tr, data, opts)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(data, formatted) {
+		return false, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+"-*")
+	if err != nil {
+		return false, err
+	}
+	if _, err := tmp.Write(formatted); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), name)
+}
+
+// formatJSON transforms the JSON object of a file and encodes the result as a single object
+// with sorted keys at every depth, indented by two spaces. Files whose keys would collide
+// once transformed, as " a" and "a" once trimmed, are refused rather than losing a value.
+func formatJSON(tr *transform.Transformer, data []byte, opts inputOptions) ([]byte, error) {
+	opts.format = "json"
+	records, err := readInput(bytes.NewReader(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	if records[0] == nil {
+		return nil, errors.New("null is not a JSON object")
+	}
+	if err := checkKeyCollisions(map[string]interface{}(records[0]), ""); err != nil {
+		return nil, err
+	}
+	outputs, err := tr.TransformRecord(records[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) != 1 {
+		return nil, fmt.Errorf("rules split the file into %d records", len(outputs))
+	}
+	record := make(map[string]interface{})
+	for _, m := range outputs[0] {
+		for k, v := range m {
+			if _, ok := record[k]; ok {
+				return nil, fmt.Errorf("key %q is written twice", k)
+			}
+			record[k] = v
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(record); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkKeyCollisions fails for the first object in a value with keys that are the same
+// once trimmed of whitespace, as the transformer trims them
+func checkKeyCollisions(v interface{}, path string) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		seen := make(map[string]string, len(keys))
+		for _, k := range keys {
+			key := strings.TrimSpace(k)
+			if other, ok := seen[key]; ok {
+				if path == "" {
+					return fmt.Errorf("keys %q and %q collide once trimmed", other, k)
+				}
+				return fmt.Errorf("keys %q and %q of %q collide once trimmed", other, k, path)
+			}
+			seen[key] = k
+			if err := checkKeyCollisions(v[k], transform.JoinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := checkKeyCollisions(e, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runStdinFilter formats the JSON buffer read from r like the fmt subcommand and writes it
+// to w, for editors that pipe buffers through the tool. Nothing is written on error, and
+// the error is a single line of the form "<stdin>:line:col: message".
+func runStdinFilter(tr *transform.Transformer, opts inputOptions, r io.Reader, w io.Writer) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("<stdin>:1:1: %v", err)
+	}
+	formatted, err := formatJSON(tr, data, opts)
+	if err != nil {
+		line, col := jsonErrorPosition(data, err)
+		return fmt.Errorf("<stdin>:%d:%d: %s", line, col, strings.ReplaceAll(err.Error(), "\n", " "))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// jsonErrorPosition returns the 1-based line and column of the error decoding data, or of
+// its start when the error has no offset. Decoding errors occur after reading the byte at
+// fault.
+func jsonErrorPosition(data []byte, err error) (int, int) {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset - 1
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestFormatFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"name": " web ", "port": 8080, "tls": {"enabled": true, "since": "2024-01-01T00:00:00Z"}, "hosts": ["b<", "7"]}`), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	tr := transform.Transformer{Coercions: []string{"timestamp"}, KeepScalars: true, KeepNesting: true}
+	changed, err := formatFile(&tr, name, inputOptions{})
+	if err != nil || !changed {
+		t.Fatalf("formatFile = %v, %v, want a change", changed, err)
+	}
+	got, _ := os.ReadFile(name)
+	want := `{
+  "hosts": [
+    "b<",
+    "7"
+  ],
+  "name": "web",
+  "port": 8080,
+  "tls": {
+    "enabled": true,
+    "since": 1704067200
+  }
+}
+`
+	if string(got) != want {
+		t.Errorf("formatted file =\n%s\nwant\n%s", got, want)
+	}
+	if info, _ := os.Stat(name); info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+
+	// Formatting a canonical file leaves it unchanged
+	if changed, err := formatFile(&tr, name, inputOptions{}); err != nil || changed {
+		t.Errorf("formatFile of a canonical file = %v, %v, want no change", changed, err)
+	}
+}
+
+func TestFormatJSONNesting(t *testing.T) {
+	tr := transform.Transformer{Coercions: []string{"timestamp"}, KeepScalars: true, KeepNesting: true}
+	tests := []struct {
+		data, want, err string
+	}{
+		// Objects nested under keys that flattening would merge are kept apart
+		{`{"a": {"id": 1, "b": {"id": 2}}, "c": {"id": 3}}`, "{\n  \"a\": {\n    \"b\": {\n      \"id\": 2\n    },\n    \"id\": 1\n  },\n  \"c\": {\n    \"id\": 3\n  }\n}\n", ""},
+		{`{"a": {}, "b": [{"z": 1, "y": {}}]}`, "{\n  \"a\": {},\n  \"b\": [\n    {\n      \"y\": {},\n      \"z\": 1\n    }\n  ]\n}\n", ""},
+		{`{"a": 1, " a": 2}`, "", `keys " a" and "a" collide once trimmed`},
+		{`{"x": {"l": [{"k ": 1, "k": 2}]}}`, "", `keys "k" and "k " of "x.l" collide once trimmed`},
+		{`null`, "", "null is not a JSON object"},
+	}
+	for _, tt := range tests {
+		got, err := formatJSON(&tr, []byte(tt.data), inputOptions{})
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("formatJSON(%s) error = %v, want %s", tt.data, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("formatJSON(%s) = %q, %v, want %q", tt.data, got, err, tt.want)
+		}
+	}
+
+	// Rules renaming a field onto another are refused too
+	rs, err := transform.ParseRules([]byte(`{"rules": [{"field": "email", "action": "email", "rename": "contact"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renaming := tr
+	renaming.Rules = rs
+	if _, err := formatJSON(&renaming, []byte(`{"email": "a@example.com", "contact": "b"}`), inputOptions{}); err == nil || err.Error() != `key "contact" is written twice` {
+		t.Errorf("formatJSON with a colliding rename error = %v, want key \"contact\" is written twice", err)
+	}
+}
+
+func TestRunStdinFilter(t *testing.T) {
+	tr := transform.Transformer{Coercions: []string{"timestamp"}, KeepScalars: true, KeepNesting: true}
+	var out bytes.Buffer
+	if err := runStdinFilter(&tr, inputOptions{}, strings.NewReader(`{"b": " x ", "a": 1}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"a\": 1,\n  \"b\": \"x\"\n}\n"; out.String() != want {
+		t.Errorf("runStdinFilter = %q, want %q", out.String(), want)
+	}
+
+	tests := []struct {
+		buffer string
+		want   string
+	}{
+		{"{\n  \"a\": 1,\n  \"b\" 2\n}", "<stdin>:3:7: invalid character '2' after object key"},
+		{"{\"a\": [1,\n", "<stdin>:2:1: unexpected EOF"},
+		{"[1]", "<stdin>:1:1: json: cannot unmarshal array into Go value of type transform.Input"},
+		{"null", "<stdin>:1:1: null is not a JSON object"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		err := runStdinFilter(&tr, inputOptions{}, strings.NewReader(tt.buffer), &out)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("runStdinFilter(%q) error = %v, want %s", tt.buffer, err, tt.want)
+		}
+		if out.Len() != 0 {
+			t.Errorf("runStdinFilter(%q) wrote %q on error", tt.buffer, out.String())
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// geoJSONGeometryTypes are the GeoJSON geometry type names
+var geoJSONGeometryTypes = map[string]bool{
+	"Point": true, "MultiPoint": true, "LineString": true, "MultiLineString": true,
+	"Polygon": true, "MultiPolygon": true, "GeometryCollection": true,
+}
+
+// transformGeoJSON transforms a GeoJSON document, normalizing feature properties while
+// keeping geometries untouched. In "geojson" mode the document keeps its GeoJSON shape; in
+// "wkt" mode every feature becomes a flat record with its geometry as WKT. Records that are
+// not GeoJSON get the standard transformation.
+func transformGeoJSON(tr *transform.Transformer, record Input, mode string) ([]Output, error) {
+	var features []map[string]interface{}
+	switch t, _ := record["type"].(string); {
+	case t == "FeatureCollection":
+		list, _ := record["features"].([]interface{})
+		for _, f := range list {
+			if feature, ok := f.(map[string]interface{}); ok {
+				features = append(features, feature)
+			}
+		}
+	case t == "Feature":
+		features = []map[string]interface{}{record}
+	case geoJSONGeometryTypes[t]:
+		features = []map[string]interface{}{{"type": "Feature", "geometry": map[string]interface{}(record)}}
+	default:
+		output, err := tr.Transform(record)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{output}, nil
+	}
+
+	if mode == "wkt" {
+		var outputs []Output
+		for _, feature := range features {
+			properties, _ := feature["properties"].(map[string]interface{})
+			output, err := tr.Transform(properties)
+			if err != nil {
+				return nil, err
+			}
+			if id, ok := feature["id"]; ok {
+				output = append(output, map[string]interface{}{"id": id})
+			}
+			if geometry, ok := feature["geometry"].(map[string]interface{}); ok {
+				wkt, err := geometryWKT(geometry)
+				if err != nil {
+					warn("invalid-geometry", "Skipping invalid geometry: %v", err)
+				} else {
+					output = append(output, map[string]interface{}{"geometry": wkt})
+				}
+			}
+			outputs = append(outputs, output)
+		}
+		return outputs, nil
+	}
+
+	// Rebuild the features with normalized properties and the original geometry
+	out := make([]interface{}, len(features))
+	for i, feature := range features {
+		f := map[string]interface{}{"type": "Feature", "geometry": feature["geometry"]}
+		if id, ok := feature["id"]; ok {
+			f["id"] = id
+		}
+		if properties, ok := feature["properties"].(map[string]interface{}); ok {
+			f["properties"] = tr.TransformValue(properties, "properties")
+		} else {
+			f["properties"] = nil
+		}
+		out[i] = f
+	}
+	if record["type"] == "FeatureCollection" {
+		collection := map[string]interface{}{"type": "FeatureCollection", "features": out}
+		if bbox, ok := record["bbox"]; ok {
+			collection["bbox"] = bbox
+		}
+		return []Output{{collection}}, nil
+	}
+	return []Output{{out[0].(map[string]interface{})}}, nil
+}
+
+// geometryWKT formats a GeoJSON geometry as Well-Known Text
+func geometryWKT(geometry map[string]interface{}) (string, error) {
+	t, _ := geometry["type"].(string)
+	if t == "GeometryCollection" {
+		list, _ := geometry["geometries"].([]interface{})
+		if len(list) == 0 {
+			return "GEOMETRYCOLLECTION EMPTY", nil
+		}
+		parts := make([]string, len(list))
+		for i, g := range list {
+			m, _ := g.(map[string]interface{})
+			wkt, err := geometryWKT(m)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = wkt
+		}
+		return "GEOMETRYCOLLECTION (" + strings.Join(parts, ", ") + ")", nil
+	}
+
+	// Nesting depth of the coordinates array for each geometry type
+	depth := map[string]int{
+		"Point": 0, "MultiPoint": 1, "LineString": 1,
+		"MultiLineString": 2, "Polygon": 2, "MultiPolygon": 3,
+	}
+	d, ok := depth[t]
+	if !ok {
+		return "", fmt.Errorf("unknown geometry type %q", t)
+	}
+	name := strings.ToUpper(t)
+	coords, ok := geometry["coordinates"].([]interface{})
+	if !ok || len(coords) == 0 {
+		return name + " EMPTY", nil
+	}
+
+	dims := 0
+	text, err := wktCoordinates(coords, d, t == "MultiPoint", &dims)
+	if err != nil {
+		return "", err
+	}
+	if dims == 3 {
+		name += " Z"
+	}
+	if d == 0 {
+		text = "(" + text + ")"
+	}
+	return name + " " + text, nil
+}
+
+// wktCoordinates formats a coordinates array nested depth levels deep, recording the number
+// of dimensions of its positions. MultiPoint positions are parenthesized individually.
+func wktCoordinates(coords []interface{}, depth int, multiPoint bool, dims *int) (string, error) {
+	if depth == 0 {
+		parts := make([]string, len(coords))
+		for i, c := range coords {
+			f, ok := c.(float64)
+			if !ok {
+				return "", fmt.Errorf("non-numeric coordinate %v", c)
+			}
+			parts[i] = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		if len(parts) < 2 {
+			return "", fmt.Errorf("position with %d coordinates", len(parts))
+		}
+		if len(parts) > *dims {
+			*dims = len(parts)
+		}
+		return strings.Join(parts, " "), nil
+	}
+
+	parts := make([]string, len(coords))
+	for i, c := range coords {
+		inner, ok := c.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("malformed coordinates")
+		}
+		text, err := wktCoordinates(inner, depth-1, multiPoint, dims)
+		if err != nil {
+			return "", err
+		}
+		if depth == 1 && multiPoint {
+			text = "(" + text + ")"
+		}
+		parts[i] = text
+	}
+	return "(" + strings.Join(parts, ", ") + ")", nil
+}
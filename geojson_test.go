@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestGeometryWKT(t *testing.T) {
+	tests := []struct {
+		geometry string
+		want     string
+	}{
+		{`{"type": "Point", "coordinates": [30, 10]}`, "POINT (30 10)"},
+		{`{"type": "Point", "coordinates": [30, 10, 2.5]}`, "POINT Z (30 10 2.5)"},
+		{`{"type": "Point", "coordinates": []}`, "POINT EMPTY"},
+		{`{"type": "MultiPoint", "coordinates": [[10, 40], [40, 30]]}`, "MULTIPOINT ((10 40), (40 30))"},
+		{`{"type": "LineString", "coordinates": [[30, 10], [10, 30], [40, 40]]}`, "LINESTRING (30 10, 10 30, 40 40)"},
+		{`{"type": "Polygon", "coordinates": [[[30, 10], [40, 40], [20, 40], [30, 10]]]}`, "POLYGON ((30 10, 40 40, 20 40, 30 10))"},
+		{`{"type": "MultiPolygon", "coordinates": [[[[1, 1], [2, 2], [1, 2], [1, 1]]]]}`, "MULTIPOLYGON (((1 1, 2 2, 1 2, 1 1)))"},
+		{`{"type": "GeometryCollection", "geometries": [{"type": "Point", "coordinates": [1, 2]}, {"type": "LineString", "coordinates": [[1, 2], [3, 4]]}]}`, "GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (1 2, 3 4))"},
+		{`{"type": "GeometryCollection", "geometries": []}`, "GEOMETRYCOLLECTION EMPTY"},
+	}
+	for _, tt := range tests {
+		var geometry map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.geometry), &geometry); err != nil {
+			t.Fatal(err)
+		}
+		got, err := geometryWKT(geometry)
+		if err != nil || got != tt.want {
+			t.Errorf("geometryWKT(%s) = %q, %v, want %q", tt.geometry, got, err, tt.want)
+		}
+	}
+
+	for _, geometry := range []string{
+		`{"type": "Circle", "coordinates": [1, 2]}`,
+		`{"type": "Point", "coordinates": [1]}`,
+		`{"type": "Point", "coordinates": ["1", "2"]}`,
+		`{"type": "LineString", "coordinates": [1, 2]}`,
+	} {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(geometry), &m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := geometryWKT(m); err == nil {
+			t.Errorf("geometryWKT(%s) succeeded, want error", geometry)
+		}
+	}
+}
+
+func TestTransformGeoJSON(t *testing.T) {
+	var collection Input
+	err := json.Unmarshal([]byte(`{"type": "FeatureCollection", "bbox": [0, 0, 1, 1], "features": [
+		{"type": "Feature", "id": "f1", "geometry": {"type": "Point", "coordinates": [1, 0.5]}, "properties": {"name": " a "}},
+		{"type": "Feature", "geometry": null, "properties": null}
+	]}`), &collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point := map[string]interface{}{"type": "Point", "coordinates": []interface{}{float64(1), 0.5}}
+	want := []Output{{{
+		"type": "FeatureCollection",
+		"bbox": []interface{}{float64(0), float64(0), float64(1), float64(1)},
+		"features": []interface{}{
+			map[string]interface{}{"type": "Feature", "id": "f1", "geometry": point, "properties": map[string]interface{}{"name": "a"}},
+			map[string]interface{}{"type": "Feature", "geometry": nil, "properties": nil},
+		},
+	}}}
+	if got, err := transformGeoJSON(&transform.Transformer{}, collection, "geojson"); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("transformGeoJSON(geojson) = %#v, %v, want %#v", got, err, want)
+	}
+
+	outputs, err := transformGeoJSON(&transform.Transformer{}, collection, "wkt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	for _, output := range outputs {
+		got = append(got, mergeOutput(output))
+	}
+	wantWKT := []map[string]interface{}{{"name": "a", "id": "f1", "geometry": "POINT (1 0.5)"}, {}}
+	if !reflect.DeepEqual(got, wantWKT) {
+		t.Errorf("transformGeoJSON(wkt) = %#v, want %#v", got, wantWKT)
+	}
+}
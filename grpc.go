@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// transformService is the server API of the transform.v1.Transformer gRPC service defined
+// in proto/transform.proto
+type transformService interface {
+	Transform(ctx context.Context, record *structpb.Struct) (*structpb.ListValue, error)
+	TransformBatch(stream grpc.BidiStreamingServer[structpb.Struct, structpb.ListValue]) error
+}
+
+// transformServiceDesc describes the transform.v1.Transformer service to grpc, as
+// protoc-gen-go-grpc would. Its messages are well-known types, so no code is generated.
+var transformServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transform.v1.Transformer",
+	HandlerType: (*transformService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Transform",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return srv.(transformService).Transform(ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/transform.v1.Transformer/Transform"}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.(transformService).Transform(ctx, req.(*structpb.Struct))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "TransformBatch",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(transformService).TransformBatch(&grpc.GenericServerStream[structpb.Struct, structpb.ListValue]{ServerStream: stream})
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "proto/transform.proto",
+}
+
+// grpcTransformer implements transformService with a Transformer and preset
+type grpcTransformer struct {
+	tr         *transform.Transformer
+	presetName string
+	admission  *admission
+	budget     *memoryBudget
+}
+
+// Transform transforms a single record
+func (g *grpcTransformer) Transform(ctx context.Context, record *structpb.Struct) (*structpb.ListValue, error) {
+	return g.transform(ctx, record)
+}
+
+// TransformBatch transforms each record received on the stream in order until the client
+// closes it
+func (g *grpcTransformer) TransformBatch(stream grpc.BidiStreamingServer[structpb.Struct, structpb.ListValue]) error {
+	for i := 1; ; i++ {
+		record, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		result, err := g.transform(stream.Context(), record)
+		if err != nil {
+			return status.Errorf(status.Code(err), "record %d: %s", i, status.Convert(err).Message())
+		}
+		if err := stream.Send(result); err != nil {
+			return err
+		}
+	}
+}
+
+// transform transforms a record into the list of its output records, once it is admitted
+// and its memory fits in the budget. Calls whose deadline expires fail with
+// DeadlineExceeded, as the caller has given up on them.
+func (g *grpcTransformer) transform(ctx context.Context, record *structpb.Struct) (*structpb.ListValue, error) {
+	free, err := g.admission.admit(ctx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, expiredStatus()
+	} else if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer free()
+	input := transform.FromStruct(record)
+	reserved, err := g.budget.reserve(ctx, requestMemory(proto.Size(record), input))
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, expiredStatus()
+	} else if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer reserved.release()
+
+	records, err := applyPreset(g.presetName, []Input{input})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	outputs := []Output{}
+	for _, record := range records {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, expiredStatus()
+		}
+		results, err := g.tr.TransformRecord(record)
+		var strictErr *transform.StrictError
+		if errors.As(err, &strictErr) {
+			return nil, status.Errorf(codes.InvalidArgument, "record has problems: %s", strings.Join(strictErr.Problems, "; "))
+		} else if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		outputs = append(outputs, results...)
+	}
+
+	result, err := transform.ToValue(outputs)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding output: %v", err)
+	}
+	return result.GetListValue(), nil
+}
+
+// expiredStatus fails a call whose deadline expired, counting it with the expired requests
+func expiredStatus() error {
+	expiredRequests.Add(1)
+	return status.Error(codes.DeadlineExceeded, errLatencyExpired.Error())
+}
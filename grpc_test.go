@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestGRPCTransformer(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	server.RegisterService(&transformServiceDesc, &grpcTransformer{tr: &transform.Transformer{Strict: true}})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	encode := func(v *structpb.ListValue) string {
+		data, err := json.Marshal(v.AsSlice())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	record := func(m map[string]interface{}) *structpb.Struct {
+		s, err := structpb.NewStruct(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	out := new(structpb.ListValue)
+	err = conn.Invoke(ctx, "/transform.v1.Transformer/Transform", record(map[string]interface{}{"name": " a ", "n": map[string]interface{}{"N": "7"}}), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := encode(out), `[[{"n":7},{"name":"a"}]]`; got != want {
+		t.Errorf("Transform = %s, want %s", got, want)
+	}
+	err = conn.Invoke(ctx, "/transform.v1.Transformer/Transform", record(map[string]interface{}{"n": 1.0}), out)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Transform of a record with problems error = %v, want InvalidArgument", err)
+	}
+
+	stream, err := conn.NewStream(ctx, &transformServiceDesc.Streams[0], "/transform.v1.Transformer/TransformBatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{" x ", "y"} {
+		if err := stream.SendMsg(record(map[string]interface{}{"name": name})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	var got []string
+	for {
+		out := new(structpb.ListValue)
+		if err := stream.RecvMsg(out); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, encode(out))
+	}
+	if len(got) != 2 || got[0] != `[[{"name":"x"}]]` || got[1] != `[[{"name":"y"}]]` {
+		t.Errorf("TransformBatch = %v", got)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+// jsonAPIResourceKey identifies a resource object by type and id
+type jsonAPIResourceKey struct {
+	typ, id string
+}
+
+// jsonAPIPreset flattens a JSON:API document into one record per primary resource. Each
+// record holds the resource's id, type and attributes, with relationships replaced by the
+// related resources from "included" (or bare identifiers when not included).
+func jsonAPIPreset(record Input) []Input {
+	index := make(map[jsonAPIResourceKey]map[string]interface{})
+	included, _ := record["included"].([]interface{})
+	for _, item := range included {
+		if res, ok := item.(map[string]interface{}); ok {
+			index[jsonAPIKey(res)] = res
+		}
+	}
+
+	var primary []interface{}
+	switch data := record["data"].(type) {
+	case map[string]interface{}:
+		primary = []interface{}{data}
+	case []interface{}:
+		primary = data
+	}
+
+	var records []Input
+	for _, item := range primary {
+		if res, ok := item.(map[string]interface{}); ok {
+			records = append(records, jsonAPIFlatten(res, index, map[jsonAPIResourceKey]bool{}))
+		}
+	}
+	return records
+}
+
+// jsonAPIKey returns the type and id of a resource object or identifier
+func jsonAPIKey(res map[string]interface{}) jsonAPIResourceKey {
+	typ, _ := res["type"].(string)
+	id, _ := res["id"].(string)
+	return jsonAPIResourceKey{typ, id}
+}
+
+// jsonAPIFlatten flattens a resource, resolving its relationships against the included
+// resources. Resources already on the resolution path are left as identifiers to break cycles.
+func jsonAPIFlatten(res map[string]interface{}, index map[jsonAPIResourceKey]map[string]interface{}, path map[jsonAPIResourceKey]bool) Input {
+	key := jsonAPIKey(res)
+	path[key] = true
+	defer delete(path, key)
+
+	out := Input{"id": key.id, "type": key.typ}
+	attributes, _ := res["attributes"].(map[string]interface{})
+	for k, v := range attributes {
+		out[k] = v
+	}
+
+	relationships, _ := res["relationships"].(map[string]interface{})
+	for name, r := range relationships {
+		rel, _ := r.(map[string]interface{})
+		resolve := func(ident map[string]interface{}) map[string]interface{} {
+			k := jsonAPIKey(ident)
+			if full, ok := index[k]; ok && !path[k] {
+				return jsonAPIFlatten(full, index, path)
+			}
+			return map[string]interface{}{"id": k.id, "type": k.typ}
+		}
+		switch data := rel["data"].(type) {
+		case map[string]interface{}:
+			out[name] = resolve(data)
+		case []interface{}:
+			list := make([]interface{}, 0, len(data))
+			for _, item := range data {
+				if ident, ok := item.(map[string]interface{}); ok {
+					list = append(list, resolve(ident))
+				}
+			}
+			out[name] = list
+		}
+	}
+
+	return out
+}
+
+// halPreset flattens a HAL document: "_links" become "<rel>_href" fields and each
+// "_embedded" resource is flattened in turn and nested under its relation name
+func halPreset(record Input) []Input {
+	return []Input{halFlatten(record)}
+}
+
+// halFlatten flattens a single HAL resource
+func halFlatten(res map[string]interface{}) Input {
+	out := make(Input)
+	for k, v := range res {
+		if k != "_links" && k != "_embedded" {
+			out[k] = v
+		}
+	}
+
+	links, _ := res["_links"].(map[string]interface{})
+	for rel, l := range links {
+		switch link := l.(type) {
+		case map[string]interface{}:
+			if href, ok := link["href"].(string); ok {
+				out[rel+"_href"] = href
+			}
+		case []interface{}:
+			var hrefs []interface{}
+			for _, item := range link {
+				if m, ok := item.(map[string]interface{}); ok {
+					if href, ok := m["href"].(string); ok {
+						hrefs = append(hrefs, href)
+					}
+				}
+			}
+			out[rel+"_href"] = hrefs
+		}
+	}
+
+	embedded, _ := res["_embedded"].(map[string]interface{})
+	for rel, e := range embedded {
+		switch item := e.(type) {
+		case map[string]interface{}:
+			out[rel] = map[string]interface{}(halFlatten(item))
+		case []interface{}:
+			list := make([]interface{}, 0, len(item))
+			for _, child := range item {
+				if m, ok := child.(map[string]interface{}); ok {
+					list = append(list, map[string]interface{}(halFlatten(m)))
+				}
+			}
+			out[rel] = list
+		}
+	}
+
+	return out
+}
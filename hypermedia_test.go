@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONAPIPreset(t *testing.T) {
+	var doc Input
+	err := json.Unmarshal([]byte(`{
+		"data": [{"type": "articles", "id": "1", "attributes": {"title": "Hi"},
+			"relationships": {
+				"author": {"data": {"type": "people", "id": "9"}},
+				"comments": {"data": [{"type": "comments", "id": "5"}, {"type": "comments", "id": "6"}]}
+			}}],
+		"included": [
+			{"type": "people", "id": "9", "attributes": {"name": "Ada"},
+			 "relationships": {"articles": {"data": [{"type": "articles", "id": "1"}]}}},
+			{"type": "comments", "id": "5", "attributes": {"body": "First"}}
+		]}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{
+		"id": "1", "type": "articles", "title": "Hi",
+		// The author's link back to the article is left as an identifier to break the cycle
+		"author": map[string]interface{}{"id": "9", "type": "people", "name": "Ada",
+			"articles": []interface{}{map[string]interface{}{"id": "1", "type": "articles"}}},
+		"comments": []interface{}{
+			map[string]interface{}{"id": "5", "type": "comments", "body": "First"},
+			map[string]interface{}{"id": "6", "type": "comments"},
+		},
+	}}
+	if got := jsonAPIPreset(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("jsonAPIPreset = %#v, want %#v", got, want)
+	}
+
+	single := Input{"data": map[string]interface{}{"type": "people", "id": "9"}}
+	if got, want := jsonAPIPreset(single), []Input{{"id": "9", "type": "people"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("jsonAPIPreset(single) = %#v, want %#v", got, want)
+	}
+}
+
+func TestHALPreset(t *testing.T) {
+	var doc Input
+	err := json.Unmarshal([]byte(`{
+		"total": "2",
+		"_links": {"self": {"href": "/orders"}, "curies": [{"href": "/docs/{rel}"}, {"name": "x"}]},
+		"_embedded": {
+			"orders": [{"id": "1", "_links": {"self": {"href": "/orders/1"}}}],
+			"owner": {"name": "Ada"}
+		}}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{
+		"total": "2", "self_href": "/orders", "curies_href": []interface{}{"/docs/{rel}"},
+		"orders": []interface{}{map[string]interface{}{"id": "1", "self_href": "/orders/1"}},
+		"owner":  map[string]interface{}{"name": "Ada"},
+	}}
+	if got := halPreset(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("halPreset = %#v, want %#v", got, want)
+	}
+}
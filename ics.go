@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icsProperty is a single unfolded content line of an iCalendar file
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsDateProperties are the properties holding a date or date-time, which are converted to
+// RFC3339 so the standard coercion turns them into epoch
+var icsDateProperties = map[string]bool{
+	"DTSTART": true, "DTEND": true, "DTSTAMP": true, "CREATED": true,
+	"LAST-MODIFIED": true, "RECURRENCE-ID": true, "DUE": true, "COMPLETED": true,
+}
+
+// icsListProperties are the properties that may repeat or hold comma separated values
+var icsListProperties = map[string]bool{"ATTENDEE": true, "CATEGORIES": true, "EXDATE": true, "RESOURCES": true}
+
+// readICS reads the VEVENT components of an iCalendar file into records. When expand is
+// positive, events with an RRULE are expanded into up to expand occurrence records.
+func readICS(r io.Reader, expand int) ([]Input, error) {
+	props, err := readICSProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Input
+	var event []icsProperty
+	depth := 0
+	inEvent := false
+	for _, p := range props {
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && !inEvent:
+			inEvent, depth, event = true, 0, nil
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT") && inEvent && depth == 0:
+			inEvent = false
+			records = append(records, icsEventRecords(event, expand)...)
+		case !inEvent:
+		case p.name == "BEGIN":
+			// Skip nested components such as VALARM
+			depth++
+		case p.name == "END":
+			depth--
+		case depth == 0:
+			event = append(event, p)
+		}
+	}
+	if inEvent {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+
+	return records, nil
+}
+
+// readICSProperties unfolds the content lines of an iCalendar stream and parses each into
+// a property, skipping malformed lines
+func readICSProperties(r io.Reader) ([]icsProperty, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Lines starting with whitespace continue the previous line
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var props []icsProperty
+	for i, line := range lines {
+		p, ok := parseICSProperty(line)
+		if !ok {
+			warn("malformed-line", "Skipping malformed iCalendar line %d", i+1)
+			continue
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// parseICSProperty parses a content line like "DTSTART;TZID=Europe/Paris:20240101T090000"
+func parseICSProperty(line string) (icsProperty, bool) {
+	// The value starts at the first colon outside a quoted parameter value
+	colon := -1
+	inQuote := false
+	for i, c := range line {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return icsProperty{}, false
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	p := icsProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p, true
+}
+
+// icsEventRecords converts the properties of an event into one record, or one record per
+// occurrence when its recurrence rule is expanded
+func icsEventRecords(props []icsProperty, expand int) []Input {
+	record := make(Input)
+	var start, end time.Time
+	var rrule string
+	var exdates []time.Time
+
+	for _, p := range props {
+		field := strings.ToLower(strings.ReplaceAll(p.name, "-", "_"))
+
+		switch {
+		case icsDateProperties[p.name]:
+			t, err := parseICSTime(p.value, p.params)
+			if err != nil {
+				warn("invalid-value", "Skipping invalid %s value %q", p.name, p.value)
+				continue
+			}
+			record[field] = t.Format(time.RFC3339)
+			if p.name == "DTSTART" {
+				start = t
+			} else if p.name == "DTEND" {
+				end = t
+			}
+		case p.name == "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				if t, err := parseICSTime(v, p.params); err == nil {
+					exdates = append(exdates, t)
+					appendICSList(record, field, t.Format(time.RFC3339))
+				}
+			}
+		case p.name == "RRULE":
+			rrule = p.value
+			record[field] = p.value
+		case p.name == "ATTENDEE" || p.name == "ORGANIZER":
+			value := strings.TrimPrefix(strings.TrimPrefix(p.value, "mailto:"), "MAILTO:")
+			if p.name == "ORGANIZER" {
+				record[field] = value
+			} else {
+				appendICSList(record, field, value)
+			}
+		case icsListProperties[p.name]:
+			for _, v := range splitICSText(p.value) {
+				appendICSList(record, field, v)
+			}
+		default:
+			record[field] = unescapeICSText(p.value)
+		}
+	}
+
+	if expand <= 0 || rrule == "" || start.IsZero() {
+		return []Input{record}
+	}
+	occurrences, err := expandRRule(rrule, start, expand, exdates)
+	if err != nil {
+		warn("rrule", "Not expanding RRULE %q: %v", rrule, err)
+		return []Input{record}
+	}
+
+	// Each occurrence keeps the event's properties with shifted start and end times
+	var records []Input
+	for _, occ := range occurrences {
+		r := make(Input, len(record)+1)
+		for k, v := range record {
+			r[k] = v
+		}
+		r["dtstart"] = occ.Format(time.RFC3339)
+		if !end.IsZero() {
+			r["dtend"] = occ.Add(end.Sub(start)).Format(time.RFC3339)
+		}
+		r["recurrence_id"] = occ.Format(time.RFC3339)
+		delete(r, "rrule")
+		records = append(records, r)
+	}
+	return records
+}
+
+// appendICSList appends a value to a list field of the record
+func appendICSList(record Input, field, value string) {
+	list, _ := record[field].([]interface{})
+	record[field] = append(list, value)
+}
+
+// parseICSTime parses a DATE or DATE-TIME value, honouring the TZID parameter for local times
+func parseICSTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		return time.Parse("20060102", value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		} else {
+			warn("unknown-tzid", "Unknown TZID %q, using UTC", tzid)
+		}
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// splitICSText splits a comma separated text value, honouring escaped commas
+func splitICSText(value string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			b.WriteByte(value[i])
+			b.WriteByte(value[i+1])
+			i++
+		case value[i] == ',':
+			parts = append(parts, unescapeICSText(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return append(parts, unescapeICSText(b.String()))
+}
+
+// icsTextUnescaper reverses the escaping of TEXT values
+var icsTextUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// unescapeICSText reverses the escaping of a TEXT value
+func unescapeICSText(value string) string {
+	return icsTextUnescaper.Replace(value)
+}
+
+// icsWeekdays maps RRULE weekday codes to weekdays
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// expandRRule returns up to limit occurrence start times of a recurrence rule, supporting
+// FREQ, INTERVAL, COUNT, UNTIL and BYDAY for weekly rules
+func expandRRule(rule string, start time.Time, limit int, exdates []time.Time) ([]time.Time, error) {
+	parts := make(map[string]string)
+	for _, part := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			parts[strings.ToUpper(k)] = strings.ToUpper(v)
+		}
+	}
+
+	interval := 1
+	if v, ok := parts["INTERVAL"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid INTERVAL %q", v)
+		}
+		interval = n
+	}
+	count := 0
+	if v, ok := parts["COUNT"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid COUNT %q", v)
+		}
+		count = n
+	}
+	var until time.Time
+	if v, ok := parts["UNTIL"]; ok {
+		t, err := parseICSTime(v, map[string]string{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid UNTIL %q", v)
+		}
+		until = t
+	}
+	for k := range parts {
+		switch k {
+		case "FREQ", "INTERVAL", "COUNT", "UNTIL", "WKST":
+		case "BYDAY":
+			if parts["FREQ"] != "WEEKLY" {
+				return nil, fmt.Errorf("BYDAY is only supported for weekly rules")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", k)
+		}
+	}
+
+	// Weekly rules repeat on the listed weekdays, by default the weekday of the start
+	days := []int{0}
+	if byday, ok := parts["BYDAY"]; ok {
+		days = nil
+		weekStart := (int(start.Weekday()) + 6) % 7
+		for _, code := range strings.Split(byday, ",") {
+			wd, ok := icsWeekdays[code]
+			if !ok {
+				return nil, fmt.Errorf("unsupported BYDAY value %q", code)
+			}
+			days = append(days, (int(wd)+6)%7-weekStart)
+		}
+		sort.Ints(days)
+	}
+
+	excluded := make(map[int64]bool)
+	for _, t := range exdates {
+		excluded[t.Unix()] = true
+	}
+
+	var occurrences []time.Time
+	generated := 0
+	for period := 0; ; period++ {
+		var candidates []time.Time
+		switch parts["FREQ"] {
+		case "DAILY":
+			candidates = []time.Time{start.AddDate(0, 0, period*interval)}
+		case "WEEKLY":
+			for _, d := range days {
+				candidates = append(candidates, start.AddDate(0, 0, period*interval*7+d))
+			}
+		case "MONTHLY":
+			candidates = []time.Time{start.AddDate(0, period*interval, 0)}
+		case "YEARLY":
+			candidates = []time.Time{start.AddDate(period*interval, 0, 0)}
+		default:
+			return nil, fmt.Errorf("unsupported FREQ %q", parts["FREQ"])
+		}
+
+		for _, t := range candidates {
+			// Skip days before the start and dates that do not exist, like February 30
+			if t.Before(start) || (parts["FREQ"] == "MONTHLY" || parts["FREQ"] == "YEARLY") && t.Day() != start.Day() {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return occurrences, nil
+			}
+			generated++
+			if count > 0 && generated > count {
+				return occurrences, nil
+			}
+			if !excluded[t.Unix()] {
+				occurrences = append(occurrences, t)
+				if len(occurrences) >= limit {
+					return occurrences, nil
+				}
+			}
+		}
+
+		// Rules without COUNT or UNTIL stop at the limit, guarded against endless skipping
+		if period > limit*400 {
+			return occurrences, nil
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandRRule(t *testing.T) {
+	// 2024-01-01 is a Monday
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return start.AddDate(0, 0, d) }
+
+	tests := []struct {
+		rule    string
+		start   time.Time
+		limit   int
+		exdates []time.Time
+		want    []time.Time
+	}{
+		{"FREQ=DAILY;COUNT=3", start, 10, nil, []time.Time{day(0), day(1), day(2)}},
+		{"FREQ=DAILY;INTERVAL=2", start, 3, nil, []time.Time{day(0), day(2), day(4)}},
+		{"FREQ=DAILY;UNTIL=20240103T090000Z", start, 10, nil, []time.Time{day(0), day(1), day(2)}},
+		{"FREQ=DAILY;UNTIL=20240103", start, 10, nil, []time.Time{day(0), day(1)}},
+		// Excluded occurrences still count towards COUNT
+		{"FREQ=DAILY;COUNT=3", start, 10, []time.Time{day(1)}, []time.Time{day(0), day(2)}},
+		{"FREQ=WEEKLY;COUNT=2", start, 10, nil, []time.Time{day(0), day(7)}},
+		{"FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=4", start, 10, nil, []time.Time{day(0), day(2), day(4), day(7)}},
+		// Days of the first week before the start are skipped, starting on a Wednesday
+		{"FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3", day(2), 10, nil, []time.Time{day(4), day(7), day(11)}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=2", start, 10, nil, []time.Time{day(1), day(15)}},
+		// Months without a 31st are skipped
+		{
+			"FREQ=MONTHLY;COUNT=3", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), 10, nil,
+			[]time.Time{
+				time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			"FREQ=YEARLY;COUNT=2", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), 10, nil,
+			[]time.Time{
+				time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{"FREQ=DAILY", start, 2, nil, []time.Time{day(0), day(1)}},
+	}
+	for _, tt := range tests {
+		got, err := expandRRule(tt.rule, tt.start, tt.limit, tt.exdates)
+		if err != nil {
+			t.Errorf("expandRRule(%q) error: %v", tt.rule, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandRRule(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestExpandRRuleErrors(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, rule := range []string{
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=1",
+	} {
+		if _, err := expandRRule(rule, start, 10, nil); err == nil {
+			t.Errorf("expandRRule(%q) succeeded, want error", rule)
+		}
+	}
+}
+
+func TestReadICS(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:1",
+		"SUMMARY:Stand-up\\, daily",
+		"DESCRIPTION:Line one\\nline ",
+		" two",
+		"DTSTART;TZID=Europe/Berlin:20240101T090000",
+		"DTEND;TZID=Europe/Berlin:20240101T091500",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"EXDATE;TZID=Europe/Berlin:20240102T090000",
+		"CATEGORIES:work,team\\,core",
+		"ATTENDEE;CN=Ada:mailto:ada@example.com",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT5M",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	records, err := readICS(strings.NewReader(ics), 0)
+	if err != nil {
+		t.Fatalf("readICS error: %v", err)
+	}
+	want := []Input{{
+		"uid":         "1",
+		"summary":     "Stand-up, daily",
+		"description": "Line one\nline two",
+		"dtstart":     "2024-01-01T09:00:00+01:00",
+		"dtend":       "2024-01-01T09:15:00+01:00",
+		"rrule":       "FREQ=DAILY;COUNT=3",
+		"exdate":      []interface{}{"2024-01-02T09:00:00+01:00"},
+		"categories":  []interface{}{"work", "team,core"},
+		"attendee":    []interface{}{"ada@example.com"},
+	}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readICS = %#v, want %#v", records, want)
+	}
+
+	records, err = readICS(strings.NewReader(ics), 10)
+	if err != nil {
+		t.Fatalf("readICS expanded error: %v", err)
+	}
+	var starts, ends []interface{}
+	for _, r := range records {
+		if _, ok := r["rrule"]; ok {
+			t.Errorf("expanded occurrence keeps its rrule")
+		}
+		starts = append(starts, r["dtstart"])
+		ends = append(ends, r["dtend"])
+	}
+	wantStarts := []interface{}{"2024-01-01T09:00:00+01:00", "2024-01-03T09:00:00+01:00"}
+	wantEnds := []interface{}{"2024-01-01T09:15:00+01:00", "2024-01-03T09:15:00+01:00"}
+	if !reflect.DeepEqual(starts, wantStarts) || !reflect.DeepEqual(ends, wantEnds) {
+		t.Errorf("expanded occurrences start %v and end %v, want %v and %v", starts, ends, wantStarts, wantEnds)
+	}
+}
+
+func TestReadICSUnterminated(t *testing.T) {
+	if _, err := readICS(strings.NewReader("BEGIN:VEVENT\r\nUID:1\r\n"), 0); err == nil {
+		t.Errorf("readICS succeeded on an unterminated VEVENT, want error")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// runInitCommand runs "init", which samples the records of an input, prints the types and
+// candidate coercions of their fields, and asks which coerce rules to write to a new rules
+// file
+func runInitCommand(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	var opts inputOptions
+	fs.StringVar(&opts.format, "input-format", "json", "input format, as for the transform command")
+	sample := fs.Int("sample", 100, "number of records sampled")
+	rulesPath := fs.String("rules", "rules.json", "rules file to create")
+	yes := fs.Bool("yes", false, "accept every suggested rule without asking")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s init [flags] input\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *sample < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*rulesPath); err == nil {
+		log.Fatalf("error: %s already exists", *rulesPath)
+	}
+
+	members, err := readSource(fs.Arg(0), opts)
+	if err != nil {
+		log.Fatalf("error reading input: %v", err)
+	}
+	var records []Input
+	for _, m := range members {
+		records = append(records, m.records...)
+	}
+	if len(records) == 0 {
+		log.Fatalf("error: %s has no records", fs.Arg(0))
+	}
+	if len(records) > *sample {
+		records = records[:*sample]
+	}
+
+	profiles := transform.ProfileRecords(records)
+	printProfiles(os.Stdout, profiles, len(records))
+	var answers io.Reader = os.Stdin
+	if *yes {
+		answers = strings.NewReader("")
+	}
+	rs, err := buildRules(profiles, answers, os.Stdout)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if err := os.WriteFile(*rulesPath, append(data, '\n'), 0o644); err != nil {
+		log.Fatalf("error writing rules: %v", err)
+	}
+	fmt.Printf("Wrote %d rules to %s\n", len(rs.Rules), *rulesPath)
+}
+
+// printProfiles prints a line for each sampled field: its path, the types of its values,
+// examples of its strings and its candidate coercion
+func printProfiles(w io.Writer, profiles []transform.FieldProfile, records int) {
+	fmt.Fprintf(w, "Sampled %d records:\n", records)
+	for _, p := range profiles {
+		var types []string
+		for kind, n := range p.Types {
+			types = append(types, fmt.Sprintf("%d %s", n, kind))
+		}
+		sort.Strings(types)
+		line := fmt.Sprintf("  %s: %s", p.Path, strings.Join(types, ", "))
+		if len(p.Examples) > 0 {
+			examples, _ := json.Marshal(p.Examples)
+			line += " e.g. " + string(examples)
+		}
+		if len(p.Coercions) > 0 {
+			line += "; suggested coercion " + strings.Join(p.Coercions, ",")
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// buildRules asks whether to add a coerce rule for each field with a candidate coercion,
+// reading answers from r and writing prompts to w. An empty answer, or the end of r, accepts
+// the candidate; "n" skips the field; other answers are the coercion order to use instead.
+func buildRules(profiles []transform.FieldProfile, r io.Reader, w io.Writer) (*transform.RuleSet, error) {
+	rs := &transform.RuleSet{Rules: []transform.Rule{}}
+	answers := bufio.NewScanner(r)
+	for _, p := range profiles {
+		if len(p.Coercions) == 0 {
+			continue
+		}
+		order := p.Coercions
+		for {
+			fmt.Fprintf(w, "Coerce %s as %s? [Y/n/order] ", p.Path, strings.Join(order, ","))
+			answer := ""
+			if answers.Scan() {
+				answer = strings.TrimSpace(answers.Text())
+			} else {
+				fmt.Fprintln(w)
+			}
+			switch strings.ToLower(answer) {
+			case "", "y", "yes":
+			case "n", "no":
+				order = nil
+			default:
+				custom, err := transform.ParseCoercionOrder(answer)
+				if err != nil {
+					fmt.Fprintf(w, "%v\n", err)
+					continue
+				}
+				order = custom
+			}
+			break
+		}
+		if order != nil {
+			rs.Rules = append(rs.Rules, transform.Rule{Field: p.Path, Action: "coerce", Coerce: order})
+		}
+	}
+	return rs, answers.Err()
+}
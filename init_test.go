@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestBuildRules(t *testing.T) {
+	profiles := []transform.FieldProfile{
+		{Path: "active", Coercions: []string{"boolean"}},
+		{Path: "name"},
+		{Path: "price", Coercions: []string{"number"}},
+		{Path: "zip", Coercions: []string{"number"}},
+		{Path: "zips", Coercions: []string{"string"}},
+	}
+	// The answers skip active, fix an invalid order for zip and end before zips
+	rs, err := buildRules(profiles, strings.NewReader("n\n\nint\nstring\n"), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []transform.Rule{
+		{Field: "price", Action: "coerce", Coerce: []string{"number"}},
+		{Field: "zip", Action: "coerce", Coerce: []string{"string"}},
+		{Field: "zips", Action: "coerce", Coerce: []string{"string"}},
+	}
+	if !reflect.DeepEqual(rs.Rules, want) {
+		t.Errorf("buildRules = %+v, want %+v", rs.Rules, want)
+	}
+}
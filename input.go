@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// lineParser parses a single line of a line-oriented input format into a record
+type lineParser func(line string) (Input, error)
+
+// lineParsers maps line-oriented input format names to their parsers
+var lineParsers = map[string]lineParser{
+	"syslog":   parseSyslog,
+	"logfmt":   parseLogfmt,
+	"combined": parseAccessLog,
+}
+
+// inputOptions holds the settings controlling how the input stream is decoded
+type inputOptions struct {
+	format     string
+	sheet      string
+	headerRow  int
+	memberGlob string
+	icsExpand  int
+	// decoder, when set, decodes JSON input following the passthrough rules and fields of
+	// its rules
+	decoder *transform.Transformer
+	// latencyBudget bounds the time from the source timestamp of a stream message, such as
+	// a Kafka record's, until its records are written
+	latencyBudget time.Duration
+}
+
+// member is a named group of records, such as the decoded contents of one archive member
+type member struct {
+	name    string
+	records []Input
+	// deadline is when the latency budget of a stream message expires, if it has one
+	deadline time.Time
+}
+
+// isStreamURI reports whether an input URI addresses a message stream rather than a source
+// that can be read as a whole
+func isStreamURI(uri string) bool {
+	return isMQTTURI(uri) || isAMQPURI(uri) || isPubSubURI(uri) || isSQSURI(uri) || isKafkaURI(uri)
+}
+
+// consumeStream passes the records of each message of a stream input to fn until the process
+// is interrupted. fn reports whether the outputs of the records were delivered; messages
+// whose outputs were not are left unacknowledged where the stream allows, so that they are
+// received again.
+func consumeStream(uri string, opts inputOptions, fn func(member) bool) error {
+	switch {
+	case isAMQPURI(uri):
+		return consumeAMQP(uri, opts, fn)
+	case isPubSubURI(uri):
+		return consumePubSub(uri, opts, fn)
+	case isSQSURI(uri):
+		return consumeSQS(uri, opts, fn)
+	case isKafkaURI(uri):
+		return consumeKafka(uri, opts, fn)
+	}
+	return subscribeMQTT(uri, opts, fn)
+}
+
+// readSource reads records from the source addressed by an input URI, defaulting to stdin.
+// Archives yield one member per matching file, other sources a single unnamed member.
+func readSource(uri string, opts inputOptions) ([]member, error) {
+	var records []Input
+	var err error
+
+	switch {
+	case uri == "" || uri == "-":
+		records, err = readInput(os.Stdin, opts)
+	case strings.HasPrefix(uri, "sqlite:"):
+		records, err = readSQLite(uri)
+	case archiveKind(uri) != "":
+		return readArchive(uri, opts.memberGlob, opts)
+	default:
+		var f *os.File
+		f, err = os.Open(uri)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		records, err = readInput(f, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []member{{records: records}}, nil
+}
+
+// readInput decodes the input stream into records according to the input format
+func readInput(r io.Reader, opts inputOptions) ([]Input, error) {
+	if err := activeChaos.decodeError(); err != nil {
+		return nil, err
+	}
+	switch opts.format {
+	case "", "json":
+		if opts.decoder != nil {
+			inputJSON, err := opts.decoder.Decode(r)
+			if err != nil {
+				return nil, err
+			}
+			return []Input{inputJSON}, nil
+		}
+		var inputJSON Input
+		if err := json.NewDecoder(r).Decode(&inputJSON); err != nil {
+			return nil, err
+		}
+		return []Input{inputJSON}, nil
+	case "ejson":
+		var inputJSON Input
+		if err := json.NewDecoder(r).Decode(&inputJSON); err != nil {
+			return nil, err
+		}
+		return []Input{unwrapExtendedJSONDocument(inputJSON)}, nil
+	case "bson":
+		return readBSON(r)
+	case "xlsx":
+		return readXLSX(r, opts.sheet, opts.headerRow)
+	case "ics":
+		return readICS(r, opts.icsExpand)
+	case "senml":
+		return readSenML(r)
+	case "yaml":
+		return readYAML(r)
+	case "toml":
+		return readTOML(r)
+	case "xml":
+		return readXML(r)
+	case "cbor":
+		return readCBOR(r)
+	}
+
+	parse, ok := lineParsers[opts.format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input format %q", opts.format)
+	}
+	return readLines(r, parse)
+}
+
+// readLines parses every non-blank line of r into a record, skipping malformed lines
+func readLines(r io.Reader, parse lineParser) ([]Input, error) {
+	var records []Input
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Skip blank lines
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		record, err := parse(line)
+		if err != nil {
+			warn("malformed-line", "Skipping malformed line %d: %v", lineNo, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestPassthroughRule(t *testing.T) {
+	rs, err := transform.ParseRules([]byte(`{"rules": [{"field": "payload", "action": "passthrough"}, {"field": "meta.blob", "action": "passthrough"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := inputOptions{format: "json", decoder: &transform.Transformer{Rules: rs}}
+	records, err := readInput(strings.NewReader(`{"id": " 1 ", "payload": {"t": "2024-01-01T00:00:00Z"}, "meta": {"n": " 2 ", "blob": [1, "2"]}, "other": {"blob": [3]}}`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Input{
+		"id":      " 1 ",
+		"payload": json.RawMessage(`{"t": "2024-01-01T00:00:00Z"}`),
+		"meta":    map[string]interface{}{"n": " 2 ", "blob": json.RawMessage(`[1, "2"]`)},
+		"other":   map[string]interface{}{"blob": []interface{}{3.0}},
+	}
+	if !reflect.DeepEqual(records[0], want) {
+		t.Errorf("readInput = %#v, want %#v", records[0], want)
+	}
+
+	transformed, err := transform.Transform(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := mergeOutput(transformed)
+	if got := string(output["payload"].(json.RawMessage)); got != `{"t": "2024-01-01T00:00:00Z"}` {
+		t.Errorf("passthrough payload = %s, want it unchanged", got)
+	}
+	if got := string(output["blob"].(json.RawMessage)); got != `[1, "2"]` {
+		t.Errorf("passthrough blob = %s, want it unchanged", got)
+	}
+
+	// Paths under a value that is not an object decode it as usual
+	records, err = readInput(strings.NewReader(`{"meta": "flat"}`), opts)
+	if err != nil || records[0]["meta"] != "flat" {
+		t.Errorf("readInput = %v, %v, want meta decoded as a string", records, err)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaWriteTimeout bounds the wait for the brokers to acknowledge a batch of messages
+const kafkaWriteTimeout = 30 * time.Second
+
+// kafkaCompressions maps the compression names of Kafka URIs to their codecs
+var kafkaCompressions = map[string]kafka.Compression{
+	"gzip":   kafka.Gzip,
+	"snappy": kafka.Snappy,
+	"lz4":    kafka.Lz4,
+	"zstd":   kafka.Zstd,
+}
+
+// kafkaParams holds the settings given by a Kafka URI
+type kafkaParams struct {
+	brokers []string
+	topic   string
+	// group is the consumer group whose committed offsets a consumer resumes from
+	group string
+	// start is where a group without committed offsets starts: kafka.FirstOffset or
+	// kafka.LastOffset
+	start int64
+	// format overrides the input or output format of message values
+	format      string
+	key         string
+	batch       int
+	compression kafka.Compression
+}
+
+// isKafkaURI reports whether a URI addresses a Kafka topic
+func isKafkaURI(uri string) bool {
+	return strings.HasPrefix(uri, "kafka://")
+}
+
+// parseKafkaURI parses a URI like
+// "kafka://broker1:9092,broker2:9092/orders?group=transform&start=earliest&format=csv" or
+// "kafka://broker:9092/clean-orders?key={id}&batch=100&compression=snappy"
+func parseKafkaURI(uri string) (kafkaParams, error) {
+	base, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, "kafka://"), "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return kafkaParams{}, fmt.Errorf("invalid Kafka URI %q: %v", uri, err)
+	}
+	hosts, topic, _ := strings.Cut(base, "/")
+	if hosts == "" || topic == "" || strings.Contains(topic, "/") {
+		return kafkaParams{}, fmt.Errorf("invalid Kafka URI %q: want kafka://broker:port,.../topic", uri)
+	}
+
+	p := kafkaParams{
+		brokers: strings.Split(hosts, ","),
+		topic:   topic,
+		group:   query.Get("group"),
+		start:   kafka.FirstOffset,
+		format:  query.Get("format"),
+		key:     query.Get("key"),
+		batch:   100,
+	}
+	switch start := query.Get("start"); start {
+	case "", "earliest":
+	case "latest":
+		p.start = kafka.LastOffset
+	default:
+		return kafkaParams{}, fmt.Errorf("invalid Kafka start %q (want earliest or latest)", start)
+	}
+	if v := query.Get("batch"); v != "" {
+		if p.batch, err = strconv.Atoi(v); err != nil || p.batch < 1 {
+			return kafkaParams{}, fmt.Errorf("invalid Kafka batch %q", v)
+		}
+	}
+	if v := query.Get("compression"); v != "" {
+		var ok bool
+		if p.compression, ok = kafkaCompressions[v]; !ok {
+			return kafkaParams{}, fmt.Errorf("invalid Kafka compression %q (want gzip, snappy, lz4 or zstd)", v)
+		}
+	}
+	return p, nil
+}
+
+// kafkaReader is the part of a Kafka reader used by the Kafka consumer
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// consumeKafka consumes the topic of a Kafka URI as a member of its consumer group and
+// passes the records decoded from each message value to fn until the process is interrupted
+func consumeKafka(uri string, opts inputOptions, fn func(member) bool) error {
+	p, err := parseKafkaURI(uri)
+	if err != nil {
+		return err
+	}
+	if p.group == "" {
+		return fmt.Errorf("Kafka URI %q has no consumer group", uri)
+	}
+	if p.format != "" {
+		opts.format = p.format
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     p.brokers,
+		GroupID:     p.group,
+		Topic:       p.topic,
+		StartOffset: p.start,
+	})
+	defer r.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return readKafka(ctx, r, p.topic, opts, fn)
+}
+
+// readKafka passes the records of each message fetched from r to fn until ctx is done.
+// Offsets are committed once fn returns, so that a restarted consumer resumes after the last
+// message whose output was written. Messages that fail to decode are skipped with a warning,
+// as Kafka has no dead-letter queue to move them to. A message whose outputs fn reports not
+// delivered stops the consumer without committing it, as committing a later offset would
+// skip it too. With a latency budget, the records of a message are due by the budget after
+// the message's timestamp.
+func readKafka(ctx context.Context, r kafkaReader, topic string, opts inputOptions, fn func(member) bool) error {
+	for {
+		msg, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("fetching from Kafka topic %q: %v", topic, err)
+		}
+		records, err := readInput(bytes.NewReader(msg.Value), opts)
+		if err != nil {
+			warn("rejected-message", "Skipping message at offset %d of %q partition %d: %v", msg.Offset, topic, msg.Partition, err)
+		} else {
+			m := member{records: records}
+			if opts.latencyBudget > 0 && !msg.Time.IsZero() {
+				m.deadline = msg.Time.Add(opts.latencyBudget)
+			}
+			if !fn(m) {
+				return fmt.Errorf("not committing offset %d of %q partition %d: %w", msg.Offset, topic, msg.Partition, errOutputSkipped)
+			}
+		}
+		// Commit even if interrupted, as the output of the message is written
+		if err := r.CommitMessages(context.Background(), msg); err != nil {
+			return fmt.Errorf("committing offset %d of %q partition %d: %v", msg.Offset, topic, msg.Partition, err)
+		}
+	}
+}
+
+// kafkaWriter is the part of a Kafka writer used by the Kafka sink
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSink produces each output as a message to a topic, keyed by a template of the
+// record's fields so that records with the same key keep their order on one partition.
+// Messages are produced in batches of up to batch messages and after each source member or
+// stream message.
+type kafkaSink struct {
+	writer  kafkaWriter
+	key     string
+	batch   int
+	format  string
+	pending []kafka.Message
+}
+
+// openKafkaSink opens a sink producing to the topic of a Kafka URI, waiting for every
+// in-sync replica to acknowledge each batch
+func openKafkaSink(uri, format string) (*kafkaSink, error) {
+	p, err := parseKafkaURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if p.format != "" {
+		format = p.format
+	}
+	switch format {
+	case "", "json", "ejson", "ndjson", "yaml", "toml", "msgpack", "cbor", "bson":
+	default:
+		return nil, fmt.Errorf("unsupported Kafka output format %q", format)
+	}
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(p.brokers...),
+		Topic:        p.topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		Compression:  p.compression,
+		BatchSize:    p.batch,
+	}
+	return &kafkaSink{writer: w, key: p.key, batch: p.batch, format: format}, nil
+}
+
+// Write queues the encoded output and produces the queue once it holds a full batch
+func (s *kafkaSink) Write(output Output) error {
+	var key []byte
+	if s.key != "" {
+		k, err := expandTemplate(s.key, mergeOutput(output))
+		if err != nil {
+			warn("skipped-record", "Skipping record: %v", err)
+			return nil
+		}
+		key = []byte(k)
+	}
+	data, err := encodeOutput(output, s.format)
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending, kafka.Message{Key: key, Value: messageBody(data, s.format)})
+	if len(s.pending) >= s.batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush produces the queued messages and waits for their acknowledgement
+func (s *kafkaSink) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancel()
+	if err := s.writer.WriteMessages(ctx, s.pending...); err != nil {
+		var writeErrs kafka.WriteErrors
+		if errors.As(err, &writeErrs) {
+			return fmt.Errorf("producing %d of %d messages failed: %v", writeErrs.Count(), len(s.pending), err)
+		}
+		return err
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// Close produces any queued messages and closes the writer
+func (s *kafkaSink) Close() error {
+	err := s.Flush()
+	if cerr := s.writer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
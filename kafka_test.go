@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafka serves messages to a consumer and records the messages committed and produced
+type fakeKafka struct {
+	messages  []kafka.Message
+	committed []int64
+	produced  []kafka.Message
+}
+
+func (f *fakeKafka) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(f.messages) == 0 {
+		return kafka.Message{}, errors.New("no more messages")
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	return msg, nil
+}
+
+func (f *fakeKafka) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		f.committed = append(f.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (f *fakeKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.produced = append(f.produced, msgs...)
+	return nil
+}
+
+func (f *fakeKafka) Close() error {
+	return nil
+}
+
+func TestParseKafkaURI(t *testing.T) {
+	p, err := parseKafkaURI("kafka://b1:9092,b2:9092/orders?group=g&start=latest&format=csv&key={id}&batch=10&compression=zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := kafkaParams{
+		brokers:     []string{"b1:9092", "b2:9092"},
+		topic:       "orders",
+		group:       "g",
+		start:       kafka.LastOffset,
+		format:      "csv",
+		key:         "{id}",
+		batch:       10,
+		compression: kafka.Zstd,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("parseKafkaURI = %+v, want %+v", p, want)
+	}
+	for _, uri := range []string{
+		"kafka://broker:9092",
+		"kafka:///orders",
+		"kafka://broker/a/b",
+		"kafka://broker/orders?start=now",
+		"kafka://broker/orders?batch=0",
+		"kafka://broker/orders?compression=brotli",
+		"kafka://broker/orders?group=%zz",
+	} {
+		if _, err := parseKafkaURI(uri); err == nil {
+			t.Errorf("parseKafkaURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestReadKafka(t *testing.T) {
+	fake := &fakeKafka{messages: []kafka.Message{
+		{Offset: 1, Value: []byte(`{"id": "1"}`)},
+		{Offset: 2, Value: []byte(`{"id": `)},
+		{Offset: 3, Value: []byte(`{"id": "3"}`)},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []Input
+	err := readKafka(ctx, fake, "orders", inputOptions{}, func(m member) bool {
+		got = append(got, m.records...)
+		if len(fake.messages) == 0 {
+			cancel()
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Input{{"id": "1"}, {"id": "3"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+	// Undecodable messages are committed too, so that they are not fetched again
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(fake.committed, want) {
+		t.Errorf("committed = %v, want %v", fake.committed, want)
+	}
+}
+
+func TestReadKafkaUndelivered(t *testing.T) {
+	fake := &fakeKafka{messages: []kafka.Message{
+		{Offset: 1, Value: []byte(`{"id": "1"}`)},
+		{Offset: 2, Value: []byte(`{"id": "2"}`)},
+		{Offset: 3, Value: []byte(`{"id": "3"}`)},
+	}}
+	err := readKafka(context.Background(), fake, "orders", inputOptions{}, func(m member) bool {
+		return m.records[0]["id"] != "2"
+	})
+	if !errors.Is(err, errOutputSkipped) {
+		t.Errorf("readKafka = %v, want errOutputSkipped", err)
+	}
+	// The consumer stops before the undelivered message, so that a restart fetches it again
+	if want := []int64{1}; !reflect.DeepEqual(fake.committed, want) {
+		t.Errorf("committed = %v, want %v", fake.committed, want)
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	fake := &fakeKafka{}
+	s := &kafkaSink{writer: fake, key: "{id}", batch: 2, format: "ndjson"}
+	for _, output := range []Output{{{"id": "1"}}, {{"name": "no id"}}, {{"id": "2"}}, {{"id": "3"}}} {
+		if err := s.Write(output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(fake.produced) != 2 {
+		t.Fatalf("produced %d messages before the batch filled, want 2", len(fake.produced))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, msg := range fake.produced {
+		keys = append(keys, string(msg.Key))
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	if got := string(fake.produced[0].Value); got != `[{"id":"1"}]` {
+		t.Errorf("value = %s", got)
+	}
+}
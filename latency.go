@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// latencyHeader is the request header carrying the latency budget left to a serve request,
+// as a duration such as "250ms" or a number of milliseconds
+const latencyHeader = "X-Request-Timeout"
+
+// errLatencyExpired is the error of work whose latency budget expired before it was done
+var errLatencyExpired = errors.New("latency budget expired")
+
+// expiredRequests counts the serve requests failed as their latency budget expired, as
+// published on /debug/vars
+var expiredRequests = expvar.NewInt("expired_requests")
+
+// parseLatencyBudget parses the latency budget of a request header
+func parseLatencyBudget(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		ms, merr := strconv.ParseInt(s, 10, 64)
+		if merr != nil {
+			return 0, fmt.Errorf("invalid %s %q: want a duration such as 250ms or milliseconds", latencyHeader, s)
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: want a positive duration", latencyHeader, s)
+	}
+	return d, nil
+}
+
+// requestContext returns the context of a request, bounded by the latency budget of its
+// header if set
+func requestContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	s := r.Header.Get(latencyHeader)
+	if s == "" {
+		return r.Context(), func() {}, nil
+	}
+	d, err := parseLatencyBudget(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), d)
+	return ctx, cancel, nil
+}
+
+// budgetExpired reports whether the latency budget of ctx expired while its parent, such
+// as the context of the client connection, is still live
+func budgetExpired(ctx, parent context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
+
+// expired reports whether the latency budget of a stream message expired. Members without
+// a deadline never expire.
+func (m member) expired() bool {
+	return !m.deadline.IsZero() && time.Now().After(m.deadline)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestParseLatencyBudget(t *testing.T) {
+	for s, want := range map[string]time.Duration{"250ms": 250 * time.Millisecond, "1.5s": 1500 * time.Millisecond, "40": 40 * time.Millisecond} {
+		if got, err := parseLatencyBudget(s); err != nil || got != want {
+			t.Errorf("parseLatencyBudget(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"soon", "0", "-5ms"} {
+		if _, err := parseLatencyBudget(s); err == nil {
+			t.Errorf("parseLatencyBudget(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestReadKafkaDeadline(t *testing.T) {
+	sent := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fake := &fakeKafka{messages: []kafka.Message{
+		{Offset: 1, Time: sent, Value: []byte(`{"id": "1"}`)},
+		{Offset: 2, Value: []byte(`{"id": "2"}`)},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	var deadlines []time.Time
+	err := readKafka(ctx, fake, "orders", inputOptions{latencyBudget: time.Second}, func(m member) bool {
+		deadlines = append(deadlines, m.deadline)
+		if len(fake.messages) == 0 {
+			cancel()
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Messages without a timestamp have no deadline
+	if len(deadlines) != 2 || !deadlines[0].Equal(sent.Add(time.Second)) || !deadlines[1].IsZero() {
+		t.Errorf("deadlines = %v, want %v and none", deadlines, sent.Add(time.Second))
+	}
+	if !(member{deadline: sent}).expired() || (member{}).expired() {
+		t.Error("expired() does not follow the deadline")
+	}
+}
+
+func TestTransformHandlerExpired(t *testing.T) {
+	a := newAdmission(1, 1)
+	server := httptest.NewServer(newTransformHandler(&transform.Transformer{}, "", 1<<10, a, nil))
+	defer server.Close()
+	post := func(timeout string) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"a": {"S": "1"}}`))
+		if timeout != "" {
+			req.Header.Set(latencyHeader, timeout)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := post("5s"); status != http.StatusOK {
+		t.Errorf("request within its budget = %d, want 200", status)
+	}
+	if status := post("soon"); status != http.StatusBadRequest {
+		t.Errorf("request with an invalid budget = %d, want 400", status)
+	}
+
+	// A request queued behind a busy slot for longer than its budget fails fast
+	free, err := a.admit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer free()
+	before := expiredRequests.Value()
+	if status := post("20ms"); status != http.StatusGatewayTimeout {
+		t.Errorf("expired request = %d, want 504", status)
+	}
+	if expired := expiredRequests.Value() - before; expired != 1 {
+		t.Errorf("counted %d expired requests, want 1", expired)
+	}
+}
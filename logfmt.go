@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseLogfmt parses a logfmt line of key=value pairs into a record
+func parseLogfmt(line string) (Input, error) {
+	record := make(Input)
+
+	s := line
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+
+		// Read the key up to '=' or the next space
+		i := strings.IndexAny(s, "= \t")
+		if i == 0 {
+			return nil, errors.New("missing key")
+		}
+		if i < 0 {
+			i = len(s)
+		}
+		key := s[:i]
+		s = s[i:]
+
+		// A bare key without a value is a boolean flag
+		if !strings.HasPrefix(s, "=") {
+			record[key] = "true"
+			continue
+		}
+		s = s[1:]
+
+		// Read the value, which is either quoted or runs to the next space
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := closingQuote(s)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated value for key %q", key)
+			}
+			unquoted, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for key %q: %v", key, err)
+			}
+			value = unquoted
+			s = s[end+1:]
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		record[key] = value
+	}
+
+	if len(record) == 0 {
+		return nil, errors.New("no key/value pairs")
+	}
+	return record, nil
+}
+
+// closingQuote returns the index of the quote closing the quoted string at the start of s
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
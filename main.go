@@ -1,170 +1,573 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
 )
 
 // Input represents the input JSON structure
-type Input map[string]interface{}
+type Input = transform.Input
 
 // Output represents the desired output JSON structure
-type Output []map[string]interface{}
+type Output = transform.Output
 
 func main() {
-	// Read input JSON from stdin
-	var inputJSON Input
-	err := json.NewDecoder(os.Stdin).Decode(&inputJSON)
-	if err != nil {
-		log.Fatalf("error decoding input JSON: %v", err)
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "state":
+			runStateCommand(os.Args[2:])
+			return
+		case "codegen":
+			runCodegenCommand(os.Args[2:])
+			return
+		case "fmt":
+			runFmtCommand(os.Args[2:])
+			return
+		case "serve":
+			runServeCommand(os.Args[2:])
+			return
+		case "rules":
+			runRulesCommand(os.Args[2:])
+			return
+		case "init":
+			runInitCommand(os.Args[2:])
+			return
+		case "selftest":
+			runSelftestCommand(os.Args[2:])
+			return
+		}
 	}
 
-	// Transform input JSON to desired output format
-	output := transformInput(inputJSON)
+	var opts inputOptions
+	inputURI := flag.String("input", "", "input file, .zip/.tar/.tar.gz archive, sqlite:file.db?table=name, mqtt://broker/topic, amqp://broker/vhost?queue=name, pubsub://project/subscription, sqs://sqs.region.amazonaws.com/account/queue?visibility=30 or kafka://broker:9092/topic?group=name URI (default stdin)")
+	outputURI := flag.String("output", "", "output .zip/.tar/.tar.gz archive mirroring the input members, sqlite:file.db?table=name&batch=1000, s3://bucket/prefix?batch=1000, bigquery://project/dataset/table?staging=gs://bucket/prefix, snowflake://account/db/schema/table?staging=s3://bucket/prefix&stage=name, redis://host/0?key=user:{id}, amqp://broker/vhost?exchange=name&routing_key=orders.{type}, pubsub://project/topic?ordering_key={id}, mqtt://broker/devices/{id}/commands?qos=1, kafka://broker:9092/topic?key={id}&compression=snappy or file:out.ndjson?max_size=100MB&max_age=24h&compress=true&keep=7 URI (default stdout)")
+	flag.StringVar(&opts.format, "input-format", "json", "input format: json, ejson (MongoDB Extended JSON), bson, syslog, logfmt, combined (Apache/Nginx access log), xlsx, ics (iCalendar events), senml, yaml (one record per document), toml, xml (one record per root element, attributes as @name fields) or cbor (one record per data item)")
+	presetName := flag.String("preset", "", "preset applied to each record before transformation: "+presetNames())
+	geoJSONMode := flag.String("geojson", "", "GeoJSON mode keeping geometries intact: geojson (emit GeoJSON) or wkt (emit flat records with WKT geometry)")
+	outputFormat := flag.String("output-format", "json", "output format: json, ndjson, ejson (canonical MongoDB Extended JSON numbers and $date timestamps), yaml (one document per record, keys sorted), toml (one [[records]] table per record, without nulls), msgpack (one MessagePack array per record, keys sorted), cbor (a sequence of one CBOR array per record, keys sorted), bson (one BSON document per record as mongodump writes them, timestamps as dates and a hex _id as an ObjectID), csv (a row per record with dot-notation columns for nested keys, written to stdout once the run ends), parquet (a file with a schema inferred from every record, written to stdout once the run ends) or avro (an object container file written to stdout, with the -avro-schema or one inferred from every record once the run ends)")
+	csvDelimiterFlag := flag.String("csv-delimiter", ",", "character separating the cells of -output-format csv, or tab")
+	avroSchemaFile := flag.String("avro-schema", "", "Avro record schema file -output-format avro writes records with, leaving out keys it lacks; fields may name the key they hold in a \"key\" attribute (default: inferred from every record)")
+	flag.StringVar(&avroSchemaOut, "avro-schema-out", "", "file to write the Avro schema of -output-format avro to, such as for registering it")
+	emit := flag.String("emit", "transformed", "records to write: transformed, both to write {\"original\": ..., \"transformed\": ...} pairs of each input record and its output, or events to write one {record, path, action, old, new} event per change instead")
+	order := flag.String("order", "asc", "order of the top-level output fields: asc or desc by key, or none for the unspecified order of earlier versions")
+	strict := flag.Bool("strict", false, "fail with a report of every skipped field, invalid number and malformed timestamp instead of warning; records with problems are not written")
+	warnAsError := flag.String("warn-as-error", "", "comma-separated warning patterns failing the run, as class or class:path globs such as unsupported-type:payload.* (classes include unsupported-type, invalid-number, invalid-base64, rule, malformed-line, skipped-record, sink-unavailable and expired-record); transformer warnings fail like -strict problems")
+	suppressWarning := flag.String("suppress-warning", "", "comma-separated warning patterns, as for -warn-as-error, of warnings not to print")
+	rawBinary := flag.Bool("raw-binary", false, "emit DynamoDB B and BS values as binary where the output has a binary type (ejson $binary, sqlite BLOB, msgpack bin, cbor byte string, bson binary) instead of as base64 strings")
+	flag.StringVar(&opts.sheet, "sheet", "", "xlsx sheet to read, by name or 1-based index (default first sheet)")
+	flag.IntVar(&opts.headerRow, "header-row", 1, "xlsx row number holding the column names")
+	flag.IntVar(&opts.icsExpand, "ics-expand", 0, "expand iCalendar RRULEs into up to this many occurrence records per event (0 keeps the rule)")
+	flag.StringVar(&opts.memberGlob, "member-glob", "*", "glob selecting archive members to transform, matched against the base name unless it contains a slash")
+	deltaState := flag.String("delta-state", "", "state file of content hashes (.bolt for BoltDB, else JSON); when set only records whose output changed since the last run are written")
+	deltaKey := flag.String("delta-key", "id", "record field identifying records for -delta-state")
+	coerce := flag.String("coerce", "", "comma-separated coercions tried in order on every string value, such as timestamp,number,boolean,string (default: RFC3339 timestamps, and integers in lists)")
+	compat := flag.String("compat", "", "compatibility level whose default coercions apply where -coerce and the rules set none, such as v1, so that upgrades keep outputs the same (default: the latest, "+transform.LatestCompat+")")
+	locale := flag.String("locale", "", "BCP 47 locale, such as fr-FR, whose month and weekday names, number separators and digits the timestamp and number coercions also parse, as in \"3 mars 2024\" or \"1 234,5\"")
+	stdinFilter := flag.Bool("stdin-filter", false, "editor filter mode: format the JSON buffer read from stdin like the fmt subcommand to stdout, reporting errors as a single <stdin>:line:col: message")
+	sparse := flag.String("sparse", "", "comma-separated JSON Pointers of the only values to transform in a JSON document, copying all other bytes unchanged to stdout")
+	ndjson := flag.Bool("ndjson", false, "NDJSON streaming mode: transform each newline-delimited JSON document read from stdin on its own, writing one line of output per document to stdout and skipping bad lines with a warning")
+	stream := flag.Bool("stream", false, "decode a large JSON document from a file or stdin one top-level entry at a time, writing each transformed entry to stdout as it is read instead of holding the whole document in memory; entries keep their input order rather than being sorted by key, so not with -order")
+	external := flag.Bool("external", false, "Terraform/Pulumi external data source mode: transform the query object read from stdin into a flat JSON object of strings on stdout")
+	rulesPath := flag.String("rules", "", "JSON rules file of actions applied to each transformed record")
+	hashField := flag.String("hash-field", "", "field receiving the SHA-256 of each output record's canonical JSON, for downstream dedup and change detection")
+	schemaField := flag.String("schema-field", "", "field receiving a short fingerprint of each output record's key paths and types, for grouping records by shape")
+	addMeta := flag.Bool("add-meta", false, "add record_index, source and ingested_at provenance fields to each output record")
+	metaPrefix := flag.String("meta-prefix", "_", "prefix of the fields added by -add-meta")
+	manifestPath := flag.String("manifest", "", "file receiving a JSON manifest of the run's inputs, outputs, record counts, hashes, duration and errors")
+	coverageReport := flag.String("coverage-report", "", "file receiving a JSON report of how many records each rule matched and of the input field paths no rule handles, for pruning stale rules and finding unhandled fields")
+	taskJSON := flag.String("task-json", "", "JSON task spec file, or env:NAME for an environment variable, giving input, output, input_format, output_format, profile, rules, manifest, coverage_report, coerce, compat, locale, order, emit, warn_as_error, suppress_warning, strict, workers and limits (max_records, timeout), as generated by the rules generate subcommand; prints the run manifest as a result line to stdout for Airflow XCom or Dagster")
+	workers := flag.Int("workers", 1, "number of records transformed in parallel, for large batches on multicore machines; output keeps the input order")
+	breakerThreshold := flag.Float64("breaker-threshold", 0, "share of the last -breaker-window writes to the output whose failure opens its circuit breaker, such as 0.5 (0 for no breaker); records whose write fails, or that arrive while the breaker is open, are skipped with a sink-unavailable warning instead of ending the run")
+	breakerWindow := flag.Int("breaker-window", 20, "number of the last writes to the output the -breaker-threshold share applies to")
+	breakerCooldown := flag.Duration("breaker-cooldown", 30*time.Second, "time an open circuit breaker waits before letting a probe write through, closing if it succeeds")
+	flag.DurationVar(&opts.latencyBudget, "latency-budget", 0, "end-to-end latency budget of Kafka input records from their record timestamp; records whose budget expires before they are transformed or written are dropped with an expired-record warning (0 for no budget)")
+	dataFD := flag.Int("data-fd", 1, "file descriptor receiving stdout output; diagnostics always go to stderr")
+	buildChaos := chaosFlags()
+	flag.Usage = usage
+	flag.Parse()
 
-	// Print output JSON to stdout
-	printOutput(output)
-}
+	var task *taskSpec
+	if *taskJSON != "" {
+		var err error
+		if task, err = loadTaskSpec(*taskJSON); err != nil {
+			log.Fatalf("error loading task: %v", err)
+		}
+		// Flags given on the command line override the task spec
+		given := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		for name, v := range task.flags() {
+			if !given[name] {
+				flag.Set(name, v)
+			}
+		}
+	}
+	if *manifestPath != "" || task != nil {
+		activeManifest = newRunManifest(*manifestPath, *outputURI)
+	}
+	// checkTimeLimit fails a task that exceeded its time limit. It is called from the main
+	// goroutine between records, so that the manifest is only ever written from there.
+	checkTimeLimit := func() {}
+	if task != nil {
+		// The result goes to stdout even when -data-fd moves the data elsewhere, so the data
+		// must go elsewhere for the result line to be parsed
+		if (*outputURI == "" || *outputURI == "-") && *dataFD == 1 {
+			fatalf("error: a task writing its data to stdout needs -data-fd to keep it apart from the result line")
+		}
+		activeManifest.result = os.Stdout
+		if timeout, _ := task.timeout(); timeout > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			checkTimeLimit = func() {
+				if ctx.Err() != nil {
+					fatalf("error: task exceeded its time limit of %s", timeout)
+				}
+			}
+		}
+	}
+	if err := separateDataOutput(*dataFD); err != nil {
+		fatalf("error: %v", err)
+	}
 
-// transformInput transforms the input JSON to the desired output format
-func transformInput(input Input) Output {
-	var output Output
+	if opts.latencyBudget < 0 {
+		fatalf("error: -latency-budget must not be negative")
+	}
+	if *breakerThreshold < 0 || *breakerThreshold > 1 || *breakerWindow < 1 {
+		fatalf("error: -breaker-threshold must be between 0 and 1, and -breaker-window positive")
+	}
+	if err := validateEmitMode(*emit); err != nil {
+		fatalf("error: %v", err)
+	}
+	if *emit == "events" && *geoJSONMode != "" {
+		fatalf("error: -emit events does not support -geojson")
+	}
+	if *geoJSONMode != "" && *geoJSONMode != "geojson" && *geoJSONMode != "wkt" {
+		fatalf("error: unsupported GeoJSON mode %q", *geoJSONMode)
+	}
+	var err error
+	if warningRules, err = parseWarningRules(*warnAsError, *suppressWarning); err != nil {
+		fatalf("error: %v", err)
+	}
+	if csvDelimiter, err = parseCSVDelimiter(*csvDelimiterFlag); err != nil {
+		fatalf("error: %v", err)
+	}
+	if avroSchema, err = loadAvroSchema(*avroSchemaFile); err != nil {
+		fatalf("error: %v", err)
+	}
+	if activeChaos, err = buildChaos(); err != nil {
+		fatalf("error: %v", err)
+	} else if activeChaos != nil {
+		fmt.Fprintf(os.Stderr, "Injecting faults: %s\n", activeChaos)
+	}
+	t := transform.Transformer{RawBinary: *rawBinary, Strict: *strict, Order: *order, Compat: *compat, Warnings: warningRules, OnWarning: printWarning, TypedTimestamps: *outputFormat == "ejson"}
+	if err := transform.ValidateOrder(*order); err != nil {
+		fatalf("error: %v", err)
+	}
+	if err := transform.ValidateCompat(*compat); err != nil {
+		fatalf("error: %v", err)
+	}
+	if *coerce != "" {
+		var err error
+		if t.Coercions, err = transform.ParseCoercionOrder(*coerce); err != nil {
+			fatalf("error: %v", err)
+		}
+	}
+	if *locale != "" {
+		var err error
+		if t.Locale, err = transform.ParseLocale(*locale); err != nil {
+			fatalf("error: %v", err)
+		}
+	}
+	if *rulesPath != "" {
+		var err error
+		if t.Rules, err = transform.LoadRules(*rulesPath); err != nil {
+			fatalf("error loading rules: %v", err)
+		}
+		opts.decoder = &t
+	}
+	if *coverageReport != "" {
+		t.Coverage = &transform.Coverage{}
+	}
+
+	if *stdinFilter {
+		// Format like the fmt subcommand, keeping values and nesting as they are and
+		// coercing values alike at every depth
+		t.KeepScalars = true
+		t.KeepNesting = true
+		if t.Coercions == nil {
+			t.Coercions = []string{"timestamp"}
+		}
+		if err := runStdinFilter(&t, opts, os.Stdin, dataOutput); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *external {
+		if *inputURI != "" || *outputURI != "" || opts.format != "json" {
+			fatalf("error: -external reads a JSON query from stdin and writes to stdout")
+		}
+		if err := runExternal(&t, *presetName, os.Stdin, dataOutput); err != nil {
+			fatalf("error: %v", err)
+		}
+		return
+	}
+	if *ndjson {
+		if *inputURI != "" || *outputURI != "" || opts.format != "json" || (*outputFormat != "json" && *outputFormat != "ndjson") {
+			fatalf("error: -ndjson reads JSON lines from stdin and writes JSON lines to stdout")
+		}
+		if *emit != "transformed" || *geoJSONMode != "" || *hashField != "" || *schemaField != "" || *addMeta || activeManifest != nil || *deltaState != "" || *breakerThreshold > 0 {
+			fatalf("error: -ndjson does not support -emit, -geojson, -hash-field, -schema-field, -add-meta, -manifest, -delta-state or -breaker-threshold")
+		}
+		skipped, err := runNDJSON(&t, *presetName, *workers, os.Stdin, dataOutput)
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Skipped %d of the input lines\n", skipped)
+		}
+		var strictErr *transform.StrictError
+		if errors.As(err, &strictErr) {
+			fatalf("error: found %d problems:\n  %s", len(strictErr.Problems), strings.Join(strictErr.Problems, "\n  "))
+		} else if err != nil {
+			fatalf("error reading NDJSON input: %v", err)
+		}
+		if *coverageReport != "" {
+			if err := writeCoverageReport(*coverageReport, t.Coverage.Report(t.Rules)); err != nil {
+				fatalf("error writing coverage report: %v", err)
+			}
+		}
+		return
+	}
+	if *stream {
+		if opts.format != "json" || *outputFormat != "json" || *outputURI != "" || archiveKind(*inputURI) != "" || isStreamURI(*inputURI) {
+			fatalf("error: -stream transforms a JSON file or stdin to JSON on stdout")
+		}
+		if *rulesPath != "" || *presetName != "" || *geoJSONMode != "" || *emit != "transformed" {
+			fatalf("error: -stream does not support -rules, -preset, -geojson or -emit, which need the whole record")
+		}
+		if *hashField != "" || *schemaField != "" || *addMeta || *deltaState != "" || *coverageReport != "" || activeManifest != nil || *breakerThreshold > 0 {
+			fatalf("error: -stream does not support -hash-field, -schema-field, -add-meta, -delta-state, -coverage-report, -manifest or -breaker-threshold")
+		}
+		if *order != "asc" {
+			// Entries are written as they are read, so they cannot be sorted
+			fatalf("error: -stream does not support -order, as entries keep their input order")
+		}
+		if err := runStream(&t, *inputURI); err != nil {
+			fatalf("error transforming stream: %v", err)
+		}
+		return
+	}
+	if *sparse != "" {
+		if opts.format != "json" || *outputURI != "" || archiveKind(*inputURI) != "" || isStreamURI(*inputURI) {
+			fatalf("error: -sparse transforms a JSON file or stdin to stdout")
+		}
+		if err := runSparse(&t, *inputURI, strings.Split(*sparse, ",")); err != nil {
+			fatalf("error transforming sparse input: %v", err)
+		}
+		return
+	}
 
-	// Iterate through input keys and transform each field
-	for key, value := range input {
-		// Skip fields with empty keys
-		if key == "" {
-			continue
+	// Read file, archive and database sources before opening the output, so that an input
+	// that fails to decode leaves the output untouched and an output may replace its input
+	var members []member
+	if !isStreamURI(*inputURI) {
+		var err error
+		if members, err = readSource(*inputURI, opts); err != nil {
+			fatalf("error decoding %s input: %v", opts.format, err)
 		}
+	}
 
-		// Sanitize key by trimming leading and trailing whitespace
-		key = strings.TrimSpace(key)
+	out, err := openSink(*outputURI, *outputFormat)
+	if err != nil {
+		fatalf("error opening output: %v", err)
+	}
+	if activeChaos != nil {
+		out = &chaosSink{next: out, chaos: activeChaos}
+	}
+	if activeManifest != nil {
+		out = &manifestSink{next: out, manifest: activeManifest}
+	}
+	// The breaker wraps the manifest so that skipped records are not counted as written
+	var outputBreaker *breaker
+	if *breakerThreshold > 0 {
+		outputBreaker = newBreaker(*breakerThreshold, *breakerWindow, *breakerCooldown)
+		uri := *outputURI
+		if uri == "" || uri == "-" {
+			uri = "stdout"
+		}
+		out = &breakerSink{next: out, breaker: outputBreaker, uri: uri}
+		if activeManifest != nil {
+			activeManifest.breaker = outputBreaker
+		}
+	}
+	// Provenance fields wrap the sink inside change detection, so that they do not count
+	// as changes
+	if *addMeta {
+		out = newMetaSink(out, *metaPrefix, *inputURI)
+	}
+	if *deltaState != "" {
+		if out, err = openDeltaSink(out, *deltaState, *deltaKey); err != nil {
+			fatalf("error opening delta state: %v", err)
+		}
+	}
 
-		// Transform value based on data type
-		switch v := value.(type) {
-		case map[string]interface{}:
-			outputMap := transformMap(v)
-			if len(outputMap) > 0 {
-				output = append(output, outputMap)
+	// transformOne transforms the record at an index of the run to the desired output format
+	// and applies the rules, which may split a record into several. It runs on the workers.
+	transformOne := func(index int, record Input) ([]Output, error) {
+		if *geoJSONMode != "" {
+			features, err := transformGeoJSON(&t, record, *geoJSONMode)
+			if err != nil {
+				return nil, err
 			}
-		case string:
-			if ts, err := time.Parse(time.RFC3339, v); err == nil {
-				output = append(output, map[string]interface{}{key: ts.Unix()})
-			} else {
-				output = append(output, map[string]interface{}{key: strings.TrimSpace(v)})
+			var outputs []Output
+			for _, output := range features {
+				results, err := t.Rules.Apply(output)
+				if err != nil {
+					return nil, fmt.Errorf("applying rules: %w", err)
+				}
+				outputs = append(outputs, results...)
 			}
-		case []interface{}:
-			outputList := transformList(v)
-			if len(outputList) > 0 {
-				output = append(output, map[string]interface{}{key: outputList})
+			return outputs, nil
+		}
+		if *emit == "events" {
+			_, events, err := t.TransformEvents(record)
+			if err != nil {
+				return nil, err
 			}
-		default:
-			fmt.Printf("Warning: Skipping unsupported data type for key %q\n", key)
+			return eventOutputs(index, events), nil
 		}
+		return t.TransformRecord(record)
 	}
 
-	return output
-}
+	// write writes the outputs of the record at an index of a source member to the sink,
+	// in input order
+	recordsRead, recordsTransformed, recordsExpired := 0, 0, 0
+	var problems []string
+	// skips counts the writes and flushes a circuit breaker skipped, so that stream messages
+	// whose outputs were skipped are not acknowledged
+	skips := 0
+	write := func(m member, i int, record Input, outputs []Output, err error) {
+		checkTimeLimit()
+		recordsTransformed++
+		if errors.Is(err, errLatencyExpired) || m.expired() {
+			recordsExpired++
+			if activeManifest != nil {
+				activeManifest.Expired++
+			}
+			warn("expired-record", "Dropping %s: %v", recordName(*inputURI, m.name, i), errLatencyExpired)
+			return
+		}
+		var strictErr *transform.StrictError
+		if errors.As(err, &strictErr) {
+			// Report every problem of the run before failing
+			for _, problem := range strictErr.Problems {
+				problems = append(problems, fmt.Sprintf("%s: %s", recordName(*inputURI, m.name, i), problem))
+			}
+			return
+		} else if err != nil {
+			fatalf("error transforming record: %v", err)
+		}
 
-// transformMap transforms a map[string]interface{} to the desired output format
-func transformMap(m map[string]interface{}) map[string]interface{} {
-	outputMap := make(map[string]interface{})
-
-	// Sort map keys lexically
-	var keys []string
-	for k := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	// Iterate through sorted keys and transform each field
-	for _, k := range keys {
-		// Sanitize key by trimming leading and trailing whitespace
-		key := strings.TrimSpace(k)
-
-		// Transform value based on data type
-		switch v := m[k].(type) {
-		case map[string]interface{}:
-			outputMap[key] = transformMap(v)
-		case string:
-			outputMap[key] = strings.TrimSpace(v)
-		case []interface{}:
-			outputList := transformList(v)
-			if len(outputList) > 0 {
-				outputMap[key] = outputList
+		// Write output to the sink
+		for _, output := range outputs {
+			if *emit == "both" {
+				output = emitBoth(record, output)
+			}
+			// Hash and fingerprint the record's own content, not each other
+			var derived Output
+			if *hashField != "" {
+				hash, err := outputHash(output)
+				if err != nil {
+					fatalf("error hashing output: %v", err)
+				}
+				derived = append(derived, map[string]interface{}{*hashField: hash})
+			}
+			if *schemaField != "" {
+				derived = append(derived, map[string]interface{}{*schemaField: schemaFingerprint(output)})
+			}
+			output = append(output, derived...)
+			if err := out.Write(output); errors.Is(err, errOutputSkipped) {
+				skips++
+			} else if err != nil {
+				fatalf("error writing output: %v", err)
 			}
-		default:
-			fmt.Printf("Warning: Skipping unsupported data type for key %q\n", key)
 		}
 	}
 
-	return outputMap
-}
+	// process transforms the records of a source member and writes them to the sink,
+	// reporting whether all their outputs were delivered
+	process := func(m member) bool {
+		checkTimeLimit()
+		if activeManifest != nil {
+			activeManifest.addInput(*inputURI, m.name, len(m.records))
+		}
+		recordsRead += len(m.records)
+		if task != nil && task.Limits.MaxRecords > 0 && recordsRead > task.Limits.MaxRecords {
+			fatalf("error: input exceeds the task limit of %d records", task.Limits.MaxRecords)
+		}
+		records, err := applyPreset(*presetName, m.records)
+		if err != nil {
+			fatalf("error applying preset: %v", err)
+		}
 
-// transformList transforms a []interface{} to the desired output format
-func transformList(l []interface{}) []interface{} {
-	var outputList []interface{}
-
-	// Iterate through list elements and transform each item
-	for _, item := range l {
-		switch v := item.(type) {
-		case map[string]interface{}:
-			outputMap := transformMap(v)
-			if len(outputMap) > 0 {
-				outputList = append(outputList, outputMap)
+		// Mirror archive members in archive outputs
+		if ms, ok := out.(memberSink); ok && m.name != "" {
+			if err := ms.StartMember(m.name); err != nil {
+				fatalf("error writing output: %v", err)
 			}
-		case string:
-			if ts, err := time.Parse(time.RFC3339, v); err == nil {
-				outputList = append(outputList, ts.Unix())
-			} else if isNumeric(v) {
-				outputList = append(outputList, parseNumber(v))
-			} else {
-				outputList = append(outputList, strings.TrimSpace(v))
+		}
+
+		// Transform records on the workers and write them in input order
+		first, skipped := recordsTransformed, skips
+		jobs := make(chan job)
+		go func() {
+			for i, record := range records {
+				jobs <- func() func() error {
+					// Records already late are not worth transforming
+					outputs, err := []Output(nil), errLatencyExpired
+					if !m.expired() {
+						outputs, err = transformOne(first+i, record)
+					}
+					return func() error {
+						write(m, i, record, outputs, err)
+						return nil
+					}
+				}
+			}
+			close(jobs)
+		}()
+		runOrdered(*workers, jobs)
+
+		// Save stage state so that a restart does not replay this member
+		if f, ok := out.(flushSink); ok {
+			if err := f.Flush(); errors.Is(err, errOutputSkipped) {
+				skips++
+			} else if err != nil {
+				fatalf("error writing output: %v", err)
 			}
-		default:
-			fmt.Printf("Warning: Skipping unsupported data type in list\n")
 		}
+		return skips == skipped
 	}
 
-	return outputList
+	if isStreamURI(*inputURI) {
+		// Transform messages as they arrive until interrupted
+		if err := consumeStream(*inputURI, opts, process); err != nil {
+			fatalf("error reading stream input: %v", err)
+		}
+	} else {
+		for _, m := range members {
+			process(m)
+		}
+	}
+	checkTimeLimit()
+	if err := out.Close(); err != nil {
+		fatalf("error closing output: %v", err)
+	}
+	if outputBreaker != nil && outputBreaker.stats.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d records while the output was unavailable\n", outputBreaker.stats.Skipped)
+	}
+	if recordsExpired > 0 {
+		fmt.Fprintf(os.Stderr, "Dropped %d records whose latency budget expired\n", recordsExpired)
+	}
+	if len(problems) > 0 {
+		fatalf("error: found %d problems:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	if *coverageReport != "" {
+		if err := writeCoverageReport(*coverageReport, t.Coverage.Report(t.Rules)); err != nil {
+			fatalf("error writing coverage report: %v", err)
+		}
+	}
+	if activeManifest != nil {
+		if err := activeManifest.write(nil); err != nil {
+			// Not fatalf, which would try to write the manifest that just failed again
+			log.Fatalf("error writing manifest: %v", err)
+		}
+	}
 }
 
-// isNumeric checks if a string represents a numeric value
-func isNumeric(s string) bool {
-	_, err := strconv.Atoi(s)
-	return err == nil
+// writeCoverageReport writes the coverage report of a run as indented JSON
+func writeCoverageReport(path string, report transform.CoverageReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
 }
 
-// parseNumber parses a numeric string and returns the corresponding number
-func parseNumber(s string) interface{} {
-	// Strip leading zeros
-	trimmed := strings.TrimLeft(s, "0")
-	// Parse integer or float
-	if strings.Contains(trimmed, ".") {
-		f, err := strconv.ParseFloat(trimmed, 64)
+// recordName locates a record for error reports by its input, archive member and index
+func recordName(inputURI, member string, index int) string {
+	name := redactURI(inputURI)
+	if member != "" {
+		name += ":" + member
+	}
+	return fmt.Sprintf("%s record %d", name, index+1)
+}
+
+// encodeOutput encodes the output in the output format: indented JSON, indented JSON with
+// Extended JSON numbers, or a single line of compact JSON for NDJSON streams
+func encodeOutput(output Output, format string) ([]byte, error) {
+	var jsonData []byte
+	var err error
+	switch format {
+	case "ndjson":
+		jsonData, err = json.Marshal(output)
+	case "yaml":
+		data, err := encodeYAML(output)
+		if err != nil {
+			return nil, fmt.Errorf("encoding output YAML: %v", err)
+		}
+		return data, nil
+	case "toml":
+		data, err := encodeTOML(output)
+		if err != nil {
+			return nil, fmt.Errorf("encoding output TOML: %v", err)
+		}
+		return data, nil
+	case "msgpack":
+		data, err := encodeMsgpack(output)
 		if err != nil {
-			return nil
+			return nil, fmt.Errorf("encoding output MessagePack: %v", err)
 		}
-		return f
+		return data, nil
+	case "cbor":
+		data, err := encodeCBOR(output)
+		if err != nil {
+			return nil, fmt.Errorf("encoding output CBOR: %v", err)
+		}
+		return data, nil
+	case "bson":
+		data, err := encodeBSON(output)
+		if err != nil {
+			return nil, fmt.Errorf("encoding output BSON: %v", err)
+		}
+		return data, nil
+	case "ejson":
+		jsonData, err = json.MarshalIndent(toExtendedJSON(output), "", "  ")
+	default:
+		jsonData, err = json.MarshalIndent(output, "", "  ")
 	}
-	i, err := strconv.Atoi(trimmed)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("encoding output JSON: %v", err)
 	}
-	return i
+	return append(jsonData, '\n'), nil
 }
 
-// printOutput prints the output JSON to stdout
-func printOutput(output Output) {
-	jsonData, err := json.MarshalIndent(output, "", "  ")
-	if err != nil {
-		log.Fatalf("error encoding output JSON: %v", err)
+// binaryFormats are the output formats whose encodings are not text
+var binaryFormats = map[string]bool{"msgpack": true, "cbor": true, "bson": true}
+
+// messageBody returns an output encoded in a format as the body of a message, without the
+// newline that ends text encodings
+func messageBody(data []byte, format string) []byte {
+	if binaryFormats[format] {
+		return data
 	}
-	fmt.Println(string(jsonData))
+	return bytes.TrimSuffix(data, []byte("\n"))
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runManifest is the manifest written by -manifest when a run ends, for orchestrators to
+// verify that a batch run is complete. Version 1 of the format has these fields:
+//
+//	version      1
+//	status       "succeeded" or "failed"
+//	started_at   RFC3339 start time
+//	finished_at  RFC3339 end time
+//	duration_ms  run time in milliseconds
+//	inputs       per source member: the redacted input URI, the archive member (if any) and
+//	             the number of records read
+//	outputs      the redacted output URI, the number of records written and the SHA-256 of
+//	             their canonical JSON, one record per line in write order, and with
+//	             -breaker-threshold the state of its circuit breaker, the times it opened,
+//	             the writes that failed and the records skipped
+//	expired      with -latency-budget, the records dropped as their budget expired
+//	errors       the error that ended a failed run
+type runManifest struct {
+	Version    int              `json:"version"`
+	Status     string           `json:"status"`
+	StartedAt  string           `json:"started_at"`
+	FinishedAt string           `json:"finished_at"`
+	DurationMS int64            `json:"duration_ms"`
+	Inputs     []manifestInput  `json:"inputs"`
+	Outputs    []manifestOutput `json:"outputs"`
+	Expired    int              `json:"expired,omitempty"`
+	Errors     []string         `json:"errors"`
+
+	path      string
+	result    io.Writer
+	outputURI string
+	started   time.Time
+	digest    *batchDigest
+	breaker   *breaker
+}
+
+// manifestInput counts the records read from a source member
+type manifestInput struct {
+	URI     string `json:"uri"`
+	Member  string `json:"member,omitempty"`
+	Records int    `json:"records"`
+}
+
+// manifestOutput describes the records written to an output
+type manifestOutput struct {
+	URI     string        `json:"uri"`
+	Records int           `json:"records"`
+	SHA256  string        `json:"sha256"`
+	Breaker *breakerStats `json:"breaker,omitempty"`
+}
+
+// activeManifest is the manifest of the current run, if -manifest or -task-json is set
+var activeManifest *runManifest
+
+// newRunManifest starts the manifest of a run writing to an output URI, to be written to
+// path when the run ends
+func newRunManifest(path, outputURI string) *runManifest {
+	if outputURI == "" {
+		outputURI = "stdout"
+	}
+	return &runManifest{
+		Version: 1, Inputs: []manifestInput{}, Outputs: []manifestOutput{}, Errors: []string{},
+		path: path, outputURI: redactURI(outputURI), started: time.Now(), digest: newBatchDigest(),
+	}
+}
+
+// addInput counts the records read from a member of an input. Stream messages are counted
+// together under the input URI.
+func (m *runManifest) addInput(uri, member string, records int) {
+	uri = redactURI(uri)
+	for i := range m.Inputs {
+		if m.Inputs[i].URI == uri && m.Inputs[i].Member == member {
+			m.Inputs[i].Records += records
+			return
+		}
+	}
+	m.Inputs = append(m.Inputs, manifestInput{URI: uri, Member: member, Records: records})
+}
+
+// write completes the manifest with the output and run time, writes it atomically to its
+// path, if any, and prints it as a single line to its result writer, if any
+func (m *runManifest) write(runErr error) error {
+	finished := time.Now()
+	m.Status, m.FinishedAt = "succeeded", finished.UTC().Format(time.RFC3339)
+	m.StartedAt, m.DurationMS = m.started.UTC().Format(time.RFC3339), finished.Sub(m.started).Milliseconds()
+	if runErr != nil {
+		m.Status = "failed"
+		m.Errors = append(m.Errors, runErr.Error())
+	}
+	summary := m.digest.manifest("")
+	m.Outputs = []manifestOutput{{URI: m.outputURI, Records: summary.Records, SHA256: summary.SHA256}}
+	if m.breaker != nil {
+		stats := m.breaker.report()
+		m.Outputs[0].Breaker = &stats
+	}
+
+	if m.result != nil {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(m.result, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	if m.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".manifest-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// manifestSink counts and hashes the outputs written to the wrapped sink
+type manifestSink struct {
+	next     sink
+	manifest *runManifest
+}
+
+// StartMember forwards to the wrapped sink
+func (s *manifestSink) StartMember(name string) error {
+	if ms, ok := s.next.(memberSink); ok {
+		return ms.StartMember(name)
+	}
+	return nil
+}
+
+// Write writes the output and adds it to the manifest
+func (s *manifestSink) Write(output Output) error {
+	if err := s.next.Write(output); err != nil {
+		return err
+	}
+	return s.manifest.digest.add(mergeOutput(output))
+}
+
+// Flush forwards to the wrapped sink
+func (s *manifestSink) Flush() error {
+	if f, ok := s.next.(flushSink); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the wrapped sink
+func (s *manifestSink) Close() error {
+	return s.next.Close()
+}
+
+// fatalf records the error in the manifest of the run, if any, and exits like log.Fatalf
+func fatalf(format string, args ...interface{}) {
+	if activeManifest != nil {
+		if err := activeManifest.write(fmt.Errorf(format, args...)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Writing manifest failed: %v\n", err)
+		}
+	}
+	log.Fatalf(format, args...)
+}
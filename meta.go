@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/url"
+	"time"
+)
+
+// metaSink injects provenance fields into each output before passing it on: the record's
+// ordinal among the written records, the source it was read from and the time it was written
+type metaSink struct {
+	next   sink
+	prefix string
+	source string
+	member string
+	index  int
+	now    func() time.Time
+}
+
+// newMetaSink wraps a sink with provenance fields for records read from an input URI,
+// naming the fields with the given prefix
+func newMetaSink(next sink, prefix, inputURI string) *metaSink {
+	return &metaSink{next: next, prefix: prefix, source: redactURI(inputURI), now: time.Now}
+}
+
+// StartMember makes the archive member the source of the following records and forwards it
+// to the wrapped sink
+func (s *metaSink) StartMember(name string) error {
+	s.member = name
+	if ms, ok := s.next.(memberSink); ok {
+		return ms.StartMember(name)
+	}
+	return nil
+}
+
+// Write appends the metadata fields to the output and forwards it. Archive members are named
+// by member name, other sources by their redacted input URI. The ingestion time is in Unix
+// seconds, like the timestamps converted by the transformer.
+func (s *metaSink) Write(output Output) error {
+	source := s.source
+	if s.member != "" {
+		source = s.member
+	}
+	output = append(output[:len(output):len(output)],
+		map[string]interface{}{s.prefix + "record_index": s.index},
+		map[string]interface{}{s.prefix + "source": source},
+		map[string]interface{}{s.prefix + "ingested_at": s.now().Unix()},
+	)
+	s.index++
+	return s.next.Write(output)
+}
+
+// Flush forwards to the wrapped sink
+func (s *metaSink) Flush() error {
+	if f, ok := s.next.(flushSink); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the wrapped sink
+func (s *metaSink) Close() error {
+	return s.next.Close()
+}
+
+// redactURI returns an input URI without its password, and "stdin" for standard input
+func redactURI(uri string) string {
+	if uri == "" || uri == "-" {
+		return "stdin"
+	}
+	if u, err := url.Parse(uri); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return uri
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encodeMsgpack encodes the output of a record as a MessagePack array of maps, the same
+// structure as its JSON, so that the encodings of successive records form a stream of
+// values. Keys are sorted and numbers take their smallest encoding, integral floats as
+// integers as in JSON. The values of B and BS descriptors become bin values with
+// -raw-binary.
+func encodeMsgpack(output Output) ([]byte, error) {
+	value, err := binaryValue(output)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// binaryValue converts an output value into one the binary formats, MessagePack and CBOR,
+// encode like JSON does, decoding passthrough subtrees
+func binaryValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case Output:
+		list := make([]interface{}, len(v))
+		for i, m := range v {
+			var err error
+			if list[i], err = binaryValue(m); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = binaryValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if list[i], err = binaryValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case json.RawMessage:
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		return binaryValue(value)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	}
+	return v, nil
+}
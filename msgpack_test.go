@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEncodeMsgpack(t *testing.T) {
+	output := Output{
+		{"created": uint64(1704067200), "price": 2.5, "n": 2.0},
+		{"raw": json.RawMessage(`{"big": 12345678901, "x": [1.5, null]}`)},
+		{"blob": []byte{0, 1}, "tags": []interface{}{"a", 7}},
+	}
+	data, err := encodeOutput(output, "msgpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.UseLooseInterfaceDecoding(true)
+	got, err := dec.DecodeInterface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"created": uint64(1704067200), "price": 2.5, "n": int64(2)},
+		map[string]interface{}{"raw": map[string]interface{}{"big": uint64(12345678901), "x": []interface{}{1.5, nil}}},
+		// Loose decoding reads bin values as strings
+		map[string]interface{}{"blob": "\x00\x01", "tags": []interface{}{"a", int64(7)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %#v, want %#v", got, want)
+	}
+	if bin := []byte{0xc4, 2, 0, 1}; !bytes.Contains(data, bin) {
+		t.Errorf("encoding %x lacks the bin value %x", data, bin)
+	}
+
+	// Messages keep a final byte that happens to be a newline
+	data, err = encodeOutput(Output{{"n": 10}}, "msgpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := messageBody(data, "msgpack"); !reflect.DeepEqual(body, data) || body[len(body)-1] != '\n' {
+		t.Errorf("messageBody = %x, want %x", body, data)
+	}
+}
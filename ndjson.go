@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// runNDJSON transforms a stream of newline-delimited JSON documents read from r, writing the
+// output of each to w as a line of compact JSON as soon as it is transformed. Lines are
+// transformed on up to workers goroutines but written in input order. A line that fails to
+// decode or transform is skipped with a warning rather than ending the stream. It returns
+// the number of lines skipped. The problems a strict Transformer finds are collected from
+// every line into a single *transform.StrictError returned once the stream ends, and the
+// lines with problems are not written.
+func runNDJSON(tr *transform.Transformer, presetName string, workers int, r io.Reader, w io.Writer) (int, error) {
+	skipped := 0
+	var problems []string
+	var scanErr error
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+			line := strings.TrimSpace(scanner.Text())
+			// Skip blank lines
+			if line == "" {
+				continue
+			}
+
+			lineNo := lineNo
+			jobs <- func() func() error {
+				data, class, err := transformLine(tr, presetName, line)
+				return func() error {
+					var strictErr *transform.StrictError
+					if errors.As(err, &strictErr) {
+						for _, problem := range strictErr.Problems {
+							problems = append(problems, fmt.Sprintf("line %d: %s", lineNo, problem))
+						}
+						return nil
+					}
+					switch class {
+					case "malformed-line":
+						warn(class, "Skipping malformed line %d: %v", lineNo, err)
+						skipped++
+						return nil
+					case "skipped-record":
+						warn(class, "Skipping record on line %d: %v", lineNo, err)
+						skipped++
+						return nil
+					}
+					if err != nil {
+						return err
+					}
+					_, err := w.Write(data)
+					return err
+				}
+			}
+		}
+		scanErr = scanner.Err()
+	}()
+	if err := runOrdered(workers, jobs); err != nil {
+		return skipped, err
+	}
+	if scanErr != nil {
+		return skipped, scanErr
+	}
+	if len(problems) > 0 {
+		return skipped, &transform.StrictError{Problems: problems}
+	}
+	return skipped, nil
+}
+
+// transformLine transforms an NDJSON line into its lines of output. A line to skip is
+// reported by the class of its warning with the error as the reason; the problems of a
+// strict Transformer are returned as its *transform.StrictError.
+func transformLine(tr *transform.Transformer, presetName, line string) ([]byte, string, error) {
+	var record Input
+	err := json.Unmarshal([]byte(line), &record)
+	if err == nil && record == nil {
+		err = errors.New("not a JSON object")
+	}
+	if err != nil {
+		return nil, "malformed-line", err
+	}
+	records, err := applyPreset(presetName, []Input{record})
+	if err != nil {
+		return nil, "", err
+	}
+	var data []byte
+	for _, record := range records {
+		outputs, err := tr.TransformRecord(record)
+		var strictErr *transform.StrictError
+		if errors.As(err, &strictErr) {
+			return nil, "", err
+		} else if err != nil {
+			return nil, "skipped-record", err
+		}
+		for _, output := range outputs {
+			line, err := encodeOutput(output, "ndjson")
+			if err != nil {
+				return nil, "", err
+			}
+			data = append(data, line...)
+		}
+	}
+	return data, "", nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestRunNDJSON(t *testing.T) {
+	input := `{"name": " a ", "n": "7"}
+
+not json
+[1, 2]
+{"bad": 1.5}
+{"name": "b"}
+`
+	want := `[{"n":"7"},{"name":"a"}]
+[{"name":"b"}]
+`
+	for _, workers := range []int{1, 4} {
+		var out bytes.Buffer
+		tr := transform.Transformer{Strict: true}
+		skipped, err := runNDJSON(&tr, "", workers, strings.NewReader(input), &out)
+		// Strict problems fail the stream once it ends, rather than skipping their line
+		var strictErr *transform.StrictError
+		if !errors.As(err, &strictErr) || len(strictErr.Problems) != 1 || !strings.HasPrefix(strictErr.Problems[0], "line 5: ") {
+			t.Errorf("runNDJSON with %d workers error = %v, want a problem on line 5", workers, err)
+		}
+		if skipped != 2 {
+			t.Errorf("runNDJSON with %d workers skipped %d lines, want 2", workers, skipped)
+		}
+		if out.String() != want {
+			t.Errorf("runNDJSON with %d workers wrote\n%s\nwant\n%s", workers, out.String(), want)
+		}
+	}
+}
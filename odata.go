@@ -0,0 +1,148 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// odataLegacyDatePattern matches the OData v2 "/Date(1234567890000+0100)/" representation
+var odataLegacyDatePattern = regexp.MustCompile(`^/Date\((-?\d+)([+-]\d{4})?\)/$`)
+
+// odataDurationPattern matches the ISO 8601 durations used by Edm.Duration
+var odataDurationPattern = regexp.MustCompile(`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// odataPreset unwraps OData payloads into plain records: "value" collections (and the v2
+// "d"/"results" envelope) yield one record per entity, "@odata." annotations and v2
+// "__metadata" are dropped, and values are converted according to their Edm type.
+func odataPreset(record Input) []Input {
+	var entities []interface{}
+	switch {
+	case record["d"] != nil:
+		// OData v2 verbose JSON wraps everything in "d", collections in "d.results"
+		d, _ := record["d"].(map[string]interface{})
+		if results, ok := d["results"].([]interface{}); ok {
+			entities = results
+		} else {
+			entities = []interface{}{d}
+		}
+	case record["value"] != nil:
+		if value, ok := record["value"].([]interface{}); ok {
+			entities = value
+		} else {
+			entities = []interface{}{map[string]interface{}(record)}
+		}
+	default:
+		entities = []interface{}{map[string]interface{}(record)}
+	}
+
+	var records []Input
+	for _, e := range entities {
+		if entity, ok := e.(map[string]interface{}); ok {
+			records = append(records, odataObject(entity))
+		}
+	}
+	return records
+}
+
+// odataObject strips the annotations of an entity or complex value and converts its
+// properties, using "<property>@odata.type" annotations as type hints
+func odataObject(m map[string]interface{}) map[string]interface{} {
+	types := make(map[string]string)
+	for k, v := range m {
+		if prop, ok := strings.CutSuffix(k, "@odata.type"); ok && prop != "" {
+			if t, ok := v.(string); ok {
+				types[prop] = t
+			}
+		}
+	}
+
+	out := make(map[string]interface{})
+	for k, v := range m {
+		// Skip instance and property annotations and v2 metadata
+		if strings.Contains(k, "@") || strings.HasPrefix(k, "odata.") || k == "__metadata" {
+			continue
+		}
+		// Skip v2 deferred navigation properties, which carry only a link
+		if nav, ok := v.(map[string]interface{}); ok && len(nav) == 1 && nav["__deferred"] != nil {
+			continue
+		}
+		if value := odataValue(v, types[k]); value != nil {
+			out[k] = value
+		}
+	}
+	return out
+}
+
+// odataValue converts a property value, returning nil for null
+func odataValue(v interface{}, edmType string) interface{} {
+	edmType = strings.TrimPrefix(strings.TrimPrefix(edmType, "#"), "Edm.")
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// Expanded v2 collections keep the "results" envelope
+		if results, ok := v["results"].([]interface{}); ok && len(v) <= 2 {
+			return odataValue(results, edmType)
+		}
+		return odataObject(v)
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if value := odataValue(item, edmType); value != nil {
+				list = append(list, value)
+			}
+		}
+		return list
+	case string:
+		return odataString(v, edmType)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return nil
+}
+
+// odataString converts a string value according to its Edm type, so that dates reach the
+// standard coercion as RFC3339 and durations become seconds
+func odataString(s, edmType string) interface{} {
+	if m := odataLegacyDatePattern.FindStringSubmatch(s); m != nil {
+		ms, err := strconv.ParseInt(m[1], 10, 64)
+		if err == nil {
+			return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	switch edmType {
+	case "Date":
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	case "Duration":
+		if seconds, ok := odataDurationSeconds(s); ok {
+			return seconds
+		}
+		warn("invalid-value", "Keeping invalid Edm.Duration %q as text", s)
+	}
+	return s
+}
+
+// odataDurationSeconds converts an ISO 8601 day-time duration like "P1DT2H30M" to seconds
+func odataDurationSeconds(s string) (string, bool) {
+	m := odataDurationPattern.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return "", false
+	}
+	var total float64
+	for i, unit := range []float64{86400, 3600, 60, 1} {
+		if m[i+2] != "" {
+			n, _ := strconv.ParseFloat(m[i+2], 64)
+			total += n * unit
+		}
+	}
+	if m[1] == "-" {
+		total = -total
+	}
+	return strconv.FormatFloat(total, 'f', -1, 64), true
+}
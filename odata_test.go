@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestODataPreset(t *testing.T) {
+	tests := []struct {
+		name, payload string
+		want          []Input
+	}{
+		{
+			name: "v4 collection",
+			payload: `{"@odata.context": "$metadata#People", "value": [
+				{"@odata.etag": "W/\"1\"", "Name": "Ada", "Age": 36, "Active": true,
+				 "Born@odata.type": "#Edm.Date", "Born": "1815-12-10",
+				 "Shift@odata.type": "#Edm.Duration", "Shift": "PT8H30M",
+				 "Address": {"City": "London", "Zip": null}},
+				{"Name": "Bob", "Tags": ["a", null, 2]}
+			]}`,
+			want: []Input{
+				{"Name": "Ada", "Age": "36", "Active": "true", "Born": "1815-12-10T00:00:00Z", "Shift": "30600",
+					"Address": map[string]interface{}{"City": "London"}},
+				{"Name": "Bob", "Tags": []interface{}{"a", "2"}},
+			},
+		},
+		{
+			name: "v2 verbose",
+			payload: `{"d": {"results": [
+				{"__metadata": {"uri": "People(1)"}, "Name": "Ada", "Created": "/Date(1704164645000)/",
+				 "Orders": {"__deferred": {"uri": "People(1)/Orders"}},
+				 "Friends": {"results": [{"Name": "Bob"}]}}
+			]}}`,
+			want: []Input{{"Name": "Ada", "Created": "2024-01-02T03:04:05Z",
+				"Friends": []interface{}{map[string]interface{}{"Name": "Bob"}}}},
+		},
+		{
+			name:    "single entity",
+			payload: `{"@odata.context": "$metadata#People/$entity", "Name": "Ada"}`,
+			want:    []Input{{"Name": "Ada"}},
+		},
+	}
+	for _, tt := range tests {
+		var record Input
+		if err := json.Unmarshal([]byte(tt.payload), &record); err != nil {
+			t.Fatal(err)
+		}
+		got := odataPreset(record)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: odataPreset = %#v, want %#v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !reflect.DeepEqual(map[string]interface{}(got[i]), map[string]interface{}(tt.want[i])) {
+				t.Errorf("%s: odataPreset[%d] = %#v, want %#v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestODataDurationSeconds(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"P1DT2H30M", "95400", true},
+		{"PT0.5S", "0.5", true},
+		{"-PT1M", "-60", true},
+		{"P", "", false},
+		{"PT", "", false},
+		{"P1Y", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := odataDurationSeconds(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("odataDurationSeconds(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
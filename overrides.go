@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// requestConfig is the configuration a request is transformed with
+type requestConfig struct {
+	// tr is the server's Transformer, or a copy of it with the options the request sets
+	tr *transform.Transformer
+	// geoJSON is the GeoJSON mode of -geojson, or empty to transform records as any other
+	geoJSON string
+}
+
+// requestOptions are the options a request may set, when the server allows it, by the
+// function setting each on the request's configuration
+var requestOptions = map[string]func(c *requestConfig, value string) error{
+	"strict": func(c *requestConfig, value string) error {
+		strict, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid strict %q (want true or false)", value)
+		}
+		c.tr.Strict = strict
+		return nil
+	},
+	"coerce": func(c *requestConfig, value string) error {
+		coercions, err := transform.ParseCoercionOrder(value)
+		if err != nil {
+			return err
+		}
+		c.tr.Coercions = coercions
+		return nil
+	},
+	"order": func(c *requestConfig, value string) error {
+		if err := transform.ValidateOrder(value); err != nil {
+			return err
+		}
+		c.tr.Order = value
+		return nil
+	},
+	"compat": func(c *requestConfig, value string) error {
+		if err := transform.ValidateCompat(value); err != nil {
+			return err
+		}
+		c.tr.Compat = value
+		return nil
+	},
+	"locale": func(c *requestConfig, value string) error {
+		locale, err := transform.ParseLocale(value)
+		if err != nil {
+			return err
+		}
+		c.tr.Locale = locale
+		return nil
+	},
+	"geojson": func(c *requestConfig, value string) error {
+		if value != "" && value != "geojson" && value != "wkt" {
+			return fmt.Errorf("unsupported GeoJSON mode %q (want geojson or wkt)", value)
+		}
+		c.geoJSON = value
+		return nil
+	},
+}
+
+// requestOptionNames returns the sorted names of the options a request may set
+func requestOptionNames() string {
+	var names []string
+	for name := range requestOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseOverrides parses the comma-separated allowlist of options requests may set
+func parseOverrides(s string) (map[string]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	allowed := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := requestOptions[name]; !ok {
+			return nil, fmt.Errorf("unknown option %q (available: %s)", name, requestOptionNames())
+		}
+		allowed[name] = true
+	}
+	return allowed, nil
+}
+
+// parseRequestConfig returns the configuration of a request: the server's Transformer,
+// with the options the request sets in X-Transform-<Option> headers or query parameters of
+// the same name, which take precedence. Options outside the allowlist are rejected rather than ignored, so that
+// clients do not silently get outputs they did not ask for.
+func parseRequestConfig(r *http.Request, base *transform.Transformer, allowed map[string]bool) (requestConfig, error) {
+	values := make(map[string]string)
+	for key, v := range r.Header {
+		if name, ok := strings.CutPrefix(strings.ToLower(key), "x-transform-"); ok && name != shapeParam && len(v) > 0 {
+			values[name] = v[0]
+		}
+	}
+	for name, v := range r.URL.Query() {
+		if name != shapeParam && len(v) > 0 {
+			values[name] = v[0]
+		}
+	}
+
+	c := requestConfig{tr: base}
+	if len(values) == 0 {
+		return c, nil
+	}
+	t := *base
+	c.tr = &t
+	for name, value := range values {
+		set, ok := requestOptions[name]
+		if !ok {
+			return requestConfig{}, fmt.Errorf("unknown option %q", name)
+		}
+		if !allowed[name] {
+			return requestConfig{}, fmt.Errorf("option %q may not be set per request", name)
+		}
+		if err := set(&c, value); err != nil {
+			return requestConfig{}, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestParseOverrides(t *testing.T) {
+	allowed, err := parseOverrides(" geojson ")
+	if err != nil || !allowed["geojson"] {
+		t.Errorf("parseOverrides = %v, %v", allowed, err)
+	}
+	if allowed, err := parseOverrides(""); err != nil || allowed != nil {
+		t.Errorf("parseOverrides(\"\") = %v, %v, want none", allowed, err)
+	}
+	if _, err := parseOverrides("geojson,timezone"); err == nil {
+		t.Error("parseOverrides of an unknown option succeeded, want error")
+	}
+}
+
+func TestTransformHandlerOverrides(t *testing.T) {
+	const feature = `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}}`
+	allowed := newTransformHandler(&transform.Transformer{}, "", 1<<10, nil, nil)
+	allowed.overrides = map[string]bool{"geojson": true}
+
+	tests := []struct {
+		h       *transformHandler
+		query   string
+		headers map[string]string
+		status  int
+		want    string
+	}{
+		{allowed, "?geojson=wkt", nil, 200, `[[{"geometry":"POINT (1 2)"}]]`},
+		{allowed, "", map[string]string{"X-Transform-Geojson": "wkt"}, 200, `[[{"geometry":"POINT (1 2)"}]]`},
+		// Query parameters take precedence over headers
+		{allowed, "?geojson=wkt", map[string]string{"X-Transform-Geojson": "svg"}, 200, `[[{"geometry":"POINT (1 2)"}]]`},
+		{allowed, "?geojson=svg", nil, 400, `{"error":"unsupported GeoJSON mode \"svg\" (want geojson or wkt)"}`},
+		{allowed, "", map[string]string{"X-Transform-Timezone": "UTC"}, 400, `{"error":"unknown option \"timezone\""}`},
+		{newTransformHandler(&transform.Transformer{}, "", 1<<10, nil, nil), "?geojson=wkt", nil, 400, `{"error":"option \"geojson\" may not be set per request"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/transform"+tt.query, strings.NewReader(feature))
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		tt.h.ServeHTTP(w, req)
+		got := strings.TrimSpace(w.Body.String())
+		if w.Code != tt.status || got != tt.want {
+			t.Errorf("POST %s %v = %d %s, want %d %s", tt.query, tt.headers, w.Code, got, tt.status, tt.want)
+		}
+	}
+}
+
+func TestTransformHandlerTransformerOverrides(t *testing.T) {
+	base := &transform.Transformer{}
+	h := newTransformHandler(base, "", 1<<10, nil, nil)
+	h.overrides = map[string]bool{"coerce": true, "order": true, "strict": true}
+
+	tests := []struct {
+		query   string
+		headers map[string]string
+		body    string
+		status  int
+		want    string
+	}{
+		{"", nil, `{"b": "42", "a": "x"}`, 200, `[[{"a":"x"},{"b":"42"}]]`},
+		{"?coerce=number&order=desc", nil, `{"b": "42", "a": "x"}`, 200, `[[{"b":42},{"a":"x"}]]`},
+		{"", map[string]string{"X-Transform-Coerce": "number"}, `{"b": "42"}`, 200, `[[{"b":42}]]`},
+		// Query parameters take precedence over headers
+		{"?order=asc", map[string]string{"X-Transform-Order": "desc"}, `{"b": "1", "a": "2"}`, 200, `[[{"a":"2"},{"b":"1"}]]`},
+		{"?strict=true", nil, `{"n": 1}`, 422, ``},
+		{"?strict=maybe", nil, `{"n": 1}`, 400, `{"error":"invalid strict \"maybe\" (want true or false)"}`},
+		{"?compat=v1", nil, `{"n": "1"}`, 400, `{"error":"option \"compat\" may not be set per request"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/transform"+tt.query, strings.NewReader(tt.body))
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		got := strings.TrimSpace(w.Body.String())
+		if w.Code != tt.status || (tt.want != "" && got != tt.want) {
+			t.Errorf("POST %s %v = %d %s, want %d %s", tt.query, tt.headers, w.Code, got, tt.status, tt.want)
+		}
+	}
+	// Overrides apply to a copy, leaving the server's Transformer as it was
+	if base.Strict || base.Coercions != nil || base.Order != "" {
+		t.Errorf("overrides changed the server's Transformer: %+v", base)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetSink writes the outputs as a Parquet file, with a schema inferred from every
+// record as by codegen: nested objects become groups and lists become LIST columns, every
+// column being optional. Integers are INT64 unless a float is met in the column, and
+// columns holding values of conflicting kinds, or only nulls, are strings with the other
+// values as JSON.
+// As the schema covers every record, the records are held until the sink is closed.
+type parquetSink struct {
+	w       io.Writer
+	records []map[string]interface{}
+	schema  *schemaNode
+}
+
+// newParquetSink returns a sink writing a Parquet file to w
+func newParquetSink(w io.Writer) *parquetSink {
+	return &parquetSink{w: w, schema: &schemaNode{kind: "object", fields: make(map[string]*schemaNode)}}
+}
+
+// Write adds the output to the records and their schema
+func (s *parquetSink) Write(output Output) error {
+	value, err := binaryValue(mergeOutput(output))
+	if err != nil {
+		return err
+	}
+	record := value.(map[string]interface{})
+	mergeObjectSchema(s.schema, record)
+	s.records = append(s.records, record)
+	return nil
+}
+
+// Close writes the records as a single Parquet file
+func (s *parquetSink) Close() error {
+	if len(s.schema.fields) == 0 {
+		// Without any column there is nothing Parquet can store
+		return nil
+	}
+	rows := make([]map[string]interface{}, len(s.records))
+	for i, record := range s.records {
+		row, err := parquetValue(s.schema, record)
+		if err != nil {
+			return err
+		}
+		rows[i] = row.(map[string]interface{})
+	}
+
+	w := parquet.NewGenericWriter[map[string]interface{}](s.w, parquet.NewSchema("record", parquetGroup(s.schema)), parquet.Compression(&parquet.Snappy))
+	if _, err := w.Write(rows); err != nil {
+		w.Close()
+		return fmt.Errorf("writing Parquet: %v", err)
+	}
+	return w.Close()
+}
+
+// parquetNode returns the Parquet node of a schema. Kinds Parquet cannot represent, as
+// conflicting values, objects without keys or values only ever null, are stored as strings.
+func parquetNode(n *schemaNode) parquet.Node {
+	switch n.kind {
+	case "bool":
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case "int":
+		return parquet.Optional(parquet.Int(64))
+	case "float":
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	case "bytes":
+		return parquet.Optional(parquet.Leaf(parquet.ByteArrayType))
+	case "array":
+		return parquet.Optional(parquet.List(parquetNode(n.elem)))
+	case "object":
+		if len(n.fields) > 0 {
+			return parquet.Optional(parquetGroup(n))
+		}
+	}
+	return parquet.Optional(parquet.String())
+}
+
+// parquetGroup returns the Parquet group of the fields of an object schema
+func parquetGroup(n *schemaNode) parquet.Group {
+	group := make(parquet.Group, len(n.fields))
+	for key, field := range n.fields {
+		group[key] = parquetNode(field)
+	}
+	return group
+}
+
+// parquetValue converts a value to the Go type the writer expects for its schema
+func parquetValue(n *schemaNode, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch n.kind {
+	case "int":
+		if i, ok := value.(int); ok {
+			return int64(i), nil
+		}
+		return value, nil
+	case "float":
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		}
+		return value, nil
+	case "bool", "bytes":
+		return value, nil
+	case "array":
+		list := value.([]interface{})
+		converted := make([]interface{}, len(list))
+		for i, e := range list {
+			var err error
+			if converted[i], err = parquetValue(n.elem, e); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	case "object":
+		if len(n.fields) == 0 {
+			break
+		}
+		m := value.(map[string]interface{})
+		converted := make(map[string]interface{}, len(m))
+		for key, e := range m {
+			var err error
+			if converted[key], err = parquetValue(n.fields[key], e); err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		return converted, nil
+	}
+
+	// Conflicting values and objects without keys are stored as JSON
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
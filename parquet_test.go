@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := newParquetSink(&buf)
+	for _, output := range []Output{
+		{{"name": "Ada"}, {"age": 36}, {"geo": map[string]interface{}{"lat": 51.5}}, {"tags": []interface{}{"a", nil}}, {"mixed": "x"}},
+		{{"name": "Grace"}, {"age": 85.5}, {"raw": json.RawMessage(`{"id": 7}`)}, {"blob": []byte{0, 1}}, {"mixed": 3}, {"empty": map[string]interface{}{}}},
+		{{"name": nil}, {"none": nil}},
+	} {
+		if err := s.Write(output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{
+		"optional double age",
+		"optional binary blob;",
+		"optional binary empty (STRING)",
+		"optional double lat",
+		"optional binary mixed (STRING)",
+		"optional binary none (STRING)",
+		"optional int64 id (INT(64,true))",
+		"optional group tags (LIST)",
+	} {
+		if !strings.Contains(f.Schema().String(), column) {
+			t.Errorf("schema lacks %q:\n%s", column, f.Schema())
+		}
+	}
+
+	r := parquet.NewReader(f)
+	var got []map[string]interface{}
+	for {
+		row := make(map[string]interface{})
+		if err := r.Read(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row)
+	}
+	want := []map[string]interface{}{
+		{"name": "Ada", "age": 36.0, "geo": map[string]interface{}{"lat": 51.5}, "tags": []interface{}{"a", nil}, "mixed": "x", "raw": nil, "blob": nil, "empty": nil, "none": nil},
+		{"name": "Grace", "age": 85.5, "geo": nil, "tags": nil, "mixed": "3", "raw": map[string]interface{}{"id": int64(7)}, "blob": "\x00\x01", "empty": "{}", "none": nil},
+		{"name": nil, "age": nil, "geo": nil, "tags": nil, "mixed": nil, "raw": nil, "blob": nil, "empty": nil, "none": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParquetSinkEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	s := newParquetSink(&buf)
+	if err := s.Write(Output{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil || buf.Len() != 0 {
+		t.Errorf("Close = %v with %d bytes, want nothing written", err, buf.Len())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// currencyExponents lists the currencies whose minor unit is not a hundredth, as used by
+// Stripe amounts
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "JPY": 0, "KMF": 0, "KRW": 0, "MGA": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// addMoney adds an amount to an object in the standard money shape shared by the payment
+// presets: flat <key>_value and <key>_currency fields, as a nested money object would be
+// flattened into its parent by the transformer and collide with the others
+func addMoney(out map[string]interface{}, key, value, currency string) {
+	out[key+"_value"] = value
+	out[key+"_currency"] = strings.ToUpper(currency)
+}
+
+// money builds the money shape of an amount without a key, such as an element of a list
+func money(value, currency string) map[string]interface{} {
+	return map[string]interface{}{"value": value, "currency": strings.ToUpper(currency)}
+}
+
+// stripePreset unwraps a Stripe webhook event into its data.object, keeping the event id,
+// type and creation time. Epoch fields become number descriptors, so that they are epoch
+// seconds at every depth rather than only where the standard coercion applies, and minor
+// unit amounts next to a currency become the standard money shape.
+func stripePreset(record Input) []Input {
+	out := make(Input)
+	if record["object"] == "event" {
+		data, _ := record["data"].(map[string]interface{})
+		object, _ := data["object"].(map[string]interface{})
+		for k, v := range stripeObject(object) {
+			out[k] = v
+		}
+		for key, field := range map[string]string{"id": "event_id", "type": "event_type", "created": "event_created"} {
+			if v, ok := record[key]; ok {
+				out[field] = stripeValue(key, v)
+			}
+		}
+		return []Input{out}
+	}
+	return []Input{Input(stripeObject(record))}
+}
+
+// stripeObject converts the fields of a Stripe object
+func stripeObject(m map[string]interface{}) map[string]interface{} {
+	currency, _ := m["currency"].(string)
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if amount, ok := v.(float64); ok && currency != "" && isStripeAmountField(k) {
+			addMoney(out, k, minorUnitsToDecimal(int64(amount), currency), currency)
+			continue
+		}
+		if value := stripeValue(k, v); value != nil {
+			out[k] = value
+		}
+	}
+	if currency != "" {
+		out["currency"] = strings.ToUpper(currency)
+	}
+	return out
+}
+
+// stripeValue converts a Stripe field value given its key
+func stripeValue(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return stripeObject(v)
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if value := stripeValue("", item); value != nil {
+				list = append(list, value)
+			}
+		}
+		return list
+	case float64:
+		if isStripeEpochField(key) {
+			return map[string]interface{}{"N": strconv.FormatInt(int64(v), 10)}
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return nil
+}
+
+// isStripeEpochField reports whether a Stripe field holds epoch seconds
+func isStripeEpochField(key string) bool {
+	switch key {
+	case "created", "date", "period_start", "period_end", "current_period_start", "current_period_end",
+		"trial_start", "trial_end", "start_date", "billing_cycle_anchor", "due_date", "available_on":
+		return true
+	}
+	return strings.HasSuffix(key, "_at")
+}
+
+// isStripeAmountField reports whether a Stripe field holds an amount in the minor units of
+// its object's currency
+func isStripeAmountField(key string) bool {
+	return key == "amount" || strings.HasPrefix(key, "amount_") || strings.HasSuffix(key, "_amount")
+}
+
+// minorUnitsToDecimal converts an amount in minor units to a decimal string
+func minorUnitsToDecimal(amount int64, currency string) string {
+	exp, ok := currencyExponents[strings.ToUpper(currency)]
+	if !ok {
+		exp = 2
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(amount), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	return r.FloatString(exp)
+}
+
+// paypalPreset unwraps a PayPal webhook event into its resource, keeping the event id and
+// type. Money objects in either the {"currency_code", "value"} or the v1 {"total",
+// "currency"} form become the standard money shape, and numbers become strings.
+func paypalPreset(record Input) []Input {
+	resource, ok := record["resource"].(map[string]interface{})
+	if !ok {
+		return []Input{Input(paypalObject(record))}
+	}
+
+	out := Input(paypalObject(resource))
+	for key, field := range map[string]string{"id": "event_id", "event_type": "event_type", "resource_type": "resource_type", "create_time": "event_time"} {
+		if s, ok := record[key].(string); ok {
+			out[field] = s
+		}
+	}
+	return []Input{out}
+}
+
+// paypalMoney returns the value and currency of a PayPal money object, in either form
+func paypalMoney(m map[string]interface{}) (value, currency string, ok bool) {
+	if code, ok := m["currency_code"].(string); ok {
+		if value, ok := m["value"].(string); ok && len(m) == 2 {
+			return value, code, true
+		}
+	}
+	if currency, ok := m["currency"].(string); ok {
+		if total, ok := m["total"].(string); ok {
+			return total, currency, true
+		}
+	}
+	return "", "", false
+}
+
+// paypalObject converts the fields of a PayPal resource, normalizing money objects
+func paypalObject(m map[string]interface{}) map[string]interface{} {
+	if value, currency, ok := paypalMoney(m); ok {
+		return money(value, currency)
+	}
+
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k == "links" {
+			// HATEOAS links only point back at the API
+			continue
+		}
+		if object, ok := v.(map[string]interface{}); ok {
+			if value, currency, ok := paypalMoney(object); ok {
+				addMoney(out, k, value, currency)
+				continue
+			}
+		}
+		if value := paypalValue(v); value != nil {
+			out[k] = value
+		}
+	}
+	return out
+}
+
+// paypalValue converts a PayPal field value
+func paypalValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return paypalObject(v)
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if value := paypalValue(item); value != nil {
+				list = append(list, value)
+			}
+		}
+		return list
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestMinorUnitsToDecimal(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{1999, "usd", "19.99"},
+		{5, "EUR", "0.05"},
+		{-250, "gbp", "-2.50"},
+		{500, "JPY", "500"},
+		{1234, "kwd", "1.234"},
+	}
+	for _, tt := range tests {
+		if got := minorUnitsToDecimal(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("minorUnitsToDecimal(%d, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestStripePreset(t *testing.T) {
+	var event Input
+	err := json.Unmarshal([]byte(`{"id": "evt_1", "object": "event", "type": "charge.succeeded", "created": 1704164645,
+		"data": {"object": {"id": "ch_1", "object": "charge", "amount": 1999, "amount_refunded": 0, "currency": "usd",
+			"paid": true, "failure_code": null, "refunds": {"data": [{"amount": 500, "currency": "jpy"}]}}}}`), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{
+		"event_id": "evt_1", "event_type": "charge.succeeded", "event_created": map[string]interface{}{"N": "1704164645"},
+		"id": "ch_1", "object": "charge", "currency": "USD", "paid": "true",
+		"amount_value": "19.99", "amount_currency": "USD",
+		"amount_refunded_value": "0.00", "amount_refunded_currency": "USD",
+		"refunds": map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"amount_value": "500", "amount_currency": "JPY", "currency": "JPY"},
+		}},
+	}}
+	if got := stripePreset(event); !reflect.DeepEqual(got, want) {
+		t.Errorf("stripePreset = %#v, want %#v", got, want)
+	}
+}
+
+func TestPaypalPreset(t *testing.T) {
+	var event Input
+	err := json.Unmarshal([]byte(`{"id": "WH-1", "event_type": "PAYMENT.CAPTURE.COMPLETED", "resource_type": "capture",
+		"create_time": "2024-01-02T03:04:05Z",
+		"resource": {"id": "cap_1", "final_capture": true, "amount": {"currency_code": "usd", "value": "10.00"},
+			"seller_receivable_breakdown": {"paypal_fee": {"currency_code": "USD", "value": "0.59"}},
+			"legacy": {"total": "1.00", "currency": "EUR", "details": {}},
+			"links": [{"href": "https://api.paypal.com/v2/payments/captures/cap_1"}]}}`), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{
+		"event_id": "WH-1", "event_type": "PAYMENT.CAPTURE.COMPLETED", "resource_type": "capture",
+		"event_time": "2024-01-02T03:04:05Z",
+		"id":         "cap_1", "final_capture": "true",
+		"amount_value": "10.00", "amount_currency": "USD",
+		"seller_receivable_breakdown": map[string]interface{}{"paypal_fee_value": "0.59", "paypal_fee_currency": "USD"},
+		"legacy_value":                "1.00", "legacy_currency": "EUR",
+	}}
+	if got := paypalPreset(event); !reflect.DeepEqual(got, want) {
+		t.Errorf("paypalPreset = %#v, want %#v", got, want)
+	}
+}
+
+// TestStripePresetTransform checks that money and epoch fields keep their shape through the
+// transformer, which flattens nested objects and coerces strings by depth
+func TestStripePresetTransform(t *testing.T) {
+	var event Input
+	err := json.Unmarshal([]byte(`{"id": "evt_1", "object": "event", "type": "charge.succeeded", "created": 1704164645,
+		"data": {"object": {"id": "ch_1", "amount": 1999, "currency": "usd", "created": 1704164645,
+			"outcome": {"type": "authorized", "created": 1704164645}}}}`), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := (&transform.Transformer{}).TransformRecord(stripePreset(event)[0])
+	if err != nil || len(outputs) != 1 {
+		t.Fatalf("TransformRecord = %v, %v", outputs, err)
+	}
+	got, err := json.Marshal(outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The outcome's fields make up one element, with its creation time as epoch seconds
+	// as much as the top-level ones
+	want := `[{"amount_currency":"USD"},{"amount_value":"19.99"},{"created":1704164645},` +
+		`{"created":1704164645,"type":"authorized"},{"currency":"USD"},{"event_created":1704164645},` +
+		`{"event_id":"evt_1"},{"event_type":"charge.succeeded"},{"id":"ch_1"}]`
+	if string(got) != want {
+		t.Errorf("transformed Stripe event = %s, want %s", got, want)
+	}
+}
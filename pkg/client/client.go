@@ -0,0 +1,237 @@
+// Package client calls the HTTP API of the transform serve command, so that services using
+// a hosted transformer do not hand-roll requests, retries and timeouts.
+//
+//	c, err := client.NewClient("http://transform:8080", client.WithAPIKey(key), client.WithRetries(3, 100*time.Millisecond))
+//	outputs, err := c.Transform(ctx, client.Input{"name": " Ada "})
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Input is a record to transform, as decoded from JSON
+type Input map[string]interface{}
+
+// Output is the output of a record, as the server writes it
+type Output []map[string]interface{}
+
+// Client transforms records with a transform server. It is safe for concurrent use.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	apiKey     string
+	retries    int
+	backoff    time.Duration
+	timeout    time.Duration
+	options    map[string]string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey sends an API key with every request, as a bearer token
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithTenant sends requests to the /t/{name}/transform endpoint of a tenant rather than
+// to /transform
+func WithTenant(name string) Option {
+	return func(c *Client) {
+		c.endpoint = strings.TrimSuffix(c.endpoint, "/transform") + "/t/" + url.PathEscape(name) + "/transform"
+	}
+}
+
+// WithHTTPClient sends requests with an http.Client other than http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries retries requests failing with a network error or a 429, 502 or 503 status up
+// to n times, waiting backoff before the first retry and doubling it before each other,
+// unless the server asks for longer in a Retry-After header
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// WithTimeout bounds each attempt at a request. The server is told the time left to a
+// request in an X-Request-Timeout header, so that it drops requests the client gave up on.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// WithOption sets a request option, such as "strict" or "geojson", for every request, as
+// the server allows in its overrides
+func WithOption(name, value string) Option {
+	return func(c *Client) { c.options[name] = value }
+}
+
+// NewClient returns a client of the transform server at a base URL, such as
+// http://transform:8080
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: want http://host or https://host", baseURL)
+	}
+	c := &Client{
+		endpoint:   strings.TrimSuffix(u.String(), "/") + "/transform",
+		httpClient: http.DefaultClient,
+		options:    make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retries < 0 || c.backoff < 0 || c.timeout < 0 {
+		return nil, errors.New("retries, backoff and timeout must not be negative")
+	}
+	return c, nil
+}
+
+// Error is the error of a request the server failed, with the problems a strict server
+// found in the record
+type Error struct {
+	StatusCode int
+	Message    string
+	Problems   []string
+}
+
+// Error describes the failure
+func (e *Error) Error() string {
+	if len(e.Problems) > 0 {
+		return fmt.Sprintf("transform server: %d %s: %s", e.StatusCode, e.Message, strings.Join(e.Problems, "; "))
+	}
+	return fmt.Sprintf("transform server: %d %s", e.StatusCode, e.Message)
+}
+
+// Transform transforms a record, returning the output of each record it transforms to, as
+// a preset or rule may expand a record into several. Numbers are json.Number, keeping large
+// integers exact.
+func (c *Client) Transform(ctx context.Context, record Input) ([]Output, error) {
+	var outputs []Output
+	err := c.do(ctx, record, "array", func(body io.Reader) error {
+		dec := json.NewDecoder(body)
+		dec.UseNumber()
+		return dec.Decode(&outputs)
+	})
+	return outputs, err
+}
+
+// TransformStream transforms a record, passing the output of each record it transforms to
+// to fn as the server streams them as NDJSON. A request is not retried once its response
+// has started, and stops at the first error fn returns.
+func (c *Client) TransformStream(ctx context.Context, record Input, fn func(Output) error) error {
+	return c.do(ctx, record, "ndjson", func(body io.Reader) error {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+			dec.UseNumber()
+			var output Output
+			if err := dec.Decode(&output); err != nil {
+				return err
+			}
+			if err := fn(output); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	})
+}
+
+// do posts a record for a response shape, retrying failed attempts, and reads the body of
+// the response with read
+func (c *Client) do(ctx context.Context, record Input, shape string, read func(io.Reader) error) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		wait, err := c.attempt(ctx, body, shape, read)
+		if err == nil || wait < 0 || attempt >= c.retries {
+			return err
+		}
+		if wait < backoff {
+			wait = backoff
+		}
+		backoff *= 2
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// attempt makes one attempt at a request, returning with its error how long to wait before
+// retrying it, or a negative wait if it must not be retried
+func (c *Client) attempt(ctx context.Context, body []byte, shape string, read func(io.Reader) error) (time.Duration, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"?shape="+shape, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for name, value := range c.options {
+		req.Header.Set("X-Transform-"+name, value)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline).Milliseconds()
+		if left <= 0 {
+			return -1, context.DeadlineExceeded
+		}
+		req.Header.Set("X-Request-Timeout", strconv.FormatInt(left, 10))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The caller's context ending is final; other network errors are retried
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return -1, read(resp.Body)
+	}
+
+	e := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var payload struct {
+		Error    string   `json:"error"`
+		Problems []string `json:"problems"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload) == nil && payload.Error != "" {
+		e.Message, e.Problems = payload.Error, payload.Problems
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(seconds) * time.Second, e
+	}
+	return -1, e
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer answers as the serve command does, after failing the first failures requests
+// with status
+func fakeServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32, chan *http.Request) {
+	var calls int32
+	requests := make(chan *http.Request, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error":"busy"}`)
+			return
+		}
+		var record Input
+		json.NewDecoder(r.Body).Decode(&record)
+		output := Output{record}
+		if r.URL.Query().Get("shape") == "ndjson" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for i := 0; i < 2; i++ {
+				json.NewEncoder(w).Encode(output)
+			}
+			return
+		}
+		json.NewEncoder(w).Encode([]Output{output})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls, requests
+}
+
+func TestNewClient(t *testing.T) {
+	for _, url := range []string{"", "transform:8080", "ftp://transform", "http://"} {
+		if _, err := NewClient(url); err == nil {
+			t.Errorf("NewClient(%q) succeeded, want an error", url)
+		}
+	}
+	if _, err := NewClient("http://transform:8080", WithRetries(-1, 0)); err == nil {
+		t.Error("NewClient with negative retries succeeded, want an error")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	srv, _, requests := fakeServer(t, 0, 0)
+	c, err := NewClient(srv.URL+"/", WithAPIKey("secret"), WithTenant("billing"), WithOption("geojson", "wkt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := c.Transform(context.Background(), Input{"n": "12345678901234567890"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || outputs[0][0]["n"] != "12345678901234567890" {
+		t.Errorf("Transform = %v", outputs)
+	}
+
+	r := <-requests
+	if r.URL.Path != "/t/billing/transform" || r.URL.Query().Get("shape") != "array" {
+		t.Errorf("request to %s, want /t/billing/transform?shape=array", r.URL)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", got)
+	}
+	if got := r.Header.Get("X-Transform-Geojson"); got != "wkt" {
+		t.Errorf("X-Transform-Geojson = %q, want wkt", got)
+	}
+	if got := r.Header.Get("X-Request-Timeout"); got != "" {
+		t.Errorf("X-Request-Timeout = %q without a deadline, want none", got)
+	}
+}
+
+func TestTransformRetries(t *testing.T) {
+	tests := []struct {
+		failures, status int
+		retries          int
+		wantCalls        int32
+		wantStatus       int
+	}{
+		{2, http.StatusServiceUnavailable, 2, 3, 0},
+		{2, http.StatusTooManyRequests, 1, 2, http.StatusTooManyRequests},
+		{1, http.StatusBadGateway, 3, 2, 0},
+		{1, http.StatusUnprocessableEntity, 3, 1, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		srv, calls, _ := fakeServer(t, int32(tt.failures), tt.status)
+		c, err := NewClient(srv.URL, WithRetries(tt.retries, time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Transform(context.Background(), Input{"a": "1"})
+		var e *Error
+		if tt.wantStatus == 0 && err != nil || tt.wantStatus != 0 && (!errors.As(err, &e) || e.StatusCode != tt.wantStatus || e.Message != "busy") {
+			t.Errorf("%d failures of %d with %d retries: err = %v, want status %d", tt.failures, tt.status, tt.retries, err, tt.wantStatus)
+		}
+		if *calls != tt.wantCalls {
+			t.Errorf("%d failures of %d with %d retries: %d calls, want %d", tt.failures, tt.status, tt.retries, *calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestTransformError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusBadRequest, `{"error":"unknown option \"timezone\""}`, `transform server: 400 unknown option "timezone"`},
+		{http.StatusUnauthorized, `{"error":"invalid API key"}`, "transform server: 401 invalid API key"},
+		{http.StatusUnprocessableEntity, `{"error":"record has problems","problems":["a: invalid number"]}`, "transform server: 422 record has problems: a: invalid number"},
+		// Responses without an error message are described by their status
+		{http.StatusInternalServerError, `oops`, "transform server: 500 Internal Server Error"},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+		c, _ := NewClient(srv.URL)
+		_, err := c.Transform(context.Background(), Input{"a": "1"})
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != tt.status || err.Error() != tt.want {
+			t.Errorf("err = %v, want %q", err, tt.want)
+		}
+		srv.Close()
+	}
+}
+
+func TestTransformTimeout(t *testing.T) {
+	srv, _, requests := fakeServer(t, 0, 0)
+	c, _ := NewClient(srv.URL, WithTimeout(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := c.Transform(ctx, Input{"a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	ms, err := strconv.Atoi((<-requests).Header.Get("X-Request-Timeout"))
+	if err != nil || ms <= 0 || ms > 10000 {
+		t.Errorf("X-Request-Timeout = %d (%v), want the 10s left to the request", ms, err)
+	}
+
+	// The handler is released before the server is closed, which waits for it
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+	c, _ = NewClient(slow.URL, WithTimeout(20*time.Millisecond))
+	if _, err := c.Transform(context.Background(), Input{"a": "1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want a deadline exceeded", err)
+	}
+}
+
+func TestTransformStream(t *testing.T) {
+	srv, calls, requests := fakeServer(t, 1, http.StatusServiceUnavailable)
+	c, _ := NewClient(srv.URL, WithRetries(1, time.Millisecond))
+	var got []Output
+	err := c.TransformStream(context.Background(), Input{"a": "1"}, func(output Output) error {
+		got = append(got, output)
+		return nil
+	})
+	if err != nil || len(got) != 2 || got[1][0]["a"] != "1" || *calls != 2 {
+		t.Errorf("TransformStream = %v, %v after %d calls, want 2 outputs after 2 calls", got, err, *calls)
+	}
+	if r := <-requests; r.URL.Query().Get("shape") != "ndjson" {
+		t.Errorf("request to %s, want shape ndjson", r.URL)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = c.TransformStream(context.Background(), Input{"a": "1"}, func(Output) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("TransformStream = %v after %d outputs, want the error of fn after 1", err, n)
+	}
+}
@@ -0,0 +1,86 @@
+package transform
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// trackingParams are the query parameters of ad and newsletter click tracking dropped by
+// url rules, besides those starting with "utm_"
+var trackingParams = map[string]bool{
+	"gclid": true, "dclid": true, "gbraid": true, "wbraid": true, "fbclid": true,
+	"msclkid": true, "mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true,
+	"igshid": true, "yclid": true,
+}
+
+// applyEmailRule normalizes an email address, or an array of them, by lowercasing its
+// domain and, with strip_tags, removing the "+tag" of its local part. Strings that are not
+// a bare address fail the rule.
+func applyEmailRule(r Rule, value interface{}) (interface{}, error) {
+	return eachString(value, func(s string) (string, error) {
+		addr, err := mail.ParseAddress(strings.TrimSpace(s))
+		if err != nil || addr.Name != "" || addr.Address != strings.TrimSpace(s) {
+			return "", fmt.Errorf("invalid email address %q", s)
+		}
+		at := strings.LastIndex(addr.Address, "@")
+		local, domain := addr.Address[:at], addr.Address[at+1:]
+		if !strings.Contains(domain, ".") {
+			return "", fmt.Errorf("invalid email address %q", s)
+		}
+		if r.StripTags {
+			if plus := strings.Index(local, "+"); plus > 0 {
+				local = local[:plus]
+			}
+		}
+		return local + "@" + strings.ToLower(domain), nil
+	})
+}
+
+// validateURLRule checks the base of a url rule
+func validateURLRule(r Rule) error {
+	if r.Base == "" {
+		return nil
+	}
+	base, err := url.Parse(r.Base)
+	if err != nil || !base.IsAbs() || base.Host == "" {
+		return fmt.Errorf("base %q is not an absolute URL", r.Base)
+	}
+	return nil
+}
+
+// applyURLRule normalizes a URL, or an array of them: relative URLs are resolved against
+// the rule's base, the scheme and host are lowercased, default ports and tracking query
+// parameters such as utm_source and gclid are dropped. Strings that are not absolute
+// http or https URLs once resolved fail the rule.
+func applyURLRule(r Rule, value interface{}) (interface{}, error) {
+	return eachString(value, func(s string) (string, error) {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return "", fmt.Errorf("invalid URL %q", s)
+		}
+		if !u.IsAbs() && r.Base != "" {
+			base, _ := url.Parse(r.Base)
+			u = base.ResolveReference(u)
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("invalid URL %q", s)
+		}
+		u.Host = strings.ToLower(u.Host)
+		if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			u.Host = u.Hostname()
+		}
+		if u.RawQuery != "" {
+			query := u.Query()
+			for key := range query {
+				if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+					query.Del(key)
+				}
+			}
+			u.RawQuery = query.Encode()
+		}
+		return u.String(), nil
+	})
+}
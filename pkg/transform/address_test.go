@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEmailRule(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		value interface{}
+		want  interface{}
+	}{
+		{Rule{}, " Ada.Lovelace@Example.COM ", "Ada.Lovelace@example.com"},
+		{Rule{}, "ada+news@example.com", "ada+news@example.com"},
+		{Rule{StripTags: true}, "ada+news@Example.com", "ada@example.com"},
+		{Rule{StripTags: true}, []interface{}{"a+1@x.io", "b@X.io"}, []interface{}{"a@x.io", "b@x.io"}},
+	}
+	for _, tt := range tests {
+		got, err := applyEmailRule(tt.rule, tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("email %+v of %v = %v, %v, want %v", tt.rule, tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []interface{}{"ada", "ada@", "@example.com", "Ada <ada@example.com>", "ada@localhost", 1.0} {
+		if got, err := applyEmailRule(Rule{}, value); err == nil {
+			t.Errorf("email of %v = %v, want error", value, got)
+		}
+	}
+}
+
+func TestURLRule(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		value interface{}
+		want  interface{}
+	}{
+		{Rule{}, "HTTPS://Example.COM:443/a/B?utm_source=x&id=1&gclid=y#top", "https://example.com/a/B?id=1#top"},
+		{Rule{}, "http://example.com:8080/?UTM_Medium=email", "http://example.com:8080/"},
+		{Rule{Base: "https://example.com/docs/"}, "../img/a.png?fbclid=z", "https://example.com/img/a.png"},
+		{Rule{Base: "https://example.com/docs/"}, "http://other.org/x", "http://other.org/x"},
+	}
+	for _, tt := range tests {
+		got, err := applyURLRule(tt.rule, tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("url %+v of %v = %v, %v, want %v", tt.rule, tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []interface{}{"/relative", "mailto:ada@example.com", "http://", "%zz", 1.0} {
+		if got, err := applyURLRule(Rule{}, value); err == nil {
+			t.Errorf("url of %v = %v, want error", value, got)
+		}
+	}
+
+	if _, err := ParseRules([]byte(`{"rules": [{"field": "link", "action": "url", "base": "/docs"}]}`)); err == nil {
+		t.Error("ParseRules accepted a relative base")
+	}
+}
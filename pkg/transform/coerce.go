@@ -0,0 +1,172 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decimalPattern matches the plain decimal numbers converted by the number coercion
+var decimalPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// timestampPattern matches strings that start like RFC3339 timestamps, which strict mode
+// reports when they fail to parse
+var timestampPattern = regexp.MustCompile(`^\s*[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt ]`)
+
+// coercions converts a string to another type, reporting whether it applied
+var coercions = map[string]func(s string) (interface{}, bool){
+	"timestamp": func(s string) (interface{}, bool) {
+		ts, err := time.Parse(time.RFC3339, s)
+		return ts.Unix(), err == nil
+	},
+	"number": func(s string) (interface{}, bool) {
+		if !decimalPattern.MatchString(s) {
+			return nil, false
+		}
+		if i, err := strconv.Atoi(s); err == nil {
+			return i, true
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	},
+	"boolean": func(s string) (interface{}, bool) {
+		switch s {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+		return nil, false
+	},
+	// integer is the number coercion the transformer has always applied to list elements
+	"integer": func(s string) (interface{}, bool) {
+		if !isNumeric(s) {
+			return nil, false
+		}
+		return parseNumber(s), true
+	},
+	"string": func(s string) (interface{}, bool) {
+		return nil, false
+	},
+}
+
+// The coercions applied to string values where neither Transformer.Coercions nor a coerce
+// rule sets an order, as the transformer has always applied them
+var (
+	legacyFieldCoercion = []string{"timestamp"}
+	legacyMapCoercion   = []string{}
+	legacyListCoercion  = []string{"timestamp", "integer"}
+)
+
+// The locations of string values, which take different default coercions
+const (
+	fieldLocation = iota
+	mapLocation
+	listLocation
+)
+
+// LatestCompat is the compatibility level applied when Transformer.Compat is empty
+const LatestCompat = "v1"
+
+// compatLevels holds the default coercions of each compatibility level by location. v1 is
+// the behavior of the transformer before levels existed. Default coercions are only ever
+// added in a new level, so that pinning a level keeps outputs the same across upgrades.
+var compatLevels = map[string][3][]string{
+	"v1": {legacyFieldCoercion, legacyMapCoercion, legacyListCoercion},
+}
+
+// CompatLevels returns the sorted compatibility levels
+func CompatLevels() []string {
+	levels := make([]string, 0, len(compatLevels))
+	for level := range compatLevels {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	return levels
+}
+
+// ValidateCompat checks a value of Transformer.Compat
+func ValidateCompat(level string) error {
+	if _, ok := compatLevels[level]; !ok && level != "" {
+		return fmt.Errorf("unsupported compatibility level %q (want %s)", level, strings.Join(CompatLevels(), ", "))
+	}
+	return nil
+}
+
+// defaultCoercion returns the coercions of the Transformer's compatibility level for
+// string values at a location
+func (t *Transformer) defaultCoercion(location int) []string {
+	level := t.Compat
+	if level == "" {
+		level = LatestCompat
+	}
+	return compatLevels[level][location]
+}
+
+// ParseCoercionOrder parses a comma-separated coercion order such as
+// "timestamp,number,boolean,string". Values that no coercion applies to stay strings.
+func ParseCoercionOrder(s string) ([]string, error) {
+	order := []string{}
+	for _, kind := range strings.Split(s, ",") {
+		kind = strings.TrimSpace(kind)
+		if err := validateCoercion(kind); err != nil {
+			return nil, err
+		}
+		order = append(order, kind)
+	}
+	return order, nil
+}
+
+// validateCoercion checks that a coercion can be named in an order
+func validateCoercion(kind string) error {
+	switch kind {
+	case "timestamp", "number", "boolean", "string":
+		return nil
+	}
+	return fmt.Errorf("unsupported coercion %q (want timestamp, number, boolean or string)", kind)
+}
+
+// coerceString converts the string value of the field at path with the first coercion of
+// its order that applies, or returns it trimmed of whitespace. The order is that of a coerce
+// rule for the field, else that of the Transformer, else the default of its compatibility
+// level for the location. A Locale's dates, numbers and digits are tried before the plain
+// forms. Strict mode reports the strings that look like timestamps but fail to parse as one.
+func (r *run) coerceString(path, s string, defaults []string) interface{} {
+	order, ok := r.Rules.coercion(path, r)
+	if !ok {
+		order = r.Coercions
+	}
+	if order == nil {
+		order = defaults
+	}
+	for _, kind := range order {
+		if kind == "string" {
+			break
+		}
+		v, ok := r.Locale.coerce(kind, s)
+		if !ok {
+			v, ok = coercions[kind](s)
+		}
+		if ok {
+			if kind == "timestamp" && r.TypedTimestamps {
+				return Timestamp(v.(int64))
+			}
+			return v
+		}
+		if kind == "timestamp" && r.Strict && timestampPattern.MatchString(s) {
+			r.problems = append(r.problems, fmt.Sprintf("malformed timestamp %q at %q", s, path))
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// JoinPath appends a key to a field path, as named by rules
+func JoinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,88 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoerceString(t *testing.T) {
+	tests := []struct {
+		order  string
+		rules  []Rule
+		path   string
+		value  string
+		legacy []string
+		want   interface{}
+	}{
+		// Legacy coercions where no order is configured
+		{value: "2024-01-01T00:00:00Z", legacy: legacyFieldCoercion, want: int64(1704067200)},
+		{value: " 42 ", legacy: legacyFieldCoercion, want: "42"},
+		{value: "2024-01-01T00:00:00Z", legacy: legacyMapCoercion, want: "2024-01-01T00:00:00Z"},
+		{value: "42", legacy: legacyListCoercion, want: 42},
+		{value: "1.5", legacy: legacyListCoercion, want: "1.5"},
+
+		// A configured order applies everywhere
+		{order: "timestamp,number,boolean", value: "2024-01-01T00:00:00Z", legacy: legacyMapCoercion, want: int64(1704067200)},
+		{order: "timestamp,number,boolean", value: "42", legacy: legacyFieldCoercion, want: 42},
+		{order: "timestamp,number,boolean", value: "-1.5e3", legacy: legacyMapCoercion, want: -1500.0},
+		{order: "timestamp,number,boolean", value: "true", legacy: legacyListCoercion, want: true},
+		{order: "timestamp,number,boolean", value: "007", legacy: legacyFieldCoercion, want: "007"},
+		{order: "timestamp,number,boolean", value: "NaN", legacy: legacyFieldCoercion, want: "NaN"},
+		{order: "string", value: "42", legacy: legacyListCoercion, want: "42"},
+
+		// Coerce rules override the order for their field
+		{order: "number", rules: []Rule{{Field: "zip", Action: "coerce", Coerce: []string{"string"}}}, path: "zip", value: "02134", want: "02134"},
+		{order: "number", rules: []Rule{{Field: "zip", Action: "coerce", Coerce: []string{"string"}}}, path: "n", value: "2", want: 2},
+		{rules: []Rule{{Field: "items.flag", Action: "coerce", Coerce: []string{"boolean"}}}, path: "items.flag", value: "false", legacy: legacyMapCoercion, want: false},
+	}
+	for _, tt := range tests {
+		tr := Transformer{Rules: &RuleSet{Rules: tt.rules}}
+		if tt.order != "" {
+			var err error
+			if tr.Coercions, err = ParseCoercionOrder(tt.order); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := (&run{Transformer: &tr}).coerceString(tt.path, tt.value, tt.legacy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerceString(%q, %q) with order %q = %#v, want %#v", tt.path, tt.value, tt.order, got, tt.want)
+		}
+	}
+
+	if _, err := ParseCoercionOrder("timestamp,integer"); err == nil {
+		t.Errorf("ParseCoercionOrder accepted an unsupported coercion")
+	}
+}
+
+func TestCoerceRule(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"rules": [{"field": "zip", "action": "coerce", "coerce": ["string"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &run{Transformer: &Transformer{}}
+	if got, ok := rs.coercion("zip", tr); !ok || !reflect.DeepEqual(got, []string{"string"}) {
+		t.Errorf("coercion(zip) = %v, %v, want [string]", got, ok)
+	}
+	if _, ok := rs.coercion("zip.code", tr); ok {
+		t.Errorf("coercion(zip.code) found the rule of zip")
+	}
+	for _, data := range []string{
+		`{"rules": [{"field": "zip", "action": "coerce"}]}`,
+		`{"rules": [{"field": "zip", "action": "coerce", "coerce": ["date"]}]}`,
+	} {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("ParseRules(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestTypedTimestamps(t *testing.T) {
+	tr := Transformer{TypedTimestamps: true}
+	output, err := tr.Transform(Input{"at": "2024-01-02T03:04:05Z", "n": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Output{{"at": Timestamp(1704164645)}, {"n": "7"}}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("Transform = %#v, want %#v", output, want)
+	}
+}
@@ -0,0 +1,105 @@
+package transform
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// conformanceFiles holds the conformance corpus, a directory of cases for each
+// compatibility level
+//
+//go:embed conformance
+var conformanceFiles embed.FS
+
+// ConformanceCase is an input and the output a compatibility level guarantees for it. The
+// cases of a level never change once released; a change of behavior adds a new level
+// whose corpus gives the new outputs.
+type ConformanceCase struct {
+	// Name is the name of the case's file, without its extension
+	Name        string `json:"-"`
+	Description string `json:"description"`
+	// Coerce is the coercion order the input is transformed with, if not the default
+	Coerce string          `json:"coerce,omitempty"`
+	Input  Input           `json:"input"`
+	Output json.RawMessage `json:"output"`
+}
+
+// ConformanceCorpus returns the cases of a compatibility level, sorted by name
+func ConformanceCorpus(level string) ([]ConformanceCase, error) {
+	if err := ValidateCompat(level); err != nil {
+		return nil, err
+	}
+	if level == "" {
+		level = LatestCompat
+	}
+	dir := path.Join("conformance", level)
+	entries, err := conformanceFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []ConformanceCase
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := conformanceFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		c := ConformanceCase{Name: name}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("%s/%s: %v", level, e.Name(), err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// Check transforms the input of the case at a compatibility level and reports how its
+// output differs from the one guaranteed
+func (c ConformanceCase) Check(level string) error {
+	t := Transformer{Compat: level, Warnings: &WarningRules{Suppress: []string{"*"}}}
+	if c.Coerce != "" {
+		var err error
+		if t.Coercions, err = ParseCoercionOrder(c.Coerce); err != nil {
+			return err
+		}
+	}
+	output, err := t.Transform(c.Input)
+	if err != nil {
+		return err
+	}
+
+	// Compare the decoded JSON of both, so that number types and spacing do not matter
+	data, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	var got, want interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(c.Output, &want); err != nil {
+		return fmt.Errorf("invalid output: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("output %s, want %s", data, compactJSON(c.Output))
+	}
+	return nil
+}
+
+// compactJSON returns JSON text without insignificant whitespace
+func compactJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
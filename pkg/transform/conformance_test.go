@@ -0,0 +1,49 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConformanceCorpus(t *testing.T) {
+	for _, level := range CompatLevels() {
+		cases, err := ConformanceCorpus(level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cases) == 0 {
+			t.Errorf("level %s has no conformance cases", level)
+		}
+		for _, c := range cases {
+			if err := c.Check(level); err != nil {
+				t.Errorf("%s/%s: %v", level, c.Name, err)
+			}
+		}
+	}
+}
+
+func TestConformanceCheck(t *testing.T) {
+	c := ConformanceCase{Name: "wrong", Input: Input{"n": "42"}, Output: json.RawMessage(`[{"n": 42}]`)}
+	if err := c.Check("v1"); err == nil || !strings.Contains(err.Error(), `want [{"n":42}]`) {
+		t.Errorf("Check = %v, want a difference", err)
+	}
+	c.Coerce = "number"
+	if err := c.Check("v1"); err != nil {
+		t.Errorf("Check with a coercion order = %v", err)
+	}
+}
+
+func TestValidateCompat(t *testing.T) {
+	for _, level := range []string{"", "v1", LatestCompat} {
+		if err := ValidateCompat(level); err != nil {
+			t.Errorf("ValidateCompat(%q) = %v", level, err)
+		}
+	}
+	if err := ValidateCompat("v0"); err == nil {
+		t.Error("ValidateCompat(v0) succeeded, want error")
+	}
+	if _, err := ConformanceCorpus("v0"); err == nil {
+		t.Error("ConformanceCorpus(v0) succeeded, want error")
+	}
+}
@@ -0,0 +1,44 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountryRule(t *testing.T) {
+	tests := []struct {
+		target string
+		value  interface{}
+		want   interface{}
+	}{
+		{"", "DEU", "DE"},
+		{"alpha2", " germany ", "DE"},
+		{"", "276", "DE"},
+		{"", 840.0, "US"},
+		{"", "United States of America", "US"},
+		{"", "Bolivia", "BO"},
+		{"", "uk", "GB"},
+		{"", "Côte d'Ivoire", "CI"},
+		{"alpha3", "fr", "FRA"},
+		{"numeric", "Austria", "040"},
+		{"numeric", "40", "040"},
+		{"name", "KOR", "South Korea"},
+		{"", []interface{}{"Japan", "NLD", 724.0}, []interface{}{"JP", "NL", "ES"}},
+	}
+	for _, tt := range tests {
+		got, err := applyCountryRule(Rule{Target: tt.target}, tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("country %s of %v = %v, %v, want %v", tt.target, tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []interface{}{"Atlantis", "XX", 999.0, 1.5, true, []interface{}{"DE", "Narnia"}} {
+		if got, err := applyCountryRule(Rule{}, value); err == nil {
+			t.Errorf("country of %v = %v, want error", value, got)
+		}
+	}
+
+	if _, err := ParseRules([]byte(`{"rules": [{"field": "country", "action": "country", "target": "alpha4"}]}`)); err == nil {
+		t.Error("ParseRules accepted an unsupported target")
+	}
+}
@@ -0,0 +1,182 @@
+package transform
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Coverage counts, across the records a Transformer transforms, how many records each rule
+// matched and which input field paths the records held, to find stale rules and unhandled
+// fields. The zero value is ready to use, and a Coverage may be shared by Transformers
+// running in parallel.
+type Coverage struct {
+	mu      sync.Mutex
+	records int
+	// matches counts the records each rule matched, by rule index
+	matches map[int]int
+	// paths counts the records holding each output field path
+	paths map[string]int
+}
+
+// CoverageReport is the coverage of a rule set over the records transformed
+type CoverageReport struct {
+	Records int            `json:"records"`
+	Rules   []RuleCoverage `json:"rules"`
+	// Unhandled are the field paths of values that no rule names, nor a field above them
+	Unhandled []PathCoverage `json:"unhandled"`
+}
+
+// RuleCoverage is the number of records a rule matched: records for which its when
+// expression held and its field had a value
+type RuleCoverage struct {
+	// Index is the 1-based position of the rule in the rule set, as in lint findings
+	Index   int    `json:"index"`
+	Action  string `json:"action"`
+	Field   string `json:"field"`
+	Matches int    `json:"matches"`
+}
+
+// PathCoverage is the number of records holding a value at a field path
+type PathCoverage struct {
+	Path    string `json:"path"`
+	Records int    `json:"records"`
+}
+
+// newRun starts the run of a record, which counts the rules it matches when the
+// Transformer has a Coverage
+func (t *Transformer) newRun() *run {
+	r := &run{Transformer: t}
+	if t.Coverage != nil {
+		r.matched = make(map[int]bool)
+	}
+	return r
+}
+
+// match records that a rule, by index, matched the record of a run
+func (r *run) match(i int) {
+	if r.matched != nil {
+		r.matched[i] = true
+	}
+}
+
+// add counts the record of a run: the rules it matched, including passthrough rules for
+// the subtrees it copied, and the field paths of its values
+func (c *Coverage) add(r *run) {
+	if c == nil || r.input == nil {
+		return
+	}
+	paths := make(map[string]bool)
+	for key, value := range r.input {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if m, ok := value.(map[string]interface{}); ok {
+			if _, _, ok := descriptor(m); !ok {
+				// Top-level objects are flattened into the output
+				leafPaths(m, "", paths)
+				continue
+			}
+		}
+		leafPaths(value, key, paths)
+	}
+	if rs := r.Rules; rs != nil {
+		for i, rule := range rs.Rules {
+			if rule.Action == "passthrough" && hasRawValue(r.input, rule.Field) {
+				r.matched[i] = true
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.matches == nil {
+		c.matches, c.paths = make(map[int]int), make(map[string]int)
+	}
+	c.records++
+	for i := range r.matched {
+		c.matches[i]++
+	}
+	for path := range paths {
+		c.paths[path]++
+	}
+}
+
+// leafPaths adds the output field paths of the values in a value found at a path that are
+// not objects. Elements of lists share the path of the list, and passthrough subtrees are
+// left out as their rules handle them.
+func leafPaths(value interface{}, path string, paths map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, _, ok := descriptor(v); ok || len(v) == 0 {
+			break
+		}
+		for key, e := range v {
+			leafPaths(e, JoinPath(path, strings.TrimSpace(key)), paths)
+		}
+		return
+	case []interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for _, e := range v {
+			leafPaths(e, path, paths)
+		}
+		return
+	case json.RawMessage:
+		return
+	}
+	if path != "" {
+		paths[path] = true
+	}
+}
+
+// hasRawValue reports whether an input holds a passthrough subtree at an input field path
+func hasRawValue(input Input, path string) bool {
+	var value interface{} = map[string]interface{}(input)
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		value = m[key]
+	}
+	_, ok := value.(json.RawMessage)
+	return ok
+}
+
+// Report returns the coverage of a rule set over the records counted so far, with the
+// unhandled paths sorted by path
+func (c *Coverage) Report(rs *RuleSet) CoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := CoverageReport{Records: c.records, Rules: []RuleCoverage{}, Unhandled: []PathCoverage{}}
+	var fields []string
+	if rs != nil {
+		for i, r := range rs.Rules {
+			report.Rules = append(report.Rules, RuleCoverage{Index: i + 1, Action: r.Action, Field: r.Field, Matches: c.matches[i]})
+			if r.Action != "passthrough" {
+				fields = append(fields, r.Field)
+			}
+		}
+	}
+	for path, n := range c.paths {
+		if !handled(path, fields) {
+			report.Unhandled = append(report.Unhandled, PathCoverage{Path: path, Records: n})
+		}
+	}
+	sort.Slice(report.Unhandled, func(i, j int) bool { return report.Unhandled[i].Path < report.Unhandled[j].Path })
+	return report
+}
+
+// handled reports whether a field path is one of the fields of rules, or lies under one
+func handled(path string, fields []string) bool {
+	for _, f := range fields {
+		if path == f || strings.HasPrefix(path, f+".") {
+			return true
+		}
+	}
+	return false
+}
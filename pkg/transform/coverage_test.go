@@ -0,0 +1,49 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"rules": [
+		{"field": "email", "action": "lowercase"},
+		{"field": "price", "action": "coerce", "coerce": ["number"], "when": "record.country == 'US'"},
+		{"field": "items", "action": "sort"},
+		{"field": "legacy", "action": "uppercase"},
+		{"field": "blob", "action": "passthrough"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Transformer{Rules: rs, Coverage: &Coverage{}}
+	for _, input := range []Input{
+		{"email": "A@B.COM", "price": "1.5", "country": "US", "items": []interface{}{map[string]interface{}{"n": "2"}}},
+		{"email": "C@D.COM", "price": "2", "country": "FR", "user": map[string]interface{}{"id": map[string]interface{}{"N": "7"}}},
+		{"blob": json.RawMessage(`{"x": 1}`), "tags": []interface{}{}},
+	} {
+		if _, err := tr.TransformRecord(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := CoverageReport{
+		Records: 3,
+		Rules: []RuleCoverage{
+			{Index: 1, Action: "lowercase", Field: "email", Matches: 2},
+			{Index: 2, Action: "coerce", Field: "price", Matches: 1},
+			{Index: 3, Action: "sort", Field: "items", Matches: 1},
+			{Index: 4, Action: "uppercase", Field: "legacy", Matches: 0},
+			{Index: 5, Action: "passthrough", Field: "blob", Matches: 1},
+		},
+		Unhandled: []PathCoverage{
+			{Path: "country", Records: 2},
+			{Path: "id", Records: 1},
+			{Path: "tags", Records: 1},
+		},
+	}
+	if got := tr.Coverage.Report(rs); !reflect.DeepEqual(got, want) {
+		t.Errorf("Report =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Decode reads a JSON object from r as a record, following the rules: the values at the
+// paths of passthrough rules are kept as undecoded json.RawMessage, and when the rules list
+// fields, only the values at those paths are materialized while all others are skipped in
+// the token stream. Without such rules the object is decoded as a whole.
+func (t *Transformer) Decode(r io.Reader) (Input, error) {
+	var rawPaths, fields []string
+	if t.Rules != nil {
+		rawPaths, fields = t.Rules.PassthroughPaths(), t.Rules.Fields
+	}
+	if len(rawPaths) == 0 && len(fields) == 0 {
+		var record Input
+		if err := json.NewDecoder(r).Decode(&record); err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+	return decodeObject(json.NewDecoder(r), "", rawPaths, fields)
+}
+
+// decodeObject decodes the JSON object read next from dec, found at path. Values at raw
+// paths are kept as json.RawMessage. When fields are given, only the values at those paths
+// are materialized and all others are skipped. Only the objects on the way to such paths
+// are decoded key by key.
+func decodeObject(dec *json.Decoder, path string, rawPaths, fields []string) (map[string]interface{}, error) {
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object, found %v", tok)
+	}
+
+	m := make(map[string]interface{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		fieldPath := JoinPath(path, key)
+
+		raw, wanted, nested := false, len(fields) == 0, false
+		for _, p := range rawPaths {
+			raw = raw || p == fieldPath
+			nested = nested || strings.HasPrefix(p, fieldPath+".")
+		}
+		for _, p := range fields {
+			wanted = wanted || p == fieldPath || strings.HasPrefix(fieldPath, p+".")
+			nested = nested || strings.HasPrefix(p, fieldPath+".")
+		}
+
+		var value json.RawMessage
+		switch {
+		case raw:
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			m[key] = value
+		case nested:
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			if !bytes.HasPrefix(value, []byte("{")) {
+				if wanted {
+					var v interface{}
+					if err := json.Unmarshal(value, &v); err != nil {
+						return nil, err
+					}
+					m[key] = v
+				}
+				continue
+			}
+			child, err := decodeObject(json.NewDecoder(bytes.NewReader(value)), fieldPath, rawPaths, fields)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = child
+		case wanted:
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			m[key] = v
+		default:
+			// Skip values the fields do not list without building them
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
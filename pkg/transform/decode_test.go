@@ -0,0 +1,57 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFields(t *testing.T) {
+	doc := `{"id": "1", "name": "Ada", "big": {"list": [1, 2, 3], "deep": {"x": 1}},
+		"address": {"city": "London", "zip": "N1", "geo": {"lat": 1.5, "lng": 2.5}},
+		"tags": ["a"], "raw": {"keep": true}, "scalar": 5}`
+	tests := []struct {
+		fields   []string
+		rawPaths []string
+		want     Input
+	}{
+		{
+			fields: []string{"id", "address.city", "address.geo", "scalar.x"},
+			want: Input{
+				"id":      "1",
+				"address": map[string]interface{}{"city": "London", "geo": map[string]interface{}{"lat": 1.5, "lng": 2.5}},
+			},
+		},
+		{
+			fields:   []string{"tags", "address.geo.lat"},
+			rawPaths: []string{"raw"},
+			want: Input{
+				"tags":    []interface{}{"a"},
+				"address": map[string]interface{}{"geo": map[string]interface{}{"lat": 1.5}},
+				"raw":     json.RawMessage(`{"keep": true}`),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tr := Transformer{Rules: &RuleSet{Fields: tt.fields}}
+		for _, p := range tt.rawPaths {
+			tr.Rules.Rules = append(tr.Rules.Rules, Rule{Field: p, Action: "passthrough"})
+		}
+		record, err := tr.Decode(strings.NewReader(doc))
+		if err != nil {
+			t.Errorf("Decode with fields %q error: %v", tt.fields, err)
+			continue
+		}
+		if !reflect.DeepEqual(record, tt.want) {
+			t.Errorf("Decode with fields %q = %#v, want %#v", tt.fields, record, tt.want)
+		}
+	}
+
+	for _, doc := range []string{`[1]`, `{"id": }`, `{"id": "1"`} {
+		tr := Transformer{Rules: &RuleSet{Fields: []string{"id"}}}
+		if _, err := tr.Decode(strings.NewReader(doc)); err == nil {
+			t.Errorf("Decode(%s) succeeded, want error", doc)
+		}
+	}
+}
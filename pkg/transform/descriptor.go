@@ -0,0 +1,196 @@
+package transform
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// descriptorKinds checks the value of each DynamoDB-style type descriptor, such as
+// {"S": "foo"} or {"N": "123"}
+var descriptorKinds = map[string]func(v interface{}) bool{
+	"S":    isString,
+	"N":    isString,
+	"B":    isString,
+	"BOOL": isStringOrBool,
+	"NULL": isStringOrBool,
+	"M": func(v interface{}) bool {
+		_, ok := v.(map[string]interface{})
+		return ok
+	},
+	"L":  isList,
+	"SS": isList,
+	"NS": isList,
+	"BS": isList,
+}
+
+// isString reports whether a descriptor value is a string
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+// isList reports whether a descriptor value is a list
+func isList(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
+}
+
+// isStringOrBool reports whether a descriptor value is a string or a boolean
+func isStringOrBool(v interface{}) bool {
+	switch v.(type) {
+	case string, bool:
+		return true
+	}
+	return false
+}
+
+// descriptor reports the kind and value of a map that is a DynamoDB-style type descriptor:
+// a map with a single type key holding a value of that type
+func descriptor(m map[string]interface{}) (string, interface{}, bool) {
+	if len(m) != 1 {
+		return "", nil, false
+	}
+	for kind, v := range m {
+		if valid, ok := descriptorKinds[kind]; ok && valid(v) {
+			return kind, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// transformDescriptor unwraps the value of a type descriptor found at an output field path
+// into a native JSON value, reporting false when the field is to be omitted: for empty
+// strings, maps, lists and sets, invalid numbers and booleans, and false NULLs. Strings are
+// coerced like top-level string fields.
+func (r *run) transformDescriptor(kind string, v interface{}, path string) (interface{}, bool) {
+	switch kind {
+	case "S":
+		s := v.(string)
+		if strings.TrimSpace(s) == "" {
+			return nil, false
+		}
+		return r.coerceString(path, s, r.defaultCoercion(fieldLocation)), true
+	case "N":
+		n, err := parseDescriptorNumber(v.(string))
+		if err != nil {
+			r.skip(path, v, "invalid-number", "invalid number %q at %q", v, path)
+			return nil, false
+		}
+		return n, true
+	case "B":
+		data, err := decodeBinary(v.(string))
+		if err != nil {
+			r.skip(path, v, "invalid-base64", "invalid base64 value at %q: %v", path, err)
+			return nil, false
+		}
+		return r.binaryValue(data), true
+	case "BOOL", "NULL":
+		b, ok := v.(bool)
+		if !ok {
+			var err error
+			if b, err = strconv.ParseBool(strings.TrimSpace(v.(string))); err != nil {
+				r.skip(path, v, "invalid-boolean", "invalid %s value %q at %q", kind, v, path)
+				return nil, false
+			}
+		}
+		if kind == "NULL" {
+			return nil, b
+		}
+		return b, true
+	case "M":
+		m := r.transformMap(v.(map[string]interface{}), path)
+		return m, len(m) > 0
+	case "L":
+		l := r.transformList(v.([]interface{}), path)
+		return l, len(l) > 0
+	case "SS", "NS", "BS":
+		set := r.transformSet(kind, v.([]interface{}), path)
+		return set, len(set) > 0
+	}
+	return nil, false
+}
+
+// parseDescriptorNumber parses the string of a number descriptor, ignoring surrounding
+// whitespace and leading zeros. NaN and infinities are rejected, as JSON cannot encode them.
+func parseDescriptorNumber(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	if i, err := strconv.Atoi(s); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return nil, fmt.Errorf("non-finite number %q", s)
+	}
+	return f, err
+}
+
+// transformSet converts the members of a string, number or binary set to native values,
+// dropping invalid members, and returns them deduplicated and sorted: strings lexically,
+// numbers by value and binary values by their bytes
+func (r *run) transformSet(kind string, members []interface{}, path string) []interface{} {
+	type member struct {
+		value interface{}
+		key   interface{}
+	}
+	var valid []member
+	for _, m := range members {
+		s, ok := m.(string)
+		if !ok {
+			r.skip(path, m, "invalid-set-member", "non-string %s member at %q", kind, path)
+			continue
+		}
+		switch kind {
+		case "SS":
+			if s = strings.TrimSpace(s); s != "" {
+				valid = append(valid, member{value: s, key: s})
+			}
+		case "NS":
+			n, err := parseDescriptorNumber(s)
+			if err != nil {
+				r.skip(path, s, "invalid-number", "invalid number %q in NS at %q", s, path)
+				continue
+			}
+			valid = append(valid, member{value: n, key: n})
+		case "BS":
+			data, err := decodeBinary(s)
+			if err != nil {
+				r.skip(path, s, "invalid-base64", "invalid base64 value in BS at %q: %v", path, err)
+				continue
+			}
+			valid = append(valid, member{value: r.binaryValue(data), key: string(data)})
+		}
+	}
+
+	// Byte strings and numbers both sort and compare correctly with compareValues
+	sort.SliceStable(valid, func(i, j int) bool { return compareValues(valid[i].key, valid[j].key) < 0 })
+	var set []interface{}
+	for i, m := range valid {
+		if i > 0 && compareValues(valid[i-1].key, m.key) == 0 {
+			continue
+		}
+		set = append(set, m.value)
+	}
+	return set
+}
+
+// binaryValue returns the output value of binary data: its standard base64 encoding, or
+// the bytes themselves when the Transformer keeps raw binary
+func (t *Transformer) binaryValue(data []byte) interface{} {
+	if t.RawBinary {
+		return data
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeBinary decodes the base64 of a binary descriptor, with or without padding
+func decodeBinary(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "=") || len(s)%4 == 0 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
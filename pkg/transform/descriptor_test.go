@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDescriptors(t *testing.T) {
+	input := Input{
+		"s":     map[string]interface{}{"S": " foo "},
+		"ts":    map[string]interface{}{"S": "2024-01-01T00:00:00Z"},
+		"empty": map[string]interface{}{"S": "  "},
+		"n":     map[string]interface{}{"N": " 0012 "},
+		"f":     map[string]interface{}{"N": "1.50"},
+		"bad":   map[string]interface{}{"N": "1.5.0"},
+		"b":     map[string]interface{}{"BOOL": "f"},
+		"bt":    map[string]interface{}{"BOOL": true},
+		"null":  map[string]interface{}{"NULL": "1"},
+		"nnull": map[string]interface{}{"NULL": "false"},
+		"m": map[string]interface{}{"M": map[string]interface{}{
+			"a": map[string]interface{}{"N": "1"},
+			"z": map[string]interface{}{"L": []interface{}{}},
+		}},
+		"l": map[string]interface{}{"L": []interface{}{
+			map[string]interface{}{"S": "x"},
+			map[string]interface{}{"N": "2"},
+			map[string]interface{}{"NULL": "0"},
+		}},
+		// Maps that are not descriptors, or descriptors of the wrong type, are flattened
+		"plain": map[string]interface{}{"S": []interface{}{"a"}},
+	}
+	output, err := Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]interface{})
+	for _, m := range output {
+		for k, v := range m {
+			got[k] = v
+		}
+	}
+	want := map[string]interface{}{
+		"s":    "foo",
+		"ts":   int64(1704067200),
+		"n":    12,
+		"f":    1.5,
+		"b":    false,
+		"bt":   true,
+		"null": nil,
+		"m":    map[string]interface{}{"a": 1},
+		"l":    []interface{}{"x", 2},
+		"S":    []interface{}{"a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform = %#v, want %#v", got, want)
+	}
+}
+
+func TestSetDescriptors(t *testing.T) {
+	tests := []struct {
+		kind    string
+		members []interface{}
+		want    []interface{}
+	}{
+		{"SS", []interface{}{"b", " a ", "b", "", 3, "c"}, []interface{}{"a", "b", "c"}},
+		{"NS", []interface{}{"10", "2", "2.0", "x", " 1.5 ", "010"}, []interface{}{1.5, 2, 10}},
+		{"BS", []interface{}{"Ag==", "AQ", "AQ==", "!!", "/w=="}, []interface{}{"AQ==", "Ag==", "/w=="}},
+		{"SS", []interface{}{" ", 1}, nil},
+	}
+	for _, tt := range tests {
+		if got := (&run{Transformer: &Transformer{}}).transformSet(tt.kind, tt.members, "x"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("transformSet(%s, %v) = %#v, want %#v", tt.kind, tt.members, got, tt.want)
+		}
+	}
+
+	output, err := Transform(Input{"tags": map[string]interface{}{"SS": []interface{}{"b", "a"}}, "none": map[string]interface{}{"NS": []interface{}{"x"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Output{{"tags": []interface{}{"a", "b"}}}); !reflect.DeepEqual(output, want) {
+		t.Errorf("Transform = %v, want %v", output, want)
+	}
+}
+
+func TestNonFiniteNumberDescriptors(t *testing.T) {
+	input := Input{
+		"inf": map[string]interface{}{"N": "Inf"},
+		"nan": map[string]interface{}{"N": " NaN "},
+		"set": map[string]interface{}{"NS": []interface{}{"-Infinity", "1"}},
+	}
+	output, err := Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output must stay encodable as JSON
+	if want := (Output{{"set": []interface{}{1}}}); !reflect.DeepEqual(output, want) {
+		t.Errorf("Transform = %v, want %v", output, want)
+	}
+	if _, err := json.Marshal(output); err != nil {
+		t.Errorf("encoding the output: %v", err)
+	}
+
+	var strictErr *StrictError
+	tr := Transformer{Strict: true}
+	if _, err := tr.Transform(input); !errors.As(err, &strictErr) || len(strictErr.Problems) != 3 {
+		t.Errorf("strict Transform error = %v, want 3 invalid numbers", err)
+	}
+}
+
+func TestBinaryDescriptor(t *testing.T) {
+	input := Input{"b": map[string]interface{}{"B": "aGk"}, "bad": map[string]interface{}{"B": "not base64!"}}
+	output, err := Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Output{{"b": "aGk="}}); !reflect.DeepEqual(output, want) {
+		t.Errorf("Transform = %v, want %v", output, want)
+	}
+
+	tr := Transformer{RawBinary: true}
+	output, err = tr.Transform(Input{"b": map[string]interface{}{"B": "aGk="}, "bs": map[string]interface{}{"BS": []interface{}{"Ag==", "AQ=="}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]interface{})
+	for _, m := range output {
+		for k, v := range m {
+			got[k] = v
+		}
+	}
+	want := map[string]interface{}{"b": []byte("hi"), "bs": []interface{}{[]byte{1}, []byte{2}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform with RawBinary = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package transform
+
+// Event describes a change made to a field while transforming a record, so that consumers
+// can replay or react to individual changes. Actions are rename (a key trimmed of
+// whitespace), trim, coerce, unwrap (a type descriptor), flatten (a top-level object merged
+// into the record), drop (a skipped field or element) and the actions of rules.
+type Event struct {
+	Path   string      `json:"path"`
+	Action string      `json:"action"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+}
+
+// TransformEvents transforms a record like TransformRecord and also returns the events
+// describing each change, in the order they were made
+func (t *Transformer) TransformEvents(input Input) ([]Output, []Event, error) {
+	r := t.newRun()
+	r.events = []Event{}
+	defer t.Coverage.add(r)
+	output, err := r.transform(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs, err := t.Rules.apply(output, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputs, r.events, nil
+}
+
+// event records a change when the run records events
+func (r *run) event(path, action string, old, new interface{}) {
+	if r.events != nil {
+		r.events = append(r.events, Event{Path: path, Action: action, Old: old, New: new})
+	}
+}
+
+// stringEvent records the change coercion made to a string value, if any
+func (r *run) stringEvent(path, old string, new interface{}) {
+	if s, ok := new.(string); ok {
+		if s != old {
+			r.event(path, "trim", old, s)
+		}
+		return
+	}
+	r.event(path, "coerce", old, new)
+}
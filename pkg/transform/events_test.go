@@ -0,0 +1,51 @@
+package transform
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTransformEvents(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"rules": [{"field": "tags", "action": "sort"}, {"field": "ids", "action": "sort"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Transformer{Rules: rules}
+	input := Input{
+		" name ":  " Ada ",
+		"created": "2024-01-01T00:00:00Z",
+		"n":       map[string]interface{}{"N": "5"},
+		"address": map[string]interface{}{"city": "London"},
+		"tags":    []interface{}{"b", "a"},
+		"ids":     []interface{}{"1"},
+		"bad":     true,
+	}
+	outputs, events, err := tr.TransformEvents(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 {
+		t.Fatalf("TransformEvents returned %d records, want 1", len(outputs))
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	want := []Event{
+		{Path: "address", Action: "flatten", Old: map[string]interface{}{"city": "London"}, New: map[string]interface{}{"city": "London"}},
+		{Path: "bad", Action: "drop", Old: true},
+		{Path: "created", Action: "coerce", Old: "2024-01-01T00:00:00Z", New: int64(1704067200)},
+		{Path: "ids", Action: "coerce", Old: "1", New: 1},
+		{Path: "n", Action: "unwrap", Old: map[string]interface{}{"N": "5"}, New: 5},
+		{Path: "name", Action: "rename", Old: " name ", New: "name"},
+		{Path: "name", Action: "trim", Old: " Ada ", New: "Ada"},
+		{Path: "tags", Action: "sort", Old: []interface{}{"b", "a"}, New: []interface{}{"a", "b"}},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events =\n%v\nwant\n%v", events, want)
+	}
+
+	// Transform records no events
+	r := &run{Transformer: &tr}
+	if _, err := r.transform(input); err != nil || r.events != nil {
+		t.Errorf("transform recorded events %v, %v", r.events, err)
+	}
+}
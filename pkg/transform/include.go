@@ -0,0 +1,298 @@
+package transform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lock pins the modules included by a rules file to the checksums of their contents, and
+// those fetched with git to the commits their refs resolved to, so that a module that
+// changes after locking fails to load instead of silently changing the rules
+type Lock struct {
+	Modules []LockedModule `json:"modules"`
+}
+
+// LockedModule is the pinned version of an included module
+type LockedModule struct {
+	Source string `json:"source"`
+	Commit string `json:"commit,omitempty"`
+	SHA256 string `json:"sha256"`
+}
+
+// LockPath returns the path of the lock file of a rules file: rules.lock for rules.json
+func LockPath(rulesPath string) string {
+	return strings.TrimSuffix(rulesPath, filepath.Ext(rulesPath)) + ".lock"
+}
+
+// module is the source of an included module: a path relative to the including file, or a
+// file of a git repository at a ref, written "git::https://host/repo.git//path/rules.yaml@v1.2.0"
+type module struct {
+	repo string
+	path string
+	ref  string
+}
+
+// includeSchemes are the URL schemes of the repositories git modules may be fetched from.
+// Others, such as file:// or git's ext:: transport, could read or run anything on the
+// machine loading the rules.
+var includeSchemes = map[string]bool{"https": true, "ssh": true}
+
+// parseModule parses the source of an include
+func parseModule(source string) (module, error) {
+	rest, ok := strings.CutPrefix(source, "git::")
+	if !ok {
+		if source == "" {
+			return module{}, fmt.Errorf("empty include")
+		}
+		return module{path: source}, nil
+	}
+	// The ref follows the last "@", as URLs may have users such as git@
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return module{}, fmt.Errorf("invalid include %q: want git::https://host/repo.git//path@ref", source)
+	}
+	rest, ref := rest[:at], rest[at+1:]
+	// The repository URL ends at the first "//" after its scheme
+	scheme, after, _ := strings.Cut(rest, "://")
+	repo, path, ok := strings.Cut(after, "//")
+	if !ok || ref == "" || path == "" {
+		return module{}, fmt.Errorf("invalid include %q: want git::https://host/repo.git//path@ref", source)
+	}
+	if !includeSchemes[scheme] {
+		return module{}, fmt.Errorf("invalid include %q: repositories must be https:// or ssh://", source)
+	}
+	// Arguments starting with "-" would be taken by git as options
+	if strings.HasPrefix(repo, "-") || strings.HasPrefix(ref, "-") {
+		return module{}, fmt.Errorf("invalid include %q: repository and ref must not start with -", source)
+	}
+	return module{repo: scheme + "://" + repo, path: path, ref: ref}, nil
+}
+
+// fetch reads the contents of a module included by a file in dir, returning the commit of
+// git modules
+func (m module) fetch(dir string) ([]byte, string, error) {
+	if m.repo == "" {
+		path := m.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		return data, "", err
+	}
+
+	tmp, err := os.MkdirTemp("", "transform-rules-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(tmp)
+	git := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("git", append([]string{"-C", tmp}, args...)...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+	if _, err := git("init", "-q"); err != nil {
+		return nil, "", err
+	}
+	if _, err := git("fetch", "-q", "--depth", "1", "--", m.repo, m.ref); err != nil {
+		return nil, "", err
+	}
+	commit, err := git("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := git("show", "FETCH_HEAD:"+m.path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, strings.TrimSpace(string(commit)), nil
+}
+
+// checksum returns the hex SHA-256 of the contents of a module
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// moduleCachePath returns where a module with a checksum is cached, or "" when there is no
+// cache directory
+func moduleCachePath(sum string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "transform", "rules", sum)
+}
+
+// LoadRules reads and validates a rules file, in JSON or, for .yaml and .yml files, YAML.
+// The rules of its included modules come first, in order, each verified against the
+// rules file's lock file.
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := parseRulesFile(path, data)
+	if err != nil || len(rs.Include) == 0 {
+		return rs, err
+	}
+
+	lock, err := readLock(LockPath(path))
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[string]LockedModule)
+	for _, m := range lock.Modules {
+		locked[m.Source] = m
+	}
+	var modules []*RuleSet
+	for _, source := range rs.Include {
+		pin, ok := locked[source]
+		if !ok {
+			return nil, fmt.Errorf("include %q is missing from %s; run the rules lock command", source, LockPath(path))
+		}
+		mod, err := parseModule(source)
+		if err != nil {
+			return nil, err
+		}
+		data, err := mod.load(filepath.Dir(path), pin)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %v", source, err)
+		}
+		m, err := parseRulesFile(mod.path, data)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %v", source, err)
+		}
+		if len(m.Include) > 0 {
+			return nil, fmt.Errorf("include %q includes other modules, which is not supported", source)
+		}
+		modules = append(modules, m)
+	}
+	return rs.merge(modules), nil
+}
+
+// load reads a module whose contents must match its pinned version, from the cache when a
+// copy of a git module with its checksum is there
+func (m module) load(dir string, pin LockedModule) ([]byte, error) {
+	cachePath := ""
+	if m.repo != "" {
+		cachePath = moduleCachePath(pin.SHA256)
+		if data, err := os.ReadFile(cachePath); err == nil && checksum(data) == pin.SHA256 {
+			return data, nil
+		}
+	}
+	data, commit, err := m.fetch(dir)
+	if err != nil {
+		return nil, err
+	}
+	if commit != pin.Commit {
+		return nil, fmt.Errorf("%s resolves to commit %s, but the lock file has %s", m.ref, commit, pin.Commit)
+	}
+	if sum := checksum(data); sum != pin.SHA256 {
+		return nil, fmt.Errorf("checksum %s does not match the lock file's %s", sum, pin.SHA256)
+	}
+	if cachePath != "" {
+		// Caching is best effort, as the module can be fetched again
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			os.WriteFile(cachePath, data, 0o644)
+		}
+	}
+	return data, nil
+}
+
+// merge returns the rule set with the rules of the modules before its own. Input fields are
+// declared only if the rule set and every module declare them.
+func (rs *RuleSet) merge(modules []*RuleSet) *RuleSet {
+	merged := &RuleSet{Fields: rs.Fields, Examples: rs.Examples}
+	for _, m := range modules {
+		merged.Rules = append(merged.Rules, m.Rules...)
+		if len(m.Fields) == 0 {
+			merged.Fields = nil
+		} else if len(merged.Fields) > 0 {
+			for _, field := range m.Fields {
+				if !contains(merged.Fields, field) {
+					merged.Fields = append(merged.Fields, field)
+				}
+			}
+		}
+	}
+	merged.Rules = append(merged.Rules, rs.Rules...)
+	return merged
+}
+
+// LockRules fetches the modules included by a rules file and writes its lock file, pinning
+// each to its current contents and commit
+func LockRules(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := parseRulesFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+	lock := &Lock{Modules: []LockedModule{}}
+	for _, source := range rs.Include {
+		m, err := parseModule(source)
+		if err != nil {
+			return nil, err
+		}
+		data, commit, err := m.fetch(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %v", source, err)
+		}
+		if _, err := parseRulesFile(m.path, data); err != nil {
+			return nil, fmt.Errorf("include %q: %v", source, err)
+		}
+		lock.Modules = append(lock.Modules, LockedModule{Source: source, Commit: commit, SHA256: checksum(data)})
+	}
+	out, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return lock, os.WriteFile(LockPath(path), append(out, '\n'), 0o644)
+}
+
+// readLock reads a lock file
+func readLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("rules with includes need a lock file, %s; run the rules lock command", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return &lock, nil
+}
+
+// parseRulesFile parses the rules of a file named path, converting YAML files to JSON
+func parseRulesFile(path string, data []byte) (*RuleSet, error) {
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("decoding rules: %v", err)
+		}
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("decoding rules: %v", err)
+		}
+	}
+	return parseRules(data)
+}
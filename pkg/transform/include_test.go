@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncludeRules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	write := func(path, data string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", filepath.Join(dir, "repo"), "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	// A shared module in a git repository, tagged v1.0.0
+	write(filepath.Join(dir, "repo", "billing", "rules.yaml"), "rules:\n  - field: currency\n    action: uppercase\n")
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "Add billing rules")
+	git("tag", "v1.0.0")
+
+	// The repository is local, so file:// is allowed for the test
+	includeSchemes["file"] = true
+	t.Cleanup(func() { delete(includeSchemes, "file") })
+	source := "git::file://" + filepath.Join(dir, "repo") + "//billing/rules.yaml@v1.0.0"
+	write(filepath.Join(dir, "shared", "names.json"), `{"rules": [{"field": "name", "action": "titlecase"}]}`)
+	rulesPath := filepath.Join(dir, "rules.json")
+	write(rulesPath, `{"include": ["shared/names.json", "`+source+`"], "rules": [{"field": "tags", "action": "sort"}]}`)
+
+	if _, err := LoadRules(rulesPath); err == nil || !strings.Contains(err.Error(), "lock file") {
+		t.Fatalf("LoadRules without a lock file = %v, want lock file error", err)
+	}
+	lock, err := LockRules(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Modules) != 2 || lock.Modules[0].Commit != "" || len(lock.Modules[1].Commit) != 40 {
+		t.Fatalf("LockRules = %+v", lock)
+	}
+
+	rs, err := LoadRules(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{{Field: "name", Action: "titlecase"}, {Field: "currency", Action: "uppercase"}, {Field: "tags", Action: "sort"}}
+	if !reflect.DeepEqual(rs.Rules, want) {
+		t.Errorf("LoadRules = %+v, want %+v", rs.Rules, want)
+	}
+
+	// Moving the tag fails once the cached copy is gone
+	write(filepath.Join(dir, "repo", "billing", "rules.yaml"), "rules:\n  - field: currency\n    action: lowercase\n")
+	git("commit", "-q", "-am", "Lowercase currencies")
+	git("tag", "-f", "v1.0.0")
+	if _, err := LoadRules(rulesPath); err != nil {
+		t.Errorf("LoadRules with a cached module = %v", err)
+	}
+	os.RemoveAll(filepath.Join(dir, "cache"))
+	if _, err := LoadRules(rulesPath); err == nil || !strings.Contains(err.Error(), "resolves to commit") {
+		t.Errorf("LoadRules after moving the tag = %v, want commit error", err)
+	}
+
+	// Changing a local module fails its checksum
+	write(filepath.Join(dir, "shared", "names.json"), `{"rules": [{"field": "name", "action": "lowercase"}]}`)
+	if _, err := LoadRules(rulesPath); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("LoadRules after changing a module = %v, want checksum error", err)
+	}
+
+	if _, err := ParseRules([]byte(`{"include": ["names.json"], "rules": []}`)); err == nil {
+		t.Errorf("ParseRules resolved an include")
+	}
+}
+
+func TestParseModule(t *testing.T) {
+	m, err := parseModule("git::ssh://git@example.com/repo.git//billing/rules.yaml@v1.0.0")
+	if want := (module{repo: "ssh://git@example.com/repo.git", path: "billing/rules.yaml", ref: "v1.0.0"}); err != nil || m != want {
+		t.Errorf("parseModule = %+v, %v, want %+v", m, err, want)
+	}
+	for _, source := range []string{
+		"",
+		"git::https://example.com/repo.git//rules.json",
+		"git::https://example.com/rules.json@v1",
+		"git::file:///tmp/repo//rules.json@v1",
+		"git::ext::sh -c touch% /tmp/pwned//rules.json@v1",
+		"git::https://example.com/repo.git//rules.json@--upload-pack=touch /tmp/pwned",
+		"git::ssh://-oProxyCommand=touch /tmp/pwned//rules.json@v1",
+	} {
+		if _, err := parseModule(source); err == nil {
+			t.Errorf("parseModule(%q) succeeded, want error", source)
+		}
+	}
+}
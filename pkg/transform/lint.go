@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Example is an assertion embedded in a rules file: a record and the records it transforms
+// into with the file's rules
+type Example struct {
+	Name  string `json:"name,omitempty"`
+	Input Input  `json:"input"`
+	// Output lists the records written for the input, each an array of output maps as
+	// written by the transform command
+	Output []Output `json:"output"`
+}
+
+// caseActions are the actions that rewrite the case of a whole string, so that the last of
+// them on a field decides its case
+var caseActions = map[string]bool{"lowercase": true, "uppercase": true, "titlecase": true}
+
+// Lint reports the rules of a valid rules file that have no effect or contradict others:
+// rules duplicated or overridden by a later rule, renames clashing with another rule's, and
+// rules whose field no record can have because it is renamed away by an earlier rule, kept
+// undecoded by a passthrough rule or outside the declared input fields
+func (rs *RuleSet) Lint() []string {
+	var findings []string
+	report := func(i int, format string, args ...interface{}) {
+		r := rs.Rules[i]
+		findings = append(findings, fmt.Sprintf("rule %d (%s %s): %s", i+1, r.Action, r.Field, fmt.Sprintf(format, args...)))
+	}
+
+	for i, r := range rs.Rules {
+		if reason := rs.unreachable(i); reason != "" {
+			report(i, "unreachable, as %s", reason)
+		}
+		for j := i + 1; j < len(rs.Rules); j++ {
+			later := rs.Rules[j]
+			switch {
+			case later.Field == r.Field && ruleKey(later) == ruleKey(r):
+				report(i, "duplicated by rule %d", j+1)
+			case later.Field == r.Field && later.When == "" && r.Rename == "" &&
+				(r.Action == "coerce" && later.Action == "coerce" || caseActions[r.Action] && caseActions[later.Action]):
+				report(i, "overridden by rule %d (%s)", j+1, later.Action)
+			case r.Rename != "" && later.Rename == r.Rename && parentPath(later.Field) == parentPath(r.Field) && later.Field != r.Field:
+				report(i, "renames to %q like rule %d (%s %s)", r.Rename, j+1, later.Action, later.Field)
+			}
+		}
+	}
+	return findings
+}
+
+// unreachable returns why no record has the field of a rule, or "" if some may
+func (rs *RuleSet) unreachable(i int) string {
+	r := rs.Rules[i]
+	for j, earlier := range rs.Rules[:i] {
+		if earlier.Rename != "" && earlier.When == "" && hasPathPrefix(r.Field, earlier.Field) {
+			return fmt.Sprintf("rule %d renames %s to %s", j+1, earlier.Field, earlier.Rename)
+		}
+	}
+	if r.Action != "passthrough" {
+		for j, other := range rs.Rules {
+			if other.Action == "passthrough" && hasPathPrefix(r.Field, other.Field) {
+				return fmt.Sprintf("rule %d passes %s through undecoded", j+1, other.Field)
+			}
+		}
+	}
+	if len(rs.Fields) == 0 {
+		return ""
+	}
+	for _, field := range rs.Fields {
+		// Top-level objects are flattened, so that output paths drop leading input keys
+		keys := strings.Split(field, ".")
+		for k := range keys {
+			if path := strings.Join(keys[k:], "."); hasPathPrefix(r.Field, path) || hasPathPrefix(path, r.Field) {
+				return ""
+			}
+		}
+	}
+	return "no declared input field leads to it"
+}
+
+// hasPathPrefix reports whether a field path is prefix or one of the paths nested under it
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+".")
+}
+
+// parentPath returns the path of the object holding the key of a field path
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// ruleKey returns the settings of a rule as a comparable string
+func ruleKey(r Rule) string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+// CheckExample transforms the input of an example, returning an error describing how the
+// records written differ from its output
+func (t *Transformer) CheckExample(e Example) error {
+	outputs, err := t.TransformRecord(e.Input)
+	if err != nil {
+		return err
+	}
+	// Compare the records as JSON, as the example's numbers decode as float64
+	got, err := normalizeJSON(outputs)
+	if err != nil {
+		return err
+	}
+	want, err := normalizeJSON(e.Output)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		return fmt.Errorf("got %s, want %s", gotJSON, wantJSON)
+	}
+	return nil
+}
+
+// normalizeJSON encodes and decodes a value so that it holds only the types of decoded JSON
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	rs, err := ParseRules([]byte(`{
+		"fields": ["user.name", "user.email", "user.tags", "score"],
+		"rules": [
+			{"field": "name", "action": "lowercase"},
+			{"field": "name", "action": "titlecase"},
+			{"field": "score", "action": "coerce", "coerce": ["number"]},
+			{"field": "score", "action": "coerce", "coerce": ["string"]},
+			{"field": "tags", "action": "sort"},
+			{"field": "tags", "action": "sort"},
+			{"field": "email", "action": "email", "rename": "contact"},
+			{"field": "email", "action": "lowercase"},
+			{"field": "phone", "action": "phone", "region": "US"},
+			{"field": "raw", "action": "passthrough"},
+			{"field": "raw.id", "action": "uppercase"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"rule 1 (lowercase name): overridden by rule 2 (titlecase)",
+		"rule 3 (coerce score): overridden by rule 4 (coerce)",
+		"rule 5 (sort tags): duplicated by rule 6",
+		"rule 8 (lowercase email): unreachable, as rule 7 renames email to contact",
+		"rule 9 (phone phone): unreachable, as no declared input field leads to it",
+		"rule 10 (passthrough raw): unreachable, as no declared input field leads to it",
+		"rule 11 (uppercase raw.id): unreachable, as rule 10 passes raw through undecoded",
+	}
+	if got := rs.Lint(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint =\n%q\nwant\n%q", got, want)
+	}
+
+	rs, err = ParseRules([]byte(`{"rules": [
+		{"field": "name", "action": "lowercase", "when": "record.lang == 'en'"},
+		{"field": "name", "action": "uppercase", "when": "record.lang == 'de'"},
+		{"field": "a.temp_f", "action": "convert", "units": "F->C", "rename": "temp"},
+		{"field": "a.temp_k", "action": "convert", "units": "K->C", "rename": "temp"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{`rule 3 (convert a.temp_f): renames to "temp" like rule 4 (convert a.temp_k)`}
+	if got := rs.Lint(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint = %q, want %q", got, want)
+	}
+}
+
+func TestCheckExample(t *testing.T) {
+	rs, err := ParseRules([]byte(`{
+		"rules": [{"field": "tags", "action": "set"}],
+		"examples": [
+			{"input": {"id": " 7 ", "tags": ["b", "a", "b"]}, "output": [[{"id": "7"}, {"tags": ["a", "b"]}]]},
+			{"name": "unsorted", "input": {"tags": ["b", "a"]}, "output": [[{"tags": ["b", "a"]}]]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Transformer{Rules: rs}
+	if err := tr.CheckExample(rs.Examples[0]); err != nil {
+		t.Errorf("CheckExample(1) = %v", err)
+	}
+	if err := tr.CheckExample(rs.Examples[1]); err == nil {
+		t.Errorf("CheckExample(2) succeeded, want error")
+	}
+
+	if _, err := ParseRules([]byte(`{"rules": [], "examples": [{"output": []}]}`)); err == nil {
+		t.Errorf("ParseRules accepted an example without input")
+	}
+}
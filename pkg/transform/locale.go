@@ -0,0 +1,314 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/language"
+)
+
+// Locale parses the dates and numbers written for a language: dates with month and weekday
+// names such as "dimanche 3 mars 2024", numbers with the language's separators such as
+// "1 234,5", and digits of other scripts such as "٢٠٢٤". The names and separators are
+// those of CLDR for the supported languages.
+type Locale struct {
+	tag  language.Tag
+	data *localeData
+}
+
+// localeData holds the CLDR names and separators of a language, lowercase and without the
+// trailing dots of abbreviations
+type localeData struct {
+	// months lists the full and abbreviated names of each month, January first
+	months [12][]string
+	// weekdays lists the full and abbreviated names of each day, Sunday first
+	weekdays [7][]string
+	// fillers are the words a date may hold between its day, month and year
+	fillers []string
+	// ordinals are the suffixes a day may carry, as in "1er"
+	ordinals []string
+	decimal  rune
+	groups   string
+}
+
+// locales maps the supported languages to their data
+var locales = map[language.Tag]*localeData{
+	language.English: {
+		months: [12][]string{
+			{"january", "jan"}, {"february", "feb"}, {"march", "mar"}, {"april", "apr"},
+			{"may"}, {"june", "jun"}, {"july", "jul"}, {"august", "aug"},
+			{"september", "sep", "sept"}, {"october", "oct"}, {"november", "nov"}, {"december", "dec"},
+		},
+		weekdays: [7][]string{
+			{"sunday", "sun"}, {"monday", "mon"}, {"tuesday", "tue"}, {"wednesday", "wed"},
+			{"thursday", "thu"}, {"friday", "fri"}, {"saturday", "sat"},
+		},
+		fillers:  []string{"the", "of"},
+		ordinals: []string{"st", "nd", "rd", "th"},
+		decimal:  '.',
+		groups:   ",",
+	},
+	language.French: {
+		months: [12][]string{
+			{"janvier", "janv"}, {"février", "févr"}, {"mars"}, {"avril", "avr"},
+			{"mai"}, {"juin"}, {"juillet", "juil"}, {"août"},
+			{"septembre", "sept"}, {"octobre", "oct"}, {"novembre", "nov"}, {"décembre", "déc"},
+		},
+		weekdays: [7][]string{
+			{"dimanche", "dim"}, {"lundi", "lun"}, {"mardi", "mar"}, {"mercredi", "mer"},
+			{"jeudi", "jeu"}, {"vendredi", "ven"}, {"samedi", "sam"},
+		},
+		fillers:  []string{"le"},
+		ordinals: []string{"er"},
+		decimal:  ',',
+		groups:   " \u00a0\u202f",
+	},
+	language.German: {
+		months: [12][]string{
+			{"januar", "jan"}, {"februar", "feb"}, {"märz"}, {"april", "apr"},
+			{"mai"}, {"juni"}, {"juli"}, {"august", "aug"},
+			{"september", "sept", "sep"}, {"oktober", "okt"}, {"november", "nov"}, {"dezember", "dez"},
+		},
+		weekdays: [7][]string{
+			{"sonntag", "so"}, {"montag", "mo"}, {"dienstag", "di"}, {"mittwoch", "mi"},
+			{"donnerstag", "do"}, {"freitag", "fr"}, {"samstag", "sonnabend", "sa"},
+		},
+		fillers: []string{"den"},
+		decimal: ',',
+		groups:  ".",
+	},
+	language.Spanish: {
+		months: [12][]string{
+			{"enero", "ene"}, {"febrero", "feb"}, {"marzo", "mar"}, {"abril", "abr"},
+			{"mayo", "may"}, {"junio", "jun"}, {"julio", "jul"}, {"agosto", "ago"},
+			{"septiembre", "sept", "sep"}, {"octubre", "oct"}, {"noviembre", "nov"}, {"diciembre", "dic"},
+		},
+		weekdays: [7][]string{
+			{"domingo", "dom"}, {"lunes", "lun"}, {"martes", "mar"}, {"miércoles", "mié"},
+			{"jueves", "jue"}, {"viernes", "vie"}, {"sábado", "sáb"},
+		},
+		fillers: []string{"de", "del"},
+		decimal: ',',
+		groups:  ".",
+	},
+	language.Italian: {
+		months: [12][]string{
+			{"gennaio", "gen"}, {"febbraio", "feb"}, {"marzo", "mar"}, {"aprile", "apr"},
+			{"maggio", "mag"}, {"giugno", "giu"}, {"luglio", "lug"}, {"agosto", "ago"},
+			{"settembre", "set"}, {"ottobre", "ott"}, {"novembre", "nov"}, {"dicembre", "dic"},
+		},
+		weekdays: [7][]string{
+			{"domenica", "dom"}, {"lunedì", "lun"}, {"martedì", "mar"}, {"mercoledì", "mer"},
+			{"giovedì", "gio"}, {"venerdì", "ven"}, {"sabato", "sab"},
+		},
+		decimal: ',',
+		groups:  ".",
+	},
+	language.Portuguese: {
+		months: [12][]string{
+			{"janeiro", "jan"}, {"fevereiro", "fev"}, {"março", "mar"}, {"abril", "abr"},
+			{"maio", "mai"}, {"junho", "jun"}, {"julho", "jul"}, {"agosto", "ago"},
+			{"setembro", "set"}, {"outubro", "out"}, {"novembro", "nov"}, {"dezembro", "dez"},
+		},
+		weekdays: [7][]string{
+			{"domingo", "dom"}, {"segunda-feira", "seg"}, {"terça-feira", "ter"}, {"quarta-feira", "qua"},
+			{"quinta-feira", "qui"}, {"sexta-feira", "sex"}, {"sábado", "sáb"},
+		},
+		fillers: []string{"de"},
+		decimal: ',',
+		groups:  ". \u00a0",
+	},
+	language.Dutch: {
+		months: [12][]string{
+			{"januari", "jan"}, {"februari", "feb"}, {"maart", "mrt"}, {"april", "apr"},
+			{"mei"}, {"juni", "jun"}, {"juli", "jul"}, {"augustus", "aug"},
+			{"september", "sep"}, {"oktober", "okt"}, {"november", "nov"}, {"december", "dec"},
+		},
+		weekdays: [7][]string{
+			{"zondag", "zo"}, {"maandag", "ma"}, {"dinsdag", "di"}, {"woensdag", "wo"},
+			{"donderdag", "do"}, {"vrijdag", "vr"}, {"zaterdag", "za"},
+		},
+		decimal: ',',
+		groups:  ".",
+	},
+}
+
+// localeTags are the supported languages, in the order matched by localeMatcher
+var localeTags = []language.Tag{
+	language.English, language.French, language.German, language.Spanish,
+	language.Italian, language.Portuguese, language.Dutch,
+}
+
+// localeMatcher matches requested locales to the supported languages
+var localeMatcher = language.NewMatcher(localeTags)
+
+// ParseLocale parses a BCP 47 locale such as fr-FR, matching it to the closest supported
+// language: English, French, German, Spanish, Italian, Portuguese or Dutch
+func ParseLocale(s string) (*Locale, error) {
+	tag, err := language.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locale %q: %v", s, err)
+	}
+	_, index, confidence := localeMatcher.Match(tag)
+	if confidence < language.High {
+		return nil, fmt.Errorf("unsupported locale %q (want English, French, German, Spanish, Italian, Portuguese or Dutch)", s)
+	}
+	return &Locale{tag: tag, data: locales[localeTags[index]]}, nil
+}
+
+// String returns the locale's BCP 47 tag
+func (l *Locale) String() string {
+	return l.tag.String()
+}
+
+// coerce applies a coercion to a string the way the locale writes it, reporting whether
+// it applied. A nil Locale applies no coercions.
+func (l *Locale) coerce(kind, s string) (interface{}, bool) {
+	if l == nil {
+		return nil, false
+	}
+	shaped := shapeDigits(s)
+	switch kind {
+	case "timestamp":
+		if ts, ok := l.parseDate(shaped); ok {
+			return ts.Unix(), true
+		}
+	case "number":
+		return coercions["number"](l.normalizeNumber(shaped))
+	}
+	if shaped != s {
+		return coercions[kind](shaped)
+	}
+	return nil, false
+}
+
+// parseDate parses a date written with a month name, such as "3 mars 2024" or "dimanche
+// 3 mars 2024", as midnight UTC. A weekday must match the date.
+func (l *Locale) parseDate(s string) (time.Time, bool) {
+	var tokens []string
+	for _, token := range strings.Fields(strings.ToLower(s)) {
+		token = strings.Trim(token, ".,")
+		if token != "" && !contains(l.data.fillers, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	weekday := -1
+	if len(tokens) == 4 {
+		weekday = nameIndex(l.data.weekdays[:], tokens[0])
+		if weekday < 0 {
+			return time.Time{}, false
+		}
+		tokens = tokens[1:]
+	}
+	if len(tokens) != 3 {
+		return time.Time{}, false
+	}
+
+	day, month, year := 0, -1, 0
+	for _, token := range tokens {
+		if m := nameIndex(l.data.months[:], token); m >= 0 && month < 0 {
+			month = m
+			continue
+		}
+		if len(token) == 4 && year == 0 {
+			if n, err := strconv.Atoi(token); err == nil {
+				year = n
+				continue
+			}
+		}
+		for _, suffix := range l.data.ordinals {
+			token = strings.TrimSuffix(token, suffix)
+		}
+		n, err := strconv.Atoi(token)
+		if err != nil || day != 0 || n < 1 || n > 31 {
+			return time.Time{}, false
+		}
+		day = n
+	}
+	if day == 0 || month < 0 || year == 0 {
+		return time.Time{}, false
+	}
+	ts := time.Date(year, time.Month(month+1), day, 0, 0, 0, 0, time.UTC)
+	if ts.Day() != day || (weekday >= 0 && int(ts.Weekday()) != weekday) {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+// normalizeNumber rewrites a number written with the locale's group and decimal separators
+// as a plain decimal number
+func (l *Locale) normalizeNumber(s string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r == l.data.decimal:
+			b.WriteRune('.')
+		case strings.ContainsRune(l.data.groups, r):
+		case r == '.' || r == ',':
+			// Numbers with the other separator are left as written
+			return s
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// nameIndex returns the index of the names that include name, or -1
+func nameIndex(names [][]string, name string) int {
+	for i, forms := range names {
+		if contains(forms, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains reports whether a list of strings includes s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// digitZeros are the zero digits of the scripts whose decimal digits are shaped to ASCII
+var digitZeros = []rune{
+	'٠', // Arabic-Indic
+	'۰', // Extended Arabic-Indic
+	'०', // Devanagari
+	'০', // Bengali
+	'੦', // Gurmukhi
+	'૦', // Gujarati
+	'௦', // Tamil
+	'౦', // Telugu
+	'೦', // Kannada
+	'൦', // Malayalam
+	'๐', // Thai
+	'໐', // Lao
+	'༠', // Tibetan
+	'၀', // Myanmar
+	'០', // Khmer
+	'᠐', // Mongolian
+	'０', // Fullwidth
+}
+
+// shapeDigits replaces the decimal digits of other scripts with ASCII digits
+func shapeDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 || !unicode.IsDigit(r) {
+			return r
+		}
+		for _, zero := range digitZeros {
+			if r >= zero && r <= zero+9 {
+				return '0' + r - zero
+			}
+		}
+		return r
+	}, s)
+}
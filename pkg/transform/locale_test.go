@@ -0,0 +1,51 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocaleCoercions(t *testing.T) {
+	tests := []struct {
+		locale string
+		value  string
+		legacy []string
+		want   interface{}
+	}{
+		// Month and weekday names
+		{locale: "fr-FR", value: "3 mars 2024", legacy: legacyFieldCoercion, want: int64(1709424000)},
+		{locale: "fr-FR", value: "dimanche 3 mars 2024", legacy: legacyFieldCoercion, want: int64(1709424000)},
+		{locale: "fr-FR", value: "lundi 3 mars 2024", legacy: legacyFieldCoercion, want: "lundi 3 mars 2024"},
+		{locale: "fr-CA", value: "le 1er févr. 2024", legacy: legacyFieldCoercion, want: int64(1706745600)},
+		{locale: "de-DE", value: "Sonntag, 3. März 2024", legacy: legacyFieldCoercion, want: int64(1709424000)},
+		{locale: "es", value: "3 de marzo de 2024", legacy: legacyFieldCoercion, want: int64(1709424000)},
+		{locale: "en-US", value: "March 3rd, 2024", legacy: legacyFieldCoercion, want: int64(1709424000)},
+		{locale: "fr-FR", value: "31 février 2024", legacy: legacyFieldCoercion, want: "31 février 2024"},
+		{locale: "fr-FR", value: "3 march 2024", legacy: legacyFieldCoercion, want: "3 march 2024"},
+		{locale: "fr-FR", value: "2024-01-01T00:00:00Z", legacy: legacyFieldCoercion, want: int64(1704067200)},
+
+		// Separators and digits
+		{locale: "fr-FR", value: "1 234,5", legacy: []string{"number"}, want: 1234.5},
+		{locale: "fr-FR", value: "1 234", legacy: []string{"number"}, want: 1234},
+		{locale: "de-DE", value: "1.234", legacy: []string{"number"}, want: 1234},
+		{locale: "en-GB", value: "1,234.5", legacy: []string{"number"}, want: 1234.5},
+		{locale: "fr-FR", value: "١٢٣", legacy: legacyListCoercion, want: 123},
+		{locale: "fr-FR", value: "٣ mars ٢٠٢٤", legacy: legacyFieldCoercion, want: int64(1709424000)},
+	}
+	for _, tt := range tests {
+		locale, err := ParseLocale(tt.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := Transformer{Locale: locale}
+		if got := (&run{Transformer: &tr}).coerceString("", tt.value, tt.legacy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerceString(%q) with locale %s = %#v, want %#v", tt.value, tt.locale, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"xx-!!", "ja-JP"} {
+		if _, err := ParseLocale(s); err == nil {
+			t.Errorf("ParseLocale(%q) succeeded, want error", s)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// validatePhoneRule checks the region hint of a phone rule
+func validatePhoneRule(r Rule) error {
+	if r.Region != "" && !phonenumbers.GetSupportedRegions()[strings.ToUpper(r.Region)] {
+		return fmt.Errorf("unsupported region %q", r.Region)
+	}
+	return nil
+}
+
+// applyPhoneRule normalizes a phone number string, or an array of them, to E.164 such as
+// "+14155552671". Numbers without a country code are read as numbers of the rule's region.
+// Strings that are not valid phone numbers fail the rule.
+func applyPhoneRule(r Rule, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return normalizePhone(v, r.Region)
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, element := range v {
+			s, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("element %d is not a string", i)
+			}
+			var err error
+			if normalized[i], err = normalizePhone(s, r.Region); err != nil {
+				return nil, err
+			}
+		}
+		return normalized, nil
+	}
+	return nil, fmt.Errorf("value is not a string")
+}
+
+// normalizePhone formats a phone number in E.164, parsing it for a region
+func normalizePhone(s, region string) (string, error) {
+	number, err := phonenumbers.Parse(s, strings.ToUpper(region))
+	if err != nil {
+		return "", fmt.Errorf("invalid phone number %q: %v", s, err)
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		return "", fmt.Errorf("invalid phone number %q", s)
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
@@ -0,0 +1,52 @@
+package transform
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPhoneRule(t *testing.T) {
+	tests := []struct {
+		region string
+		value  interface{}
+		want   interface{}
+	}{
+		{"US", "(415) 555-2671", "+14155552671"},
+		{"us", "415.555.2671", "+14155552671"},
+		{"", "+44 20 7946 0958", "+442079460958"},
+		{"GB", "020 7946 0958", "+442079460958"},
+		{"DE", []interface{}{"030 901820", "+1 415 555 2671"}, []interface{}{"+4930901820", "+14155552671"}},
+	}
+	for _, tt := range tests {
+		got, err := applyPhoneRule(Rule{Region: tt.region}, tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("phone %s of %v = %v, %v, want %v", tt.region, tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []interface{}{"555-0000", "not a number", "415 555 2671", 4155552671.0, []interface{}{"+14155552671", 1.0}} {
+		if got, err := applyPhoneRule(Rule{}, value); err == nil {
+			t.Errorf("phone of %v = %v, want error", value, got)
+		}
+	}
+
+	if _, err := ParseRules([]byte(`{"rules": [{"field": "tel", "action": "phone", "region": "XX"}]}`)); err == nil {
+		t.Error("ParseRules accepted an unsupported region")
+	}
+
+	// Invalid numbers are flagged as rule warnings, which may fail the record
+	rs, err := ParseRules([]byte(`{"rules": [{"field": "tel", "action": "phone", "region": "US"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := ParseWarningPatterns("rule:tel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Transformer{Rules: rs, Warnings: &WarningRules{AsError: patterns}}
+	var strictErr *StrictError
+	if _, err := tr.TransformRecord(Input{"tel": "12"}); !errors.As(err, &strictErr) {
+		t.Errorf("TransformRecord of an invalid number error = %v, want a StrictError", err)
+	}
+}
@@ -0,0 +1,142 @@
+package transform
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggestedCoercions are the coercions a profile suggests for a field, in order of preference
+var suggestedCoercions = []string{"timestamp", "number", "boolean"}
+
+// FieldProfile describes the values sampled at an output field path of input records, as
+// named by rules
+type FieldProfile struct {
+	Path string
+	// Types counts the values by type: string, number, boolean, null, object, list or
+	// descriptor. List elements count one each.
+	Types map[string]int
+	// Examples are up to three of the distinct strings sampled
+	Examples []string
+	// Coercions is the coercion order suggested for the field: the first of timestamp,
+	// number and boolean that applies to every string sampled, or string when none does but
+	// the default coercions convert some of them, as with zip codes in lists
+	Coercions []string
+}
+
+// fieldSample accumulates the profile of a field
+type fieldSample struct {
+	FieldProfile
+	strings []string
+	// legacy is set when the default coercions convert some of the strings
+	legacy bool
+}
+
+// ProfileRecords samples the values of input records by the output field paths the
+// transformer gives them, returning the profiles of the fields in path order
+func ProfileRecords(records []Input) []FieldProfile {
+	fields := make(map[string]*fieldSample)
+	p := &profiler{fields: fields}
+	for _, record := range records {
+		for key, value := range record {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if m, ok := value.(map[string]interface{}); ok {
+				if _, _, ok := descriptor(m); !ok {
+					// Top-level objects are flattened into the output
+					p.walkMap(m, "")
+					continue
+				}
+			}
+			p.walk(key, value, legacyFieldCoercion)
+		}
+	}
+
+	profiles := make([]FieldProfile, 0, len(fields))
+	for _, f := range fields {
+		f.Coercions = suggestCoercions(f.strings, f.legacy)
+		profiles = append(profiles, f.FieldProfile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Path < profiles[j].Path })
+	return profiles
+}
+
+// profiler accumulates the profiles of fields
+type profiler struct {
+	fields map[string]*fieldSample
+}
+
+// walk records a value found at a path, where the default coercions of strings are legacy
+func (p *profiler) walk(path string, value interface{}, legacy []string) {
+	f := p.fields[path]
+	if f == nil {
+		f = &fieldSample{FieldProfile: FieldProfile{Path: path, Types: make(map[string]int)}}
+		p.fields[path] = f
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, _, ok := descriptor(v); ok {
+			f.Types["descriptor"]++
+			return
+		}
+		f.Types["object"]++
+		p.walkMap(v, path)
+	case []interface{}:
+		f.Types["list"]++
+		for _, e := range v {
+			if _, ok := e.([]interface{}); ok {
+				// Nested lists are not transformed
+				continue
+			}
+			p.walk(path, e, legacyListCoercion)
+		}
+	case string:
+		f.Types["string"]++
+		if len(f.Examples) < 3 && !contains(f.Examples, v) {
+			f.Examples = append(f.Examples, v)
+		}
+		f.strings = append(f.strings, v)
+		for _, kind := range legacy {
+			if _, ok := coercions[kind](v); ok {
+				f.legacy = true
+			}
+		}
+	case nil:
+		f.Types["null"]++
+	case bool:
+		f.Types["boolean"]++
+	default:
+		f.Types["number"]++
+	}
+}
+
+// walkMap records the values of an object found at a path
+func (p *profiler) walkMap(m map[string]interface{}, path string) {
+	for key, value := range m {
+		p.walk(JoinPath(path, strings.TrimSpace(key)), value, legacyMapCoercion)
+	}
+}
+
+// suggestCoercions returns the coercions of a FieldProfile for the strings sampled
+func suggestCoercions(values []string, legacy bool) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	for _, kind := range suggestedCoercions {
+		all := true
+		for _, v := range values {
+			if _, ok := coercions[kind](v); !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			return []string{kind}
+		}
+	}
+	if legacy {
+		return []string{"string"}
+	}
+	return nil
+}
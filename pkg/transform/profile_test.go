@@ -0,0 +1,29 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProfileRecords(t *testing.T) {
+	records := []Input{
+		{"price": "12.50", "user": map[string]interface{}{"name": "Ada", "born": "1815-12-10T00:00:00Z"}, "zips": []interface{}{"02134"}, "n": 1.0},
+		{"price": "3", "user": map[string]interface{}{"name": "Grace"}, "zips": []interface{}{"10001"}, "n": nil, "id": map[string]interface{}{"N": "7"}},
+	}
+	got := make(map[string]FieldProfile)
+	for _, p := range ProfileRecords(records) {
+		got[p.Path] = p
+	}
+	want := map[string]FieldProfile{
+		"born":  {Path: "born", Types: map[string]int{"string": 1}, Examples: []string{"1815-12-10T00:00:00Z"}, Coercions: []string{"timestamp"}},
+		"id":    {Path: "id", Types: map[string]int{"descriptor": 1}},
+		"n":     {Path: "n", Types: map[string]int{"number": 1, "null": 1}},
+		"name":  {Path: "name", Types: map[string]int{"string": 2}, Examples: []string{"Ada", "Grace"}},
+		"price": {Path: "price", Types: map[string]int{"string": 2}, Examples: []string{"12.50", "3"}, Coercions: []string{"number"}},
+		// Lists coerce integers by default, which a string coercion keeps as written
+		"zips": {Path: "zips", Types: map[string]int{"list": 2, "string": 2}, Examples: []string{"02134", "10001"}, Coercions: []string{"string"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProfileRecords =\n%+v\nwant\n%+v", got, want)
+	}
+}
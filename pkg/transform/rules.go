@@ -0,0 +1,577 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+)
+
+// RuleSet is a rules file: a list of actions applied in order to each transformed record.
+// Once parsed, a RuleSet may be applied by multiple goroutines at once.
+type RuleSet struct {
+	// Include lists the modules whose rules come before the file's own: paths relative to
+	// the rules file, or files of git repositories such as
+	// "git::https://github.com/acme/rules.git//billing.yaml@v1.2.0"
+	Include []string `json:"include,omitempty"`
+	// Fields lists the input field paths records are expected to have. When set, JSON input
+	// is decoded along these paths only and other fields are dropped unread.
+	Fields []string `json:"fields,omitempty"`
+	Rules  []Rule   `json:"rules"`
+	// Examples are records with the output the rules are expected to give them, checked by
+	// the rules test command
+	Examples []Example `json:"examples,omitempty"`
+}
+
+// Rule applies an action to the value of a field. Field paths name nested object keys
+// separated by ".", such as "profile.tags".
+type Rule struct {
+	Field  string `json:"field"`
+	Action string `json:"action"`
+	// By sorts or deduplicates arrays of objects by the value of this key within each element
+	By string `json:"by,omitempty"`
+	// Order is "asc" (the default) or "desc"
+	Order string `json:"order,omitempty"`
+	// Count is the number of elements kept by first and last
+	Count int `json:"count,omitempty"`
+	// Size is the most elements in each record created by chunk
+	Size int `json:"size,omitempty"`
+	// Region is the ISO 3166-1 alpha-2 region, such as US, of the phone numbers without a
+	// country code normalized by a phone rule
+	Region string `json:"region,omitempty"`
+	// Target is the code system a country rule converts to: alpha2 (the default), alpha3,
+	// numeric or name
+	Target string `json:"target,omitempty"`
+	// Units are the units a convert rule converts between, such as "lb->kg" or "F->C"
+	Units string `json:"units,omitempty"`
+	// Precision is the number of decimal places convert rounds to, up to 15 (default no
+	// rounding)
+	Precision *int `json:"precision,omitempty"`
+	// Rename moves the value a rule updates to this key, as in converting temp_f into
+	// temp_c
+	Rename string `json:"rename,omitempty"`
+	// StripTags removes the "+tag" of the addresses normalized by an email rule, as in
+	// "ada+news@example.com"
+	StripTags bool `json:"strip_tags,omitempty"`
+	// Base is the absolute URL relative URLs are resolved against by a url rule
+	Base string `json:"base,omitempty"`
+	// Coerce is the coercion order of a coerce rule, overriding Transformer.Coercions for the
+	// field
+	Coerce []string `json:"coerce,omitempty"`
+	// When is a CEL expression limiting the rule to the records it is true for, such as
+	// `has(record.country) && record.country == "US"`. Coerce rules see the input record,
+	// other rules the transformed record as left by the rules before them.
+	When string `json:"when,omitempty"`
+
+	when cel.Program
+}
+
+// ruleAction validates the settings of a rule and applies it to a field value. Actions
+// without apply are handled by RuleSet.Apply (chunk), during transformation (coerce) or
+// while decoding (passthrough).
+type ruleAction struct {
+	validate func(r Rule) error
+	apply    func(r Rule, value interface{}) (interface{}, error)
+}
+
+// ruleActions maps action names to their implementations
+var ruleActions = map[string]ruleAction{
+	"sort":        {validate: validateSortRule, apply: applySortRule},
+	"dedupe":      {validate: validateSortRule, apply: applyDedupeRule},
+	"set":         {validate: validateSortRule, apply: applySetRule},
+	"first":       {validate: validateSliceRule, apply: applyFirstRule},
+	"last":        {validate: validateSliceRule, apply: applyLastRule},
+	"phone":       {validate: validatePhoneRule, apply: applyPhoneRule},
+	"country":     {validate: validateCountryRule, apply: applyCountryRule},
+	"convert":     {validate: validateConvertRule, apply: applyConvertRule},
+	"email":       {validate: func(Rule) error { return nil }, apply: applyEmailRule},
+	"url":         {validate: validateURLRule, apply: applyURLRule},
+	"chunk":       {validate: validateChunkRule},
+	"coerce":      {validate: validateCoerceRule},
+	"passthrough": {validate: func(Rule) error { return nil }},
+
+	"lowercase":           textRuleAction(strings.ToLower),
+	"uppercase":           textRuleAction(strings.ToUpper),
+	"titlecase":           textRuleAction(titleCase),
+	"collapse-whitespace": textRuleAction(collapseWhitespace),
+	"strip-diacritics":    textRuleAction(stripDiacritics),
+	"slugify":             textRuleAction(slugify),
+}
+
+// ParseRules decodes and validates the JSON of a rules file, rejecting unknown settings so
+// that typos do not silently disable a rule. Includes are resolved only by LoadRules, as
+// their paths are relative to the rules file.
+func ParseRules(data []byte) (*RuleSet, error) {
+	rs, err := parseRules(data)
+	if err == nil && len(rs.Include) > 0 {
+		return nil, fmt.Errorf("rules with includes must be loaded from a file")
+	}
+	return rs, err
+}
+
+// parseRules decodes and validates the JSON of a rules file for ParseRules and LoadRules
+func parseRules(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rs); err != nil {
+		return nil, fmt.Errorf("decoding rules: %v", err)
+	}
+	for _, field := range rs.Fields {
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") {
+			return nil, fmt.Errorf("invalid field path %q", field)
+		}
+	}
+	for i, r := range rs.Rules {
+		if r.Field == "" {
+			return nil, fmt.Errorf("rule %d has no field", i+1)
+		}
+		action, ok := ruleActions[r.Action]
+		if !ok {
+			return nil, fmt.Errorf("rule %d has unsupported action %q", i+1, r.Action)
+		}
+		if err := action.validate(r); err != nil {
+			return nil, fmt.Errorf("rule %d (%s %s): %v", i+1, r.Action, r.Field, err)
+		}
+		if r.When != "" {
+			if r.Action == "passthrough" {
+				return nil, fmt.Errorf("rule %d (%s %s) cannot have when, as it applies while decoding", i+1, r.Action, r.Field)
+			}
+			var err error
+			if rs.Rules[i].when, err = compileWhen(r.When); err != nil {
+				return nil, fmt.Errorf("rule %d (%s %s): %v", i+1, r.Action, r.Field, err)
+			}
+		}
+		if r.Rename != "" && (action.apply == nil || strings.Contains(r.Rename, ".")) {
+			return nil, fmt.Errorf("rule %d (%s %s) cannot rename to %q", i+1, r.Action, r.Field, r.Rename)
+		}
+	}
+	for i, e := range rs.Examples {
+		if e.Input == nil {
+			return nil, fmt.Errorf("example %d has no input", i+1)
+		}
+	}
+	return &rs, nil
+}
+
+// Apply applies the rules in order to an output, returning the records to write: the output
+// itself, or one record per chunk of a chunked array. Rules whose field a record does not
+// have leave the record unchanged, as do rules that do not apply to the field's value. A nil
+// RuleSet returns the output as it is.
+func (rs *RuleSet) Apply(output Output) ([]Output, error) {
+	return rs.apply(output, &run{Transformer: &Transformer{}})
+}
+
+// apply applies the rules for a run of the transformer, which handles their warnings and
+// records their events
+func (rs *RuleSet) apply(output Output, tr *run) ([]Output, error) {
+	if rs == nil {
+		return []Output{output}, tr.err()
+	}
+	outputs := []Output{output}
+	for i, r := range rs.Rules {
+		if r.Action == "coerce" || r.Action == "passthrough" {
+			continue
+		}
+		if r.Action == "chunk" {
+			var chunked []Output
+			for _, output := range outputs {
+				if !r.applies(tr, mergeMaps(output)) {
+					chunked = append(chunked, output)
+					continue
+				}
+				updateField(output, r.Field, func(value interface{}) (interface{}, error) {
+					tr.match(i)
+					return value, nil
+				})
+				chunks := chunkOutput(r, output)
+				if tr.events != nil && len(chunks) > 1 {
+					// The event gives the array and the number of records it was split into
+					var list interface{}
+					updateField(output, r.Field, func(value interface{}) (interface{}, error) {
+						list = value
+						return value, nil
+					})
+					tr.event(r.Field, r.Action, list, len(chunks))
+				}
+				chunked = append(chunked, chunks...)
+			}
+			outputs = chunked
+			continue
+		}
+
+		action := ruleActions[r.Action]
+		for _, output := range outputs {
+			if !r.applies(tr, mergeMaps(output)) {
+				continue
+			}
+			err := updateField(output, r.Field, func(value interface{}) (interface{}, error) {
+				tr.match(i)
+				updated, err := action.apply(r, value)
+				if err == nil && tr.events != nil && !reflect.DeepEqual(value, updated) {
+					tr.event(r.Field, r.Action, value, updated)
+				}
+				return updated, err
+			})
+			if err != nil {
+				problem := fmt.Sprintf("rule %s %s: %v", r.Action, r.Field, err)
+				tr.warn(Warning{Class: "rule", Path: r.Field, Message: "Skipping " + problem}, false, problem)
+			} else if r.Rename != "" {
+				renameField(output, r.Field, r.Rename)
+			}
+		}
+	}
+	return outputs, tr.err()
+}
+
+// coercion returns the coercion order of the last coerce rule for a field path that applies
+// to the input record of a run. Field paths of values in lists of objects name the list, as
+// in "items.price".
+func (rs *RuleSet) coercion(path string, tr *run) ([]string, bool) {
+	if rs == nil {
+		return nil, false
+	}
+	for i := len(rs.Rules) - 1; i >= 0; i-- {
+		if r := rs.Rules[i]; r.Action == "coerce" && r.Field == path && r.applies(tr, tr.input) {
+			tr.match(i)
+			return r.Coerce, true
+		}
+	}
+	return nil, false
+}
+
+// PassthroughPaths returns the field paths of the passthrough rules. The values at these
+// input paths are copied to the output as they are, without being decoded or transformed,
+// which saves decoding large subtrees that need no changes. Unlike other rules, passthrough
+// paths are input paths: a passthrough object at the top level is not flattened.
+func (rs *RuleSet) PassthroughPaths() []string {
+	var paths []string
+	for _, r := range rs.Rules {
+		if r.Action == "passthrough" {
+			paths = append(paths, r.Field)
+		}
+	}
+	return paths
+}
+
+// updateField replaces the value at a field path in the output maps with the result of fn
+func updateField(output Output, path string, fn func(interface{}) (interface{}, error)) error {
+	keys := strings.Split(path, ".")
+	for _, m := range output {
+		if err := updateMapField(m, keys, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameField moves the value at a field path in the output maps to another key of the
+// same map
+func renameField(output Output, path, key string) {
+	keys := strings.Split(path, ".")
+	for _, m := range output {
+		for _, k := range keys[:len(keys)-1] {
+			if m, _ = m[k].(map[string]interface{}); m == nil {
+				break
+			}
+		}
+		if value, ok := m[keys[len(keys)-1]]; ok {
+			delete(m, keys[len(keys)-1])
+			m[key] = value
+		}
+	}
+}
+
+// updateMapField replaces the value at the key path in a map with the result of fn
+func updateMapField(m map[string]interface{}, keys []string, fn func(interface{}) (interface{}, error)) error {
+	value, ok := m[keys[0]]
+	if !ok {
+		return nil
+	}
+	if len(keys) > 1 {
+		child, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		return updateMapField(child, keys[1:], fn)
+	}
+	updated, err := fn(value)
+	if err != nil {
+		return err
+	}
+	m[keys[0]] = updated
+	return nil
+}
+
+// validateSortRule checks the order of a sort, dedupe or set rule
+func validateSortRule(r Rule) error {
+	if r.Order != "" && r.Order != "asc" && r.Order != "desc" {
+		return fmt.Errorf("unsupported order %q", r.Order)
+	}
+	return nil
+}
+
+// applySortRule sorts an array by element value, or by the value of the rule's key within
+// element objects. Elements without the key sort last, and equal elements keep their order.
+func applySortRule(r Rule, value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an array")
+	}
+	sorted := append([]interface{}(nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, aok := r.elementKey(sorted[i])
+		b, bok := r.elementKey(sorted[j])
+		if !aok || !bok {
+			return aok && !bok
+		}
+		if r.Order == "desc" {
+			return compareValues(b, a) < 0
+		}
+		return compareValues(a, b) < 0
+	})
+	return sorted, nil
+}
+
+// applyDedupeRule removes the elements of an array equal to an earlier element, or whose
+// value of the rule's key equals that of an earlier element. Numbers are equal when their
+// values are, so 1 and 1.0 are duplicates. Elements without the key are kept.
+func applyDedupeRule(r Rule, value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an array")
+	}
+	kept := make([]interface{}, 0, len(list))
+	var keys []interface{}
+	for _, element := range list {
+		key, ok := r.elementKey(element)
+		if !ok {
+			kept = append(kept, element)
+			continue
+		}
+		duplicate := false
+		for _, k := range keys {
+			if compareValues(k, key) == 0 {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, element)
+			keys = append(keys, key)
+		}
+	}
+	return kept, nil
+}
+
+// applySetRule canonicalizes an array as a set, deduplicating and then sorting it, like
+// DynamoDB string and number sets
+func applySetRule(r Rule, value interface{}) (interface{}, error) {
+	deduped, err := applyDedupeRule(r, value)
+	if err != nil {
+		return nil, err
+	}
+	return applySortRule(r, deduped)
+}
+
+// elementKey returns the value an array element is sorted and deduplicated by: the element
+// itself, or the value of the rule's key when the element is an object that has it
+func (r Rule) elementKey(element interface{}) (interface{}, bool) {
+	if r.By == "" {
+		return element, true
+	}
+	m, ok := element.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	k, ok := m[r.By]
+	return k, ok
+}
+
+// validateCoerceRule checks the coercion order of a coerce rule
+func validateCoerceRule(r Rule) error {
+	if len(r.Coerce) == 0 {
+		return fmt.Errorf("coerce lists no coercions")
+	}
+	for _, kind := range r.Coerce {
+		if err := validateCoercion(kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSliceRule checks that a first or last rule keeps at least one element
+func validateSliceRule(r Rule) error {
+	if r.Count < 1 {
+		return fmt.Errorf("count must be at least 1")
+	}
+	return nil
+}
+
+// applyFirstRule keeps the first count elements of an array
+func applyFirstRule(r Rule, value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an array")
+	}
+	if len(list) > r.Count {
+		list = list[:r.Count]
+	}
+	return list, nil
+}
+
+// applyLastRule keeps the last count elements of an array
+func applyLastRule(r Rule, value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an array")
+	}
+	if len(list) > r.Count {
+		list = list[len(list)-r.Count:]
+	}
+	return list, nil
+}
+
+// validateChunkRule checks that a chunk rule has a chunk size
+func validateChunkRule(r Rule) error {
+	if r.Size < 1 {
+		return fmt.Errorf("size must be at least 1")
+	}
+	return nil
+}
+
+// chunkOutput splits an output whose array field has more than size elements into one
+// record per chunk of the array. Each record repeats the other fields and numbers its chunk
+// in "<field>_chunk" (from 0) and "<field>_chunks" fields, so that consumers can reassemble
+// the array.
+func chunkOutput(r Rule, output Output) []Output {
+	var list []interface{}
+	updateField(output, r.Field, func(value interface{}) (interface{}, error) {
+		list, _ = value.([]interface{})
+		return value, nil
+	})
+	if len(list) <= r.Size {
+		return []Output{output}
+	}
+
+	chunks := (len(list) + r.Size - 1) / r.Size
+	outputs := make([]Output, 0, chunks)
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * r.Size
+		if end > len(list) {
+			end = len(list)
+		}
+		chunk := cloneValue(output).(Output)
+		updateField(chunk, r.Field, func(interface{}) (interface{}, error) {
+			return list[i*r.Size : end], nil
+		})
+		chunk = append(chunk,
+			map[string]interface{}{r.Field + "_chunk": i},
+			map[string]interface{}{r.Field + "_chunks": chunks},
+		)
+		outputs = append(outputs, chunk)
+	}
+	return outputs
+}
+
+// cloneValue deep-copies the maps and slices of an output or output value
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case Output:
+		c := make(Output, len(v))
+		for i, m := range v {
+			c[i] = cloneValue(m).(map[string]interface{})
+		}
+		return c
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			c[k] = cloneValue(child)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, child := range v {
+			c[i] = cloneValue(child)
+		}
+		return c
+	}
+	return v
+}
+
+// compareValues orders JSON values: null, then booleans, numbers and strings, then arrays
+// and objects by their JSON encoding
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	if fa, ok := toFloat(a); ok {
+		fb, _ := toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return strings.Compare(string(ja), string(jb))
+}
+
+// valueRank returns the position of a value's JSON type in the order used by compareValues
+func valueRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	case map[string]interface{}:
+		return 5
+	}
+	if _, ok := toFloat(v); ok {
+		return 2
+	}
+	return 6
+}
+
+// toFloat converts the numeric types found in outputs to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case Timestamp:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
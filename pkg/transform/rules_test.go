@@ -0,0 +1,183 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"rules": [{"field": "tags", "action": "sort"}, {"field": "a.items", "action": "sort", "by": "n", "order": "desc"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{{Field: "tags", Action: "sort"}, {Field: "a.items", Action: "sort", By: "n", Order: "desc"}}
+	if !reflect.DeepEqual(rs.Rules, want) {
+		t.Errorf("ParseRules = %+v, want %+v", rs.Rules, want)
+	}
+
+	for _, data := range []string{
+		`{"rules": [{"action": "sort"}]}`,
+		`{"rules": [{"field": "x", "action": "shuffle"}]}`,
+		`{"rules": [{"field": "x", "action": "sort", "order": "up"}]}`,
+		`{"rules": [{"field": "x", "action": "sort", "key": "n"}]}`,
+		`{"rules": [{"field": "x", "action": "set", "order": "random"}]}`,
+		`{"rules": [{"field": "x", "action": "first"}]}`,
+		`{"rules": [{"field": "x", "action": "chunk", "size": 0}]}`,
+		`{"fields": ["a."], "rules": []}`,
+		`{"rules": {}}`,
+	} {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("ParseRules(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestSortRule(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		value []interface{}
+		want  []interface{}
+	}{
+		{
+			rule:  Rule{},
+			value: []interface{}{"b", 10, "a", 2, nil, true, int64(5), 1.5},
+			want:  []interface{}{nil, true, 1.5, 2, int64(5), 10, "a", "b"},
+		},
+		{
+			rule:  Rule{Order: "desc"},
+			value: []interface{}{"b", "c", "a"},
+			want:  []interface{}{"c", "b", "a"},
+		},
+		{
+			rule: Rule{By: "n"},
+			value: []interface{}{
+				map[string]interface{}{"n": 2, "id": "x"},
+				map[string]interface{}{"id": "none"},
+				map[string]interface{}{"n": 1},
+				map[string]interface{}{"n": 2, "id": "y"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"n": 1},
+				map[string]interface{}{"n": 2, "id": "x"},
+				map[string]interface{}{"n": 2, "id": "y"},
+				map[string]interface{}{"id": "none"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := applySortRule(tt.rule, tt.value)
+		if err != nil {
+			t.Errorf("applySortRule(%+v) error: %v", tt.rule, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("applySortRule(%+v, %v) = %v, want %v", tt.rule, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRuleSetApply(t *testing.T) {
+	rs := &RuleSet{Rules: []Rule{{Field: "tags", Action: "sort"}, {Field: "profile.roles", Action: "sort"}, {Field: "name", Action: "sort"}}}
+	output := Output{
+		{"tags": []interface{}{"z", "a"}},
+		{"profile": map[string]interface{}{"roles": []interface{}{"ops", "dev"}}},
+		{"name": "not a list"},
+	}
+	got, err := rs.Apply(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Output{{
+		{"tags": []interface{}{"a", "z"}},
+		{"profile": map[string]interface{}{"roles": []interface{}{"dev", "ops"}}},
+		{"name": "not a list"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apply = %v, want %v", got, want)
+	}
+}
+
+func TestDedupeAndSetRules(t *testing.T) {
+	tests := []struct {
+		action string
+		rule   Rule
+		value  []interface{}
+		want   []interface{}
+	}{
+		{"dedupe", Rule{}, []interface{}{"b", "a", "b", 1, 1.0, "1"}, []interface{}{"b", "a", 1, "1"}},
+		{"dedupe", Rule{}, []interface{}{}, []interface{}{}},
+		{
+			"dedupe", Rule{By: "id"},
+			[]interface{}{
+				map[string]interface{}{"id": "x", "v": 1},
+				map[string]interface{}{"v": 2},
+				map[string]interface{}{"id": "x", "v": 3},
+				map[string]interface{}{"v": 2},
+			},
+			[]interface{}{
+				map[string]interface{}{"id": "x", "v": 1},
+				map[string]interface{}{"v": 2},
+				map[string]interface{}{"v": 2},
+			},
+		},
+		{"set", Rule{}, []interface{}{"c", "a", "c", "b"}, []interface{}{"a", "b", "c"}},
+		{"set", Rule{}, []interface{}{3, 1.0, 2, int64(3), 1}, []interface{}{1.0, 2, 3}},
+		{"set", Rule{Order: "desc"}, []interface{}{"a", "b", "a"}, []interface{}{"b", "a"}},
+	}
+	for _, tt := range tests {
+		got, err := ruleActions[tt.action].apply(tt.rule, tt.value)
+		if err != nil {
+			t.Errorf("%s %+v error: %v", tt.action, tt.rule, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %+v of %v = %v, want %v", tt.action, tt.rule, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFirstAndLastRules(t *testing.T) {
+	list := []interface{}{1, 2, 3, 4}
+	tests := []struct {
+		action string
+		count  int
+		want   []interface{}
+	}{
+		{"first", 2, []interface{}{1, 2}},
+		{"first", 10, []interface{}{1, 2, 3, 4}},
+		{"last", 3, []interface{}{2, 3, 4}},
+		{"last", 4, []interface{}{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		got, err := ruleActions[tt.action].apply(Rule{Count: tt.count}, list)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %d = %v, %v, want %v", tt.action, tt.count, got, err, tt.want)
+		}
+	}
+}
+
+func TestChunkRule(t *testing.T) {
+	rs := &RuleSet{Rules: []Rule{
+		{Field: "order.items", Action: "chunk", Size: 2},
+		{Field: "order.items", Action: "sort", Order: "desc"},
+	}}
+	output := Output{{"id": "1"}, {"order": map[string]interface{}{"items": []interface{}{1, 2, 3, 4, 5}}}}
+	got, err := rs.Apply(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Output{
+		{{"id": "1"}, {"order": map[string]interface{}{"items": []interface{}{2, 1}}}, {"order.items_chunk": 0}, {"order.items_chunks": 3}},
+		{{"id": "1"}, {"order": map[string]interface{}{"items": []interface{}{4, 3}}}, {"order.items_chunk": 1}, {"order.items_chunks": 3}},
+		{{"id": "1"}, {"order": map[string]interface{}{"items": []interface{}{5}}}, {"order.items_chunk": 2}, {"order.items_chunks": 3}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apply = %v, want %v", got, want)
+	}
+
+	// Arrays that fit in a chunk leave the record as it is
+	small := Output{{"order": map[string]interface{}{"items": []interface{}{1}}}}
+	if got, _ := rs.Apply(small); len(got) != 1 || len(got[0]) != 1 {
+		t.Errorf("apply of a short array = %v, want the record unchanged", got)
+	}
+}
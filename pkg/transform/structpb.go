@@ -0,0 +1,112 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// FromStruct returns the record held by a google.protobuf.Struct, for services receiving
+// records as protobuf messages. Numbers are float64, as in records decoded from JSON
+// without json.Decoder.UseNumber.
+func FromStruct(s *structpb.Struct) Input {
+	return Input(s.AsMap())
+}
+
+// ToStruct merges the maps of an output into a google.protobuf.Struct, converting its
+// values as ToValue does
+func ToStruct(output Output) (*structpb.Struct, error) {
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	for _, m := range output {
+		for k, v := range m {
+			value, err := ToValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			s.Fields[k] = value
+		}
+	}
+	return s, nil
+}
+
+// ToValue converts a value of an output into a google.protobuf.Value without going through
+// JSON text. Objects become structs and lists, outputs and lists of outputs become lists of
+// their maps. Numbers, including json.Number, become doubles, which hold integers exactly
+// up to 2^53. Passthrough json.RawMessage subtrees are decoded into their values and byte
+// slices become standard base64 strings, as in JSON.
+func ToValue(v interface{}) (*structpb.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return structpb.NewNullValue(), nil
+	case bool:
+		return structpb.NewBoolValue(v), nil
+	case string:
+		return structpb.NewStringValue(v), nil
+	case int:
+		return structpb.NewNumberValue(float64(v)), nil
+	case int64:
+		return structpb.NewNumberValue(float64(v)), nil
+	case Timestamp:
+		return structpb.NewNumberValue(float64(v)), nil
+	case float64:
+		return structpb.NewNumberValue(v), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", v)
+		}
+		return structpb.NewNumberValue(f), nil
+	case []byte:
+		return structpb.NewStringValue(base64.StdEncoding.EncodeToString(v)), nil
+	case json.RawMessage:
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		var decoded interface{}
+		if err := dec.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		return ToValue(decoded)
+	case map[string]interface{}:
+		return toStructValue(v)
+	case Input:
+		return toStructValue(v)
+	case []interface{}:
+		return toListValue(len(v), func(i int) interface{} { return v[i] })
+	case []map[string]interface{}:
+		return toListValue(len(v), func(i int) interface{} { return v[i] })
+	case Output:
+		return toListValue(len(v), func(i int) interface{} { return v[i] })
+	case []Output:
+		return toListValue(len(v), func(i int) interface{} { return v[i] })
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// toStructValue converts an object into a struct value
+func toStructValue(m map[string]interface{}) (*structpb.Value, error) {
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(m))}
+	for k, item := range m {
+		value, err := ToValue(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		s.Fields[k] = value
+	}
+	return structpb.NewStructValue(s), nil
+}
+
+// toListValue converts the n items of a list into a list value
+func toListValue(n int, item func(i int) interface{}) (*structpb.Value, error) {
+	l := &structpb.ListValue{Values: make([]*structpb.Value, n)}
+	for i := range n {
+		value, err := ToValue(item(i))
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %v", i, err)
+		}
+		l.Values[i] = value
+	}
+	return structpb.NewListValue(l), nil
+}
@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestStructRoundTrip(t *testing.T) {
+	record, err := structpb.NewStruct(map[string]interface{}{
+		"name":  map[string]interface{}{"S": " Ada "},
+		"age":   map[string]interface{}{"N": "36"},
+		"tags":  map[string]interface{}{"SS": []interface{}{"a", "b"}},
+		"extra": map[string]interface{}{"M": map[string]interface{}{"ok": map[string]interface{}{"BOOL": true}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := Transform(FromStruct(record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ToStruct(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "Ada", "age": 36.0, "tags": []interface{}{"a", "b"}, "extra": map[string]interface{}{"ok": true}}
+	if !reflect.DeepEqual(got.AsMap(), want) {
+		t.Errorf("struct = %v, want %v", got.AsMap(), want)
+	}
+}
+
+func TestToValue(t *testing.T) {
+	got, err := ToValue([]Output{{
+		{"raw": json.RawMessage(`{"id": 7, "list": [true, null]}`)},
+		{"n": json.Number("1.5"), "i": int64(3), "bin": []byte{0, 1}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := structpb.NewValue([]interface{}{[]interface{}{
+		map[string]interface{}{"raw": map[string]interface{}{"id": 7, "list": []interface{}{true, nil}}},
+		map[string]interface{}{"n": 1.5, "i": 3, "bin": "AAE="},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("ToValue = %v, want %v", got, want)
+	}
+
+	if _, err := ToValue(map[string]interface{}{"c": make(chan int)}); err == nil {
+		t.Error("ToValue of a channel succeeded, want error")
+	}
+}
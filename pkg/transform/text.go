@@ -0,0 +1,89 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	xtransform "golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// titleCase capitalizes the first letter of each word of a string
+func titleCase(s string) string {
+	return cases.Title(language.Und).String(s)
+}
+
+// collapseWhitespace trims a string and replaces each run of whitespace in it with a
+// single space
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// textRuleAction returns the rule action applying a string rewrite to a string, or to each
+// element of an array of strings
+func textRuleAction(rewrite func(s string) string) ruleAction {
+	return ruleAction{
+		validate: func(Rule) error { return nil },
+		apply: func(r Rule, value interface{}) (interface{}, error) {
+			return eachString(value, func(s string) (string, error) {
+				return rewrite(s), nil
+			})
+		},
+	}
+}
+
+// eachString applies fn to a string value, or to each element of an array of strings
+func eachString(value interface{}, fn func(s string) (string, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case []interface{}:
+		rewritten := make([]interface{}, len(v))
+		for i, element := range v {
+			s, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("element %d is not a string", i)
+			}
+			var err error
+			if rewritten[i], err = fn(s); err != nil {
+				return nil, err
+			}
+		}
+		return rewritten, nil
+	}
+	return nil, fmt.Errorf("value is not a string")
+}
+
+// stripDiacritics removes the accents and other combining marks of a string, so that
+// "Crème Brûlée" becomes "Creme Brulee"
+func stripDiacritics(s string) string {
+	t := xtransform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := xtransform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return stripped
+}
+
+// slugify turns a string into a URL slug of lowercase ASCII letters and digits separated
+// by single hyphens, so that "Crème Brûlée: 2 Ways!" becomes "creme-brulee-2-ways"
+func slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(stripDiacritics(s)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
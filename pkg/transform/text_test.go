@@ -0,0 +1,48 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextRules(t *testing.T) {
+	tests := []struct {
+		action string
+		value  interface{}
+		want   interface{}
+	}{
+		{"lowercase", "Ada LOVELACE", "ada lovelace"},
+		{"uppercase", "gb", "GB"},
+		{"titlecase", "ada lovelace-byron", "Ada Lovelace-Byron"},
+		{"collapse-whitespace", "  a \t b\n\nc ", "a b c"},
+		{"strip-diacritics", "Crème Brûlée, Zoë, Ångström", "Creme Brulee, Zoe, Angstrom"},
+		{"slugify", "  Crème Brûlée: 2 Ways! ", "creme-brulee-2-ways"},
+		{"slugify", "---", ""},
+		{"uppercase", []interface{}{"a", "b"}, []interface{}{"A", "B"}},
+	}
+	for _, tt := range tests {
+		got, err := ruleActions[tt.action].apply(Rule{}, tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s of %q = %q, %v, want %q", tt.action, tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []interface{}{1.0, []interface{}{"a", 1.0}} {
+		if got, err := ruleActions["lowercase"].apply(Rule{}, value); err == nil {
+			t.Errorf("lowercase of %v = %v, want error", value, got)
+		}
+	}
+
+	rs, err := ParseRules([]byte(`{"rules": [{"field": "title", "action": "slugify", "rename": "slug"}, {"field": "name", "action": "titlecase"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rs.Apply(Output{{"title": "Hello, World"}, {"name": "ada"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Output{{{"slug": "hello-world"}, {"name": "Ada"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apply = %v, want %v", got, want)
+	}
+}
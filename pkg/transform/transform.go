@@ -0,0 +1,418 @@
+// Package transform converts decoded JSON records to the output format of the transform
+// command, so that other services can embed the same logic without shelling out. String
+// values are trimmed and coerced, type descriptors unwrapped, top-level objects flattened
+// and rules applied.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Input represents the input JSON structure
+type Input map[string]interface{}
+
+// Output represents the desired output JSON structure
+type Output []map[string]interface{}
+
+// Transformer transforms records. The zero value transforms them the way the transform
+// command does without flags, except that it drops warnings rather than printing them.
+//
+// A Transformer is safe for concurrent use by multiple goroutines, as a server shares one
+// across its requests: each call keeps the state of its record to itself and only reads the
+// fields, the rules and the input. The fields must not be changed once the Transformer is in
+// use.
+type Transformer struct {
+	// Coercions is the order of coercions tried on every string value, such as
+	// timestamp, number, boolean, string. When nil, each location keeps its legacy coercions.
+	Coercions []string
+	// Rules are applied to each record by TransformRecord; their coerce rules also apply in
+	// Transform
+	Rules *RuleSet
+	// KeepScalars keeps the numbers, booleans and nulls that are otherwise skipped as
+	// unsupported, so that transforming an output again leaves it unchanged
+	KeepScalars bool
+	// KeepNesting keeps nested objects under their keys rather than flattening their fields
+	// into the output, so that documents such as configuration files keep their structure
+	KeepNesting bool
+	// RawBinary emits the values of B and BS descriptors as []byte rather than as standard
+	// base64 strings, for output formats with a binary type
+	RawBinary bool
+	// TypedTimestamps emits coerced timestamps as Timestamp rather than int64, for output
+	// formats with a date type that must tell them from other integers
+	TypedTimestamps bool
+	// Order sorts the top-level output maps by their keys: "asc" (the default when empty)
+	// or "desc", or "none" for the unspecified order of Go maps
+	Order string
+	// Strict makes Transform fail with a *StrictError listing every field it would skip
+	// and every malformed timestamp, instead of warning about skipped fields
+	Strict bool
+	// Locale also coerces the dates, numbers and digits written as in a language, such as
+	// "3 mars 2024" for fr-FR
+	Locale *Locale
+	// Warnings promote warnings to the problems of a *StrictError, or suppress them
+	Warnings *WarningRules
+	// Coverage, when set, counts the rules each record matches and the field paths it
+	// holds in Transform, TransformRecord and TransformEvents
+	Coverage *Coverage
+	// Compat is the compatibility level whose default coercions apply where neither
+	// Coercions nor a coerce rule sets an order, such as "v1"; LatestCompat when empty
+	Compat string
+	// OnWarning, when set, is called with each warning that is neither promoted nor
+	// suppressed; other warnings are dropped. It may be called by several goroutines at once.
+	OnWarning func(Warning)
+}
+
+// Timestamp is a string coerced as a timestamp by a Transformer setting TypedTimestamps, in
+// seconds since the epoch. It encodes as a JSON number like the int64 emitted otherwise.
+type Timestamp int64
+
+// StrictError lists the problems found in a record by a strict Transformer, or the
+// warnings promoted to errors
+type StrictError struct {
+	Problems []string
+}
+
+// Error joins the problems into a single line
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("%d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// run holds the problems found while transforming a record, and its events when they are
+// recorded
+type run struct {
+	*Transformer
+	// input is the record being transformed, as seen by the when expressions of coerce rules
+	input    Input
+	problems []string
+	events   []Event
+	// matched holds the indexes of the rules the record matched, when coverage is counted
+	matched map[int]bool
+}
+
+// skip reports a value at a path skipped by the transformer, with the class of warning
+// for WarningRules: as a warning, or as a problem of the record in strict mode or when the
+// warning is promoted
+func (r *run) skip(path string, value interface{}, class, format string, args ...interface{}) {
+	r.event(path, "drop", value, nil)
+	problem := fmt.Sprintf(format, args...)
+	r.warn(Warning{Class: class, Path: path, Message: "Skipping " + problem}, true, problem)
+}
+
+// Transform transforms the input JSON to the desired output format with the default
+// Transformer
+func Transform(input Input) (Output, error) {
+	var t Transformer
+	return t.Transform(input)
+}
+
+// Transform transforms the input JSON to the desired output format. DynamoDB-style type
+// descriptors such as {"N": "123"} are unwrapped into native values, and subtrees decoded
+// as json.RawMessage are passed through unchanged. Only strict Transformers fail.
+func (t *Transformer) Transform(input Input) (Output, error) {
+	r := t.newRun()
+	defer t.Coverage.add(r)
+	return r.transform(input)
+}
+
+// transform transforms a record for Transform
+func (r *run) transform(input Input) (Output, error) {
+	var output Output
+	r.input = input
+
+	// Iterate through input keys in order and transform each field
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	if r.Order != "none" {
+		sort.Strings(keys)
+	}
+	for _, key := range keys {
+		value := input[key]
+		// Skip fields with empty keys
+		if key == "" {
+			r.event(key, "drop", value, nil)
+			continue
+		}
+
+		// Sanitize key by trimming leading and trailing whitespace
+		if trimmed := strings.TrimSpace(key); trimmed != key {
+			r.event(trimmed, "rename", key, trimmed)
+			key = trimmed
+		}
+
+		// Transform value based on data type
+		switch v := value.(type) {
+		case map[string]interface{}:
+			// Type descriptors are unwrapped into the field's value
+			if kind, dv, ok := descriptor(v); ok {
+				if value, ok := r.transformDescriptor(kind, dv, key); ok {
+					r.event(key, "unwrap", v, value)
+					output = append(output, map[string]interface{}{key: value})
+				} else {
+					r.event(key, "drop", v, nil)
+				}
+				continue
+			}
+			if r.KeepNesting {
+				output = append(output, map[string]interface{}{key: r.transformMap(v, key)})
+				continue
+			}
+			// Other nested objects are flattened into the output
+			outputMap := r.transformMap(v, "")
+			if len(outputMap) > 0 {
+				r.event(key, "flatten", v, outputMap)
+				output = append(output, outputMap)
+			} else {
+				r.event(key, "drop", v, nil)
+			}
+		case string:
+			coerced := r.coerceString(key, v, r.defaultCoercion(fieldLocation))
+			r.stringEvent(key, v, coerced)
+			output = append(output, map[string]interface{}{key: coerced})
+		case []interface{}:
+			outputList := r.transformList(v, key)
+			if len(outputList) > 0 {
+				output = append(output, map[string]interface{}{key: outputList})
+			} else {
+				r.event(key, "drop", v, nil)
+			}
+		case json.RawMessage:
+			// Passthrough subtrees are copied without being decoded
+			output = append(output, map[string]interface{}{key: v})
+		default:
+			if r.KeepScalars && isScalar(v) {
+				output = append(output, map[string]interface{}{key: v})
+				continue
+			}
+			r.skip(key, v, "unsupported-type", "unsupported data type for key %q", key)
+		}
+	}
+
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	sortOutput(output, r.Order)
+	return output, nil
+}
+
+// err returns the problems found in the record as a *StrictError, if any
+func (r *run) err() error {
+	if len(r.problems) == 0 {
+		return nil
+	}
+	sort.Strings(r.problems)
+	return &StrictError{Problems: r.problems}
+}
+
+// orders are the values of Transformer.Order
+var orders = map[string]bool{"": true, "asc": true, "desc": true, "none": true}
+
+// ValidateOrder checks a value of Transformer.Order
+func ValidateOrder(order string) error {
+	if !orders[order] {
+		return fmt.Errorf("unsupported order %q (want asc, desc or none)", order)
+	}
+	return nil
+}
+
+// sortOutput sorts the maps of an output by their smallest key, as flattened objects hold
+// several keys, so that outputs are the same from run to run
+func sortOutput(output Output, order string) {
+	if order == "none" {
+		return
+	}
+	type entry struct {
+		key string
+		m   map[string]interface{}
+	}
+	entries := make([]entry, len(output))
+	for i, m := range output {
+		entries[i].m = m
+		for k := range m {
+			if entries[i].key == "" || k < entries[i].key {
+				entries[i].key = k
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if order == "desc" {
+			return entries[i].key > entries[j].key
+		}
+		return entries[i].key < entries[j].key
+	})
+	for i, e := range entries {
+		output[i] = e.m
+	}
+}
+
+// TransformRecord transforms a record and applies the rules to it, returning the records to
+// write
+func (t *Transformer) TransformRecord(input Input) ([]Output, error) {
+	r := t.newRun()
+	defer t.Coverage.add(r)
+	output, err := r.transform(input)
+	if err != nil {
+		return nil, err
+	}
+	return t.Rules.apply(output, r)
+}
+
+// TransformValue transforms a single JSON value found at an output field path the way
+// Transform transforms a top-level field. Values the transformer drops become null.
+func (t *Transformer) TransformValue(v interface{}, path string) interface{} {
+	// Skipped values are warned about even in strict mode, as there is no record to fail
+	lenient := *t
+	lenient.Strict = false
+	r := &run{Transformer: &lenient}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if kind, dv, ok := descriptor(v); ok {
+			value, _ := r.transformDescriptor(kind, dv, path)
+			return value
+		}
+		return r.transformMap(v, path)
+	case string:
+		return r.coerceString(path, v, r.defaultCoercion(fieldLocation))
+	case []interface{}:
+		return r.transformList(v, path)
+	}
+	return v
+}
+
+// transformMap transforms a map[string]interface{} found at an output field path to the
+// desired output format
+func (r *run) transformMap(m map[string]interface{}, path string) map[string]interface{} {
+	outputMap := make(map[string]interface{})
+
+	// Sort map keys lexically
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Iterate through sorted keys and transform each field
+	for _, k := range keys {
+		// Sanitize key by trimming leading and trailing whitespace
+		key := strings.TrimSpace(k)
+		fieldPath := JoinPath(path, key)
+		if key != k {
+			r.event(fieldPath, "rename", k, key)
+		}
+
+		// Transform value based on data type
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			if kind, dv, ok := descriptor(v); ok {
+				if value, ok := r.transformDescriptor(kind, dv, fieldPath); ok {
+					r.event(fieldPath, "unwrap", v, value)
+					outputMap[key] = value
+				} else {
+					r.event(fieldPath, "drop", v, nil)
+				}
+				continue
+			}
+			outputMap[key] = r.transformMap(v, fieldPath)
+		case string:
+			coerced := r.coerceString(fieldPath, v, r.defaultCoercion(mapLocation))
+			r.stringEvent(fieldPath, v, coerced)
+			outputMap[key] = coerced
+		case []interface{}:
+			outputList := r.transformList(v, fieldPath)
+			if len(outputList) > 0 {
+				outputMap[key] = outputList
+			} else {
+				r.event(fieldPath, "drop", v, nil)
+			}
+		case json.RawMessage:
+			outputMap[key] = v
+		default:
+			if r.KeepScalars && isScalar(v) {
+				outputMap[key] = v
+				continue
+			}
+			r.skip(fieldPath, v, "unsupported-type", "unsupported data type for key %q", fieldPath)
+		}
+	}
+
+	return outputMap
+}
+
+// transformList transforms a []interface{} found at an output field path to the desired
+// output format. Elements share the path of the list.
+func (r *run) transformList(l []interface{}, path string) []interface{} {
+	var outputList []interface{}
+
+	// Iterate through list elements and transform each item
+	for _, item := range l {
+		switch v := item.(type) {
+		case map[string]interface{}:
+			if kind, dv, ok := descriptor(v); ok {
+				if value, ok := r.transformDescriptor(kind, dv, path); ok {
+					r.event(path, "unwrap", v, value)
+					outputList = append(outputList, value)
+				} else {
+					r.event(path, "drop", v, nil)
+				}
+				continue
+			}
+			outputMap := r.transformMap(v, path)
+			if len(outputMap) > 0 {
+				outputList = append(outputList, outputMap)
+			} else {
+				r.event(path, "drop", v, nil)
+			}
+		case string:
+			coerced := r.coerceString(path, v, r.defaultCoercion(listLocation))
+			r.stringEvent(path, v, coerced)
+			outputList = append(outputList, coerced)
+		default:
+			if r.KeepScalars && isScalar(v) {
+				outputList = append(outputList, v)
+				continue
+			}
+			r.skip(path, v, "unsupported-type", "unsupported data type in list %q", path)
+		}
+	}
+
+	return outputList
+}
+
+// isScalar reports whether a decoded value is a JSON number, boolean or null
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, bool, float64, int, int64, Timestamp, json.Number:
+		return true
+	}
+	return false
+}
+
+// isNumeric checks if a string represents a numeric value
+func isNumeric(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// parseNumber parses a numeric string and returns the corresponding number
+func parseNumber(s string) interface{} {
+	// Strip leading zeros
+	trimmed := strings.TrimLeft(s, "0")
+	// Parse integer or float
+	if strings.Contains(trimmed, ".") {
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil
+		}
+		return f
+	}
+	i, err := strconv.Atoi(trimmed)
+	if err != nil {
+		return nil
+	}
+	return i
+}
@@ -0,0 +1,241 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	got, err := Transform(Input{
+		" name ":  " Ada ",
+		"created": "2024-01-01T00:00:00Z",
+		"address": map[string]interface{}{"city": " London ", "zip": "N1"},
+		"tags":    []interface{}{"7", " x "},
+		"":        "dropped",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":    "Ada",
+		"created": int64(1704067200),
+		"city":    "London",
+		"zip":     "N1",
+		"tags":    []interface{}{7, "x"},
+	}
+	merged := make(map[string]interface{})
+	for _, m := range got {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Transform = %v, want %v", merged, want)
+	}
+}
+
+func TestTransformRecord(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"rules": [
+		{"field": "zip", "action": "coerce", "coerce": ["string"]},
+		{"field": "tags", "action": "chunk", "size": 1}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Transformer{Coercions: []string{"number"}, Rules: rules}
+	got, err := tr.TransformRecord(Input{"zip": "02134", "tags": []interface{}{"1", "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("TransformRecord returned %d records, want 2", len(got))
+	}
+	for i, output := range got {
+		data, _ := json.Marshal(output)
+		for _, m := range output {
+			if zip, ok := m["zip"]; ok && zip != "02134" {
+				t.Errorf("record %d: zip = %#v, want the coerce rule to keep it a string", i, zip)
+			}
+			if tags, ok := m["tags"]; ok && !reflect.DeepEqual(tags, []interface{}{i + 1}) {
+				t.Errorf("record %d: %s, want tags [%d]", i, data, i+1)
+			}
+		}
+	}
+
+	if v := tr.TransformValue(map[string]interface{}{"n": "5"}, "x"); !reflect.DeepEqual(v, map[string]interface{}{"n": 5}) {
+		t.Errorf("TransformValue = %#v", v)
+	}
+}
+
+func ExampleTransformer() {
+	tr := Transformer{Coercions: []string{"number", "boolean"}}
+	output, err := tr.Transform(Input{"active": "true"})
+	if err != nil {
+		panic(err)
+	}
+	data, _ := json.Marshal(output)
+	fmt.Println(string(data))
+	// Output: [{"active":true}]
+}
+
+func TestKeepScalars(t *testing.T) {
+	input := Input{"n": 1.5, "b": false, "z": nil, "m": map[string]interface{}{"x": 2.0}, "l": []interface{}{true}}
+	if output, _ := Transform(input); len(output) != 0 {
+		t.Errorf("Transform = %v, want scalars skipped", output)
+	}
+	tr := Transformer{KeepScalars: true}
+	output, err := tr.Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]interface{})
+	for _, m := range output {
+		for k, v := range m {
+			got[k] = v
+		}
+	}
+	want := map[string]interface{}{"n": 1.5, "b": false, "z": nil, "x": 2.0, "l": []interface{}{true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform with KeepScalars = %v, want %v", got, want)
+	}
+}
+
+func TestKeepNesting(t *testing.T) {
+	tr := Transformer{KeepNesting: true, KeepScalars: true}
+	output, err := tr.Transform(Input{"tls": map[string]interface{}{"since": " x ", "opts": map[string]interface{}{"n": 1.0}}, "empty": map[string]interface{}{}, "name": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Output{
+		{"empty": map[string]interface{}{}},
+		{"name": "a"},
+		{"tls": map[string]interface{}{"opts": map[string]interface{}{"n": 1.0}, "since": "x"}},
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("Transform with KeepNesting = %v, want %v", output, want)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	input := Input{
+		"ok":    "fine",
+		"n":     1.5,
+		"when":  "2024-13-01T00:00:00Z",
+		"count": map[string]interface{}{"N": "1.2.3"},
+		"tags":  []interface{}{"a", false},
+	}
+	tr := Transformer{Strict: true}
+	output, err := tr.Transform(input)
+	var strictErr *StrictError
+	if !errors.As(err, &strictErr) || output != nil {
+		t.Fatalf("Transform = %v, %v, want a *StrictError", output, err)
+	}
+	want := []string{
+		`invalid number "1.2.3" at "count"`,
+		`malformed timestamp "2024-13-01T00:00:00Z" at "when"`,
+		`unsupported data type for key "n"`,
+		`unsupported data type in list "tags"`,
+	}
+	if !reflect.DeepEqual(strictErr.Problems, want) {
+		t.Errorf("problems = %q, want %q", strictErr.Problems, want)
+	}
+
+	// Records without problems transform as usual
+	if output, err := tr.Transform(Input{"ok": "fine", "when": "2024-01-01T00:00:00Z"}); err != nil || len(output) != 2 {
+		t.Errorf("Transform = %v, %v, want 2 fields", output, err)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	input := Input{"c": "3", "a": "1", "nested": map[string]interface{}{"d": "4", "b": "2"}, "e": "5"}
+	tests := []struct {
+		order string
+		want  Output
+	}{
+		{"", Output{{"a": "1"}, {"b": "2", "d": "4"}, {"c": "3"}, {"e": "5"}}},
+		{"desc", Output{{"e": "5"}, {"c": "3"}, {"b": "2", "d": "4"}, {"a": "1"}}},
+	}
+	for _, tt := range tests {
+		tr := Transformer{Order: tt.order}
+		for i := 0; i < 5; i++ {
+			if got, _ := tr.Transform(input); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Transform with order %q = %v, want %v", tt.order, got, tt.want)
+			}
+		}
+	}
+	if err := ValidateOrder("random"); err == nil {
+		t.Error("ValidateOrder accepted an unknown order")
+	}
+}
+
+// TestTransformerConcurrent shares a Transformer and its inputs across goroutines, for the
+// race detector of go test -race to check that transforming keeps no shared mutable state
+func TestTransformerConcurrent(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"rules": [
+		{"field": "price", "action": "coerce", "coerce": ["number"], "when": "record.country == 'FR'"},
+		{"field": "name", "action": "titlecase", "when": "has(record.country)"},
+		{"field": "tags", "action": "sort"},
+		{"field": "weight", "action": "convert", "units": "lb->kg", "precision": 2},
+		{"field": "items", "action": "chunk", "size": 2}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	locale, err := ParseLocale("fr-FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &Transformer{
+		Coercions: []string{"timestamp", "number"},
+		Rules:     rules,
+		Locale:    locale,
+		Warnings:  &WarningRules{Suppress: []string{"*"}},
+		Coverage:  &Coverage{},
+	}
+	inputs := []Input{
+		{"name": " ada lovelace ", "country": "FR", "price": "1 234,5", "tags": []interface{}{"b", "a"}, "created": "3 mars 2024"},
+		{"weight": "10", "items": []interface{}{"1", "2", "3"}, "meta": map[string]interface{}{"n": map[string]interface{}{"N": "7"}}},
+		{"name": "grace", "skipped": true, "tags": []interface{}{map[string]interface{}{"S": "z"}, "y"}},
+	}
+	// The outputs of a Transformer used by a single goroutine
+	sequential := *tr
+	sequential.Coverage = nil
+	want := make([][]Output, len(inputs))
+	for i, input := range inputs {
+		if want[i], err = sequential.TransformRecord(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const goroutines, rounds = 8, 20
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				for i, input := range inputs {
+					got, err := tr.TransformRecord(input)
+					if err != nil || !reflect.DeepEqual(got, want[i]) {
+						t.Errorf("TransformRecord(%v) = %v, %v, want %v", input, got, err, want[i])
+						return
+					}
+					if _, _, err := tr.TransformEvents(input); err != nil {
+						t.Error(err)
+						return
+					}
+					tr.TransformValue(input["tags"], "tags")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if report := tr.Coverage.Report(rules); report.Records != 2*goroutines*rounds*len(inputs) {
+		t.Errorf("coverage counted %d records, want %d", report.Records, 2*goroutines*rounds*len(inputs))
+	}
+}
@@ -0,0 +1,113 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// unit converts between a unit and the base unit of its quantity: base = value*scale + offset
+type unit struct {
+	quantity      string
+	scale, offset float64
+}
+
+// units maps unit names to their conversions. The base units are the meter, kilogram,
+// degree Celsius, liter and meter per second.
+var units = map[string]unit{
+	"mm": {"length", 0.001, 0},
+	"cm": {"length", 0.01, 0},
+	"m":  {"length", 1, 0},
+	"km": {"length", 1000, 0},
+	"in": {"length", 0.0254, 0},
+	"ft": {"length", 0.3048, 0},
+	"yd": {"length", 0.9144, 0},
+	"mi": {"length", 1609.344, 0},
+
+	"mg": {"mass", 1e-6, 0},
+	"g":  {"mass", 0.001, 0},
+	"kg": {"mass", 1, 0},
+	"t":  {"mass", 1000, 0},
+	"oz": {"mass", 0.028349523125, 0},
+	"lb": {"mass", 0.45359237, 0},
+	"st": {"mass", 6.35029318, 0},
+
+	"C": {"temperature", 1, 0},
+	"F": {"temperature", 5.0 / 9, -32 * 5.0 / 9},
+	"K": {"temperature", 1, -273.15},
+
+	"ml":    {"volume", 0.001, 0},
+	"l":     {"volume", 1, 0},
+	"floz":  {"volume", 0.0295735295625, 0},
+	"pt":    {"volume", 0.473176473, 0},
+	"qt":    {"volume", 0.946352946, 0},
+	"gal":   {"volume", 3.785411784, 0},
+	"m/s":   {"speed", 1, 0},
+	"km/h":  {"speed", 1 / 3.6, 0},
+	"mph":   {"speed", 0.44704, 0},
+	"knots": {"speed", 1852.0 / 3600, 0},
+}
+
+// parseUnits splits the units of a convert rule, such as "lb->kg", into their conversions
+func parseUnits(s string) (unit, unit, error) {
+	fromName, toName, ok := strings.Cut(s, "->")
+	if !ok {
+		return unit{}, unit{}, fmt.Errorf("units %q are not of the form from->to", s)
+	}
+	from, ok := units[strings.TrimSpace(fromName)]
+	if !ok {
+		return unit{}, unit{}, fmt.Errorf("unsupported unit %q", fromName)
+	}
+	to, ok := units[strings.TrimSpace(toName)]
+	if !ok {
+		return unit{}, unit{}, fmt.Errorf("unsupported unit %q", toName)
+	}
+	if from.quantity != to.quantity {
+		return unit{}, unit{}, fmt.Errorf("cannot convert %s to %s", from.quantity, to.quantity)
+	}
+	return from, to, nil
+}
+
+// maxConvertPrecision is the most decimal places a convert rule rounds to, beyond which
+// float64 values have no more significant digits
+const maxConvertPrecision = 15
+
+// validateConvertRule checks the units and precision of a convert rule
+func validateConvertRule(r Rule) error {
+	if _, _, err := parseUnits(r.Units); err != nil {
+		return err
+	}
+	if r.Precision != nil && (*r.Precision < 0 || *r.Precision > maxConvertPrecision) {
+		return fmt.Errorf("precision must be between 0 and %d", maxConvertPrecision)
+	}
+	return nil
+}
+
+// applyConvertRule converts a number, or a numeric string, from one unit to another,
+// rounding it to the rule's precision in decimal places when one is set. NaN and
+// infinities are rejected, in the value or its conversion, as JSON cannot encode them.
+func applyConvertRule(r Rule, value interface{}) (interface{}, error) {
+	n, ok := toFloat(value)
+	if s, isString := value.(string); isString {
+		var err error
+		n, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		ok = err == nil
+	}
+	if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+		return nil, fmt.Errorf("value is not a finite number")
+	}
+	from, to, _ := parseUnits(r.Units)
+	converted := (n*from.scale + from.offset - to.offset) / to.scale
+	if math.IsInf(converted, 0) {
+		return nil, fmt.Errorf("converted value is out of range")
+	}
+	if r.Precision != nil {
+		pow := math.Pow(10, float64(*r.Precision))
+		// Values too large to scale have no decimal places left to round
+		if scaled := converted * pow; !math.IsInf(scaled, 0) {
+			converted = math.Round(scaled) / pow
+		}
+	}
+	return converted, nil
+}
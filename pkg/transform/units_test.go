@@ -0,0 +1,70 @@
+package transform
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConvertRule(t *testing.T) {
+	two, zero := 2, 0
+	tests := []struct {
+		units     string
+		precision *int
+		value     interface{}
+		want      interface{}
+	}{
+		{"lb->kg", &two, 10.0, 4.54},
+		{"kg->lb", &two, 1, 2.2},
+		{"F->C", &two, 212.0, 100.0},
+		{"C->F", nil, -40.0, -40.0},
+		{"K->C", &two, " 0 ", -273.15},
+		{"mi->km", &zero, 26.2, 42.0},
+		{"km/h->mph", &two, int64(100), 62.14},
+		{"gal->l", &two, "1", 3.79},
+	}
+	for _, tt := range tests {
+		got, err := applyConvertRule(Rule{Units: tt.units, Precision: tt.precision}, tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convert %s of %v = %v, %v, want %v", tt.units, tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []interface{}{"heavy", "NaN", " Inf ", math.Inf(-1)} {
+		if got, err := applyConvertRule(Rule{Units: "lb->kg"}, value); err == nil {
+			t.Errorf("convert of %v = %v, want error", value, got)
+		}
+	}
+	if got, err := applyConvertRule(Rule{Units: "kg->lb"}, math.MaxFloat64); err == nil {
+		t.Errorf("convert overflowing to infinity = %v, want error", got)
+	}
+	// Scaling a large value to the precision overflows, leaving it unrounded
+	if got, err := applyConvertRule(Rule{Units: "kg->kg", Precision: &two}, 1e307); err != nil || got != 1e307 {
+		t.Errorf("convert of 1e307 = %v, %v, want 1e307", got, err)
+	}
+
+	for _, rules := range []string{
+		`{"rules": [{"field": "w", "action": "convert", "units": "lb"}]}`,
+		`{"rules": [{"field": "w", "action": "convert", "units": "lb->parsec"}]}`,
+		`{"rules": [{"field": "w", "action": "convert", "units": "lb->km"}]}`,
+		`{"rules": [{"field": "w", "action": "convert", "units": "lb->kg", "precision": -1}]}`,
+		`{"rules": [{"field": "w", "action": "convert", "units": "lb->kg", "precision": 400}]}`,
+		`{"rules": [{"field": "w", "action": "chunk", "size": 1, "rename": "x"}]}`,
+	} {
+		if _, err := ParseRules([]byte(rules)); err == nil {
+			t.Errorf("ParseRules(%s) succeeded, want error", rules)
+		}
+	}
+
+	rs, err := ParseRules([]byte(`{"rules": [{"field": "reading.temp_f", "action": "convert", "units": "F->C", "precision": 1, "rename": "temp_c"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rs.Apply(Output{{"reading": map[string]interface{}{"temp_f": 98.6}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Output{{{"reading": map[string]interface{}{"temp_c": 37.0}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apply = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package transform
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Warning is a diagnostic about a value that was skipped or kept as it was. Its class names
+// the kind of diagnostic, such as unsupported-type, and its path the field concerned, if any.
+type Warning struct {
+	Class   string
+	Path    string
+	Message string
+}
+
+// WarningRules promote warnings to errors or suppress them, by patterns of the form
+// "class" or "class:path" such as "unsupported-type:payload.*". Classes and paths are
+// matched as path.Match globs, and promotion wins over suppression.
+type WarningRules struct {
+	AsError  []string
+	Suppress []string
+}
+
+// ParseWarningPatterns parses a comma-separated list of warning patterns
+func ParseWarningPatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		class, fieldPath, _ := strings.Cut(p, ":")
+		if class == "" {
+			return nil, fmt.Errorf("warning pattern %q has no class", p)
+		}
+		for _, glob := range []string{class, fieldPath} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("invalid warning pattern %q: %v", p, err)
+			}
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// Action returns what becomes of a warning: "error", "suppress" or "warn". A nil
+// WarningRules warns about everything.
+func (wr *WarningRules) Action(w Warning) string {
+	if wr == nil {
+		return "warn"
+	}
+	for _, p := range wr.AsError {
+		if matchWarning(p, w) {
+			return "error"
+		}
+	}
+	for _, p := range wr.Suppress {
+		if matchWarning(p, w) {
+			return "suppress"
+		}
+	}
+	return "warn"
+}
+
+// matchWarning reports whether a warning matches a pattern. Patterns without a path match
+// warnings about any path.
+func matchWarning(pattern string, w Warning) bool {
+	class, fieldPath, hasPath := strings.Cut(pattern, ":")
+	if ok, _ := path.Match(class, w.Class); !ok {
+		return false
+	}
+	if !hasPath {
+		return true
+	}
+	ok, _ := path.Match(fieldPath, w.Path)
+	return ok
+}
+
+// warn handles a warning of the record being transformed: it becomes a problem of the
+// record when promoted, or in strict mode when strict is set, and is otherwise passed to
+// OnWarning unless suppressed
+func (r *run) warn(w Warning, strict bool, problem string) {
+	switch action := r.Warnings.Action(w); {
+	case action == "error" || strict && r.Strict:
+		r.problems = append(r.problems, problem)
+	case action == "warn" && r.OnWarning != nil:
+		r.OnWarning(w)
+	}
+}
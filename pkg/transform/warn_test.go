@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWarningRulesAction(t *testing.T) {
+	rules := &WarningRules{
+		AsError:  []string{"invalid-number", "unsupported-type:payload.*"},
+		Suppress: []string{"unsupported-type", "invalid-*:legacy"},
+	}
+	tests := []struct {
+		w    Warning
+		want string
+	}{
+		{Warning{Class: "invalid-number", Path: "n"}, "error"},
+		{Warning{Class: "invalid-number", Path: "legacy"}, "error"},
+		{Warning{Class: "unsupported-type", Path: "payload.size"}, "error"},
+		{Warning{Class: "unsupported-type", Path: "size"}, "suppress"},
+		{Warning{Class: "invalid-base64", Path: "legacy"}, "suppress"},
+		{Warning{Class: "invalid-base64", Path: "blob"}, "warn"},
+		{Warning{Class: "rule"}, "warn"},
+	}
+	for _, tt := range tests {
+		if got := rules.Action(tt.w); got != tt.want {
+			t.Errorf("Action(%+v) = %q, want %q", tt.w, got, tt.want)
+		}
+	}
+	var none *WarningRules
+	if got := none.Action(Warning{Class: "rule"}); got != "warn" {
+		t.Errorf("nil Action = %q, want warn", got)
+	}
+}
+
+func TestParseWarningPatterns(t *testing.T) {
+	got, err := ParseWarningPatterns("unsupported-type:payload.*, rule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"unsupported-type:payload.*", "rule"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseWarningPatterns = %q, want %q", got, want)
+	}
+	for _, s := range []string{"", ":payload", "rule:[", "invalid-["} {
+		if _, err := ParseWarningPatterns(s); err == nil {
+			t.Errorf("ParseWarningPatterns(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestPromotedWarnings(t *testing.T) {
+	tr := Transformer{Warnings: &WarningRules{
+		AsError:  []string{"unsupported-type:meta.*", "rule"},
+		Suppress: []string{"unsupported-type"},
+	}}
+
+	// Suppressed warnings skip the field without failing
+	if _, err := tr.Transform(Input{"size": 3.0}); err != nil {
+		t.Errorf("Transform with suppressed warning: %v", err)
+	}
+
+	_, err := tr.Transform(Input{"record": map[string]interface{}{"meta": map[string]interface{}{"size": 3.0}}})
+	var strictErr *StrictError
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("Transform with promoted warning = %v, want *StrictError", err)
+	}
+	if want := []string{`unsupported data type for key "meta.size"`}; !reflect.DeepEqual(strictErr.Problems, want) {
+		t.Errorf("Problems = %q, want %q", strictErr.Problems, want)
+	}
+
+	// Rule warnings fail only when promoted, not in strict mode
+	rules, err := ParseRules([]byte(`{"rules": [{"action": "sort", "field": "name"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Rules = rules
+	if _, err := tr.TransformRecord(Input{"name": "x"}); !errors.As(err, &strictErr) {
+		t.Errorf("TransformRecord with promoted rule warning = %v, want *StrictError", err)
+	}
+	strict := Transformer{Strict: true, Rules: rules}
+	if _, err := strict.TransformRecord(Input{"name": "x"}); err != nil {
+		t.Errorf("strict TransformRecord with rule warning: %v", err)
+	}
+}
+
+func TestOnWarning(t *testing.T) {
+	var got []Warning
+	tr := Transformer{
+		Warnings:  &WarningRules{Suppress: []string{"unsupported-type:hidden"}},
+		OnWarning: func(w Warning) { got = append(got, w) },
+	}
+	if _, err := tr.Transform(Input{"size": 3.0, "hidden": 4.0}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Warning{{Class: "unsupported-type", Path: "size", Message: `Skipping unsupported data type for key "size"`}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnWarning got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// whenEnv is the CEL environment of the when expressions of rules, which see the record
+// as the map "record", as in `record.country == "US"`
+var whenEnv, _ = cel.NewEnv(cel.Variable("record", cel.MapType(cel.StringType, cel.DynType)))
+
+// compileWhen compiles the when expression of a rule, which must be a boolean
+func compileWhen(expr string) (cel.Program, error) {
+	ast, issues := whenEnv.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("when: %v", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("when: expression is a %s, not a bool", ast.OutputType())
+	}
+	return whenEnv.Program(ast)
+}
+
+// applies evaluates the when expression of a rule against a record, reporting whether the
+// rule applies to it. Rules without one always apply. Expressions that fail to evaluate,
+// for example by reading a missing key without has(), are warned about and do not apply.
+func (r Rule) applies(tr *run, record map[string]interface{}) bool {
+	if r.when == nil {
+		return true
+	}
+	if record == nil {
+		record = map[string]interface{}{}
+	}
+	out, _, err := r.when.Eval(map[string]interface{}{"record": record})
+	if err == nil {
+		if b, ok := out.Value().(bool); ok {
+			return b
+		}
+		err = fmt.Errorf("result %v is not a bool", out.Value())
+	}
+	problem := fmt.Sprintf("rule %s %s: when: %v", r.Action, r.Field, err)
+	tr.warn(Warning{Class: "rule", Path: r.Field, Message: "Skipping " + problem}, false, problem)
+	return false
+}
+
+// mergeMaps merges the maps of an output into the single record when expressions see
+func mergeMaps(output Output) map[string]interface{} {
+	record := make(map[string]interface{})
+	for _, m := range output {
+		for k, v := range m {
+			record[k] = v
+		}
+	}
+	return record
+}
@@ -0,0 +1,56 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhenRules(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"rules": [
+		{"field": "zip", "action": "coerce", "coerce": ["string"], "when": "record.country == 'US'"},
+		{"field": "zip", "action": "coerce", "coerce": ["number"]},
+		{"field": "name", "action": "uppercase", "when": "has(record.vip) && record.vip == 'yes'"},
+		{"field": "temp", "action": "convert", "units": "F->C", "when": "record.country == 'US'"},
+		{"field": "tags", "action": "chunk", "size": 1, "when": "size(record.tags) > 2"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Transformer{Rules: rs, Coercions: []string{"number"}}
+	tests := []struct {
+		input Input
+		want  []Output
+	}{
+		{
+			Input{"country": "US", "zip": "02134", "name": "ada", "vip": "yes", "temp": "212", "tags": []interface{}{"a", "b"}},
+			[]Output{{{"country": "US"}, {"name": "ADA"}, {"tags": []interface{}{"a", "b"}}, {"temp": 100.0}, {"vip": "yes"}, {"zip": "02134"}}},
+		},
+		{
+			Input{"country": "FR", "zip": "75001", "name": "ada", "tags": []interface{}{"a", "b", "c"}},
+			[]Output{
+				{{"country": "FR"}, {"name": "ada"}, {"tags": []interface{}{"a"}}, {"zip": 75001}, {"tags_chunk": 0}, {"tags_chunks": 3}},
+				{{"country": "FR"}, {"name": "ada"}, {"tags": []interface{}{"b"}}, {"zip": 75001}, {"tags_chunk": 1}, {"tags_chunks": 3}},
+				{{"country": "FR"}, {"name": "ada"}, {"tags": []interface{}{"c"}}, {"zip": 75001}, {"tags_chunk": 2}, {"tags_chunks": 3}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := tr.TransformRecord(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TransformRecord(%v) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, data := range []string{
+		`{"rules": [{"field": "x", "action": "sort", "when": "record.x =="}]}`,
+		`{"rules": [{"field": "x", "action": "sort", "when": "1 + 2"}]}`,
+		`{"rules": [{"field": "x", "action": "passthrough", "when": "true"}]}`,
+	} {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("ParseRules(%s) succeeded, want error", data)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// preset rewrites a decoded record before transformation, for example by unwrapping a
+// vendor-specific envelope, and may expand it into several records
+type preset func(record Input) []Input
+
+// presets maps preset names to their implementations
+var presets = map[string]preset{
+	"changestream": changeStreamPreset,
+	"email":        emailPreset,
+	"fhir":         fhirPreset,
+	"firestore":    firestorePreset,
+	"hal":          halPreset,
+	"har":          harPreset,
+	"jsonapi":      jsonAPIPreset,
+	"odata":        odataPreset,
+	"paypal":       paypalPreset,
+	"postman":      postmanPreset,
+	"protojson":    protojsonPreset,
+	"salesforce":   salesforcePreset,
+	"stripe":       stripePreset,
+}
+
+// presetNames returns the sorted names of the available presets
+func presetNames() string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyPreset runs the named preset over every record, leaving records untouched when no
+// preset is selected
+func applyPreset(name string, records []Input) ([]Input, error) {
+	if name == "" {
+		return records, nil
+	}
+	p, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, presetNames())
+	}
+
+	var out []Input
+	for _, record := range records {
+		out = append(out, p(record)...)
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// protobufDurationPattern matches the protojson representation of google.protobuf.Duration
+var protobufDurationPattern = regexp.MustCompile(`^-?\d+(\.\d{1,9})?s$`)
+
+// protojsonPreset normalizes protojson conventions into plain values: "@type" annotations of
+// Any messages are dropped, well-known types packed in an Any are unwrapped with packed
+// durations like "1.5s" becoming seconds, and numbers and booleans become strings like the
+// rest of the input. Unpacked fields carry no type, so strings such as "30s" are kept as
+// they are. Timestamps are already RFC3339 strings and are left for the standard coercion.
+func protojsonPreset(record Input) []Input {
+	out := make(Input)
+	for k, v := range record {
+		if k == "@type" {
+			continue
+		}
+		if value := protojsonValue(v); value != nil {
+			out[k] = value
+		}
+	}
+	return []Input{out}
+}
+
+// protojsonValue recursively normalizes a protojson value, returning nil for null
+func protojsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if typeURL, ok := v["@type"].(string); ok {
+			// Well-known types packed in an Any carry their JSON form under "value"
+			name := typeURL[strings.LastIndex(typeURL, "/")+1:]
+			if value, ok := v["value"]; ok && strings.HasPrefix(name, "google.protobuf.") {
+				return protojsonWellKnown(name, value)
+			}
+		}
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if k == "@type" {
+				continue
+			}
+			if value := protojsonValue(item); value != nil {
+				m[k] = value
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if value := protojsonValue(item); value != nil {
+				l = append(l, value)
+			}
+		}
+		return l
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return nil
+}
+
+// protojsonWellKnown converts the JSON value of a well-known type packed in an Any
+func protojsonWellKnown(name string, v interface{}) interface{} {
+	switch name {
+	case "google.protobuf.Duration":
+		if s, ok := v.(string); ok && protobufDurationPattern.MatchString(s) {
+			return protobufDurationSeconds(s)
+		}
+	case "google.protobuf.Timestamp", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		if s, ok := v.(string); ok {
+			return s
+		}
+	case "google.protobuf.FieldMask":
+		// Field masks are a comma separated list of paths
+		if s, ok := v.(string); ok {
+			var paths []interface{}
+			for _, p := range strings.Split(s, ",") {
+				if p != "" {
+					paths = append(paths, p)
+				}
+			}
+			return paths
+		}
+	case "google.protobuf.Empty":
+		return nil
+	}
+	// Struct, Value, ListValue and the numeric and boolean wrappers are plain JSON already
+	return protojsonValue(v)
+}
+
+// protobufDurationSeconds strips the unit suffix from a protojson duration such as "1.5s"
+func protobufDurationSeconds(s string) string {
+	return strings.TrimSuffix(s, "s")
+}
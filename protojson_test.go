@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProtojsonPreset(t *testing.T) {
+	tests := []struct {
+		record Input
+		want   Input
+	}{
+		{
+			Input{"@type": "type.googleapis.com/acme.Order", "id": "o-1", "total": 12.5, "paid": true, "note": nil},
+			Input{"id": "o-1", "total": "12.5", "paid": "true"},
+		},
+		{
+			Input{"timeout": map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.Duration", "value": "1.500s"}},
+			Input{"timeout": "1.500"},
+		},
+		{
+			// Unpacked strings carry no type and are kept, as are packed non-durations
+			Input{"label": "30s", "timeout": map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.Duration", "value": "soon"}},
+			Input{"label": "30s", "timeout": "soon"},
+		},
+		{
+			Input{
+				"at":   map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.Timestamp", "value": "2024-01-02T03:04:05Z"},
+				"mask": map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.FieldMask", "value": "a.b,c"},
+				"none": map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.Empty", "value": map[string]interface{}{}},
+				"n":    map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.Int32Value", "value": float64(3)},
+			},
+			Input{"at": "2024-01-02T03:04:05Z", "mask": []interface{}{"a.b", "c"}, "n": "3"},
+		},
+		{
+			// Any messages of other types keep their fields without the annotation
+			Input{"detail": map[string]interface{}{"@type": "type.googleapis.com/acme.Detail", "value": "x", "codes": []interface{}{float64(1), nil, "b"}}},
+			Input{"detail": map[string]interface{}{"value": "x", "codes": []interface{}{"1", "b"}}},
+		},
+	}
+	for _, tt := range tests {
+		got := protojsonPreset(tt.record)
+		if want := []Input{tt.want}; !reflect.DeepEqual(got, want) {
+			t.Errorf("protojsonPreset(%v) = %#v, want %#v", tt.record, got, want)
+		}
+	}
+}
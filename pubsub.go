@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/oauth2/google"
+)
+
+// pubSubAPIURL is the Google Pub/Sub REST endpoint, replaced by PUBSUB_EMULATOR_HOST when set
+const pubSubAPIURL = "https://pubsub.googleapis.com/v1"
+
+// pubSubMaxBatch is the most messages Pub/Sub accepts in a single publish request
+const pubSubMaxBatch = 1000
+
+// pubSubParams holds the resource and flow control settings of a Pub/Sub URI
+type pubSubParams struct {
+	// resource is "projects/{project}/subscriptions/{name}" or "projects/{project}/topics/{name}"
+	resource    string
+	maxMessages int
+	batch       int
+	orderingKey string
+}
+
+// isPubSubURI reports whether a URI addresses a Pub/Sub subscription or topic
+func isPubSubURI(uri string) bool {
+	return strings.HasPrefix(uri, "pubsub://")
+}
+
+// parsePubSubURI parses a URI like "pubsub://project/subscription?max_messages=100" for input
+// or "pubsub://project/topic?ordering_key={device_id}&batch=100" for output
+func parsePubSubURI(uri, kind string) (pubSubParams, error) {
+	base, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, "pubsub://"), "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return pubSubParams{}, fmt.Errorf("invalid Pub/Sub URI %q: %v", uri, err)
+	}
+	parts := strings.Split(base, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return pubSubParams{}, fmt.Errorf("Pub/Sub URI %q is not pubsub://project/%s", uri, strings.TrimSuffix(kind, "s"))
+	}
+
+	p := pubSubParams{
+		resource:    "projects/" + parts[0] + "/" + kind + "/" + parts[1],
+		maxMessages: 100,
+		batch:       1,
+		orderingKey: query.Get("ordering_key"),
+	}
+	for name, n := range map[string]*int{"max_messages": &p.maxMessages, "batch": &p.batch} {
+		if v := query.Get(name); v != "" {
+			if *n, err = strconv.Atoi(v); err != nil || *n < 1 {
+				return pubSubParams{}, fmt.Errorf("invalid Pub/Sub %s %q", name, v)
+			}
+		}
+	}
+	if p.batch > pubSubMaxBatch {
+		return pubSubParams{}, fmt.Errorf("Pub/Sub batch %d exceeds %d messages", p.batch, pubSubMaxBatch)
+	}
+	return p, nil
+}
+
+// pubSubClient returns the HTTP client and API URL to use, preferring the local emulator
+func pubSubClient(ctx context.Context) (*http.Client, string, error) {
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); host != "" {
+		return http.DefaultClient, "http://" + host + "/v1", nil
+	}
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/pubsub")
+	if err != nil {
+		return nil, "", fmt.Errorf("finding Google credentials: %v", err)
+	}
+	return client, pubSubAPIURL, nil
+}
+
+// pubSubMessage is a message as sent to and received from the Pub/Sub API
+type pubSubMessage struct {
+	Data        string `json:"data"`
+	MessageID   string `json:"messageId,omitempty"`
+	OrderingKey string `json:"orderingKey,omitempty"`
+}
+
+// postPubSub posts a JSON request to a Pub/Sub resource method and decodes the response into v
+func postPubSub(ctx context.Context, client *http.Client, apiURL, method string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(client, req, v)
+}
+
+// consumePubSub pulls messages from the subscription of a Pub/Sub URI and passes the records
+// decoded from each message to fn until the process is interrupted. Up to max_messages are
+// pulled at a time and acknowledged once fn has returned for all of them, so that messages of
+// an ordered subscription are processed in order. Messages that fail to decode, or whose
+// outputs fn reports not delivered, are returned for redelivery, leaving the subscription's
+// dead-letter policy to take them out.
+func consumePubSub(uri string, opts inputOptions, fn func(member) bool) error {
+	p, err := parsePubSubURI(uri, "subscriptions")
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client, apiURL, err := pubSubClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var pulled struct {
+			ReceivedMessages []struct {
+				AckID   string        `json:"ackId"`
+				Message pubSubMessage `json:"message"`
+			} `json:"receivedMessages"`
+		}
+		err := postPubSub(ctx, client, apiURL, p.resource+":pull", map[string]interface{}{"maxMessages": p.maxMessages}, &pulled)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var acks, nacks []string
+		for _, received := range pulled.ReceivedMessages {
+			data, err := base64.StdEncoding.DecodeString(received.Message.Data)
+			var records []Input
+			if err == nil {
+				records, err = readInput(bytes.NewReader(data), opts)
+			}
+			if err != nil {
+				warn("rejected-message", "Rejecting message %q from %q: %v", received.Message.MessageID, p.resource, err)
+				nacks = append(nacks, received.AckID)
+				continue
+			}
+			if !fn(member{records: records}) {
+				// Have the message redelivered, as its outputs were not delivered
+				nacks = append(nacks, received.AckID)
+				continue
+			}
+			acks = append(acks, received.AckID)
+		}
+
+		// Acknowledge even when interrupted, since the pulled messages have been processed
+		if len(acks) > 0 {
+			if err := postPubSub(context.Background(), client, apiURL, p.resource+":acknowledge", map[string]interface{}{"ackIds": acks}, nil); err != nil {
+				return err
+			}
+		}
+		if len(nacks) > 0 {
+			body := map[string]interface{}{"ackIds": nacks, "ackDeadlineSeconds": 0}
+			if err := postPubSub(context.Background(), client, apiURL, p.resource+":modifyAckDeadline", body, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pubSubSink publishes each output as a message to a Pub/Sub topic, optionally with an
+// ordering key built from the record's fields. Messages are published in batches of up to
+// batch messages and after each source member or stream message.
+type pubSubSink struct {
+	client      *http.Client
+	apiURL      string
+	topic       string
+	batch       int
+	orderingKey string
+	format      string
+	pending     []pubSubMessage
+}
+
+// openPubSubSink opens a sink publishing to the topic of a Pub/Sub URI
+func openPubSubSink(uri, format string) (*pubSubSink, error) {
+	p, err := parsePubSubURI(uri, "topics")
+	if err != nil {
+		return nil, err
+	}
+	client, apiURL, err := pubSubClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &pubSubSink{client: client, apiURL: apiURL, topic: p.resource, batch: p.batch, orderingKey: p.orderingKey, format: format}, nil
+}
+
+// Write queues the encoded output and publishes the queue once it holds a full batch
+func (s *pubSubSink) Write(output Output) error {
+	var key string
+	if s.orderingKey != "" {
+		var err error
+		if key, err = expandTemplate(s.orderingKey, mergeOutput(output)); err != nil {
+			warn("skipped-record", "Skipping record: %v", err)
+			return nil
+		}
+	}
+	data, err := encodeOutput(output, s.format)
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending, pubSubMessage{
+		Data:        base64.StdEncoding.EncodeToString(messageBody(data, s.format)),
+		OrderingKey: key,
+	})
+	if len(s.pending) >= s.batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush publishes the queued messages in a single request, which keeps their order
+func (s *pubSubSink) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	var published struct {
+		MessageIDs []string `json:"messageIds"`
+	}
+	if err := postPubSub(context.Background(), s.client, s.apiURL, s.topic+":publish", map[string]interface{}{"messages": s.pending}, &published); err != nil {
+		return err
+	}
+	if len(published.MessageIDs) != len(s.pending) {
+		return errors.New("Pub/Sub did not publish every message of the batch")
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// Close publishes any queued messages
+func (s *pubSubSink) Close() error {
+	return s.Flush()
+}
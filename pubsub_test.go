@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParsePubSubURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		kind string
+		want pubSubParams
+	}{
+		{"pubsub://proj/orders-sub", "subscriptions", pubSubParams{resource: "projects/proj/subscriptions/orders-sub", maxMessages: 100, batch: 1}},
+		{"pubsub://proj/sub?max_messages=10", "subscriptions", pubSubParams{resource: "projects/proj/subscriptions/sub", maxMessages: 10, batch: 1}},
+		{
+			"pubsub://proj/commands?ordering_key=device-{id}&batch=50", "topics",
+			pubSubParams{resource: "projects/proj/topics/commands", maxMessages: 100, batch: 50, orderingKey: "device-{id}"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parsePubSubURI(tt.uri, tt.kind)
+		if err != nil {
+			t.Errorf("parsePubSubURI(%q) error: %v", tt.uri, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePubSubURI(%q) = %+v, want %+v", tt.uri, got, tt.want)
+		}
+	}
+
+	for _, uri := range []string{
+		"pubsub://proj",
+		"pubsub://proj/a/b",
+		"pubsub:///topic",
+		"pubsub://proj/topic?batch=0",
+		"pubsub://proj/topic?batch=1001",
+		"pubsub://proj/sub?max_messages=x",
+	} {
+		if _, err := parsePubSubURI(uri, "topics"); err == nil {
+			t.Errorf("parsePubSubURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestPubSubSink(t *testing.T) {
+	var requests [][]pubSubMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/proj/topics/commands:publish" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Messages []pubSubMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, body.Messages)
+		ids := make([]string, len(body.Messages))
+		for i := range ids {
+			ids[i] = strconv.Itoa(i)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"messageIds": ids})
+	}))
+	defer server.Close()
+	t.Setenv("PUBSUB_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	s, err := openPubSubSink("pubsub://proj/commands?ordering_key={device}&batch=2", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, device := range []string{"a", "b", "a"} {
+		if err := s.Write(Output{map[string]interface{}{"device": device}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Records without the ordering key field are skipped
+	if err := s.Write(Output{map[string]interface{}{"other": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, batch := range requests {
+		var keys []string
+		for _, m := range batch {
+			data, _ := base64.StdEncoding.DecodeString(m.Data)
+			var output Output
+			if err := json.Unmarshal(data, &output); err != nil || output[0]["device"] != m.OrderingKey {
+				t.Errorf("message %q has ordering key %q", data, m.OrderingKey)
+			}
+			keys = append(keys, m.OrderingKey)
+		}
+		got = append(got, keys)
+	}
+	if want := [][]string{{"a", "b"}, {"a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published batches %v, want %v", got, want)
+	}
+}
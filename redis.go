@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSink writes each record to a Redis key derived from its fields, either as a JSON
+// string or as a hash of its fields
+type redisSink struct {
+	client     *redis.Client
+	key        string
+	ttl        time.Duration
+	hash       bool
+	skipCached bool
+	skipped    int
+}
+
+// isRedisURI reports whether an output URI addresses a Redis server
+func isRedisURI(uri string) bool {
+	return strings.HasPrefix(uri, "redis://") || strings.HasPrefix(uri, "rediss://")
+}
+
+// parseRedisSinkURI splits a URI like "redis://host:6379/0?key=user:{id}&ttl=1h&encoding=hash
+// &skip_cached=true" into client options and sink settings
+func parseRedisSinkURI(uri string) (*redis.Options, *redisSink, error) {
+	base, rawQuery, _ := strings.Cut(uri, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid redis URI %q: %v", uri, err)
+	}
+	opts, err := redis.ParseURL(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid redis URI %q: %v", uri, err)
+	}
+
+	s := &redisSink{key: query.Get("key"), skipCached: query.Get("skip_cached") == "true"}
+	if s.key == "" {
+		return nil, nil, fmt.Errorf("redis URI %q has no key template", uri)
+	}
+	if ttl := query.Get("ttl"); ttl != "" {
+		if s.ttl, err = time.ParseDuration(ttl); err != nil || s.ttl <= 0 {
+			return nil, nil, fmt.Errorf("invalid redis TTL %q", ttl)
+		}
+	}
+	switch query.Get("encoding") {
+	case "", "json":
+	case "hash":
+		s.hash = true
+	default:
+		return nil, nil, fmt.Errorf("unsupported redis encoding %q", query.Get("encoding"))
+	}
+	return opts, s, nil
+}
+
+// openRedisSink connects to the Redis server of the URI
+func openRedisSink(uri string) (*redisSink, error) {
+	opts, s, err := parseRedisSinkURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	s.client = redis.NewClient(opts)
+	if err := s.client.Ping(context.Background()).Err(); err != nil {
+		s.client.Close()
+		return nil, fmt.Errorf("connecting to redis: %v", err)
+	}
+	return s, nil
+}
+
+// Write stores the merged output under its key. In cache-aside mode, records whose key
+// already exists are skipped and the cached value is left as it is.
+func (s *redisSink) Write(output Output) error {
+	record := mergeOutput(output)
+	key, err := expandTemplate(s.key, record)
+	if err != nil {
+		warn("skipped-record", "Skipping record: %v", err)
+		return nil
+	}
+
+	ctx := context.Background()
+	if !s.hash {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if !s.skipCached {
+			return s.client.Set(ctx, key, data, s.ttl).Err()
+		}
+		stored, err := s.client.SetNX(ctx, key, data, s.ttl).Result()
+		if err == nil && !stored {
+			s.skipped++
+		}
+		return err
+	}
+
+	if s.skipCached {
+		n, err := s.client.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			s.skipped++
+			return nil
+		}
+	}
+	fields := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		if str, ok := v.(string); ok {
+			fields[k] = str
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fields[k] = string(data)
+	}
+	// Replace the hash as a whole so that fields missing from the record do not linger
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, fields)
+	if s.ttl > 0 {
+		pipe.Expire(ctx, key, s.ttl)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Close reports the records skipped in cache-aside mode and disconnects
+func (s *redisSink) Close() error {
+	if s.skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d records already cached in redis\n", s.skipped)
+	}
+	return s.client.Close()
+}
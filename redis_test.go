@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestParseRedisSinkURI(t *testing.T) {
+	opts, s, err := parseRedisSinkURI("redis://:secret@cache:6380/2?key=user:{id}&ttl=90s&encoding=hash&skip_cached=true")
+	if err != nil {
+		t.Fatalf("parseRedisSinkURI error: %v", err)
+	}
+	if opts.Addr != "cache:6380" || opts.DB != 2 || opts.Password != "secret" {
+		t.Errorf("parseRedisSinkURI options = %s db %d password %q", opts.Addr, opts.DB, opts.Password)
+	}
+	if s.key != "user:{id}" || s.ttl != 90*time.Second || !s.hash || !s.skipCached {
+		t.Errorf("parseRedisSinkURI sink = %+v", s)
+	}
+
+	for _, uri := range []string{
+		"redis://cache",
+		"redis://cache?key=k&ttl=soon",
+		"redis://cache?key=k&encoding=xml",
+		"http://cache?key=k",
+	} {
+		if _, _, err := parseRedisSinkURI(uri); err == nil {
+			t.Errorf("parseRedisSinkURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	record := map[string]interface{}{"id": "42", "ts": int64(1609459200), "tags": []interface{}{"a"}}
+	tests := map[string]string{
+		"user:{id}":        "user:42",
+		"{id}/{ts}":        "42/1609459200",
+		"static":           "static",
+		"t:{tags}":         `t:["a"]`,
+		"devices/{id}/set": "devices/42/set",
+	}
+	for tmpl, want := range tests {
+		got, err := expandTemplate(tmpl, record)
+		if err != nil || got != want {
+			t.Errorf("expandTemplate(%q) = %q, %v, want %q", tmpl, got, err, want)
+		}
+	}
+	if _, err := expandTemplate("user:{name}", record); err == nil {
+		t.Errorf("expandTemplate with a missing field succeeded, want error")
+	}
+}
+
+func TestRedisSink(t *testing.T) {
+	server := miniredis.RunT(t)
+	record := Output{{"id": "1"}, {"name": "ada"}, {"seen": int64(1609459200)}}
+
+	s, err := openRedisSink("redis://" + server.Addr() + "?key=user:{id}&ttl=1h")
+	if err != nil {
+		t.Fatalf("openRedisSink error: %v", err)
+	}
+	if err := s.Write(record); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	s.Close()
+	if got, _ := server.Get("user:1"); got != `{"id":"1","name":"ada","seen":1609459200}` {
+		t.Errorf("stored JSON = %q", got)
+	}
+	if ttl := server.TTL("user:1"); ttl != time.Hour {
+		t.Errorf("TTL = %v, want 1h", ttl)
+	}
+
+	// Cache-aside mode leaves existing keys alone
+	s, err = openRedisSink("redis://" + server.Addr() + "?key=user:{id}&skip_cached=true")
+	if err != nil {
+		t.Fatalf("openRedisSink error: %v", err)
+	}
+	if err := s.Write(Output{{"id": "1"}, {"name": "grace"}}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if s.skipped != 1 {
+		t.Errorf("skipped = %d, want 1", s.skipped)
+	}
+	s.Close()
+	if got, _ := server.Get("user:1"); got != `{"id":"1","name":"ada","seen":1609459200}` {
+		t.Errorf("cached JSON was overwritten with %q", got)
+	}
+
+	s, err = openRedisSink("redis://" + server.Addr() + "?key=h:{id}&encoding=hash")
+	if err != nil {
+		t.Fatalf("openRedisSink error: %v", err)
+	}
+	if err := s.Write(record); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	s.Close()
+	if name, seen := server.HGet("h:1", "name"), server.HGet("h:1", "seen"); name != "ada" || seen != "1609459200" {
+		t.Errorf("stored hash fields name %q seen %q", name, seen)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rotatedTimeLayout names rotated files after the time they were rotated, so that they sort
+// oldest first
+const rotatedTimeLayout = "20060102T150405Z"
+
+// byteSizeUnits are the suffixes accepted by parseByteSize, longest first
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseByteSize parses a size like "100MB", "512K" or "4096"
+func parseByteSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, unit = strings.TrimSuffix(upper, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(upper), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
+
+// rotatingFileSink appends the output to a file, rotating it once it reaches a size or age.
+// Rotated files are renamed with the rotation time appended, optionally gzip-compressed, and
+// pruned to the newest keep files.
+type rotatingFileSink struct {
+	path     string
+	format   string
+	maxSize  int64
+	maxAge   time.Duration
+	compress bool
+	keep     int
+
+	file   *os.File
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+}
+
+// isRotatingFileURI reports whether an output URI addresses a rotating file
+func isRotatingFileURI(uri string) bool {
+	return strings.HasPrefix(uri, "file:")
+}
+
+// parseRotatingFileURI parses a URI like
+// "file:/var/log/transform.ndjson?max_size=100MB&max_age=24h&compress=true&keep=7"
+func parseRotatingFileURI(uri, format string) (*rotatingFileSink, error) {
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, "file:"), "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file URI %q: %v", uri, err)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("file URI %q has no path", uri)
+	}
+
+	s := &rotatingFileSink{path: path, format: format, compress: query.Get("compress") == "true"}
+	if v := query.Get("max_size"); v != "" {
+		if s.maxSize, err = parseByteSize(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := query.Get("max_age"); v != "" {
+		if s.maxAge, err = time.ParseDuration(v); err != nil || s.maxAge <= 0 {
+			return nil, fmt.Errorf("invalid file max_age %q", v)
+		}
+	}
+	if v := query.Get("keep"); v != "" {
+		if s.keep, err = strconv.Atoi(v); err != nil || s.keep < 1 {
+			return nil, fmt.Errorf("invalid file keep %q", v)
+		}
+	}
+	return s, nil
+}
+
+// openRotatingFileSink opens the file of a file URI for appending
+func openRotatingFileSink(uri, format string) (*rotatingFileSink, error) {
+	s, err := parseRotatingFileURI(uri, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the current file, continuing one left by an earlier run
+func (s *rotatingFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.w, s.size, s.opened = file, bufio.NewWriter(file), info.Size(), time.Now()
+	return nil
+}
+
+// Write appends the encoded output, first rotating the file if the output would take it
+// past its maximum size or the file has reached its maximum age
+func (s *rotatingFileSink) Write(output Output) error {
+	data, err := encodeOutput(output, s.format)
+	if err != nil {
+		return err
+	}
+	if s.size > 0 && (s.maxSize > 0 && s.size+int64(len(data)) > s.maxSize || s.maxAge > 0 && time.Since(s.opened) >= s.maxAge) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.w.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+// Flush writes the buffered output to the file
+func (s *rotatingFileSink) Flush() error {
+	return s.w.Flush()
+}
+
+// rotate renames the current file aside, compresses and prunes rotated files and opens a
+// new current file
+func (s *rotatingFileSink) rotate() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	stamp := time.Now().UTC().Format(rotatedTimeLayout)
+	name := s.path + "." + stamp
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			if _, err := os.Stat(name + ".gz"); os.IsNotExist(err) {
+				break
+			}
+		}
+		// Several rotations within a second get a sequence number
+		name = fmt.Sprintf("%s.%s-%d", s.path, stamp, i)
+	}
+	if err := os.Rename(s.path, name); err != nil {
+		return err
+	}
+	if s.compress {
+		if err := compressFile(name); err != nil {
+			return err
+		}
+	}
+	if s.keep > 0 {
+		if err := s.prune(); err != nil {
+			return err
+		}
+	}
+	return s.open()
+}
+
+// compressFile replaces a file with a gzip-compressed copy named with a ".gz" suffix
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	dst, err := os.Create(name + ".gz")
+	if err != nil {
+		src.Close()
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	// Close the source before removing it, which Windows refuses for open files
+	src.Close()
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// prune removes all but the newest keep rotated files
+func (s *rotatingFileSink) prune() error {
+	matches, err := filepath.Glob(s.path + ".[0-9]*")
+	if err != nil {
+		return err
+	}
+	type rotatedFile struct {
+		name  string
+		order string
+	}
+	var rotated []rotatedFile
+	for _, match := range matches {
+		stamp, seq, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(match, s.path+"."), ".gz"), "-")
+		if _, err := time.Parse(rotatedTimeLayout, stamp); err != nil {
+			continue
+		}
+		n, _ := strconv.Atoi(seq)
+		rotated = append(rotated, rotatedFile{match, fmt.Sprintf("%s-%06d", stamp, n)})
+	}
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].order < rotated[j].order })
+	for len(rotated) > s.keep {
+		if err := os.Remove(rotated[0].name); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Close flushes and closes the current file
+func (s *rotatingFileSink) Close() error {
+	if err := s.w.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
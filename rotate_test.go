@@ -0,0 +1,93 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{"4096": 4096, "512K": 512 << 10, "100MB": 100 << 20, "1gb": 1 << 30, "10B": 10}
+	for s, want := range tests {
+		if got, err := parseByteSize(s); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1K", "1.5MB", "10TB"} {
+		if _, err := parseByteSize(s); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestParseRotatingFileURI(t *testing.T) {
+	s, err := parseRotatingFileURI("file:out.ndjson?max_size=1MB&max_age=24h&compress=true&keep=7", "ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.path != "out.ndjson" || s.maxSize != 1<<20 || s.maxAge.Hours() != 24 || !s.compress || s.keep != 7 {
+		t.Errorf("parseRotatingFileURI = %+v", s)
+	}
+	for _, uri := range []string{"file:", "file:x?keep=0", "file:x?max_age=soon", "file:x?max_size=big"} {
+		if _, err := parseRotatingFileURI(uri, "json"); err == nil {
+			t.Errorf("parseRotatingFileURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.ndjson")
+	// Each record encodes to 12 bytes, so two fit in a file
+	s, err := openRotatingFileSink("file:"+path+"?max_size=24&compress=true&keep=2", "ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := s.Write(Output{{"v": v}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"v":"g"}]` + "\n"; string(current) != want {
+		t.Errorf("current file = %q, want %q", current, want)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Fatalf("rotated files = %q, want the newest 2", rotated)
+	}
+	var contents []string
+	for _, name := range rotated {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("rotated file %q is not compressed", name)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(zr)
+		f.Close()
+		contents = append(contents, string(data))
+	}
+	sort.Strings(contents)
+	if got, want := strings.Join(contents, ""), `[{"v":"c"}]`+"\n"+`[{"v":"d"}]`+"\n"+`[{"v":"e"}]`+"\n"+`[{"v":"f"}]`+"\n"; got != want {
+		t.Errorf("rotated contents = %q, want %q", got, want)
+	}
+}
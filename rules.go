@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// runRulesCommand checks rules files: "rules lint" validates them and reports rules that
+// have no effect or contradict others, and "rules test" checks their examples. It exits 1
+// if any file has findings or failing examples. "rules lock" pins the modules they include,
+// and "rules generate --from-flags" prints the task spec of a command line's flags.
+func runRulesCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: %s rules lint rules.json...\n       %s rules test [flags] rules.json...\n       %s rules lock rules.json...\n       %s rules generate --from-flags [transform flags]\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+	}
+	if len(args) == 0 || (args[0] != "lint" && args[0] != "test" && args[0] != "lock" && args[0] != "generate") {
+		usage()
+		os.Exit(2)
+	}
+	if args[0] == "generate" {
+		if len(args) < 2 || (args[1] != "-from-flags" && args[1] != "--from-flags") {
+			usage()
+			os.Exit(2)
+		}
+		spec, err := taskSpecFromFlags(args[2:])
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		data, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		fmt.Printf("%s\n", data)
+		return
+	}
+	fs := flag.NewFlagSet("rules "+args[0], flag.ExitOnError)
+	var coerce, locale string
+	if args[0] == "test" {
+		fs.StringVar(&coerce, "coerce", "", "comma-separated coercions tried in order on every string value of the examples")
+		fs.StringVar(&locale, "locale", "", "BCP 47 locale whose dates and numbers the coercions of the examples also parse")
+	}
+	fs.Usage = func() {
+		usage()
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var tr transform.Transformer
+	var err error
+	if coerce != "" {
+		if tr.Coercions, err = transform.ParseCoercionOrder(coerce); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+	}
+	if locale != "" {
+		if tr.Locale, err = transform.ParseLocale(locale); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		var ok bool
+		switch args[0] {
+		case "lint":
+			ok = lintRules(path, os.Stdout)
+		case "test":
+			ok = testRules(tr, path, os.Stdout)
+		case "lock":
+			ok = lockRules(path, os.Stdout)
+		}
+		failed = failed || !ok
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// lintRules loads a rules file and prints its findings to w, reporting whether it has none
+func lintRules(path string, w io.Writer) bool {
+	rs, err := transform.LoadRules(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	findings := rs.Lint()
+	for _, finding := range findings {
+		fmt.Fprintf(w, "%s: %s\n", path, finding)
+	}
+	return len(findings) == 0
+}
+
+// testRules checks the examples of a rules file with a transformer using its rules,
+// printing each failure and a summary to w, and reports whether every example passed
+func testRules(tr transform.Transformer, path string, w io.Writer) bool {
+	rs, err := transform.LoadRules(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	if len(rs.Examples) == 0 {
+		fmt.Fprintf(w, "%s: no examples\n", path)
+		return true
+	}
+	tr.Rules = rs
+	failures := 0
+	for i, e := range rs.Examples {
+		if err := tr.CheckExample(e); err != nil {
+			name := fmt.Sprintf("example %d", i+1)
+			if e.Name != "" {
+				name += fmt.Sprintf(" (%s)", e.Name)
+			}
+			fmt.Fprintf(w, "%s: FAIL %s: %v\n", path, name, err)
+			failures++
+		}
+	}
+	if failures > 0 {
+		fmt.Fprintf(w, "%s: %d of %d examples failed\n", path, failures, len(rs.Examples))
+		return false
+	}
+	fmt.Fprintf(w, "%s: ok, %d examples\n", path, len(rs.Examples))
+	return true
+}
+
+// lockRules writes the lock file of a rules file, printing the modules pinned to w, and
+// reports whether it succeeded
+func lockRules(path string, w io.Writer) bool {
+	lock, err := transform.LockRules(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	for _, m := range lock.Modules {
+		if m.Commit != "" {
+			fmt.Fprintf(w, "%s: locked %s at %s\n", path, m.Source, m.Commit)
+		} else {
+			fmt.Fprintf(w, "%s: locked %s\n", path, m.Source)
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestRulesCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	clean := write("clean.json", `{
+		"rules": [{"field": "name", "action": "titlecase"}],
+		"examples": [{"input": {"name": "ada lovelace"}, "output": [[{"name": "Ada Lovelace"}]]}]
+	}`)
+	failing := write("failing.json", `{
+		"rules": [{"field": "name", "action": "uppercase"}, {"field": "name", "action": "uppercase"}],
+		"examples": [{"name": "shouting", "input": {"name": "ada"}, "output": [[{"name": "ada"}]]}]
+	}`)
+	invalid := write("invalid.json", `{"rules": [{"field": "name", "action": "shout"}]}`)
+
+	tests := []struct {
+		check func(path string, out *bytes.Buffer) bool
+		path  string
+		ok    bool
+		want  string
+	}{
+		{func(path string, out *bytes.Buffer) bool { return lintRules(path, out) }, clean, true, ""},
+		{func(path string, out *bytes.Buffer) bool { return lintRules(path, out) }, failing, false, "rule 1 (uppercase name): duplicated by rule 2"},
+		{func(path string, out *bytes.Buffer) bool { return lintRules(path, out) }, invalid, false, `unsupported action "shout"`},
+		{func(path string, out *bytes.Buffer) bool { return testRules(transform.Transformer{}, path, out) }, clean, true, "ok, 1 examples"},
+		{func(path string, out *bytes.Buffer) bool { return testRules(transform.Transformer{}, path, out) }, failing, false, `FAIL example 1 (shouting): got [[{"name":"ADA"}]]`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if ok := tt.check(tt.path, &out); ok != tt.ok || !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s: ok = %v, output %q, want %v and %q", filepath.Base(tt.path), ok, out.String(), tt.ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3API is the part of the S3 client used by the S3 sink
+type s3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// s3Sink writes the output to S3 in batches of up to batch records, one object per batch.
+// Each batch is uploaded under "_pending/", promoted to its final key once complete, and
+// then described by a manifest under "_manifests/", so that readers that go by manifests
+// see whole batches only.
+type s3Sink struct {
+	client s3API
+	bucket string
+	prefix string
+	batch  int
+	format string
+	buf    bytes.Buffer
+	digest *batchDigest
+}
+
+// isS3URI reports whether an output URI addresses an S3 location
+func isS3URI(uri string) bool {
+	return strings.HasPrefix(uri, "s3://")
+}
+
+// parseS3SinkURI parses a URI like "s3://bucket/prefix?batch=1000"; the default batch holds
+// all records of a source member or stream message
+func parseS3SinkURI(uri, format string) (*s3Sink, error) {
+	base, rawQuery, _ := strings.Cut(uri, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URI %q: %v", uri, err)
+	}
+	bucket, prefix, err := parseStagingURI(base, "s3")
+	if err != nil {
+		return nil, err
+	}
+	batch, err := parseBatchSize(query.Get("batch"))
+	if err != nil {
+		return nil, err
+	}
+	return &s3Sink{bucket: bucket, prefix: prefix, batch: batch, format: format, digest: newBatchDigest()}, nil
+}
+
+// openS3Sink opens a sink for an S3 URI, using the default AWS configuration
+func openS3Sink(uri, format string) (*s3Sink, error) {
+	s, err := parseS3SinkURI(uri, format)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %v", err)
+	}
+	s.client = s3.NewFromConfig(cfg)
+	return s, nil
+}
+
+// Write adds the encoded output to the current batch, committing the batch once it is full
+func (s *s3Sink) Write(output Output) error {
+	data, err := encodeOutput(output, s.format)
+	if err != nil {
+		return err
+	}
+	if err := s.digest.add(mergeOutput(output)); err != nil {
+		return err
+	}
+	s.buf.Write(data)
+	if s.batch > 0 && s.digest.records >= s.batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush commits the current batch: it uploads the batch under a pending key, copies it to
+// its final key, removes the pending object and writes the commit manifest
+func (s *s3Sink) Flush() error {
+	if s.digest.records == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	commitID := newCommitID()
+	name := commitID + "." + outputExtension(s.format)
+	pending := stagingObject(s.prefix, "_pending/"+name)
+	final := stagingObject(s.prefix, name)
+
+	if _, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(pending),
+		Body:   bytes.NewReader(s.buf.Bytes()),
+	}); err != nil {
+		return fmt.Errorf("uploading batch %s: %v", commitID, err)
+	}
+	if _, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(final),
+		CopySource: aws.String(url.PathEscape(s.bucket) + "/" + (&url.URL{Path: pending}).EscapedPath()),
+	}); err != nil {
+		return fmt.Errorf("promoting batch %s: %v", commitID, err)
+	}
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(pending),
+	}); err != nil {
+		return fmt.Errorf("removing pending batch %s: %v", commitID, err)
+	}
+
+	m := s.digest.manifest(commitID)
+	m.Objects = []string{"s3://" + s.bucket + "/" + final}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(stagingObject(s.prefix, "_manifests/"+commitID+".json")),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}); err != nil {
+		return fmt.Errorf("writing manifest of batch %s: %v", commitID, err)
+	}
+
+	s.buf.Reset()
+	s.digest = newBatchDigest()
+	return nil
+}
+
+// Close commits the last batch
+func (s *s3Sink) Close() error {
+	return s.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 keeps the objects of a single bucket in memory
+type fakeS3 struct {
+	objects map[string]string
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	f.objects[*in.Key] = string(data)
+	return &s3.PutObjectOutput{}, err
+}
+
+func (f *fakeS3) CopyObject(_ context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.objects[*in.Key] = f.objects[strings.TrimPrefix(*in.CopySource, *in.Bucket+"/")]
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(f.objects, *in.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestParseS3SinkURI(t *testing.T) {
+	s, err := parseS3SinkURI("s3://bucket/a/b/?batch=10", "ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.bucket != "bucket" || s.prefix != "a/b" || s.batch != 10 {
+		t.Errorf("parseS3SinkURI = %q, %q, %d", s.bucket, s.prefix, s.batch)
+	}
+	for _, uri := range []string{"s3://", "s3://bucket?batch=-1", "s3://bucket?batch=%zz"} {
+		if _, err := parseS3SinkURI(uri, "json"); err == nil {
+			t.Errorf("parseS3SinkURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestS3Sink(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string]string)}
+	s, err := parseS3SinkURI("s3://bucket/out?batch=2", "ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.client = fake
+	for _, id := range []string{"1", "2", "3"} {
+		if err := s.Write(Output{{"id": id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var data, manifests []string
+	for key := range fake.objects {
+		switch {
+		case strings.HasPrefix(key, "out/_pending/"):
+			t.Errorf("pending object %q was not removed", key)
+		case strings.HasPrefix(key, "out/_manifests/"):
+			manifests = append(manifests, key)
+		default:
+			data = append(data, key)
+		}
+	}
+	sort.Strings(data)
+	sort.Strings(manifests)
+	if len(data) != 2 || len(manifests) != 2 {
+		t.Fatalf("objects = %q, want 2 batches and 2 manifests", fake.objects)
+	}
+	if got, want := fake.objects[data[0]]+fake.objects[data[1]], "[{\"id\":\"1\"}]\n[{\"id\":\"2\"}]\n[{\"id\":\"3\"}]\n"; got != want {
+		t.Errorf("batch contents = %q, want %q", got, want)
+	}
+
+	var m commitManifest
+	if err := json.Unmarshal([]byte(fake.objects[manifests[0]]), &m); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"s3://bucket/" + data[0]}; m.Records != 2 || !reflect.DeepEqual(m.Objects, want) || len(m.SHA256) != 64 {
+		t.Errorf("manifest = %+v, want 2 records in %q", m, want)
+	}
+}
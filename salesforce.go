@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// salesforceDateTimePattern matches Salesforce datetimes like "2021-05-01T10:00:00.000+0000"
+var salesforceDateTimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?[+-]\d{4}$`)
+
+// salesforceDateTimeLayout is the time layout of Salesforce datetimes
+const salesforceDateTimeLayout = "2006-01-02T15:04:05.999999999-0700"
+
+// salesforcePreset normalizes SOQL query results: each of "records" becomes a record with
+// its "attributes" stripped, parent relationships flattened into dotted fields like
+// "Owner.Name", child relationship subqueries reduced to lists of records, and datetimes
+// converted to RFC3339 for the standard coercion.
+func salesforcePreset(record Input) []Input {
+	list, ok := record["records"].([]interface{})
+	if !ok {
+		return []Input{salesforceRecord(record)}
+	}
+
+	var records []Input
+	for _, r := range list {
+		if m, ok := r.(map[string]interface{}); ok {
+			records = append(records, salesforceRecord(m))
+		}
+	}
+	return records
+}
+
+// salesforceRecord cleans a single sObject record
+func salesforceRecord(m map[string]interface{}) Input {
+	out := make(Input)
+	salesforceFields(out, "", m)
+	return out
+}
+
+// salesforceFields copies the fields of an sObject into out, prefixing parent relationship
+// fields with their relationship path
+func salesforceFields(out Input, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if k == "attributes" {
+			continue
+		}
+		key := prefix + k
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if children, ok := v["records"].([]interface{}); ok {
+				// Child relationship subquery
+				list := make([]interface{}, 0, len(children))
+				for _, c := range children {
+					if child, ok := c.(map[string]interface{}); ok {
+						list = append(list, map[string]interface{}(salesforceRecord(child)))
+					}
+				}
+				out[key] = list
+			} else {
+				// Parent relationship
+				salesforceFields(out, key+".", v)
+			}
+		case []interface{}:
+			out[key] = v
+		case string:
+			out[key] = salesforceString(v)
+		case float64:
+			out[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			out[key] = strconv.FormatBool(v)
+		}
+	}
+}
+
+// salesforceString converts Salesforce datetimes to RFC3339 and returns other strings unchanged
+func salesforceString(s string) string {
+	if salesforceDateTimePattern.MatchString(s) {
+		if t, err := time.Parse(salesforceDateTimeLayout, s); err == nil {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return s
+}
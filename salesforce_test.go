@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSalesforcePreset(t *testing.T) {
+	var result Input
+	err := json.Unmarshal([]byte(`{"totalSize": 1, "done": true, "records": [
+		{"attributes": {"type": "Account", "url": "/services/data/v58.0/sobjects/Account/001"},
+		 "Id": "001", "Name": "Acme", "AnnualRevenue": 1.5e6, "IsDeleted": false, "Fax": null,
+		 "CreatedDate": "2021-05-01T10:00:00.000+0000",
+		 "LastActivityDate": "2021-05-02",
+		 "Owner": {"attributes": {"type": "User"}, "Name": "Ada", "Manager": {"attributes": {"type": "User"}, "Name": "Bob"}},
+		 "Contacts": {"totalSize": 1, "done": true, "records": [
+			{"attributes": {"type": "Contact"}, "LastName": "Lovelace", "Birthdate": "1815-12-10T08:30:00.5-0100"}
+		 ]}}
+	]}`), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{
+		"Id": "001", "Name": "Acme", "AnnualRevenue": "1500000", "IsDeleted": "false",
+		"CreatedDate":      "2021-05-01T10:00:00Z",
+		"LastActivityDate": "2021-05-02",
+		"Owner.Name":       "Ada", "Owner.Manager.Name": "Bob",
+		"Contacts": []interface{}{map[string]interface{}{"LastName": "Lovelace", "Birthdate": "1815-12-10T08:30:00.5-01:00"}},
+	}}
+	if got := salesforcePreset(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("salesforcePreset = %#v, want %#v", got, want)
+	}
+
+	// A single record outside a query result is cleaned as well
+	single := Input{"attributes": map[string]interface{}{"type": "Account"}, "Id": "001"}
+	if got, want := salesforcePreset(single), []Input{{"Id": "001"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("salesforcePreset(single) = %#v, want %#v", got, want)
+	}
+}
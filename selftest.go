@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// selfCheck is a check of the conformance suite run by the selftest subcommand
+type selfCheck struct {
+	name string
+	run  func() error
+}
+
+// runSelftestCommand runs "selftest", which runs the conformance suite built into the
+// binary and reports each check, exiting with status 1 when any fails, for validating a
+// deployment where the test suite and toolchain are not available
+func runSelftestCommand(args []string) {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	run := fs.String("run", "", "regular expression selecting the checks run by name, such as ^roundtrip/")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s selftest [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	pattern, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid -run pattern: %v\n", err)
+		os.Exit(2)
+	}
+
+	if failed := runSelfChecks(os.Stdout, selfChecks(), pattern); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runSelfChecks runs the checks whose names match a pattern, writing a line for each and a
+// summary, and returns the number that failed
+func runSelfChecks(w io.Writer, checks []selfCheck, pattern *regexp.Regexp) int {
+	passed, failed := 0, 0
+	for _, c := range checks {
+		if !pattern.MatchString(c.name) {
+			continue
+		}
+		if err := c.run(); err != nil {
+			fmt.Fprintf(w, "FAIL %s: %v\n", c.name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "ok   %s\n", c.name)
+		passed++
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "FAIL: %d passed, %d failed\n", passed, failed)
+	} else {
+		fmt.Fprintf(w, "PASS: %d passed\n", passed)
+	}
+	return failed
+}
+
+// selfChecks returns the checks of the conformance suite: round trips through each output
+// format, the coercion matrix, the corpus of each compatibility level and the enforcement
+// of limits
+func selfChecks() []selfCheck {
+	var checks []selfCheck
+	for _, format := range []string{"json", "ndjson", "ejson", "yaml", "toml", "msgpack", "cbor", "bson"} {
+		checks = append(checks, selfCheck{"roundtrip/" + format, func() error { return checkRoundTrip(format) }})
+	}
+	for _, c := range coercionMatrix {
+		for _, loc := range []struct{ name, input, want string }{
+			{"field", `{"v": %s}`, c.field},
+			{"map", `{"m": {"v": %s}}`, c.mapValue},
+			{"list", `{"l": [%s]}`, c.list},
+			{"number", `{"v": {"N": %s}}`, c.number},
+		} {
+			name := fmt.Sprintf("coerce/%s/%s/%q", c.order, loc.name, c.value)
+			checks = append(checks, selfCheck{name, func() error { return checkCoercion(c.order, c.value, loc.input, loc.want) }})
+		}
+	}
+	for _, level := range transform.CompatLevels() {
+		cases, err := transform.ConformanceCorpus(level)
+		if err != nil {
+			checks = append(checks, selfCheck{"conformance/" + level, func() error { return err }})
+			continue
+		}
+		for _, c := range cases {
+			checks = append(checks, selfCheck{"conformance/" + level + "/" + c.Name, func() error { return c.Check(level) }})
+		}
+	}
+	return append(checks,
+		selfCheck{"limits/max-body", checkMaxBody},
+		selfCheck{"limits/memory-budget", checkMemoryBudget},
+		selfCheck{"limits/admission", checkAdmission},
+		selfCheck{"limits/bson-document-size", checkBSONDocumentSize},
+	)
+}
+
+// quietTransformer returns a transformer that keeps its warnings off stderr
+func quietTransformer() *transform.Transformer {
+	return &transform.Transformer{Warnings: &transform.WarningRules{Suppress: []string{"*"}}}
+}
+
+// selfTestRecord is the record whose output the round trip checks encode, holding a
+// value of every type the transformer emits
+const selfTestRecord = `{"name": {"S": " Ada "}, "age": {"N": "36"}, "score": {"N": "1.5"}, "ok": {"BOOL": true},
+	"at": {"S": "2021-01-01T00:00:00Z"}, "tags": {"SS": ["a", "b"]}, "address": {"M": {"city": {"S": "Paris"}}}}`
+
+// checkRoundTrip encodes the output of the test record in a format and decodes it again,
+// checking that every value survives. Formats without numbers or dates of their own give
+// them back as strings, so both sides are coerced alike before they are compared.
+func checkRoundTrip(format string) error {
+	var record Input
+	if err := json.Unmarshal([]byte(selfTestRecord), &record); err != nil {
+		return err
+	}
+	tr := quietTransformer()
+	tr.TypedTimestamps = format == "ejson"
+	output, err := tr.Transform(record)
+	if err != nil {
+		return err
+	}
+	data, err := encodeOutput(output, format)
+	if err != nil {
+		return err
+	}
+	decoded, err := decodeSelfTestOutput(format, data)
+	if err != nil {
+		return fmt.Errorf("decoding: %v", err)
+	}
+
+	normalize := func(m map[string]interface{}) (string, error) {
+		tr := quietTransformer()
+		tr.KeepScalars = true
+		tr.Coercions = []string{"timestamp", "number", "boolean", "string"}
+		output, err := tr.Transform(Input(m))
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(mergeOutput(output))
+		return string(data), err
+	}
+	want, err := normalize(mergeOutput(output))
+	if err != nil {
+		return err
+	}
+	got, err := normalize(decoded)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("decoded %s, want %s", got, want)
+	}
+	return nil
+}
+
+// decodeSelfTestOutput decodes an output encoded in a format into the merged map of its
+// records
+func decodeSelfTestOutput(format string, data []byte) (map[string]interface{}, error) {
+	var records []map[string]interface{}
+	var err error
+	switch format {
+	case "json", "ndjson":
+		err = json.Unmarshal(data, &records)
+	case "ejson":
+		if err = json.Unmarshal(data, &records); err == nil {
+			for i, m := range records {
+				records[i] = unwrapExtendedJSONDocument(m)
+			}
+		}
+	case "yaml":
+		err = yaml.Unmarshal(data, &records)
+	case "toml":
+		var doc struct {
+			Records []map[string]interface{} `toml:"records"`
+		}
+		_, err = toml.Decode(string(data), &doc)
+		records = doc.Records
+	case "msgpack":
+		// Integers decode into their smallest Go types, which JSON turns back into numbers
+		var items []map[string]interface{}
+		if err = msgpack.Unmarshal(data, &items); err == nil {
+			if data, err = json.Marshal(items); err == nil {
+				err = json.Unmarshal(data, &records)
+			}
+		}
+	case "cbor":
+		var item interface{}
+		if err = cborDecMode.Unmarshal(data, &item); err == nil {
+			list, _ := cborToJSON(item, false).([]interface{})
+			for _, m := range list {
+				if m, ok := m.(map[string]interface{}); ok {
+					records = append(records, m)
+				}
+			}
+		}
+	case "bson":
+		var inputs []Input
+		inputs, err = readBSON(bytes.NewReader(data))
+		for _, m := range inputs {
+			records = append(records, m)
+		}
+	default:
+		return nil, fmt.Errorf("no decoder for format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var output Output
+	for _, m := range records {
+		output = append(output, m)
+	}
+	return mergeOutput(output), nil
+}
+
+// coercionMatrix gives the JSON each string becomes under a coercion order, at the top
+// level, in a nested map, in a list and as the value of an N descriptor; an empty result
+// means the value is skipped. The legacy order is the one the transformer applies by
+// default, which differs by location.
+var coercionMatrix = []struct {
+	order, value                  string
+	field, mapValue, list, number string
+}{
+	{"legacy", "2021-01-01T00:00:00Z", `1609459200`, `"2021-01-01T00:00:00Z"`, `[1609459200]`, ``},
+	{"legacy", "42", `"42"`, `"42"`, `[42]`, `42`},
+	{"legacy", "4.5", `"4.5"`, `"4.5"`, `["4.5"]`, `4.5`},
+	{"legacy", "007", `"007"`, `"007"`, `[7]`, `7`},
+	{"legacy", "true", `"true"`, `"true"`, `["true"]`, ``},
+	{"legacy", " padded ", `"padded"`, `"padded"`, `["padded"]`, ``},
+	{"timestamp,number,boolean,string", "2021-01-01T00:00:00Z", `1609459200`, `1609459200`, `[1609459200]`, ``},
+	{"timestamp,number,boolean,string", "42", `42`, `42`, `[42]`, `42`},
+	{"timestamp,number,boolean,string", "4.5", `4.5`, `4.5`, `[4.5]`, `4.5`},
+	{"timestamp,number,boolean,string", "007", `"007"`, `"007"`, `["007"]`, `7`},
+	{"timestamp,number,boolean,string", "true", `true`, `true`, `[true]`, ``},
+	{"timestamp,number,boolean,string", " padded ", `"padded"`, `"padded"`, `["padded"]`, ``},
+	{"string", "2021-01-01T00:00:00Z", `"2021-01-01T00:00:00Z"`, `"2021-01-01T00:00:00Z"`, `["2021-01-01T00:00:00Z"]`, ``},
+	{"string", "42", `"42"`, `"42"`, `["42"]`, `42`},
+}
+
+// checkCoercion transforms a value placed into an input template under a coercion order,
+// checking the result against the JSON wanted for it
+func checkCoercion(order, value, input, want string) error {
+	tr := quietTransformer()
+	if order != "legacy" {
+		coercions, err := transform.ParseCoercionOrder(order)
+		if err != nil {
+			return err
+		}
+		tr.Coercions = coercions
+	}
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var record Input
+	if err := json.Unmarshal([]byte(fmt.Sprintf(input, quoted)), &record); err != nil {
+		return err
+	}
+	output, err := tr.Transform(record)
+	if err != nil {
+		return err
+	}
+	got, err := json.Marshal(mergeOutput(output))
+	if err != nil {
+		return err
+	}
+
+	// The value lands under its own key, or under the key of the map it is flattened from
+	key := "v"
+	if strings.HasPrefix(input, `{"l"`) {
+		key = "l"
+	}
+	expected := "{}"
+	if want != "" {
+		expected = fmt.Sprintf(`{%q:%s}`, key, want)
+	}
+	if string(got) != expected {
+		return fmt.Errorf("got %s, want %s", got, expected)
+	}
+	return nil
+}
+
+// postSelfTest posts a body to a transform handler, returning the status of its response
+func postSelfTest(h http.Handler, body string) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/transform", strings.NewReader(body)))
+	return w.Code
+}
+
+// checkMaxBody checks that the server rejects bodies over -max-body with 413
+func checkMaxBody() error {
+	h := newTransformHandler(quietTransformer(), "", 32, nil, nil)
+	if status := postSelfTest(h, `{"a": {"S": "1"}}`); status != http.StatusOK {
+		return fmt.Errorf("body within the limit = %d, want 200", status)
+	}
+	if status := postSelfTest(h, `{"a": {"S": "`+strings.Repeat("x", 64)+`"}}`); status != http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("body over the limit = %d, want 413", status)
+	}
+	return nil
+}
+
+// checkMemoryBudget checks that the server rejects requests whose memory exceeds the whole
+// -memory-budget with 413
+func checkMemoryBudget() error {
+	body := `{"a": {"S": "1"}}`
+	var record Input
+	if err := json.Unmarshal([]byte(body), &record); err != nil {
+		return err
+	}
+	h := newTransformHandler(quietTransformer(), "", 1<<20, nil, newMemoryBudget(requestMemory(len(body), record)-1, 0))
+	if status := postSelfTest(h, body); status != http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("request over the budget = %d, want 413", status)
+	}
+	return nil
+}
+
+// checkAdmission checks that the server turns requests away with 429 while its slots and
+// queue are full
+func checkAdmission() error {
+	a := newAdmission(1, 0)
+	free, err := a.admit(context.Background())
+	if err != nil {
+		return err
+	}
+	h := newTransformHandler(quietTransformer(), "", 1<<20, a, nil)
+	status := postSelfTest(h, `{"a": {"S": "1"}}`)
+	free()
+	if status != http.StatusTooManyRequests {
+		return fmt.Errorf("request to a saturated server = %d, want 429", status)
+	}
+	if status := postSelfTest(h, `{"a": {"S": "1"}}`); status != http.StatusOK {
+		return fmt.Errorf("request once a slot is free = %d, want 200", status)
+	}
+	return nil
+}
+
+// checkBSONDocumentSize checks that BSON documents over the size limit are rejected before
+// they are read
+func checkBSONDocumentSize() error {
+	header := binary.LittleEndian.AppendUint32(nil, uint32(maxBSONDocumentSize+1))
+	if _, err := readBSON(bytes.NewReader(header)); err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("oversized document read with error %v, want a size error", err)
+	}
+	return nil
+}
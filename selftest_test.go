@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSelfChecks(t *testing.T) {
+	var out strings.Builder
+	if failed := runSelfChecks(&out, selfChecks(), regexp.MustCompile("")); failed != 0 {
+		t.Errorf("%d checks failed:\n%s", failed, out.String())
+	}
+}
+
+func TestRunSelfChecks(t *testing.T) {
+	checks := []selfCheck{
+		{"a/pass", func() error { return nil }},
+		{"a/fail", func() error { return errors.New("broken") }},
+		{"b/pass", func() error { return nil }},
+	}
+	var out strings.Builder
+	if failed := runSelfChecks(&out, checks, regexp.MustCompile("^a/")); failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	want := "ok   a/pass\nFAIL a/fail: broken\nFAIL: 1 passed, 1 failed\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// senmlRelativeTimeLimit is the threshold below which SenML times are relative to now
+const senmlRelativeTimeLimit = 1 << 28
+
+// readSenML reads a SenML JSON pack and expands it into one resolved record per entry. Base
+// name, time, unit, value and sum are applied to each entry, times become RFC3339 so the
+// standard coercion turns them into epoch, and numeric values become strings.
+func readSenML(r io.Reader) ([]Input, error) {
+	var pack []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&pack); err != nil {
+		return nil, err
+	}
+
+	var records []Input
+	var baseName, baseUnit string
+	var baseTime, baseValue, baseSum float64
+	now := float64(time.Now().UnixNano()) / 1e9
+	for i, entry := range pack {
+		// Base fields apply to this and all following entries until overridden
+		if bn, ok := entry["bn"].(string); ok {
+			baseName = bn
+		}
+		if bu, ok := entry["bu"].(string); ok {
+			baseUnit = bu
+		}
+		if bt, ok := entry["bt"].(float64); ok {
+			baseTime = bt
+		}
+		if bv, ok := entry["bv"].(float64); ok {
+			baseValue = bv
+		}
+		if bs, ok := entry["bs"].(float64); ok {
+			baseSum = bs
+		}
+
+		name, _ := entry["n"].(string)
+		name = baseName + name
+		if name == "" {
+			warn("invalid-value", "Skipping SenML entry %d without a name", i)
+			continue
+		}
+		record := Input{"n": name}
+
+		t, _ := entry["t"].(float64)
+		t += baseTime
+		if t < senmlRelativeTimeLimit {
+			t += now
+		}
+		record["t"] = senmlTime(t)
+
+		if u, ok := entry["u"].(string); ok {
+			record["u"] = u
+		} else if baseUnit != "" {
+			record["u"] = baseUnit
+		}
+
+		// An entry has at most one value, which is resolved against the base value if numeric
+		switch {
+		case entry["v"] != nil:
+			v, _ := entry["v"].(float64)
+			record["v"] = senmlNumber(baseValue + v)
+		case entry["vs"] != nil:
+			record["vs"], _ = entry["vs"].(string)
+		case entry["vb"] != nil:
+			vb, _ := entry["vb"].(bool)
+			record["vb"] = strconv.FormatBool(vb)
+		case entry["vd"] != nil:
+			record["vd"], _ = entry["vd"].(string)
+		}
+		if s, ok := entry["s"].(float64); ok {
+			record["s"] = senmlNumber(baseSum + s)
+		}
+		if ut, ok := entry["ut"].(float64); ok {
+			record["ut"] = senmlNumber(ut)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// senmlTime formats SenML seconds since the epoch as RFC3339
+func senmlTime(t float64) string {
+	sec, frac := math.Modf(t)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano)
+}
+
+// senmlNumber formats a SenML number without exponent
+func senmlNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSenML(t *testing.T) {
+	pack := `[
+		{"bn": "urn:dev:ow:10e2073a01080063:", "bt": 1.320067464e+09, "bu": "%RH", "v": 20},
+		{"u": "lon", "v": 24.30621},
+		{"n": "temp", "u": "Cel", "t": 1.5, "bv": 10, "v": 1.5, "s": 3, "ut": 60},
+		{"n": "door", "vb": true},
+		{"n": "label", "vs": "kitchen"},
+		{"n": "blob", "vd": "aGk"}
+	]`
+	records, err := readSenML(strings.NewReader(pack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "urn:dev:ow:10e2073a01080063:"
+	want := []Input{
+		{"n": name, "t": "2011-10-31T13:24:24Z", "u": "%RH", "v": "20"},
+		{"n": name, "t": "2011-10-31T13:24:24Z", "u": "lon", "v": "24.30621"},
+		{"n": name + "temp", "t": "2011-10-31T13:24:25.5Z", "u": "Cel", "v": "11.5", "s": "3", "ut": "60"},
+		{"n": name + "door", "t": "2011-10-31T13:24:24Z", "u": "%RH", "vb": "true"},
+		{"n": name + "label", "t": "2011-10-31T13:24:24Z", "u": "%RH", "vs": "kitchen"},
+		{"n": name + "blob", "t": "2011-10-31T13:24:24Z", "u": "%RH", "vd": "aGk"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readSenML = %#v, want %#v", records, want)
+	}
+
+	// Times below 2**28 are relative to now
+	records, err = readSenML(strings.NewReader(`[{"n": "x", "t": -60, "v": 1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, records[0]["t"].(string))
+	if err != nil || time.Since(ts) < 59*time.Second || time.Since(ts) > 70*time.Second {
+		t.Errorf("relative time = %v (%v), want a minute ago", records[0]["t"], err)
+	}
+
+	if _, err := readSenML(strings.NewReader(`{"n": "x"}`)); err == nil {
+		t.Error("readSenML of an object succeeded, want error")
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// runServeCommand implements the serve subcommand, which serves the transformer over HTTP
+// and gRPC so that other services can call it instead of running the binary
+func runServeCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on for HTTP, or empty to serve only gRPC")
+	grpcAddr := fs.String("grpc-addr", "", "address to also listen on for the gRPC service of proto/transform.proto; not with -tenants, as its requests name no tenant")
+	maxConcurrent := fs.Int("max-concurrent", 16, "most requests transformed at once; others wait for a slot")
+	maxQueue := fs.Int("max-queue", 64, "most requests waiting for a slot; others are turned away with 429 Too Many Requests")
+	maxBody := fs.Int64("max-body", 10<<20, "largest request body in bytes")
+	memoryLimit := fs.Int64("memory-budget", 0, "most bytes of memory estimated for the requests transformed at once, from their size and number of values; others wait for memory to be released (0 for no budget)")
+	memoryWait := fs.Duration("memory-wait", 5*time.Second, "longest a request waits for -memory-budget before failing with 503 Service Unavailable")
+	presetName := fs.String("preset", "", "preset applied to each request before transformation: "+presetNames())
+	rulesPath := fs.String("rules", "", "JSON rules file of actions applied to each transformed record")
+	coerce := fs.String("coerce", "", "comma-separated coercions tried in order on every string value")
+	compat := fs.String("compat", "", "compatibility level whose default coercions apply where -coerce and the rules set none, such as v1 (default: the latest)")
+	strict := fs.Bool("strict", false, "reject requests with skipped fields, invalid numbers or malformed timestamps, listing the problems")
+	overridesList := fs.String("overrides", "", "comma-separated options requests may set for themselves in X-Transform-<Option> headers or query parameters, such as strict,order; others are rejected with 400 Bad Request (available: "+requestOptionNames()+")")
+	breakerThreshold := fs.Float64("breaker-threshold", 0, "share of the last -breaker-window writes to a tenant's output whose failure opens its circuit breaker, such as 0.5 (0 for no breaker); requests whose outputs are skipped fail with 503 Service Unavailable, and the breakers' state is published in /debug/vars")
+	breakerWindow := fs.Int("breaker-window", 20, "number of the last writes to a tenant's output the -breaker-threshold share applies to")
+	breakerCooldown := fs.Duration("breaker-cooldown", 30*time.Second, "time an open circuit breaker waits before letting a probe write through, closing if it succeeds")
+	tenantsPath := fs.String("tenants", "", "JSON file of named configurations, each with its own API key, preset, rules, coerce, compat, strict, overrides, output, output_format and limits (max_concurrent, max_queue, max_body, memory_budget), served on /t/{name}/transform and on /transform for its API key; other flags are the defaults of their fields")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s serve [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 || *maxConcurrent < 1 || *maxQueue < 0 || *maxBody < 1 || *memoryLimit < 0 || *breakerThreshold < 0 || *breakerThreshold > 1 || *breakerWindow < 1 || (*addr == "" && *grpcAddr == "") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *grpcAddr != "" && *tenantsPath != "" {
+		// gRPC requests carry no API key, so they would bypass every tenant's configuration
+		log.Fatalf("error: -grpc-addr cannot be used with -tenants")
+	}
+
+	tr := transform.Transformer{Strict: *strict, Compat: *compat, OnWarning: printWarning}
+	var err error
+	if err := transform.ValidateCompat(*compat); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if *coerce != "" {
+		if tr.Coercions, err = transform.ParseCoercionOrder(*coerce); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+	}
+	if *rulesPath != "" {
+		if tr.Rules, err = transform.LoadRules(*rulesPath); err != nil {
+			log.Fatalf("error loading rules: %v", err)
+		}
+	}
+	if _, err := applyPreset(*presetName, nil); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	overrides, err := parseOverrides(*overridesList)
+	if err != nil {
+		log.Fatalf("error: -overrides: %v", err)
+	}
+	admission := newAdmission(*maxConcurrent, *maxQueue)
+	budget := newMemoryBudget(*memoryLimit, *memoryWait)
+
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		server := grpc.NewServer(grpc.MaxConcurrentStreams(uint32(*maxConcurrent)), grpc.MaxRecvMsgSize(int(*maxBody)))
+		server.RegisterService(&transformServiceDesc, &grpcTransformer{tr: &tr, presetName: *presetName, admission: admission, budget: budget})
+		log.Printf("Serving gRPC transform.v1.Transformer on %s", *grpcAddr)
+		if *addr == "" {
+			log.Fatal(server.Serve(lis))
+		}
+		go func() { log.Fatal(server.Serve(lis)) }()
+	}
+
+	mux := http.NewServeMux()
+	if *tenantsPath != "" {
+		configs, err := loadTenants(*tenantsPath)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		defaults := serveDefaults{
+			maxConcurrent: *maxConcurrent, maxQueue: *maxQueue, maxBody: *maxBody, memoryBudget: *memoryLimit, memoryWait: *memoryWait,
+			breakerThreshold: *breakerThreshold, breakerWindow: *breakerWindow, breakerCooldown: *breakerCooldown,
+		}
+		var tenants []*tenant
+		for _, c := range configs {
+			c = c.withDefaults(*presetName, *rulesPath, *coerce, *compat, *overridesList, *strict)
+			t, err := newTenant(c, defaults)
+			if err != nil {
+				log.Fatalf("error: %v", err)
+			}
+			tenants = append(tenants, t)
+		}
+		router := newTenantRouter(tenants)
+		mux.Handle("/transform", router)
+		mux.Handle("/t/", router)
+		log.Printf("Serving %d tenants", len(tenants))
+	} else {
+		h := newTransformHandler(&tr, *presetName, *maxBody, admission, budget)
+		h.overrides = overrides
+		mux.Handle("/transform", h)
+	}
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("Serving POST /transform and GET /debug/vars on %s", *addr)
+	log.Fatal(server.ListenAndServe())
+}
+
+// transformHandler transforms the JSON object POSTed as a request body into the JSON array
+// of the records it transforms to, as a preset may expand a record into several, and a
+// rule split one
+type transformHandler struct {
+	tr         *transform.Transformer
+	presetName string
+	maxBody    int64
+	// admission limits the requests transformed at once, and those waiting
+	admission *admission
+	// budget limits the memory of the requests transformed at once
+	budget *memoryBudget
+	// sink, when set, is also written the outputs of each request
+	sink *sharedSink
+	// overrides are the options requests may set for themselves
+	overrides map[string]bool
+}
+
+// newTransformHandler returns the handler of POST /transform
+func newTransformHandler(tr *transform.Transformer, presetName string, maxBody int64, admission *admission, budget *memoryBudget) *transformHandler {
+	return &transformHandler{tr: tr, presetName: presetName, maxBody: maxBody, admission: admission, budget: budget}
+}
+
+// ServeHTTP transforms a request. Bodies that are not a JSON object are rejected with 400,
+// as are options set by the request that are invalid or not among the overrides;
+// records the preset cannot convert or a strict Transformer finds problems in with 422. Requests arriving when the
+// admission queue is full are turned away with 429, those whose memory exceeds the budget
+// with 413, and those that wait too long for it with 503. Requests giving a latency budget
+// in an X-Request-Timeout header fail with 504 once it expires, rather than being answered
+// after the caller has given up. With a sink, outputs that cannot be written to it fail
+// with 502, and those its circuit breaker skips with 503. The outputs are written in the shape the request asks for, by default an array.
+func (h *transformHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	config, err := parseRequestConfig(r, h.tr, h.overrides)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	shape, err := responseShape(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	ctx, cancel, err := requestContext(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	defer cancel()
+	free, err := h.admission.admit(ctx)
+	if errors.Is(err, errSaturated) {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, err.Error(), nil)
+		return
+	} else if budgetExpired(ctx, r.Context()) {
+		writeExpired(w)
+		return
+	} else if err != nil {
+		return
+	}
+	defer free()
+
+	// Reserve the memory of the body before reading it, from its Content-Length or else the
+	// largest body allowed, and grow the reservation by the nodes of the record once they
+	// are counted
+	estimate := h.maxBody
+	if r.ContentLength >= 0 && r.ContentLength < estimate {
+		estimate = r.ContentLength
+	} else if h.budget != nil && estimate > h.budget.limit {
+		// A body of unknown length may still fit the budget
+		estimate = h.budget.limit
+	}
+	reserved, err := h.budget.reserve(ctx, estimate)
+	if err != nil {
+		writeBudgetError(ctx, w, r, err)
+		return
+	}
+	defer reserved.release()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBody))
+	var record Input
+	if err == nil {
+		err = json.NewDecoder(bytes.NewReader(body)).Decode(&record)
+	}
+	if err != nil || record == nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", h.maxBody), nil)
+		case err != nil:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("decoding body: %v", err), nil)
+		default:
+			writeError(w, http.StatusBadRequest, "body is not a JSON object", nil)
+		}
+		return
+	}
+	if err := reserved.resize(ctx, requestMemory(len(body), record)); err != nil {
+		writeBudgetError(ctx, w, r, err)
+		return
+	}
+
+	records, err := applyPreset(h.presetName, []Input{record})
+	if err != nil {
+		// Retrying a record its preset cannot convert would fail again
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		return
+	}
+	outputs := []Output{}
+	for _, record := range records {
+		if budgetExpired(ctx, r.Context()) {
+			writeExpired(w)
+			return
+		}
+		results, err := transformRequestRecord(config, record)
+		var strictErr *transform.StrictError
+		if errors.As(err, &strictErr) {
+			writeError(w, http.StatusUnprocessableEntity, "record has problems", strictErr.Problems)
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		outputs = append(outputs, results...)
+	}
+	// The caller has given up on outputs finished too late
+	if budgetExpired(ctx, r.Context()) {
+		writeExpired(w)
+		return
+	}
+
+	// Check the shape before writing to the sink, so that rejected requests store nothing
+	if err := checkShape(outputs, shape); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		return
+	}
+	if h.sink != nil {
+		if err := h.sink.Write(outputs); errors.Is(err, errOutputSkipped) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, err.Error(), nil)
+			return
+		} else if err != nil {
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("writing output: %v", err), nil)
+			return
+		}
+	}
+
+	writeOutputs(w, outputs, shape)
+}
+
+// transformRequestRecord transforms a record of a request with its configuration, as the
+// features of a GeoJSON object in a GeoJSON mode
+func transformRequestRecord(config requestConfig, record Input) ([]Output, error) {
+	if config.geoJSON == "" {
+		return config.tr.TransformRecord(record)
+	}
+	features, err := transformGeoJSON(config.tr, record, config.geoJSON)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []Output
+	for _, feature := range features {
+		results, err := config.tr.Rules.Apply(feature)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, results...)
+	}
+	return outputs, nil
+}
+
+// writeBudgetError fails a request whose memory could not be reserved: with 504 once its
+// latency budget expired, 503 when it waited too long for the memory budget and 413 when it
+// needs more than the whole budget
+func writeBudgetError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case budgetExpired(ctx, r.Context()):
+		writeExpired(w)
+	case errors.Is(err, errBudgetBusy):
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, err.Error(), nil)
+	default:
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error(), nil)
+	}
+}
+
+// writeExpired fails a request whose latency budget expired with 504 Gateway Timeout
+func writeExpired(w http.ResponseWriter) {
+	expiredRequests.Add(1)
+	writeError(w, http.StatusGatewayTimeout, errLatencyExpired.Error(), nil)
+}
+
+// writeError writes an error response as {"error": ..., "problems": [...]}
+func writeError(w http.ResponseWriter, status int, msg string, problems []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error    string   `json:"error"`
+		Problems []string `json:"problems,omitempty"`
+	}{msg, problems})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestTransformHandler(t *testing.T) {
+	rs, err := transform.ParseRules([]byte(`{"rules": [{"field": "items", "action": "chunk", "size": 1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newTransformHandler(&transform.Transformer{Rules: rs, Strict: true}, "", 64, newAdmission(2, 8), nil))
+	defer server.Close()
+
+	tests := []struct {
+		method string
+		body   string
+		status int
+		want   string
+	}{
+		{"POST", `{"name": " a ", "n": {"N": "1"}}`, 200, `[[{"n":1},{"name":"a"}]]`},
+		{"POST", `{"items": ["x", "y"]}`, 200, `[[{"items":["x"]},{"items_chunk":0},{"items_chunks":2}],[{"items":["y"]},{"items_chunk":1},{"items_chunks":2}]]`},
+		{"POST", `{"n": 1}`, 422, `{"error":"record has problems","problems":["unsupported data type for key \"n\""]}`},
+		{"POST", `[1]`, 400, ``},
+		{"POST", `null`, 400, `{"error":"body is not a JSON object"}`},
+		{"POST", `{"name": "` + strings.Repeat("x", 64) + `"}`, 413, `{"error":"body exceeds 64 bytes"}`},
+		{"GET", ``, 405, `{"error":"method not allowed"}`},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.TrimSpace(body.String())
+		if resp.StatusCode != tt.status || (tt.want != "" && got != tt.want) {
+			t.Errorf("%s %s = %d %s, want %d %s", tt.method, tt.body, resp.StatusCode, got, tt.status, tt.want)
+		}
+	}
+}
+
+func TestTransformHandlerPresetError(t *testing.T) {
+	w := httptest.NewRecorder()
+	h := newTransformHandler(&transform.Transformer{}, "missing", 64, nil, nil)
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/transform", strings.NewReader(`{"a": "1"}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("request failing its preset = %d, want 422", w.Code)
+	}
+}
+
+// TestTransformHandlerConcurrent sends requests at once to a handler sharing one
+// Transformer, for go test -race
+func TestTransformHandlerConcurrent(t *testing.T) {
+	rs, err := transform.ParseRules([]byte(`{"rules": [
+		{"field": "name", "action": "uppercase", "when": "record.n > 1.0"},
+		{"field": "tags", "action": "sort"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newTransformHandler(&transform.Transformer{Rules: rs, Coercions: []string{"number"}}, "", 1<<10, newAdmission(4, 16), nil))
+	defer server.Close()
+
+	const body, want = `{"name": " ada ", "n": "2", "tags": ["b", "a"]}`, `[[{"n":2},{"name":"ADA"},{"tags":["a","b"]}]]`
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if got := strings.TrimSpace(string(data)); err != nil || got != want {
+				t.Errorf("POST %s = %s, %v, want %s", body, got, err, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
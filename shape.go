@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// shapeParam is the query parameter, and X-Transform-Shape header, choosing the shape of a
+// response; it is not one of the request options
+const shapeParam = "shape"
+
+// responseShapes are the shapes a response may take: an array of the outputs of the
+// records a request transforms to, each an array of maps as written by -output-format json;
+// an object merging the maps of its single record, as the rows of sqlite outputs; or
+// newline-delimited outputs streamed as -output-format ndjson writes them
+var responseShapes = map[string]bool{"array": true, "object": true, "ndjson": true}
+
+// responseShape returns the shape requested in the shape query parameter or
+// X-Transform-Shape header, defaulting to array
+func responseShape(r *http.Request) (string, error) {
+	shape := r.URL.Query().Get(shapeParam)
+	if shape == "" {
+		shape = r.Header.Get("X-Transform-Shape")
+	}
+	if shape == "" {
+		return "array", nil
+	}
+	if !responseShapes[shape] {
+		return "", fmt.Errorf("unsupported shape %q (want array, object or ndjson)", shape)
+	}
+	return shape, nil
+}
+
+// checkShape checks that the outputs of a request fit a shape: requests for an object whose
+// record a preset or the GeoJSON mode split into several have no single object
+func checkShape(outputs []Output, shape string) error {
+	if shape == "object" && len(outputs) != 1 {
+		return fmt.Errorf("request transformed to %d records, not one object; use shape array or ndjson", len(outputs))
+	}
+	return nil
+}
+
+// writeOutputs writes the outputs of a request in a shape they fit. The outputs are
+// encoded before the response is started, so that outputs that cannot be encoded fail with
+// 500 rather than an empty 200.
+func writeOutputs(w http.ResponseWriter, outputs []Output, shape string) {
+	var body bytes.Buffer
+	var lines [][]byte
+	var err error
+	switch shape {
+	case "object":
+		err = json.NewEncoder(&body).Encode(mergeOutput(outputs[0]))
+	case "ndjson":
+		for _, output := range outputs {
+			var data []byte
+			if data, err = encodeOutput(output, "ndjson"); err != nil {
+				break
+			}
+			lines = append(lines, data)
+		}
+	default:
+		err = json.NewEncoder(&body).Encode(outputs)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("encoding output: %v", err), nil)
+		return
+	}
+
+	if shape != "ndjson" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	for _, data := range lines {
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
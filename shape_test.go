@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestTransformHandlerShapes(t *testing.T) {
+	const collection = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [3, 4]}}
+	]}`
+	h := newTransformHandler(&transform.Transformer{}, "", 1<<10, nil, nil)
+	h.overrides = map[string]bool{"geojson": true}
+
+	tests := []struct {
+		query, header, body string
+		status              int
+		contentType, want   string
+	}{
+		{"", "", `{"b": " 1 "}`, 200, "application/json", `[[{"b":"1"}]]`},
+		{"?shape=array", "", `{"b": " 1 "}`, 200, "application/json", `[[{"b":"1"}]]`},
+		{"?shape=object", "", `{"a": {"c": "2", "b": "1"}}`, 200, "application/json", `{"b":"1","c":"2"}`},
+		{"", "object", `{"b": "1"}`, 200, "application/json", `{"b":"1"}`},
+		{"?shape=ndjson&geojson=wkt", "", collection, 200, "application/x-ndjson",
+			"[{\"geometry\":\"POINT (1 2)\"}]\n[{\"geometry\":\"POINT (3 4)\"}]"},
+		{"?shape=object&geojson=wkt", "", collection, 422, "application/json", `{"error":"request transformed to 2 records, not one object; use shape array or ndjson"}`},
+		{"?shape=xml", "", `{"b": "1"}`, 400, "application/json", `{"error":"unsupported shape \"xml\" (want array, object or ndjson)"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/transform"+tt.query, strings.NewReader(tt.body))
+		if tt.header != "" {
+			req.Header.Set("X-Transform-Shape", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		got := strings.TrimSpace(w.Body.String())
+		if w.Code != tt.status || w.Header().Get("Content-Type") != tt.contentType || got != tt.want {
+			t.Errorf("POST %s (header %q) = %d %s %s, want %d %s %s", tt.query, tt.header, w.Code, w.Header().Get("Content-Type"), got, tt.status, tt.contentType, tt.want)
+		}
+	}
+}
+
+func TestWriteOutputsEncodingError(t *testing.T) {
+	// Outputs JSON cannot encode fail with 500 in every shape, rather than an empty 200
+	outputs := []Output{{{"v": math.NaN()}}}
+	for _, shape := range []string{"array", "object", "ndjson"} {
+		w := httptest.NewRecorder()
+		writeOutputs(w, outputs, shape)
+		if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "encoding output") {
+			t.Errorf("writeOutputs(%s) = %d %s, want 500", shape, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTransformHandlerShapeBeforeSink(t *testing.T) {
+	const collection = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [3, 4]}}
+	]}`
+	collected := &collectSink{}
+	h := newTransformHandler(&transform.Transformer{}, "", 1<<10, nil, nil)
+	h.overrides = map[string]bool{"geojson": true}
+	h.sink = &sharedSink{next: collected}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/transform?shape=object&geojson=wkt", strings.NewReader(collection)))
+	// A request rejected for its shape stores nothing
+	if w.Code != http.StatusUnprocessableEntity || len(collected.outputs) != 0 {
+		t.Errorf("POST shape=object of 2 records = %d with %d outputs stored, want 422 with none", w.Code, len(collected.outputs))
+	}
+}
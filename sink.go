@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sink receives the transformed output of each record
+type sink interface {
+	// Write stores the output of a single record
+	Write(output Output) error
+	// Close flushes any buffered output and releases the sink
+	Close() error
+}
+
+// flushSink is a sink that saves its state after each source member or stream message
+type flushSink interface {
+	sink
+	// Flush saves the state of the records written so far
+	Flush() error
+}
+
+// openSink opens the sink addressed by an output URI, defaulting to stdout
+func openSink(uri, format string) (sink, error) {
+	switch format {
+	case "", "json", "ejson", "ndjson", "yaml", "toml", "msgpack", "cbor", "bson", "csv", "parquet", "avro":
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+
+	switch {
+	case format == "csv":
+		if uri != "" && uri != "-" {
+			return nil, fmt.Errorf("CSV output is only written to stdout")
+		}
+		return newCSVSink(dataOutput, csvDelimiter), nil
+	case format == "parquet":
+		if uri != "" && uri != "-" {
+			return nil, fmt.Errorf("Parquet output is only written to stdout")
+		}
+		return newParquetSink(dataOutput), nil
+	case format == "avro":
+		if uri != "" && uri != "-" {
+			return nil, fmt.Errorf("Avro output is only written to stdout")
+		}
+		return newAvroSink(dataOutput, avroSchema, avroSchemaOut)
+	case uri == "" || uri == "-":
+		return stdoutSink{format: format}, nil
+	case strings.HasPrefix(uri, "sqlite:"):
+		return openSQLiteSink(uri)
+	case isWarehouseURI(uri):
+		return openWarehouseSink(uri)
+	case isRedisURI(uri):
+		return openRedisSink(uri)
+	case isAMQPURI(uri):
+		return openAMQPSink(uri, format)
+	case isPubSubURI(uri):
+		return openPubSubSink(uri, format)
+	case isMQTTURI(uri):
+		return openMQTTSink(uri, format)
+	case isKafkaURI(uri):
+		return openKafkaSink(uri, format)
+	case isRotatingFileURI(uri):
+		return openRotatingFileSink(uri, format)
+	case isS3URI(uri):
+		return openS3Sink(uri, format)
+	case archiveKind(uri) != "":
+		return openArchiveSink(uri, format)
+	}
+	return nil, fmt.Errorf("unsupported output %q", uri)
+}
+
+// stdoutSink prints each output to the data output in the output format
+type stdoutSink struct {
+	format string
+}
+
+// Write prints the encoded output to the data output
+func (s stdoutSink) Write(output Output) error {
+	data, err := encodeOutput(output, s.format)
+	if err != nil {
+		return err
+	}
+	_, err = dataOutput.Write(data)
+	return err
+}
+
+// Close is a no-op for stdout
+func (stdoutSink) Close() error {
+	return nil
+}
+
+// mergeOutput merges the maps of an output into a single flat record
+func mergeOutput(output Output) map[string]interface{} {
+	record := make(map[string]interface{})
+	for _, m := range output {
+		for k, v := range m {
+			record[k] = v
+		}
+	}
+	return record
+}
+
+// templatePattern matches the "{field}" placeholders of key, topic and routing templates
+var templatePattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// expandTemplate replaces the "{field}" placeholders of a template with the values of the
+// merged record, failing if a field is missing
+func expandTemplate(tmpl string, record map[string]interface{}) (string, error) {
+	var missing string
+	s := templatePattern.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		field := placeholder[1 : len(placeholder)-1]
+		value, ok := record[field]
+		if !ok {
+			missing = field
+			return ""
+		}
+		if s, ok := value.(string); ok {
+			return s
+		}
+		data, _ := json.Marshal(value)
+		return string(data)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("record has no field %q for template %q", missing, tmpl)
+	}
+	return s, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// span is the byte range of a JSON value within a document
+type span struct {
+	pointer    string
+	start, end int
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer such as "/a/b~1c/0" into its unescaped
+// reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON Pointer %q does not start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// pointerPath returns the output field path of the value a JSON Pointer references, as used
+// by coerce rules: object keys joined with ".", with array indexes left out
+func pointerPath(tokens []string) string {
+	var path string
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err == nil {
+			continue
+		}
+		path = transform.JoinPath(path, token)
+	}
+	return path
+}
+
+// findPointer returns the byte range of the value referenced by a JSON Pointer in a
+// document, reporting false when the document has no such value
+func findPointer(doc []byte, tokens []string) (int, int, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	for _, token := range tokens {
+		delim, err := dec.Token()
+		if err != nil {
+			return 0, 0, false, err
+		}
+		switch delim {
+		case json.Delim('{'):
+			found := false
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return 0, 0, false, err
+				}
+				if key == token {
+					found = true
+					break
+				}
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return 0, 0, false, err
+				}
+			}
+			if !found {
+				return 0, 0, false, nil
+			}
+		case json.Delim('['):
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 {
+				return 0, 0, false, nil
+			}
+			for i := 0; i < index; i++ {
+				if !dec.More() {
+					return 0, 0, false, nil
+				}
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return 0, 0, false, err
+				}
+			}
+			if !dec.More() {
+				return 0, 0, false, nil
+			}
+		default:
+			// Scalars have no children
+			return 0, 0, false, nil
+		}
+	}
+
+	var value json.RawMessage
+	if err := dec.Decode(&value); err != nil {
+		return 0, 0, false, err
+	}
+	end := int(dec.InputOffset())
+	return end - len(value), end, true, nil
+}
+
+// transformSparse transforms only the values referenced by the JSON Pointers in a document,
+// splicing their new encodings into the document so that every other byte is unchanged.
+// Pointers the document does not resolve are skipped with a warning.
+func transformSparse(t *transform.Transformer, doc []byte, pointers []string) ([]byte, error) {
+	var spans []span
+	for _, pointer := range pointers {
+		tokens, err := parsePointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+		start, end, ok, err := findPointer(doc, tokens)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %q: %v", pointer, err)
+		}
+		if !ok {
+			warn("missing-pointer", "Skipping JSON Pointer %q missing from the document", pointer)
+			continue
+		}
+		spans = append(spans, span{pointer: pointer, start: start, end: end})
+	}
+
+	// Splice from the end of the document so that earlier offsets stay valid
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for i := 1; i < len(spans); i++ {
+		if spans[i].end > spans[i-1].start {
+			return nil, fmt.Errorf("JSON Pointers %q and %q overlap", spans[i].pointer, spans[i-1].pointer)
+		}
+	}
+	out := append([]byte(nil), doc...)
+	for _, s := range spans {
+		var value interface{}
+		if err := json.Unmarshal(doc[s.start:s.end], &value); err != nil {
+			return nil, err
+		}
+		tokens, _ := parsePointer(s.pointer)
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(t.TransformValue(value, pointerPath(tokens))); err != nil {
+			return nil, err
+		}
+		encoded := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+		out = append(out[:s.start], append(encoded, out[s.end:]...)...)
+	}
+	return out, nil
+}
+
+// runSparse transforms the values at the given JSON Pointers of the JSON document of an
+// input file or stdin and writes the document to the data output
+func runSparse(t *transform.Transformer, inputURI string, pointers []string) error {
+	var r io.Reader = os.Stdin
+	if inputURI != "" && inputURI != "-" {
+		f, err := os.Open(inputURI)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(doc) {
+		return fmt.Errorf("input is not a JSON document")
+	}
+	out, err := transformSparse(t, doc, pointers)
+	if err != nil {
+		return err
+	}
+	_, err = dataOutput.Write(out)
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := map[string][]string{
+		"":         nil,
+		"/a":       {"a"},
+		"/a/0/b":   {"a", "0", "b"},
+		"/a~1b/~0": {"a/b", "~"},
+		"/":        {""},
+	}
+	for pointer, want := range tests {
+		got, err := parsePointer(pointer)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePointer(%q) = %q, %v, want %q", pointer, got, err, want)
+		}
+	}
+	if _, err := parsePointer("a/b"); err == nil {
+		t.Errorf("parsePointer accepted a pointer without a leading /")
+	}
+}
+
+func TestTransformSparse(t *testing.T) {
+	doc := `{"a":  {"x": " 1 "}, "keep": " 2 ",` + "\n" + `"list": ["5", {"t": "2024-01-01T00:00:00Z"}], "s": "2024-01-01T00:00:00Z", "html": "<b>"}`
+	tests := []struct {
+		pointers []string
+		want     string
+	}{
+		{
+			[]string{"/a", "/list/1/t"},
+			`{"a":  {"x":"1"}, "keep": " 2 ",` + "\n" + `"list": ["5", {"t": 1704067200}], "s": "2024-01-01T00:00:00Z", "html": "<b>"}`,
+		},
+		{
+			[]string{"/s", "/list", "/html"},
+			`{"a":  {"x": " 1 "}, "keep": " 2 ",` + "\n" + `"list": [5,{"t":"2024-01-01T00:00:00Z"}], "s": 1704067200, "html": "<b>"}`,
+		},
+		// Missing pointers leave the document unchanged
+		{[]string{"/missing", "/list/5", "/keep/x", "/list/x"}, doc},
+	}
+	for _, tt := range tests {
+		got, err := transformSparse(&transform.Transformer{}, []byte(doc), tt.pointers)
+		if err != nil {
+			t.Errorf("transformSparse(%q) error: %v", tt.pointers, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("transformSparse(%q) =\n%s\nwant\n%s", tt.pointers, got, tt.want)
+		}
+	}
+
+	if _, err := transformSparse(&transform.Transformer{}, []byte(doc), []string{"/a", "/a/x"}); err == nil {
+		t.Errorf("transformSparse accepted overlapping pointers")
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// sqliteCommitsTable is the table in which batch mode records a manifest of each commit
+const sqliteCommitsTable = "_transform_commits"
+
+// sqliteJSONType is the declared type of the columns the sink creates for maps and lists,
+// whose JSON text is decoded when the table is read
+const sqliteJSONType = "JSON"
+
+// parseSQLiteURI splits a "sqlite:file.db?table=t" URI into its database path, table and
+// query parameters
+func parseSQLiteURI(uri string) (string, string, url.Values, error) {
+	rest := strings.TrimPrefix(uri, "sqlite:")
+	file, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid sqlite URI %q: %v", uri, err)
+	}
+	table := query.Get("table")
+	if file == "" || table == "" {
+		return "", "", nil, fmt.Errorf("sqlite URI %q must be of the form sqlite:file.db?table=name", uri)
+	}
+	return file, table, query, nil
+}
+
+// quoteIdent quotes an SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// readSQLite reads every row of the table addressed by a sqlite URI into records
+func readSQLite(uri string) ([]Input, error) {
+	file, table, _, err := parseSQLiteURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT * FROM " + quoteIdent(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	jsonColumns := make([]bool, len(columns))
+	for i, t := range types {
+		jsonColumns[i] = strings.EqualFold(t.DatabaseTypeName(), sqliteJSONType)
+	}
+
+	var records []Input
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		// Convert column values to the string form the transformer expects, skipping NULLs
+		record := make(Input)
+		for i, column := range columns {
+			if value, ok := sqliteToJSON(values[i], jsonColumns[i]); ok {
+				record[column] = value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// sqliteToJSON converts a scanned column value to a JSON value, reporting false for NULL.
+// The text of columns declared JSON is decoded.
+func sqliteToJSON(v interface{}, isJSON bool) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	case []byte:
+		if !utf8.Valid(v) {
+			return base64.StdEncoding.EncodeToString(v), true
+		}
+		return sqliteText(string(v), isJSON), true
+	case string:
+		return sqliteText(v, isJSON), true
+	}
+	return fmt.Sprint(v), true
+}
+
+// sqliteText decodes the text of a JSON column, and returns any other text unchanged, even
+// when it looks like JSON
+func sqliteText(s string, isJSON bool) interface{} {
+	if isJSON {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(s), &decoded); err == nil {
+			return decoded
+		}
+	}
+	return s
+}
+
+// sqliteSink inserts each output as a row, adding columns as new keys appear. In batch mode
+// ("batch=N") rows are inserted in transactions of up to N rows, ended early after each
+// source member or stream message, and each transaction records its manifest in the
+// _transform_commits table, so that a batch is committed with its manifest or not at all.
+type sqliteSink struct {
+	db      *sql.DB
+	table   string
+	columns map[string]bool
+	batch   int
+	tx      *sql.Tx
+	digest  *batchDigest
+}
+
+// sqliteExecer is the part of sql.DB and sql.Tx used to write rows
+type sqliteExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// openSQLiteSink opens the database addressed by a sqlite URI and creates the table if needed
+func openSQLiteSink(uri string) (*sqliteSink, error) {
+	file, table, query, err := parseSQLiteURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := parseBatchSize(query.Get("batch"))
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqliteSink{db: db, table: table, columns: make(map[string]bool), batch: batch}
+
+	// Load the columns of an existing table
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			db.Close()
+			return nil, err
+		}
+		s.columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write inserts the merged output as a single row, committing the batch once it is full
+func (s *sqliteSink) Write(output Output) error {
+	record := mergeOutput(output)
+	if len(record) == 0 {
+		return nil
+	}
+
+	var exec sqliteExecer = s.db
+	if s.batch > 0 {
+		if s.tx == nil {
+			tx, err := s.db.Begin()
+			if err != nil {
+				return err
+			}
+			s.tx, s.digest = tx, newBatchDigest()
+		}
+		exec = s.tx
+		if err := s.digest.add(record); err != nil {
+			return err
+		}
+	}
+
+	// Sort columns so statements are deterministic
+	var keys []string
+	for k := range record {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if err := s.ensureColumns(exec, keys, record); err != nil {
+		return err
+	}
+
+	columns := make([]string, len(keys))
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, k := range keys {
+		columns[i] = quoteIdent(k)
+		placeholders[i] = "?"
+		value, err := jsonToSQLite(record[k])
+		if err != nil {
+			return fmt.Errorf("column %q: %v", k, err)
+		}
+		args[i] = value
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(s.table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if _, err := exec.Exec(stmt, args...); err != nil {
+		return err
+	}
+	if s.batch > 0 && s.digest.records >= s.batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush commits the current batch together with its manifest
+func (s *sqliteSink) Flush() error {
+	if s.tx == nil {
+		return nil
+	}
+	tx := s.tx
+	s.tx = nil
+	m := s.digest.manifest(newCommitID())
+	_, err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (commit_id TEXT PRIMARY KEY, table_name TEXT, records INTEGER, sha256 TEXT, committed_at TEXT)", quoteIdent(sqliteCommitsTable)))
+	if err == nil {
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?, ?)", quoteIdent(sqliteCommitsTable)), m.CommitID, s.table, m.Records, m.SHA256, m.CommittedAt)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureColumns creates the table or adds any columns it does not have yet, declaring those
+// created for the maps and lists of a record as JSON
+func (s *sqliteSink) ensureColumns(exec sqliteExecer, keys []string, record map[string]interface{}) error {
+	if len(s.columns) == 0 {
+		columns := make([]string, len(keys))
+		for i, k := range keys {
+			columns[i] = sqliteColumn(k, record[k])
+		}
+		if _, err := exec.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(s.table), strings.Join(columns, ", "))); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			s.columns[k] = true
+		}
+		return nil
+	}
+
+	for _, k := range keys {
+		if s.columns[k] {
+			continue
+		}
+		if _, err := exec.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteIdent(s.table), sqliteColumn(k, record[k]))); err != nil {
+			return err
+		}
+		s.columns[k] = true
+	}
+	return nil
+}
+
+// sqliteColumn returns the definition of a column created for a value: declared JSON for
+// the values stored as JSON text, untyped otherwise
+func sqliteColumn(name string, v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}, json.RawMessage:
+		return quoteIdent(name) + " " + sqliteJSONType
+	}
+	return quoteIdent(name)
+}
+
+// Close commits any open batch and closes the database
+func (s *sqliteSink) Close() error {
+	if err := s.Flush(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
+
+// jsonToSQLite converts a transformed value to a column value, storing maps and lists as JSON
+// text and binary data as a BLOB
+func jsonToSQLite(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, int, int64, float64, bool, []byte:
+		return v, nil
+	case transform.Timestamp:
+		return int64(v), nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case json.RawMessage:
+		return string(v), nil
+	}
+	return nil, errors.New("unsupported value type")
+}
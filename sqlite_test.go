@@ -0,0 +1,99 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteSinkBatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.db")
+	s, err := openSQLiteSink("sqlite:" + file + "?table=events&batch=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := s.Write(Output{{"id": id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The full batch is committed, the third row is still pending
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	count := func(table string) int {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + quoteIdent(table)).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count("events"); n != 2 {
+		t.Errorf("rows before close = %d, want 2", n)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("events"); n != 3 {
+		t.Errorf("rows after close = %d, want 3", n)
+	}
+	rows, err := db.Query("SELECT table_name, records, length(sha256) FROM " + sqliteCommitsTable + " ORDER BY commit_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []int
+	for rows.Next() {
+		var table string
+		var records, hashLen int
+		if err := rows.Scan(&table, &records, &hashLen); err != nil {
+			t.Fatal(err)
+		}
+		if table != "events" || hashLen != 64 {
+			t.Errorf("manifest row = %q, %d, %d", table, records, hashLen)
+		}
+		got = append(got, records)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 1 {
+		t.Errorf("committed batches = %v, want [2 1]", got)
+	}
+
+	if _, err := openSQLiteSink("sqlite:" + file + "?table=events&batch=0"); err == nil {
+		t.Errorf("openSQLiteSink accepted batch=0")
+	}
+}
+
+func TestSQLiteJSONColumns(t *testing.T) {
+	uri := "sqlite:" + filepath.Join(t.TempDir(), "out.db") + "?table=records"
+	s, err := openSQLiteSink(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(Output{{"id": "1", "tags": []interface{}{"a"}, "note": "[draft]"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Columns added later are declared JSON too
+	if err := s.Write(Output{{"id": "2", "meta": map[string]interface{}{"k": "v"}, "note": `{"x": 1}`}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := readSQLite(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{
+		{"id": "1", "tags": []interface{}{"a"}, "note": "[draft]"},
+		{"id": "2", "meta": map[string]interface{}{"k": "v"}, "note": `{"x": 1}`},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readSQLite = %#v, want %#v", records, want)
+	}
+}
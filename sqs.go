@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// sqsMaxVisibility is the longest visibility timeout SQS allows, 12 hours
+const sqsMaxVisibility = 12 * 60 * 60
+
+// sqsAPI is the part of the SQS client used by the SQS consumer
+type sqsAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+// sqsConsumer polls an SQS queue
+type sqsConsumer struct {
+	client   sqsAPI
+	queueURL string
+	// wait is the long-polling wait of each receive in seconds
+	wait int32
+	// visibility is the visibility timeout of received messages in seconds, extended
+	// while a message is processed
+	visibility int32
+	// max is the most messages received at once
+	max int32
+}
+
+// isSQSURI reports whether an input URI addresses an SQS queue
+func isSQSURI(uri string) bool {
+	return strings.HasPrefix(uri, "sqs://")
+}
+
+// parseSQSURI parses a URI like
+// "sqs://sqs.eu-west-1.amazonaws.com/123456789012/orders?wait=20&visibility=60&max=10",
+// whose host and path are those of the queue URL
+func parseSQSURI(uri string) (*sqsConsumer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQS URI %q: %v", uri, err)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid SQS URI %q: want sqs://host/account/queue", uri)
+	}
+	c := &sqsConsumer{queueURL: "https://" + u.Host + u.Path, wait: 20, visibility: 30, max: 10}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQS URI %q: %v", uri, err)
+	}
+	for _, p := range []struct {
+		name     string
+		value    *int32
+		min, max int32
+	}{
+		{"wait", &c.wait, 0, 20},
+		{"visibility", &c.visibility, 1, sqsMaxVisibility},
+		{"max", &c.max, 1, 10},
+	} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < int(p.min) || n > int(p.max) {
+			return nil, fmt.Errorf("invalid SQS %s %q (want %d to %d)", p.name, v, p.min, p.max)
+		}
+		*p.value = int32(n)
+	}
+	return c, nil
+}
+
+// consumeSQS polls the queue of an SQS URI and passes the records decoded from each message
+// body to fn until the process is interrupted, using the default AWS configuration
+func consumeSQS(uri string, opts inputOptions, fn func(member) bool) error {
+	c, err := parseSQSURI(uri)
+	if err != nil {
+		return err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return fmt.Errorf("loading AWS configuration: %v", err)
+	}
+	c.client = sqs.NewFromConfig(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for ctx.Err() == nil {
+		if err := c.poll(ctx, opts, fn); err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poll receives a batch of messages and processes each in turn. Messages are deleted once
+// fn reports their outputs delivered, with their visibility timeout extended while it runs
+// so that no other consumer receives them meanwhile. Messages that fail to decode or whose
+// outputs were not delivered are made visible again after a backoff growing with their
+// receive count, so that the queue's redrive policy moves them to its dead-letter queue
+// after its maximum receives.
+func (c *sqsConsumer) poll(ctx context.Context, opts inputOptions, fn func(member) bool) error {
+	out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:                    aws.String(c.queueURL),
+		MaxNumberOfMessages:         c.max,
+		WaitTimeSeconds:             c.wait,
+		VisibilityTimeout:           c.visibility,
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+	})
+	if err != nil {
+		return fmt.Errorf("receiving from %s: %v", c.queueURL, err)
+	}
+
+	for _, msg := range out.Messages {
+		records, err := readInput(strings.NewReader(aws.ToString(msg.Body)), opts)
+		if err != nil {
+			if err := c.retry(ctx, msg, "rejected-message", err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		done := c.extendVisibility(ctx, msg.ReceiptHandle)
+		delivered := fn(member{records: records})
+		close(done)
+		if !delivered {
+			if err := c.retry(ctx, msg, "undelivered-message", errOutputSkipped); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(c.queueURL),
+			ReceiptHandle: msg.ReceiptHandle,
+		}); err != nil {
+			return fmt.Errorf("deleting message %s: %v", aws.ToString(msg.MessageId), err)
+		}
+	}
+	return nil
+}
+
+// retry makes a message that failed visible again after its retry delay
+func (c *sqsConsumer) retry(ctx context.Context, msg types.Message, kind string, reason error) error {
+	receives, _ := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	delay := c.retryDelay(receives)
+	warn(kind, "Retrying message %s from %s in %ds: %v", aws.ToString(msg.MessageId), c.queueURL, delay, reason)
+	if _, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.queueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: delay,
+	}); err != nil {
+		return fmt.Errorf("delaying message %s: %v", aws.ToString(msg.MessageId), err)
+	}
+	return nil
+}
+
+// retryDelay returns the visibility timeout of a message that failed after its nth receive:
+// the visibility timeout doubled for each earlier receive, up to the SQS maximum
+func (c *sqsConsumer) retryDelay(receives int) int32 {
+	delay := int64(c.visibility)
+	for i := 1; i < receives && delay < sqsMaxVisibility; i++ {
+		delay *= 2
+	}
+	if delay > sqsMaxVisibility {
+		delay = sqsMaxVisibility
+	}
+	return int32(delay)
+}
+
+// extendVisibility renews the visibility timeout of a message every half timeout until the
+// returned channel is closed
+func (c *sqsConsumer) extendVisibility(ctx context.Context, receipt *string) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(c.visibility) * time.Second / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(c.queueURL),
+					ReceiptHandle:     receipt,
+					VisibilityTimeout: c.visibility,
+				}); err != nil {
+					warn("visibility", "Extending visibility of a message from %s failed: %v", c.queueURL, err)
+				}
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeSQS serves one batch of messages and records what became of each
+type fakeSQS struct {
+	messages []types.Message
+	deleted  []string
+	delayed  map[string]int32
+}
+
+func (f *fakeSQS) ReceiveMessage(_ context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	out := &sqs.ReceiveMessageOutput{Messages: f.messages}
+	f.messages = nil
+	return out, nil
+}
+
+func (f *fakeSQS) DeleteMessage(_ context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, *in.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(_ context.Context, in *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.delayed[*in.ReceiptHandle] = in.VisibilityTimeout
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestParseSQSURI(t *testing.T) {
+	c, err := parseSQSURI("sqs://sqs.eu-west-1.amazonaws.com/123456789012/orders?visibility=60&max=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.queueURL != "https://sqs.eu-west-1.amazonaws.com/123456789012/orders" || c.wait != 20 || c.visibility != 60 || c.max != 5 {
+		t.Errorf("parseSQSURI = %+v", c)
+	}
+	for _, uri := range []string{
+		"sqs://sqs.eu-west-1.amazonaws.com",
+		"sqs://host/1/q?wait=21",
+		"sqs://host/1/q?visibility=0",
+		"sqs://host/1/q?max=many",
+		"sqs://host/1/q?max=%zz",
+	} {
+		if _, err := parseSQSURI(uri); err == nil {
+			t.Errorf("parseSQSURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestSQSPoll(t *testing.T) {
+	attrs := func(receives string) map[string]string {
+		return map[string]string{string(types.MessageSystemAttributeNameApproximateReceiveCount): receives}
+	}
+	fake := &fakeSQS{
+		messages: []types.Message{
+			{MessageId: aws.String("1"), ReceiptHandle: aws.String("r1"), Body: aws.String(`{"id": "1"}`), Attributes: attrs("1")},
+			{MessageId: aws.String("2"), ReceiptHandle: aws.String("r2"), Body: aws.String(`{"id": `), Attributes: attrs("3")},
+			{MessageId: aws.String("3"), ReceiptHandle: aws.String("r3"), Body: aws.String(`{"id": "3"}`), Attributes: attrs("1")},
+			{MessageId: aws.String("4"), ReceiptHandle: aws.String("r4"), Body: aws.String(`{"id": "4"}`), Attributes: attrs("2")},
+		},
+		delayed: make(map[string]int32),
+	}
+	c := &sqsConsumer{client: fake, queueURL: "https://host/1/q", visibility: 30, max: 10}
+
+	var got []Input
+	if err := c.poll(context.Background(), inputOptions{}, func(m member) bool {
+		got = append(got, m.records...)
+		// The outputs of the last message were skipped
+		return m.records[0]["id"] != "4"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{"id": "1"}, {"id": "3"}, {"id": "4"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(fake.deleted, []string{"r1", "r3"}) {
+		t.Errorf("deleted = %v, want [r1 r3]", fake.deleted)
+	}
+	// The third receive of a failing message waits four visibility timeouts, and an
+	// undelivered one is retried the same way
+	if !reflect.DeepEqual(fake.delayed, map[string]int32{"r2": 120, "r4": 60}) {
+		t.Errorf("delayed = %v, want r2 for 120s and r4 for 60s", fake.delayed)
+	}
+}
+
+func TestSQSRetryDelay(t *testing.T) {
+	c := &sqsConsumer{visibility: 30}
+	for receives, want := range map[int]int32{0: 30, 1: 30, 2: 60, 5: 480, 20: sqsMaxVisibility} {
+		if got := c.retryDelay(receives); got != want {
+			t.Errorf("retryDelay(%d) = %d, want %d", receives, got, want)
+		}
+	}
+}
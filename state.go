@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBatchSize is the number of puts a bolt state store buffers before committing them
+const boltBatchSize = 1000
+
+// boltLockTimeout is how long opening a BoltDB file waits for another process to release it
+const boltLockTimeout = 5 * time.Second
+
+// stateStore persists string values by key so that stream stages survive restarts
+type stateStore interface {
+	// Get returns the value stored for key and whether there is one
+	Get(key string) (string, bool, error)
+	// Put stores the value for key
+	Put(key, value string) error
+	// Flush saves any buffered values
+	Flush() error
+	// Close saves any buffered values and releases the store
+	Close() error
+}
+
+// isBoltStatePath reports whether a state path names a BoltDB file rather than a JSON file.
+// Plain .db paths are left to JSON so they are not confused with SQLite databases.
+func isBoltStatePath(path string) bool {
+	return strings.HasSuffix(path, ".bolt")
+}
+
+// openStateStore opens the state of the named stage at path. Paths ending in .bolt
+// are BoltDB files holding one bucket per stage, shared by all stages; other paths are JSON
+// files holding the state of a single stage.
+func openStateStore(path, stage string) (stateStore, error) {
+	if isBoltStatePath(path) {
+		return openBoltStateStore(path, stage)
+	}
+	return openFileStateStore(path)
+}
+
+// fileStateStore keeps the state in memory and saves it to a JSON file when flushed
+type fileStateStore struct {
+	path   string
+	values map[string]string
+}
+
+// openFileStateStore loads the JSON state file at path, which need not exist yet
+func openFileStateStore(path string) (*fileStateStore, error) {
+	s := &fileStateStore{path: path, values: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.values); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the value stored for key
+func (s *fileStateStore) Get(key string) (string, bool, error) {
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+// Put stores the value for key
+func (s *fileStateStore) Put(key, value string) error {
+	s.values[key] = value
+	return nil
+}
+
+// Close saves the state file
+func (s *fileStateStore) Close() error {
+	return s.Flush()
+}
+
+// Flush saves the state file, replacing it atomically
+func (s *fileStateStore) Flush() error {
+	data, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// boltStateStore keeps the state of a stage in a bucket of a BoltDB file, committing puts
+// after each source member or stream message and in batches within large members
+type boltStateStore struct {
+	db      *bolt.DB
+	bucket  []byte
+	pending map[string]string
+}
+
+// openBoltStateStore opens the BoltDB file at path and creates the bucket of the stage
+func openBoltStateStore(path, stage string) (*boltStateStore, error) {
+	db, err := openBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &boltStateStore{db: db, bucket: []byte(stage), pending: make(map[string]string)}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(s.bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the value stored for key, including puts not committed yet
+func (s *boltStateStore) Get(key string) (string, bool, error) {
+	if value, ok := s.pending[key]; ok {
+		return value, true, nil
+	}
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(s.bucket).Get([]byte(key)); v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return string(value), value != nil, err
+}
+
+// Put buffers the value for key, committing the buffer once it is full
+func (s *boltStateStore) Put(key, value string) error {
+	s.pending[key] = value
+	if len(s.pending) >= boltBatchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush commits the buffered puts
+func (s *boltStateStore) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		for k, v := range s.pending {
+			if err := b.Put([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.pending = make(map[string]string)
+	return nil
+}
+
+// Close commits the buffered puts and closes the file
+func (s *boltStateStore) Close() error {
+	if err := s.Flush(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
+
+// openBolt opens a BoltDB file, failing if another process keeps it locked
+func openBolt(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: boltLockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("state store %s is locked by another process", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening state store %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// compactBoltState rewrites the BoltDB file at path without its free pages
+func compactBoltState(path string) error {
+	src, err := openBolt(path)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".compact"
+	dst, err := openBolt(tmp)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	err = bolt.Compact(dst, src, 64<<20)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	// Close the source before replacing it, as open files cannot be renamed over on Windows
+	if closeErr := src.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runStateCommand runs a "state" maintenance subcommand
+func runStateCommand(args []string) {
+	if len(args) != 2 || args[0] != "compact" {
+		log.Fatalf("usage: %s state compact <state.bolt>", os.Args[0])
+	}
+	if !isBoltStatePath(args[1]) {
+		log.Fatalf("error compacting state: %s is not a .bolt state store", args[1])
+	}
+
+	before, err := os.Stat(args[1])
+	if err != nil {
+		log.Fatalf("error compacting state: %v", err)
+	}
+	if err := compactBoltState(args[1]); err != nil {
+		log.Fatalf("error compacting state: %v", err)
+	}
+	after, err := os.Stat(args[1])
+	if err != nil {
+		log.Fatalf("error compacting state: %v", err)
+	}
+	fmt.Printf("Compacted %s from %d to %d bytes\n", args[1], before.Size(), after.Size())
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsBoltStatePath(t *testing.T) {
+	tests := map[string]bool{
+		"state.bolt":     true,
+		"dir/state.bolt": true,
+		// .db is left to JSON, as it is the usual SQLite extension
+		"state.db":        false,
+		"state.json":      false,
+		"state.bolt.json": false,
+		"state":           false,
+	}
+	for path, want := range tests {
+		if got := isBoltStatePath(path); got != want {
+			t.Errorf("isBoltStatePath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestStateStore(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{filepath.Join(dir, "state.json"), filepath.Join(dir, "state.bolt")} {
+		s, err := openStateStore(path, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("a", "1"); err != nil {
+			t.Fatal(err)
+		}
+		// Buffered values are visible before they are saved
+		if v, ok, err := s.Get("a"); v != "1" || !ok || err != nil {
+			t.Errorf("%s: Get before close = %q, %v, %v, want 1", path, v, ok, err)
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err = openStateStore(path, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok, err := s.Get("a"); v != "1" || !ok || err != nil {
+			t.Errorf("%s: Get after reopening = %q, %v, %v, want 1", path, v, ok, err)
+		}
+		if _, ok, err := s.Get("b"); ok || err != nil {
+			t.Errorf("%s: Get of a missing key = %v, %v, want not found", path, ok, err)
+		}
+		s.Close()
+	}
+}
+
+func TestFileStateStoreFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := openStateStore(path, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("a", "1")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// Flushed values survive a crash, which leaves the store unclosed
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != `{"a":"1"}` {
+		t.Errorf("state file after Flush = %s (%v), want {\"a\":\"1\"}", data, err)
+	}
+}
+
+func TestBoltStateStoreStages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bolt")
+	s, err := openStateStore(path, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("a", "1")
+	s.Close()
+
+	// Each stage has its own bucket in the shared file
+	s, err = openStateStore(path, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, ok, err := s.Get("a"); ok || err != nil {
+		t.Errorf("Get from another stage = %v, %v, want not found", ok, err)
+	}
+}
+
+func TestOpenFileStateStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("[1]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openStateStore(path, "delta"); err == nil {
+		t.Error("openStateStore of an invalid state file succeeded, want error")
+	}
+}
+
+func TestCompactBoltState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bolt")
+	s, err := openStateStore(path, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*boltBatchSize; i++ {
+		s.Put(fmt.Sprintf("key%d", i), "value")
+	}
+	s.Put("kept", "1")
+	s.Close()
+
+	if err := compactBoltState(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("compaction left its temporary file behind: %v", err)
+	}
+	s, err = openStateStore(path, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, ok, err := s.Get("kept"); v != "1" || !ok || err != nil {
+		t.Errorf("Get after compaction = %q, %v, %v, want 1", v, ok, err)
+	}
+}
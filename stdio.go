@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// dataOutput receives the data written by the stdout sink. Everything else the process
+// prints goes to stderr.
+var dataOutput io.Writer = os.Stdout
+
+// separateDataOutput makes the given file descriptor the only destination of data and
+// points os.Stdout at stderr, so that diagnostics printed by this program or by a
+// dependency can never interleave with the data. The data descriptor defaults to stdout (1);
+// another descriptor lets stdout stay free for other use, as in "3>data.ndjson".
+func separateDataOutput(fd int) error {
+	data := os.Stdout
+	if fd != 1 {
+		if fd < 0 || fd == 2 {
+			return fmt.Errorf("invalid data file descriptor %d", fd)
+		}
+		data = os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+		if data == nil {
+			return fmt.Errorf("invalid data file descriptor %d", fd)
+		}
+		if _, err := data.Stat(); err != nil {
+			return fmt.Errorf("data file descriptor %d is not open: %v", fd, err)
+		}
+	}
+	dataOutput = data
+	os.Stdout = os.Stderr
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSeparateDataOutput(t *testing.T) {
+	stdout, data := os.Stdout, dataOutput
+	defer func() { os.Stdout, dataOutput = stdout, data }()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	os.Stdout = w
+	if err := separateDataOutput(1); err != nil {
+		t.Fatal(err)
+	}
+	if dataOutput != w || os.Stdout != os.Stderr {
+		t.Errorf("data output was not separated from os.Stdout")
+	}
+	if err := (stdoutSink{format: "ndjson"}).Write(Output{{"id": "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	got, _ := io.ReadAll(r)
+	if want := `[{"id":"1"}]` + "\n"; string(got) != want {
+		t.Errorf("data output = %q, want %q", got, want)
+	}
+
+	for _, fd := range []int{-1, 2} {
+		if err := separateDataOutput(fd); err == nil {
+			t.Errorf("separateDataOutput(%d) succeeded, want error", fd)
+		}
+	}
+}
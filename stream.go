@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// streamTransform transforms a JSON object read from r one top-level entry at a time,
+// writing each transformed entry to w as an element of the output array as soon as it is
+// decoded, so that only the largest entry is ever held in memory rather than the whole
+// document. Entries are written in input order, which differs from the key order of
+// Transform unless the document's keys are sorted. The problems of a strict Transformer are
+// collected from every entry into a single *transform.StrictError.
+func streamTransform(t *transform.Transformer, r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("expected a JSON object, found %v", tok)
+	}
+
+	var problems []string
+	written := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+
+		output, err := t.Transform(Input{key: value})
+		var strictErr *transform.StrictError
+		if errors.As(err, &strictErr) {
+			problems = append(problems, strictErr.Problems...)
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, m := range output {
+			data, err := json.MarshalIndent(m, "  ", "  ")
+			if err != nil {
+				return fmt.Errorf("encoding output JSON: %v", err)
+			}
+			sep := ",\n  "
+			if written == 0 {
+				sep = "[\n  "
+			}
+			if _, err := fmt.Fprintf(w, "%s%s", sep, data); err != nil {
+				return err
+			}
+			written++
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	end := "\n]\n"
+	if written == 0 {
+		end = "[]\n"
+	}
+	if _, err := io.WriteString(w, end); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &transform.StrictError{Problems: problems}
+	}
+	return nil
+}
+
+// runStream streams the JSON object of an input file or stdin through streamTransform to
+// the data output
+func runStream(t *transform.Transformer, inputURI string) error {
+	var r io.Reader = os.Stdin
+	if inputURI != "" && inputURI != "-" {
+		f, err := os.Open(inputURI)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return streamTransform(t, r, dataOutput)
+}
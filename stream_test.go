@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+func TestStreamTransform(t *testing.T) {
+	doc := `{"b": " x ", "a": {"n": {"N": "1"}, "s": " y "}, "c": [], "d": "2024-01-01T00:00:00Z"}`
+	var out bytes.Buffer
+	if err := streamTransform(&transform.Transformer{}, strings.NewReader(doc), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "b": "x"
+  },
+  {
+    "n": 1,
+    "s": "y"
+  },
+  {
+    "d": 1704067200
+  }
+]
+`
+	if out.String() != want {
+		t.Errorf("streamTransform wrote\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := streamTransform(&transform.Transformer{}, strings.NewReader(`{}`), &out); err != nil || out.String() != "[]\n" {
+		t.Errorf("streamTransform({}) = %q, %v", out.String(), err)
+	}
+
+	var strictErr *transform.StrictError
+	err := streamTransform(&transform.Transformer{Strict: true}, strings.NewReader(`{"a": 1, "b": "x", "c": true}`), &out)
+	if !errors.As(err, &strictErr) || len(strictErr.Problems) != 2 {
+		t.Errorf("strict streamTransform error = %v, want 2 problems", err)
+	}
+
+	for _, doc := range []string{`[1]`, `{"a": }`, `{"a": "1"`} {
+		if err := streamTransform(&transform.Transformer{}, strings.NewReader(doc), &out); err == nil {
+			t.Errorf("streamTransform(%s) succeeded, want error", doc)
+		}
+	}
+}
+
+// TestStreamTransformOrder compares streamTransform with the whole document transform,
+// which sorts the output by key where the stream keeps the input order. The fields of
+// nested objects are hoisted into their element, so the first document names them to sort
+// in place.
+func TestStreamTransformOrder(t *testing.T) {
+	tests := []struct {
+		doc  string
+		same bool
+	}{
+		{`{"a": " x ", "b": {"bn": {"N": "1"}}, "c": "2024-01-01T00:00:00Z", "d": ["1"]}`, true},
+		{`{"d": ["1"], "a": " x ", "c": "2024-01-01T00:00:00Z", "b": {"bn": {"N": "1"}}}`, false},
+	}
+	for _, tt := range tests {
+		var streamed bytes.Buffer
+		if err := streamTransform(&transform.Transformer{}, strings.NewReader(tt.doc), &streamed); err != nil {
+			t.Fatal(err)
+		}
+		var input Input
+		if err := json.Unmarshal([]byte(tt.doc), &input); err != nil {
+			t.Fatal(err)
+		}
+		output, err := (&transform.Transformer{}).Transform(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		whole, err := encodeOutput(output, "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := bytes.Equal(streamed.Bytes(), whole); same != tt.same {
+			t.Errorf("streamTransform(%s) =\n%s\nwhole document transform =\n%s\nwant equal %v", tt.doc, streamed.Bytes(), whole, tt.same)
+		}
+
+		// Either way the elements are the same
+		var got, want []map[string]interface{}
+		if err := json.Unmarshal(streamed.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(whole, &want); err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(got, func(i, j int) bool { return fmt.Sprint(got[i]) < fmt.Sprint(got[j]) })
+		sort.Slice(want, func(i, j int) bool { return fmt.Sprint(want[i]) < fmt.Sprint(want[j]) })
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("streamTransform(%s) elements = %v, want %v", tt.doc, got, want)
+		}
+	}
+}
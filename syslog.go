@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// syslogNil is the RFC 5424 NILVALUE used for absent header fields
+const syslogNil = "-"
+
+// parseSyslog parses an RFC 5424 syslog line into a record
+func parseSyslog(line string) (Input, error) {
+	record := make(Input)
+
+	// Parse the PRI part, e.g. "<165>"
+	if !strings.HasPrefix(line, "<") {
+		return nil, errors.New("missing PRI")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return nil, errors.New("invalid PRI")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return nil, fmt.Errorf("invalid PRI %q", line[1:end])
+	}
+	record["facility"] = strconv.Itoa(pri / 8)
+	record["severity"] = strconv.Itoa(pri % 8)
+	rest := line[end+1:]
+
+	// Parse the space separated header fields
+	headers := []string{"version", "timestamp", "hostname", "app_name", "procid", "msgid"}
+	for _, name := range headers {
+		var field string
+		field, rest = nextSyslogField(rest)
+		if field == "" {
+			return nil, fmt.Errorf("missing %s", name)
+		}
+		if field != syslogNil {
+			record[name] = field
+		}
+	}
+	if record["version"] != "1" {
+		return nil, fmt.Errorf("unsupported version %v", record["version"])
+	}
+
+	// Parse the structured data, which is either NILVALUE or one or more SD-ELEMENTs
+	if strings.HasPrefix(rest, syslogNil) {
+		rest = rest[len(syslogNil):]
+	} else {
+		sd, remainder, err := parseStructuredData(rest)
+		if err != nil {
+			return nil, err
+		}
+		record["structured_data"] = sd
+		rest = remainder
+	}
+
+	// Whatever follows is the free-form message, optionally prefixed with a BOM
+	if rest != "" {
+		if rest[0] != ' ' {
+			return nil, errors.New("malformed structured data")
+		}
+		msg := strings.TrimPrefix(rest[1:], "\ufeff")
+		if msg != "" {
+			record["message"] = msg
+		}
+	}
+
+	return record, nil
+}
+
+// nextSyslogField returns the next space terminated header field and the remainder
+func nextSyslogField(s string) (string, string) {
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// parseStructuredData parses consecutive SD-ELEMENTs into a map keyed by SD-ID
+func parseStructuredData(s string) (map[string]interface{}, string, error) {
+	sd := make(map[string]interface{})
+
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+
+		// Read the SD-ID
+		i := strings.IndexAny(s, " ]")
+		if i <= 0 {
+			return nil, "", errors.New("malformed structured data")
+		}
+		id := s[:i]
+		s = s[i:]
+
+		// Read SD-PARAMs of the form name="value" until the closing bracket
+		params := make(map[string]interface{})
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, "=\"")
+			if eq <= 0 {
+				return nil, "", fmt.Errorf("malformed parameter in %q", id)
+			}
+			name := s[:eq]
+			value, n, err := readSyslogParamValue(s[eq+2:])
+			if err != nil {
+				return nil, "", fmt.Errorf("parameter %q in %q: %v", name, id, err)
+			}
+			params[name] = value
+			s = s[eq+2+n:]
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("unterminated element %q", id)
+		}
+		s = s[1:]
+		sd[id] = params
+	}
+
+	if len(sd) == 0 {
+		return nil, "", errors.New("malformed structured data")
+	}
+	return sd, s, nil
+}
+
+// readSyslogParamValue reads an escaped parameter value up to and including its
+// closing quote, returning the unescaped value and the number of bytes consumed
+func readSyslogParamValue(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			// Only '"', '\' and ']' are escaped, anything else is kept verbatim
+			if i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+				i++
+				b.WriteByte(s[i])
+			} else {
+				b.WriteByte(c)
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated value")
+}
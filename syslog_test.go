@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSyslog(t *testing.T) {
+	tests := []struct {
+		line string
+		want Input
+	}{
+		{
+			line: `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed`,
+			want: Input{
+				"facility": "4", "severity": "2", "version": "1",
+				"timestamp": "2003-10-11T22:14:15.003Z", "hostname": "mymachine.example.com",
+				"app_name": "su", "msgid": "ID47", "message": "'su root' failed",
+			},
+		},
+		{
+			line: `<165>1 2003-10-11T22:14:15.003Z host evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"][examplePriority@32473 class="high"] ` + "\ufeff" + `An application event`,
+			want: Input{
+				"facility": "20", "severity": "5", "version": "1",
+				"timestamp": "2003-10-11T22:14:15.003Z", "hostname": "host",
+				"app_name": "evntslog", "msgid": "ID47", "message": "An application event",
+				"structured_data": map[string]interface{}{
+					"exampleSDID@32473":     map[string]interface{}{"iut": "3", "eventSource": "Application"},
+					"examplePriority@32473": map[string]interface{}{"class": "high"},
+				},
+			},
+		},
+		{
+			// Only '"', '\' and ']' are escaped in SD-PARAM values
+			line: `<13>1 - - - - - [x@1 a="say \"hi\"" b="c:\\d" c="[1\]" d="a\nb"]`,
+			want: Input{
+				"facility": "1", "severity": "5", "version": "1",
+				"structured_data": map[string]interface{}{
+					"x@1": map[string]interface{}{"a": `say "hi"`, "b": `c:\d`, "c": "[1]", "d": `a\nb`},
+				},
+			},
+		},
+		{
+			line: `<0>1 - - - 1234 - [empty@1]`,
+			want: Input{
+				"facility": "0", "severity": "0", "version": "1", "procid": "1234",
+				"structured_data": map[string]interface{}{"empty@1": map[string]interface{}{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseSyslog(tt.line)
+		if err != nil {
+			t.Errorf("parseSyslog(%q) error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSyslog(%q) = %#v, want %#v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseSyslogErrors(t *testing.T) {
+	for _, line := range []string{
+		`34>1 - - - - - -`,
+		`<192>1 - - - - - -`,
+		`<34>2 - - - - - -`,
+		`<34>1 - - -`,
+		`<34>1 - - - - - [x@1 a="unterminated]`,
+		`<34>1 - - - - - [x@1 a=b]`,
+		`<34>1 - - - - - [x@1`,
+		`<34>1 - - - - - [x@1]msg`,
+	} {
+		if _, err := parseSyslog(line); err == nil {
+			t.Errorf("parseSyslog(%q) succeeded, want error", line)
+		}
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		line string
+		want Input
+	}{
+		{`level=info msg=started port=8080`, Input{"level": "info", "msg": "started", "port": "8080"}},
+		{`msg="hello \"world\"" path="C:\\tmp"`, Input{"msg": `hello "world"`, "path": `C:\tmp`}},
+		{"debug  \tempty= x=1", Input{"debug": "true", "empty": "", "x": "1"}},
+	}
+	for _, tt := range tests {
+		got, err := parseLogfmt(tt.line)
+		if err != nil {
+			t.Errorf("parseLogfmt(%q) error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLogfmt(%q) = %#v, want %#v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{`=value`, `msg="unterminated`, `   `} {
+		if _, err := parseLogfmt(line); err == nil {
+			t.Errorf("parseLogfmt(%q) succeeded, want error", line)
+		}
+	}
+}
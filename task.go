@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// taskSpec is the task run by -task-json, as passed by an orchestrator such as Airflow or
+// Dagster. Each field sets the flag of the same meaning unless it is given on the command line.
+//
+//	{"input": "data.tar.gz", "output": "s3://bucket/out?batch=1000", "profile": "stripe",
+//	 "limits": {"max_records": 100000, "timeout": "10m"}}
+type taskSpec struct {
+	Input           string     `json:"input,omitempty"`
+	Output          string     `json:"output,omitempty"`
+	InputFormat     string     `json:"input_format,omitempty"`
+	OutputFormat    string     `json:"output_format,omitempty"`
+	Profile         string     `json:"profile,omitempty"`
+	Rules           string     `json:"rules,omitempty"`
+	Manifest        string     `json:"manifest,omitempty"`
+	CoverageReport  string     `json:"coverage_report,omitempty"`
+	Coerce          string     `json:"coerce,omitempty"`
+	Compat          string     `json:"compat,omitempty"`
+	Locale          string     `json:"locale,omitempty"`
+	Order           string     `json:"order,omitempty"`
+	Emit            string     `json:"emit,omitempty"`
+	WarnAsError     string     `json:"warn_as_error,omitempty"`
+	SuppressWarning string     `json:"suppress_warning,omitempty"`
+	Strict          bool       `json:"strict,omitempty"`
+	Workers         int        `json:"workers,omitempty"`
+	Limits          taskLimits `json:"limits,omitzero"`
+}
+
+// taskLimits are the limits beyond which a task fails
+type taskLimits struct {
+	MaxRecords int    `json:"max_records,omitempty"`
+	Timeout    string `json:"timeout,omitempty"`
+}
+
+// loadTaskSpec reads a task spec from a file, or from an environment variable given as
+// "env:NAME"
+func loadTaskSpec(source string) (*taskSpec, error) {
+	var data []byte
+	if name, ok := strings.CutPrefix(source, "env:"); ok {
+		data = []byte(os.Getenv(name))
+		if len(data) == 0 {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+	return parseTaskSpec(data)
+}
+
+// parseTaskSpec parses and validates a task spec
+func parseTaskSpec(data []byte) (*taskSpec, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var spec taskSpec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid task spec: %v", err)
+	}
+	if spec.Workers < 0 {
+		return nil, fmt.Errorf("invalid task spec: negative workers")
+	}
+	if spec.Limits.MaxRecords < 0 {
+		return nil, fmt.Errorf("invalid task spec: negative max_records")
+	}
+	if _, err := spec.timeout(); err != nil {
+		return nil, err
+	}
+	if _, ok := presets[spec.Profile]; spec.Profile != "" && !ok {
+		return nil, fmt.Errorf("invalid task spec: unknown profile %q (available: %s)", spec.Profile, presetNames())
+	}
+	return &spec, nil
+}
+
+// timeout returns the time limit of the task, or 0 for none
+func (s *taskSpec) timeout() (time.Duration, error) {
+	if s.Limits.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s.Limits.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid task spec: invalid timeout %q", s.Limits.Timeout)
+	}
+	return d, nil
+}
+
+// stringFlags returns the string fields of the task spec by the name of the flag they set
+func (s *taskSpec) stringFlags() map[string]*string {
+	return map[string]*string{
+		"input":            &s.Input,
+		"output":           &s.Output,
+		"input-format":     &s.InputFormat,
+		"output-format":    &s.OutputFormat,
+		"preset":           &s.Profile,
+		"rules":            &s.Rules,
+		"manifest":         &s.Manifest,
+		"coverage-report":  &s.CoverageReport,
+		"coerce":           &s.Coerce,
+		"compat":           &s.Compat,
+		"locale":           &s.Locale,
+		"order":            &s.Order,
+		"emit":             &s.Emit,
+		"warn-as-error":    &s.WarnAsError,
+		"suppress-warning": &s.SuppressWarning,
+	}
+}
+
+// flags returns the values the task spec gives to command-line flags
+func (s *taskSpec) flags() map[string]string {
+	values := make(map[string]string)
+	for name, v := range s.stringFlags() {
+		if *v != "" {
+			values[name] = *v
+		}
+	}
+	if s.Strict {
+		values["strict"] = "true"
+	}
+	if s.Workers > 0 {
+		values["workers"] = strconv.Itoa(s.Workers)
+	}
+	return values
+}
+
+// taskSpecFromFlags returns the task spec equivalent to the command-line flags of a run, for
+// moving a command line to declarative configuration. Flags the task spec has no field for
+// are an error.
+func taskSpecFromFlags(args []string) (*taskSpec, error) {
+	spec := &taskSpec{}
+	fs := flag.NewFlagSet("flags", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for name, v := range spec.stringFlags() {
+		fs.StringVar(v, name, "", "")
+	}
+	fs.BoolVar(&spec.Strict, "strict", false, "")
+	fs.IntVar(&spec.Workers, "workers", 0, "")
+	if err := fs.Parse(args); err != nil {
+		if name, ok := strings.CutPrefix(err.Error(), "flag provided but not defined: "); ok {
+			return nil, fmt.Errorf("%s has no task spec equivalent", name)
+		}
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	// Check the spec the way -task-json does
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return parseTaskSpec(data)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTaskSpec(t *testing.T) {
+	spec, err := parseTaskSpec([]byte(`{"input": "in.tar", "output": "s3://b/p", "limits": {"max_records": 10, "timeout": "90s"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"input": "in.tar", "output": "s3://b/p"}
+	if got := spec.flags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("flags() = %v, want %v", got, want)
+	}
+	if d, _ := spec.timeout(); d.Seconds() != 90 || spec.Limits.MaxRecords != 10 {
+		t.Errorf("limits = %v, %d", d, spec.Limits.MaxRecords)
+	}
+
+	for _, spec := range []string{
+		`{"inputs": "in.tar"}`,
+		`{"profile": "nope"}`,
+		`{"limits": {"timeout": "soon"}}`,
+		`{"limits": {"max_records": -1}}`,
+		`[]`,
+	} {
+		if _, err := parseTaskSpec([]byte(spec)); err == nil {
+			t.Errorf("parseTaskSpec(%s) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestLoadTaskSpecEnv(t *testing.T) {
+	t.Setenv("TASK_SPEC", `{"input": "a.json", "rules": "rules.json"}`)
+	spec, err := loadTaskSpec("env:TASK_SPEC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Input != "a.json" || spec.Rules != "rules.json" {
+		t.Errorf("spec = %+v", spec)
+	}
+	if _, err := loadTaskSpec("env:TASK_SPEC_UNSET"); err == nil {
+		t.Error("loading an unset variable succeeded")
+	}
+}
+
+func TestTaskSpecFromFlags(t *testing.T) {
+	spec, err := taskSpecFromFlags([]string{"-input", "in.tar", "-preset", "stripe", "-coerce=number,string", "--compat", "v1", "-strict", "-workers", "4", "-warn-as-error", "rule:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"input":         "in.tar",
+		"preset":        "stripe",
+		"coerce":        "number,string",
+		"compat":        "v1",
+		"strict":        "true",
+		"workers":       "4",
+		"warn-as-error": "rule:*",
+	}
+	if got := spec.flags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("flags() = %v, want %v", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-stream"},
+		{"-preset", "nope"},
+		{"-workers", "-1"},
+		{"in.json"},
+	} {
+		if _, err := taskSpecFromFlags(args); err == nil {
+			t.Errorf("taskSpecFromFlags(%q) succeeded, want error", args)
+		}
+	}
+}
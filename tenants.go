@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// tenantsFile is the -tenants file of the serve subcommand, giving the named configurations
+// a server hosts so that one deployment serves several teams:
+//
+//	{"tenants": [{"name": "billing", "api_key": "env:BILLING_KEY", "preset": "stripe",
+//	  "rules": "billing.json", "output": "sqlite:billing.db?table=charges",
+//	  "limits": {"max_concurrent": 4, "max_body": 1048576}}]}
+type tenantsFile struct {
+	Tenants []tenantConfig `json:"tenants"`
+}
+
+// tenantConfig is the configuration of a tenant. Fields left out take the value of the
+// serve flag of the same meaning.
+type tenantConfig struct {
+	Name string `json:"name"`
+	// APIKey is the key requests for the tenant carry, or env:NAME for an environment variable
+	APIKey       string       `json:"api_key,omitempty"`
+	Preset       string       `json:"preset,omitempty"`
+	Rules        string       `json:"rules,omitempty"`
+	Coerce       string       `json:"coerce,omitempty"`
+	Compat       string       `json:"compat,omitempty"`
+	Strict       bool         `json:"strict,omitempty"`
+	Overrides    string       `json:"overrides,omitempty"`
+	Output       string       `json:"output,omitempty"`
+	OutputFormat string       `json:"output_format,omitempty"`
+	Limits       tenantLimits `json:"limits,omitzero"`
+}
+
+// tenantLimits are the limits of a tenant's requests, apart from those of other tenants
+type tenantLimits struct {
+	MaxConcurrent int   `json:"max_concurrent,omitempty"`
+	MaxQueue      int   `json:"max_queue,omitempty"`
+	MaxBody       int64 `json:"max_body,omitempty"`
+	MemoryBudget  int64 `json:"memory_budget,omitempty"`
+}
+
+// serveDefaults are the serve flags tenants take the values of limits they leave out from,
+// and the settings of the circuit breakers of their outputs
+type serveDefaults struct {
+	maxConcurrent, maxQueue int
+	maxBody, memoryBudget   int64
+	memoryWait              time.Duration
+	breakerThreshold        float64
+	breakerWindow           int
+	breakerCooldown         time.Duration
+}
+
+// loadTenants reads and validates a -tenants file
+func loadTenants(path string) ([]tenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var file tenantsFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid tenants file: %v", err)
+	}
+	if len(file.Tenants) == 0 {
+		return nil, fmt.Errorf("invalid tenants file: no tenants")
+	}
+
+	names := make(map[string]bool)
+	keys := make(map[string]string)
+	for i, t := range file.Tenants {
+		if t.Name == "" || strings.ContainsAny(t.Name, "/?#") {
+			return nil, fmt.Errorf("invalid tenants file: tenant %d has an invalid name %q", i+1, t.Name)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("invalid tenants file: duplicate tenant %q", t.Name)
+		}
+		names[t.Name] = true
+		if name, ok := strings.CutPrefix(t.APIKey, "env:"); ok {
+			if file.Tenants[i].APIKey = os.Getenv(name); file.Tenants[i].APIKey == "" {
+				return nil, fmt.Errorf("tenant %q: environment variable %s is not set", t.Name, name)
+			}
+		}
+		if key := file.Tenants[i].APIKey; key != "" {
+			if other, ok := keys[key]; ok {
+				return nil, fmt.Errorf("invalid tenants file: tenants %q and %q share an API key", other, t.Name)
+			}
+			keys[key] = t.Name
+		}
+		if l := t.Limits; l.MaxConcurrent < 0 || l.MaxQueue < 0 || l.MaxBody < 0 || l.MemoryBudget < 0 {
+			return nil, fmt.Errorf("invalid tenants file: tenant %q has negative limits", t.Name)
+		}
+		if _, ok := presets[t.Preset]; t.Preset != "" && !ok {
+			return nil, fmt.Errorf("invalid tenants file: tenant %q has unknown preset %q (available: %s)", t.Name, t.Preset, presetNames())
+		}
+		if err := transform.ValidateCompat(t.Compat); err != nil {
+			return nil, fmt.Errorf("invalid tenants file: tenant %q: %v", t.Name, err)
+		}
+		if _, err := parseOverrides(t.Overrides); err != nil {
+			return nil, fmt.Errorf("invalid tenants file: tenant %q: overrides: %v", t.Name, err)
+		}
+	}
+	return file.Tenants, nil
+}
+
+// withDefaults returns the configuration with the serve flags in place of the fields it
+// leaves out
+func (c tenantConfig) withDefaults(preset, rules, coerce, compat, overrides string, strict bool) tenantConfig {
+	if c.Preset == "" {
+		c.Preset = preset
+	}
+	if c.Rules == "" {
+		c.Rules = rules
+	}
+	if c.Coerce == "" {
+		c.Coerce = coerce
+	}
+	if c.Compat == "" {
+		c.Compat = compat
+	}
+	if c.Overrides == "" {
+		c.Overrides = overrides
+	}
+	c.Strict = c.Strict || strict
+	return c
+}
+
+// tenant is a configuration hosted by a server, with the handler of its requests
+type tenant struct {
+	name    string
+	apiKey  string
+	handler *transformHandler
+}
+
+// newTenant builds the transformer, limits and sink of a tenant's configuration
+func newTenant(c tenantConfig, defaults serveDefaults) (*tenant, error) {
+	tr := &transform.Transformer{Strict: c.Strict, Compat: c.Compat, OnWarning: printWarning, TypedTimestamps: c.OutputFormat == "ejson"}
+	var err error
+	if c.Coerce != "" {
+		if tr.Coercions, err = transform.ParseCoercionOrder(c.Coerce); err != nil {
+			return nil, fmt.Errorf("tenant %q: %v", c.Name, err)
+		}
+	}
+	if c.Rules != "" {
+		if tr.Rules, err = transform.LoadRules(c.Rules); err != nil {
+			return nil, fmt.Errorf("tenant %q: loading rules: %v", c.Name, err)
+		}
+	}
+
+	limits := c.Limits
+	if limits.MaxConcurrent == 0 {
+		limits.MaxConcurrent = defaults.maxConcurrent
+	}
+	if limits.MaxQueue == 0 {
+		limits.MaxQueue = defaults.maxQueue
+	}
+	if limits.MaxBody == 0 {
+		limits.MaxBody = defaults.maxBody
+	}
+	if limits.MemoryBudget == 0 {
+		limits.MemoryBudget = defaults.memoryBudget
+	}
+	overrides, err := parseOverrides(c.Overrides)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %q: overrides: %v", c.Name, err)
+	}
+	h := &transformHandler{
+		tr:         tr,
+		presetName: c.Preset,
+		maxBody:    limits.MaxBody,
+		admission:  newAdmission(limits.MaxConcurrent, limits.MaxQueue),
+		budget:     newMemoryBudget(limits.MemoryBudget, defaults.memoryWait),
+		overrides:  overrides,
+	}
+	if c.Output != "" {
+		if c.Output == "-" {
+			return nil, fmt.Errorf("tenant %q: output must not be stdout", c.Name)
+		}
+		s, err := openSink(c.Output, c.OutputFormat)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %v", c.Name, err)
+		}
+		if defaults.breakerThreshold > 0 {
+			b := newBreaker(defaults.breakerThreshold, defaults.breakerWindow, defaults.breakerCooldown)
+			b.publish(c.Name)
+			s = &breakerSink{next: s, breaker: b, uri: c.Output}
+		}
+		h.sink = &sharedSink{next: s}
+	}
+	return &tenant{name: c.Name, apiKey: c.APIKey, handler: h}, nil
+}
+
+// sharedSink serializes the writes of concurrent requests to a sink
+type sharedSink struct {
+	mu   sync.Mutex
+	next sink
+}
+
+// Write writes the outputs of a request and flushes them
+func (s *sharedSink) Write(outputs []Output) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, output := range outputs {
+		if err := s.next.Write(output); err != nil {
+			return err
+		}
+	}
+	if f, ok := s.next.(flushSink); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// tenantRouter routes requests to the tenant named by their URL prefix,
+// /t/{name}/transform, or holding the API key they carry on /transform
+type tenantRouter struct {
+	tenants map[string]*tenant
+}
+
+// newTenantRouter routes requests to tenants
+func newTenantRouter(tenants []*tenant) *tenantRouter {
+	r := &tenantRouter{tenants: make(map[string]*tenant, len(tenants))}
+	for _, t := range tenants {
+		r.tenants[t.name] = t
+	}
+	return r
+}
+
+// requestAPIKey returns the API key of a request, from an X-API-Key header or a bearer token
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return ""
+}
+
+// ServeHTTP serves a request with the handler of its tenant. Requests for a tenant with an
+// API key must carry it, and fail with 401 otherwise; requests for unknown tenants fail
+// with 404.
+func (tr *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := requestAPIKey(r)
+	var t *tenant
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/t/"); ok {
+		name, path, _ := strings.Cut(rest, "/")
+		if t = tr.tenants[name]; t == nil || path != "transform" {
+			writeError(w, http.StatusNotFound, "unknown tenant", nil)
+			return
+		}
+		if t.apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(t.apiKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid API key", nil)
+			return
+		}
+	} else {
+		for _, candidate := range tr.tenants {
+			if candidate.apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(candidate.apiKey)) == 1 {
+				t = candidate
+			}
+		}
+		if t == nil {
+			writeError(w, http.StatusUnauthorized, "invalid API key", nil)
+			return
+		}
+	}
+	t.handler.ServeHTTP(w, r)
+}
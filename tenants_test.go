@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTenants(t *testing.T) {
+	dir := t.TempDir()
+	load := func(data string) error {
+		path := filepath.Join(dir, "tenants.json")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadTenants(path)
+		return err
+	}
+
+	t.Setenv("BILLING_KEY", "secret")
+	if err := load(`{"tenants": [{"name": "billing", "api_key": "env:BILLING_KEY", "preset": "stripe", "limits": {"max_body": 1024}}, {"name": "ops"}]}`); err != nil {
+		t.Errorf("valid tenants: %v", err)
+	}
+	for _, data := range []string{
+		`{"tenants": []}`,
+		`{"tenants": [{"name": ""}]}`,
+		`{"tenants": [{"name": "a/b"}]}`,
+		`{"tenants": [{"name": "a"}, {"name": "a"}]}`,
+		`{"tenants": [{"name": "a", "api_key": "k"}, {"name": "b", "api_key": "k"}]}`,
+		`{"tenants": [{"name": "a", "api_key": "env:UNSET_TENANT_KEY"}]}`,
+		`{"tenants": [{"name": "a", "preset": "nope"}]}`,
+		`{"tenants": [{"name": "a", "compat": "v0"}]}`,
+		`{"tenants": [{"name": "a", "limits": {"max_body": -1}}]}`,
+		`{"tenants": [{"name": "a", "limits": {"memory_budget": -1}}]}`,
+		`{"tenants": [{"name": "a", "limits": {"max_queue": -1}}]}`,
+		`{"tenants": [{"name": "a", "unknown": true}]}`,
+	} {
+		if err := load(data); err == nil {
+			t.Errorf("loadTenants(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestTenantRouter(t *testing.T) {
+	defaults := serveDefaults{maxConcurrent: 1, maxQueue: 1, maxBody: 1 << 10}
+	billing, err := newTenant(tenantConfig{Name: "billing", APIKey: "secret", Coerce: "number", Limits: tenantLimits{MaxBody: 32}}, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collected := &collectSink{}
+	billing.handler.sink = &sharedSink{next: collected}
+	ops, err := newTenant(tenantConfig{Name: "ops"}, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := newTenantRouter([]*tenant{billing, ops})
+
+	tests := []struct {
+		path, key, body string
+		status          int
+		want            string
+	}{
+		{"/transform", "secret", `{"n": "42"}`, 200, `[[{"n":42}]]`},
+		{"/t/billing/transform", "secret", `{"n": "42"}`, 200, `[[{"n":42}]]`},
+		{"/t/ops/transform", "", `{"n": " 42 "}`, 200, `[[{"n":"42"}]]`},
+		{"/transform", "", `{"n": "42"}`, 401, `{"error":"invalid API key"}`},
+		{"/transform", "wrong", `{"n": "42"}`, 401, `{"error":"invalid API key"}`},
+		{"/t/billing/transform", "", `{"n": "42"}`, 401, `{"error":"invalid API key"}`},
+		{"/t/nope/transform", "", `{"n": "42"}`, 404, `{"error":"unknown tenant"}`},
+		{"/t/ops/other", "", `{"n": "42"}`, 404, `{"error":"unknown tenant"}`},
+		{"/t/ops/transform", "", `null`, 400, `{"error":"body is not a JSON object"}`},
+		// Each tenant has its own limits
+		{"/t/billing/transform", "secret", `{"n": "` + strings.Repeat("4", 64) + `"}`, 413, `{"error":"body exceeds 32 bytes"}`},
+		{"/t/ops/transform", "", `{"n": "` + strings.Repeat("4", 64) + `"}`, 200, ``},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		got := strings.TrimSpace(w.Body.String())
+		if w.Code != tt.status || (tt.want != "" && got != tt.want) {
+			t.Errorf("POST %s with key %q = %d %s, want %d %s", tt.path, tt.key, w.Code, got, tt.status, tt.want)
+		}
+	}
+
+	// The billing tenant's outputs were also written to its sink
+	if len(collected.outputs) != 2 {
+		t.Errorf("sink was written %d outputs, want 2", len(collected.outputs))
+	}
+}
+
+func TestTenantWithDefaults(t *testing.T) {
+	c := tenantConfig{Name: "billing", Preset: "stripe", Coerce: "number"}.withDefaults("", "rules.json", "timestamp", "v1", "strict", true)
+	want := tenantConfig{Name: "billing", Preset: "stripe", Rules: "rules.json", Coerce: "number", Compat: "v1", Overrides: "strict", Strict: true}
+	if c != want {
+		t.Errorf("withDefaults = %+v, want %+v", c, want)
+	}
+}
+
+// failingSink fails every write
+type failingSink struct{}
+
+func (failingSink) Write(Output) error { return errors.New("sink down") }
+func (failingSink) Close() error       { return nil }
+
+func TestTenantSinkError(t *testing.T) {
+	ops, err := newTenant(tenantConfig{Name: "ops"}, serveDefaults{maxConcurrent: 1, maxBody: 1 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops.handler.sink = &sharedSink{next: failingSink{}}
+	req := httptest.NewRequest(http.MethodPost, "/t/ops/transform", strings.NewReader(`{"n": "1"}`))
+	req.Header.Set("X-API-Key", "ignored")
+	w := httptest.NewRecorder()
+	newTenantRouter([]*tenant{ops}).ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("request with a failing sink = %d, want 502", w.Code)
+	}
+}
+
+func TestTenantSinkBreaker(t *testing.T) {
+	defaults := serveDefaults{maxConcurrent: 1, maxBody: 1 << 10, breakerThreshold: 1, breakerWindow: 1, breakerCooldown: time.Minute}
+	output := "sqlite:" + filepath.Join(t.TempDir(), "out.db") + "?table=records"
+	ops, err := newTenant(tenantConfig{Name: "ops", Output: output}, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ops.handler.sink.next.(*breakerSink); !ok {
+		t.Fatalf("tenant sink is %T, want a breaker", ops.handler.sink.next)
+	}
+	// The breaker's state is published in /debug/vars under the tenant's name
+	if v := circuitBreakers.Get("ops"); v == nil || !strings.Contains(v.String(), `"state":"closed"`) {
+		t.Errorf("circuit_breakers ops = %v, want a closed breaker", v)
+	}
+
+	ops.handler.sink = &sharedSink{next: &breakerSink{next: failingSink{}, breaker: newBreaker(1, 1, time.Minute), uri: "redis://cache/0"}}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		newTenantRouter([]*tenant{ops}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/t/ops/transform", strings.NewReader(`{"n": "1"}`)))
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d with a skipped output = %d, want 503 with Retry-After", i+1, w.Code)
+		}
+	}
+}
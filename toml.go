@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlTimeLayouts are the layouts of TOML's local dates and times, by the name of the
+// location the decoder gives them
+var tomlTimeLayouts = map[string]string{
+	"datetime-local": "2006-01-02T15:04:05.999999999",
+	"date-local":     "2006-01-02",
+	"time-local":     "15:04:05.999999999",
+}
+
+// readTOML reads a TOML document as a single record. Numbers and booleans become strings
+// like the rest of the input, and dates and times RFC3339 strings, or for local ones the
+// strings they are written as
+func readTOML(r io.Reader) ([]Input, error) {
+	var doc map[string]interface{}
+	if _, err := toml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return []Input{tomlToJSON(doc).(map[string]interface{})}, nil
+}
+
+// tomlToJSON converts a decoded TOML value into a record value, with scalars as strings
+func tomlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = tomlToJSON(e)
+		}
+		return v
+	case []map[string]interface{}:
+		// Arrays of tables
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = tomlToJSON(e)
+		}
+		return list
+	case []interface{}:
+		for i, e := range v {
+			v[i] = tomlToJSON(e)
+		}
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Sprint(v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if layout, ok := tomlTimeLayouts[v.Location().String()]; ok {
+			return v.Format(layout)
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// encodeTOML encodes the output of a record as an entry of the array of tables "records",
+// so that the entries of successive records form a single TOML document. The maps of the
+// output are merged into the entry, with sorted keys, and null values are left out as TOML
+// has no null.
+func encodeTOML(output Output) ([]byte, error) {
+	// Round trip through JSON for the values of passthrough subtrees
+	jsonData, err := json.Marshal(mergeOutput(output))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	var record map[string]interface{}
+	if err := dec.Decode(&record); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(map[string]interface{}{"records": []interface{}{jsonToTOML(record)}}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonToTOML converts a value decoded from JSON with json.Number into a value TOML can
+// encode, without nulls
+func jsonToTOML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = jsonToTOML(e)
+		}
+		return v
+	case []interface{}:
+		list := v[:0]
+		for _, e := range v {
+			if e != nil {
+				list = append(list, jsonToTOML(e))
+			}
+		}
+		return list
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
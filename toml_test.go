@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestReadTOML(t *testing.T) {
+	doc := `
+title = " Config "
+port = 8080
+ratio = 0.5
+enabled = true
+released = 2024-01-01T00:00:00Z
+day = 2024-01-02
+at = 07:30:00
+
+[owner]
+name = "Ada"
+ids = [1, 2.5]
+
+[[servers]]
+host = "a"
+
+[[servers]]
+host = "b"
+`
+	records, err := readInput(strings.NewReader(doc), inputOptions{format: "toml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{{
+		"title":    " Config ",
+		"port":     "8080",
+		"ratio":    "0.5",
+		"enabled":  "true",
+		"released": "2024-01-01T00:00:00Z",
+		"day":      "2024-01-02",
+		"at":       "07:30:00",
+		"owner":    map[string]interface{}{"name": "Ada", "ids": []interface{}{"1", "2.5"}},
+		"servers":  []interface{}{map[string]interface{}{"host": "a"}, map[string]interface{}{"host": "b"}},
+	}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readInput = %#v, want %#v", records, want)
+	}
+
+	if _, err := readInput(strings.NewReader("a = \n"), inputOptions{format: "toml"}); err == nil {
+		t.Error("readInput of invalid TOML succeeded")
+	}
+}
+
+func TestEncodeTOML(t *testing.T) {
+	var doc []byte
+	for _, output := range []Output{
+		{{"id": "1", "joined": int64(1704067200), "missing": nil}, {"user": map[string]interface{}{"name": "Ada"}}},
+		{{"id": "2", "raw": json.RawMessage(`{"ratio": 0.5, "tags": ["a", null]}`)}},
+	} {
+		data, err := encodeOutput(output, "toml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc = append(doc, data...)
+	}
+
+	// The records of successive outputs form a single document
+	var got map[string]interface{}
+	if _, err := toml.Decode(string(doc), &got); err != nil {
+		t.Fatalf("decoding %s: %v", doc, err)
+	}
+	want := map[string]interface{}{"records": []map[string]interface{}{
+		{"id": "1", "joined": int64(1704067200), "user": map[string]interface{}{"name": "Ada"}},
+		{"id": "2", "raw": map[string]interface{}{"ratio": 0.5, "tags": []interface{}{"a"}}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encodeOutput(toml) = %s, want the records %v", doc, want)
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/oauth2/google"
+)
+
+// warehousePollInterval is how often load jobs and statements are polled for completion
+const warehousePollInterval = 2 * time.Second
+
+// Google API endpoints used by the BigQuery sink
+const (
+	gcsUploadURL   = "https://storage.googleapis.com/upload/storage/v1"
+	bigQueryAPIURL = "https://bigquery.googleapis.com/bigquery/v2"
+)
+
+// warehouseSink stages the output as NDJSON rows in object storage and loads the staged
+// file into a warehouse table when closed
+type warehouseSink struct {
+	file  *os.File
+	rows  int
+	stage func(ctx context.Context, name string, body io.ReadSeeker) (string, error)
+	load  func(ctx context.Context, object string) error
+}
+
+// isWarehouseURI reports whether an output URI addresses a BigQuery or Snowflake table
+func isWarehouseURI(uri string) bool {
+	return strings.HasPrefix(uri, "bigquery://") || strings.HasPrefix(uri, "snowflake://")
+}
+
+// openWarehouseSink opens a sink for a URI like
+// "bigquery://project/dataset/table?staging=gs://bucket/prefix" or
+// "snowflake://account/database/schema/table?staging=s3://bucket/prefix&stage=name"
+func openWarehouseSink(uri string) (*warehouseSink, error) {
+	s := &warehouseSink{}
+	var err error
+	if strings.HasPrefix(uri, "bigquery://") {
+		err = s.configureBigQuery(uri)
+	} else {
+		err = s.configureSnowflake(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.file, err = os.CreateTemp("", "transform-*.ndjson"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends the merged output as a single NDJSON row
+func (s *warehouseSink) Write(output Output) error {
+	record := mergeOutput(output)
+	if len(record) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.rows++
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close uploads the staged rows and runs the load, removing the local staging file
+func (s *warehouseSink) Close() error {
+	defer os.Remove(s.file.Name())
+	defer s.file.Close()
+	if s.rows == 0 {
+		return nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	name := fmt.Sprintf("transform-%s-%d.ndjson", time.Now().UTC().Format("20060102T150405.000000000"), os.Getpid())
+	object, err := s.stage(ctx, name, s.file)
+	if err != nil {
+		return fmt.Errorf("staging output: %v", err)
+	}
+	if err := s.load(ctx, object); err != nil {
+		return fmt.Errorf("loading %s: %v", object, err)
+	}
+	return nil
+}
+
+// parseStagingURI splits a "gs://bucket/prefix" or "s3://bucket/prefix" staging location
+func parseStagingURI(uri, scheme string) (string, string, error) {
+	rest, ok := strings.CutPrefix(uri, scheme+"://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if !ok || bucket == "" {
+		return "", "", fmt.Errorf("staging location %q must be of the form %s://bucket/prefix", uri, scheme)
+	}
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// stagingObject joins a staging prefix and file name into an object name
+func stagingObject(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// bigQueryTable is the table addressed by a bigquery URI
+type bigQueryTable struct {
+	project, dataset, table string
+	bucket, prefix          string
+	location                string
+	truncate                bool
+}
+
+// parseBigQueryURI parses a "bigquery://project/dataset/table?staging=gs://bucket/prefix"
+// URI with optional "location" and "write=truncate" parameters
+func parseBigQueryURI(uri string) (bigQueryTable, error) {
+	var t bigQueryTable
+	rest, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, "bigquery://"), "?")
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return t, fmt.Errorf("bigquery URI %q must be of the form bigquery://project/dataset/table?staging=gs://bucket/prefix", uri)
+	}
+	t.project, t.dataset, t.table = parts[0], parts[1], parts[2]
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return t, fmt.Errorf("invalid bigquery URI %q: %v", uri, err)
+	}
+	if t.bucket, t.prefix, err = parseStagingURI(query.Get("staging"), "gs"); err != nil {
+		return t, err
+	}
+	t.location = query.Get("location")
+	switch query.Get("write") {
+	case "", "append":
+	case "truncate":
+		t.truncate = true
+	default:
+		return t, fmt.Errorf("unsupported bigquery write mode %q", query.Get("write"))
+	}
+	return t, nil
+}
+
+// configureBigQuery stages rows in Cloud Storage and loads them with a BigQuery load job,
+// authenticating with Application Default Credentials
+func (s *warehouseSink) configureBigQuery(uri string) error {
+	t, err := parseBigQueryURI(uri)
+	if err != nil {
+		return err
+	}
+	client, err := google.DefaultClient(context.Background(), "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return fmt.Errorf("finding Google credentials: %v", err)
+	}
+
+	s.stage = func(ctx context.Context, name string, body io.ReadSeeker) (string, error) {
+		object := stagingObject(t.prefix, name)
+		u := fmt.Sprintf("%s/b/%s/o?uploadType=media&name=%s", gcsUploadURL, url.PathEscape(t.bucket), url.QueryEscape(object))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if err := doJSON(client, req, nil); err != nil {
+			return "", err
+		}
+		return "gs://" + t.bucket + "/" + object, nil
+	}
+
+	s.load = func(ctx context.Context, object string) error {
+		load := map[string]interface{}{
+			"sourceUris":       []string{object},
+			"sourceFormat":     "NEWLINE_DELIMITED_JSON",
+			"autodetect":       true,
+			"writeDisposition": "WRITE_APPEND",
+			"destinationTable": map[string]interface{}{"projectId": t.project, "datasetId": t.dataset, "tableId": t.table},
+		}
+		if t.truncate {
+			load["writeDisposition"] = "WRITE_TRUNCATE"
+		} else {
+			// Let appended rows add columns the table does not have yet
+			load["schemaUpdateOptions"] = []string{"ALLOW_FIELD_ADDITION"}
+		}
+		job := map[string]interface{}{
+			"jobReference":  map[string]interface{}{"projectId": t.project, "location": t.location},
+			"configuration": map[string]interface{}{"load": load},
+		}
+		body, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+
+		var status bigQueryJob
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/projects/%s/jobs", bigQueryAPIURL, url.PathEscape(t.project)), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if err := doJSON(client, req, &status); err != nil {
+			return err
+		}
+
+		// Poll the job until it is done
+		for status.Status.State != "DONE" {
+			time.Sleep(warehousePollInterval)
+			u := fmt.Sprintf("%s/projects/%s/jobs/%s?location=%s", bigQueryAPIURL, url.PathEscape(t.project),
+				url.PathEscape(status.JobReference.JobID), url.QueryEscape(status.JobReference.Location))
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+			if err != nil {
+				return err
+			}
+			if err := doJSON(client, req, &status); err != nil {
+				return err
+			}
+		}
+		if status.Status.ErrorResult != nil {
+			return fmt.Errorf("BigQuery load job %s failed: %s", status.JobReference.JobID, status.Status.ErrorResult.Message)
+		}
+		return nil
+	}
+	return nil
+}
+
+// bigQueryJob is the subset of a BigQuery job resource needed to follow a load job
+type bigQueryJob struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Status struct {
+		State       string `json:"state"`
+		ErrorResult *struct {
+			Message string `json:"message"`
+		} `json:"errorResult"`
+	} `json:"status"`
+}
+
+// snowflakeTable is the table addressed by a snowflake URI
+type snowflakeTable struct {
+	account, database, schema, table string
+	bucket, prefix                   string
+	stage, warehouse, role           string
+}
+
+// parseSnowflakeURI parses a "snowflake://account/database/schema/table?staging=s3://bucket/
+// prefix&stage=name" URI with optional "warehouse" and "role" parameters. The named
+// external stage must point at the staging location.
+func parseSnowflakeURI(uri string) (snowflakeTable, error) {
+	var t snowflakeTable
+	rest, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, "snowflake://"), "?")
+	parts := strings.Split(rest, "/")
+	if len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return t, fmt.Errorf("snowflake URI %q must be of the form snowflake://account/database/schema/table?staging=s3://bucket/prefix&stage=name", uri)
+	}
+	t.account, t.database, t.schema, t.table = parts[0], parts[1], parts[2], parts[3]
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return t, fmt.Errorf("invalid snowflake URI %q: %v", uri, err)
+	}
+	if t.bucket, t.prefix, err = parseStagingURI(query.Get("staging"), "s3"); err != nil {
+		return t, err
+	}
+	if t.stage = query.Get("stage"); t.stage == "" {
+		return t, fmt.Errorf("snowflake URI %q has no stage", uri)
+	}
+	t.warehouse, t.role = query.Get("warehouse"), query.Get("role")
+	return t, nil
+}
+
+// copyStatement returns the COPY INTO statement loading a staged file into the table
+func (t snowflakeTable) copyStatement(file string) string {
+	return fmt.Sprintf("COPY INTO %s.%s.%s FROM @%s FILES = ('%s') FILE_FORMAT = (TYPE = JSON) MATCH_BY_COLUMN_NAME = CASE_INSENSITIVE",
+		quoteIdent(t.database), quoteIdent(t.schema), quoteIdent(t.table), t.stage, strings.ReplaceAll(file, "'", "''"))
+}
+
+// configureSnowflake stages rows in S3 with the default AWS credential chain and loads them
+// with COPY INTO through the Snowflake SQL API, authenticating with the token in
+// SNOWFLAKE_TOKEN of the type in SNOWFLAKE_TOKEN_TYPE (default OAUTH)
+func (s *warehouseSink) configureSnowflake(uri string) error {
+	t, err := parseSnowflakeURI(uri)
+	if err != nil {
+		return err
+	}
+	token := os.Getenv("SNOWFLAKE_TOKEN")
+	if token == "" {
+		return errors.New("SNOWFLAKE_TOKEN is not set")
+	}
+	tokenType := os.Getenv("SNOWFLAKE_TOKEN_TYPE")
+	if tokenType == "" {
+		tokenType = "OAUTH"
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return fmt.Errorf("loading AWS configuration: %v", err)
+	}
+	client := s3.NewFromConfig(cfg)
+
+	s.stage = func(ctx context.Context, name string, body io.ReadSeeker) (string, error) {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(t.bucket),
+			Key:         aws.String(stagingObject(t.prefix, name)),
+			Body:        body,
+			ContentType: aws.String("application/x-ndjson"),
+		})
+		return name, err
+	}
+
+	s.load = func(ctx context.Context, file string) error {
+		body, err := json.Marshal(map[string]interface{}{
+			"statement": t.copyStatement(file),
+			"timeout":   3600,
+			"database":  t.database,
+			"schema":    t.schema,
+			"warehouse": t.warehouse,
+			"role":      t.role,
+		})
+		if err != nil {
+			return err
+		}
+		base := fmt.Sprintf("https://%s.snowflakecomputing.com/api/v2/statements", t.account)
+		newRequest := func(method, u string, body io.Reader) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, method, u, body)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("X-Snowflake-Authorization-Token-Type", tokenType)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			return req, nil
+		}
+
+		req, err := newRequest(http.MethodPost, base, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		var status snowflakeStatus
+		code, err := doSnowflake(req, &status)
+		// Statements still running answer 202 and are polled by handle
+		for err == nil && code == http.StatusAccepted {
+			time.Sleep(warehousePollInterval)
+			if req, err = newRequest(http.MethodGet, base+"/"+url.PathEscape(status.StatementHandle), nil); err != nil {
+				return err
+			}
+			code, err = doSnowflake(req, &status)
+		}
+		return err
+	}
+	return nil
+}
+
+// snowflakeStatus is the subset of a Snowflake SQL API response needed to follow a statement
+type snowflakeStatus struct {
+	StatementHandle string `json:"statementHandle"`
+	Message         string `json:"message"`
+}
+
+// doSnowflake sends a SQL API request, returning the status code of successful responses
+func doSnowflake(req *http.Request, status *snowflakeStatus) (int, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("decoding Snowflake response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return 0, fmt.Errorf("Snowflake statement failed with %s: %s", resp.Status, status.Message)
+	}
+	return resp.StatusCode, nil
+}
+
+// doJSON sends a Google API request and decodes the JSON response into v unless it is nil
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(data))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseBigQueryURI(t *testing.T) {
+	got, err := parseBigQueryURI("bigquery://proj/ds/events?staging=gs://bucket/loads/daily/&location=EU&write=truncate")
+	if err != nil {
+		t.Fatalf("parseBigQueryURI error: %v", err)
+	}
+	want := bigQueryTable{project: "proj", dataset: "ds", table: "events", bucket: "bucket", prefix: "loads/daily", location: "EU", truncate: true}
+	if got != want {
+		t.Errorf("parseBigQueryURI = %+v, want %+v", got, want)
+	}
+
+	for _, uri := range []string{
+		"bigquery://proj/ds?staging=gs://bucket",
+		"bigquery://proj/ds/events",
+		"bigquery://proj/ds/events?staging=s3://bucket",
+		"bigquery://proj/ds/events?staging=gs://bucket&write=merge",
+	} {
+		if _, err := parseBigQueryURI(uri); err == nil {
+			t.Errorf("parseBigQueryURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestParseSnowflakeURI(t *testing.T) {
+	got, err := parseSnowflakeURI("snowflake://acme-eu/analytics/raw/events?staging=s3://bucket&stage=raw.load_stage&warehouse=load_wh&role=loader")
+	if err != nil {
+		t.Fatalf("parseSnowflakeURI error: %v", err)
+	}
+	want := snowflakeTable{
+		account: "acme-eu", database: "analytics", schema: "raw", table: "events",
+		bucket: "bucket", stage: "raw.load_stage", warehouse: "load_wh", role: "loader",
+	}
+	if got != want {
+		t.Errorf("parseSnowflakeURI = %+v, want %+v", got, want)
+	}
+	wantCopy := `COPY INTO "analytics"."raw"."events" FROM @raw.load_stage FILES = ('a''b.ndjson') FILE_FORMAT = (TYPE = JSON) MATCH_BY_COLUMN_NAME = CASE_INSENSITIVE`
+	if stmt := got.copyStatement("a'b.ndjson"); stmt != wantCopy {
+		t.Errorf("copyStatement = %q, want %q", stmt, wantCopy)
+	}
+
+	for _, uri := range []string{
+		"snowflake://acme/analytics/events?staging=s3://bucket&stage=s",
+		"snowflake://acme/analytics/raw/events?stage=s",
+		"snowflake://acme/analytics/raw/events?staging=s3://bucket",
+	} {
+		if _, err := parseSnowflakeURI(uri); err == nil {
+			t.Errorf("parseSnowflakeURI(%q) succeeded, want error", uri)
+		}
+	}
+}
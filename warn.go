@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ajaygolang/Coding-Challenge-Comcast/pkg/transform"
+)
+
+// warningRules are the -warn-as-error and -suppress-warning patterns, shared by the
+// warnings of the transformer and those of the command
+var warningRules *transform.WarningRules
+
+// warn prints a warning of a class, unless it is suppressed, and fails when it is promoted
+// to an error
+func warn(class, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	switch warningRules.Action(transform.Warning{Class: class, Message: msg}) {
+	case "error":
+		fatalf("error: %s (promoted from warning %s)", msg, class)
+	case "warn":
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+}
+
+// printWarning prints a warning of the transformer, as the OnWarning hook of the
+// transformers of the command
+func printWarning(w transform.Warning) {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", w.Message)
+}
+
+// parseWarningRules parses the -warn-as-error and -suppress-warning patterns, returning nil
+// when there are none
+func parseWarningRules(asError, suppress string) (*transform.WarningRules, error) {
+	var rules transform.WarningRules
+	var err error
+	if asError != "" {
+		if rules.AsError, err = transform.ParseWarningPatterns(asError); err != nil {
+			return nil, err
+		}
+	}
+	if suppress != "" {
+		if rules.Suppress, err = transform.ParseWarningPatterns(suppress); err != nil {
+			return nil, err
+		}
+	}
+	if rules.AsError == nil && rules.Suppress == nil {
+		return nil, nil
+	}
+	return &rules, nil
+}
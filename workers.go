@@ -0,0 +1,36 @@
+package main
+
+// job computes a result, such as the transformed outputs of a record, and returns the
+// function that writes it
+type job func() func() error
+
+// runOrdered runs the jobs received from jobs on up to workers goroutines, calling the
+// write function of each in the order the jobs were sent, so that records are transformed
+// in parallel but written in input order. Up to workers results wait to be written. It
+// returns the first error of a write function, abandoning the jobs not yet written.
+func runOrdered(workers int, jobs <-chan job) error {
+	if workers < 1 {
+		workers = 1
+	}
+	pending := make(chan chan func() error, workers)
+	go func() {
+		sem := make(chan struct{}, workers)
+		for j := range jobs {
+			done := make(chan func() error, 1)
+			pending <- done
+			sem <- struct{}{}
+			go func(j job) {
+				done <- j()
+				<-sem
+			}(j)
+		}
+		close(pending)
+	}()
+
+	for done := range pending {
+		if err := (<-done)(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunOrdered(t *testing.T) {
+	for _, workers := range []int{0, 1, 4} {
+		var order []int
+		jobs := make(chan job)
+		go func() {
+			for i := 0; i < 20; i++ {
+				jobs <- func() func() error {
+					// Later jobs finish first
+					time.Sleep(time.Duration(20-i) * 100 * time.Microsecond)
+					return func() error {
+						order = append(order, i)
+						return nil
+					}
+				}
+			}
+			close(jobs)
+		}()
+		if err := runOrdered(workers, jobs); err != nil {
+			t.Fatal(err)
+		}
+		var want []int
+		for i := 0; i < 20; i++ {
+			want = append(want, i)
+		}
+		if !reflect.DeepEqual(order, want) {
+			t.Errorf("runOrdered with %d workers wrote %v", workers, order)
+		}
+	}
+
+	jobs := make(chan job, 2)
+	jobs <- func() func() error { return func() error { return errors.New("boom") } }
+	close(jobs)
+	if err := runOrdered(2, jobs); err == nil {
+		t.Error("runOrdered ignored the error of a write")
+	}
+}
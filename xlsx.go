@@ -0,0 +1,319 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxWorkbook is the subset of xl/workbook.xml needed to locate sheets
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships is the subset of xl/_rels/workbook.xml.rels mapping ids to parts
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is rich or plain text as found in shared and inline strings
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// xlsxSharedStrings is xl/sharedStrings.xml
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxStyles is the subset of xl/styles.xml needed to detect date formatted cells
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+// xlsxSheet is a worksheet part
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string    `xml:"r,attr"`
+			Type   string    `xml:"t,attr"`
+			Style  int       `xml:"s,attr"`
+			Value  string    `xml:"v"`
+			Inline *xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX reads the selected sheet of a workbook into records keyed by the header row
+func readXLSX(r io.Reader, sheet string, headerRow int) ([]Input, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx workbook: %v", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := decodeXLSXPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	// Optional parts: shared strings and styles
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var styles xlsxStyles
+	if _, ok := files["xl/styles.xml"]; ok {
+		if err := decodeXLSXPart(files, "xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve the selected sheet, by name or 1-based index, to its worksheet part
+	if len(workbook.Sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	index := -1
+	if sheet == "" {
+		index = 0
+	} else {
+		for i, s := range workbook.Sheets {
+			if s.Name == sheet {
+				index = i
+				break
+			}
+		}
+		if n, err := strconv.Atoi(sheet); index < 0 && err == nil && n >= 1 && n <= len(workbook.Sheets) {
+			index = n - 1
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("sheet %q not found", sheet)
+	}
+	var part string
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[index].RID {
+			if strings.HasPrefix(rel.Target, "/") {
+				part = strings.TrimPrefix(rel.Target, "/")
+			} else {
+				part = path.Join("xl", rel.Target)
+			}
+		}
+	}
+	if part == "" {
+		return nil, fmt.Errorf("sheet %q has no worksheet part", workbook.Sheets[index].Name)
+	}
+	var ws xlsxSheet
+	if err := decodeXLSXPart(files, part, &ws); err != nil {
+		return nil, err
+	}
+
+	dateStyles := xlsxDateStyles(styles)
+	var header map[int]string
+	var records []Input
+	rowNum := 0
+	for _, row := range ws.Rows {
+		// Rows may omit their number, in which case they follow the previous row
+		if row.R > 0 {
+			rowNum = row.R
+		} else {
+			rowNum++
+		}
+		if rowNum < headerRow {
+			continue
+		}
+
+		cells := make(map[int]string)
+		for col, c := range row.Cells {
+			if c.Ref != "" {
+				col = xlsxColumn(c.Ref)
+			}
+
+			var value string
+			switch c.Type {
+			case "s":
+				i, err := strconv.Atoi(c.Value)
+				if err != nil || i < 0 || i >= len(shared.Items) {
+					warn("invalid-cell", "Skipping cell %s with invalid shared string index", c.Ref)
+					continue
+				}
+				value = shared.Items[i].String()
+			case "inlineStr":
+				if c.Inline != nil {
+					value = c.Inline.String()
+				}
+			case "b":
+				value = strconv.FormatBool(c.Value == "1")
+			case "e":
+				warn("invalid-cell", "Skipping cell %s with error value %s", c.Ref, c.Value)
+				continue
+			case "", "n":
+				value = c.Value
+				if dateStyles[c.Style] && value != "" {
+					if f, err := strconv.ParseFloat(value, 64); err == nil {
+						value = xlsxSerialTime(f, workbook.Properties.Date1904).Format(time.RFC3339)
+					}
+				}
+			default:
+				value = c.Value
+			}
+			if value != "" {
+				cells[col] = value
+			}
+		}
+
+		// The first row at or after the header row provides the column names
+		if header == nil {
+			header = cells
+			continue
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		record := make(Input)
+		for col, value := range cells {
+			name, ok := header[col]
+			if !ok {
+				warn("invalid-cell", "Skipping cell in row %d without header", rowNum)
+				continue
+			}
+			record[name] = value
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// decodeXLSXPart decodes the named XML part of the workbook archive into v
+func decodeXLSXPart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("workbook is missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %v", name, err)
+	}
+	return nil
+}
+
+// String returns the text content, concatenating rich text runs
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+// xlsxColumn converts the column letters of a cell reference like "AB12" to a 0-based index
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+	}
+	return col - 1
+}
+
+// xlsxDateStyles returns the cell style indexes whose number format displays a date or time
+func xlsxDateStyles(styles xlsxStyles) map[int]bool {
+	custom := make(map[int]string)
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+
+	dates := make(map[int]bool)
+	for i, xf := range styles.CellXfs {
+		id := xf.NumFmtID
+		if code, ok := custom[id]; ok {
+			dates[i] = isDateFormatCode(code)
+		} else {
+			// Built-in date and time formats
+			dates[i] = (id >= 14 && id <= 22) || (id >= 45 && id <= 47)
+		}
+	}
+	return dates
+}
+
+// isDateFormatCode reports whether a custom number format code contains date or time tokens
+func isDateFormatCode(code string) bool {
+	inQuote, inBracket := false, false
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '[':
+			inBracket = true
+		case c == ']':
+			inBracket = false
+		case inBracket:
+		case strings.ContainsRune("ymdhs", c):
+			return true
+		}
+	}
+	return false
+}
+
+// xlsxSerialTime converts a spreadsheet serial date to a UTC time
+func xlsxSerialTime(serial float64, date1904 bool) time.Time {
+	// The 1900 system counts from 1899-12-30 to absorb the fictitious 1900-02-29
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	return epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
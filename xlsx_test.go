@@ -0,0 +1,118 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestXLSXSerialTime(t *testing.T) {
+	tests := []struct {
+		serial   float64
+		date1904 bool
+		want     time.Time
+	}{
+		{1, false, time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{61, false, time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{45292, false, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{45292.5, false, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		// Fractions that are not exact in binary round to the nearest second
+		{45292.0000115741, false, time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{0.75, false, time.Date(1899, 12, 30, 18, 0, 0, 0, time.UTC)},
+		{0, true, time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{43830, true, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := xlsxSerialTime(tt.serial, tt.date1904); !got.Equal(tt.want) {
+			t.Errorf("xlsxSerialTime(%v, %v) = %v, want %v", tt.serial, tt.date1904, got, tt.want)
+		}
+	}
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	tests := map[string]bool{
+		"yyyy-mm-dd":         true,
+		"[$-409]h:mm AM/PM":  true,
+		"dd/mm/yyyy hh:mm":   true,
+		"0.00":               false,
+		"#,##0":              false,
+		`0.0 "days"`:         false,
+		"[Red]0.00":          false,
+		`"Total: "0`:         false,
+		`[h]:mm:ss`:          true,
+		`"yyyy" 0;[Blue]-0`:  false,
+		`General`:            false,
+		`0.00E+00`:           false,
+		`mmm yy`:             true,
+		`[$€-2] #,##0.00`:    false,
+		`"Date "dd.mm.yyyy`:  true,
+		`[<=9999999]###-###`: false,
+	}
+	for code, want := range tests {
+		if got := isDateFormatCode(code); got != want {
+			t.Errorf("isDateFormatCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB12": 27, "XFD1048576": 16383}
+	for ref, want := range tests {
+		if got := xlsxColumn(ref); got != want {
+			t.Errorf("xlsxColumn(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Notes" r:id="rId1"/><sheet name="Data" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+			<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>name</t></si><si><t>joined</t></si><si><r><t>Ada </t></r><r><t>L.</t></r></si></sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>
+			<cellXfs><xf numFmtId="0"/><xf numFmtId="164"/><xf numFmtId="14"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>ignored</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="inlineStr"><is><t>title</t></is></c></row>
+			<row r="2"><c r="A2" t="s"><v>0</v></c><c r="B2" t="s"><v>1</v></c><c r="C2" t="inlineStr"><is><t>active</t></is></c><c r="D2" t="inlineStr"><is><t>n</t></is></c></row>
+			<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3" s="1"><v>45292.5</v></c><c r="C3" t="b"><v>1</v></c><c r="D3"><v>42</v></c></row>
+			<row r="4"/>
+			<row><c r="A5" t="inlineStr"><is><t>Grace</t></is></c><c r="B5" s="2"><v>45293</v></c><c r="D5" t="e"><v>#DIV/0!</v></c></row>
+		</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sheet := range []string{"Data", "2"} {
+		records, err := readXLSX(bytes.NewReader(buf.Bytes()), sheet, 2)
+		if err != nil {
+			t.Fatalf("readXLSX(%q) error: %v", sheet, err)
+		}
+		want := []Input{
+			{"name": "Ada L.", "joined": "2024-01-01T12:00:00Z", "active": "true", "n": "42"},
+			{"name": "Grace", "joined": "2024-01-02T00:00:00Z"},
+		}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("readXLSX(%q) = %#v, want %#v", sheet, records, want)
+		}
+	}
+
+	if _, err := readXLSX(bytes.NewReader(buf.Bytes()), "Missing", 1); err == nil {
+		t.Errorf("readXLSX succeeded for a missing sheet, want error")
+	}
+}
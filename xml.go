@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// readXML reads a stream of XML documents, each root element decoded into a record. Child
+// elements become fields named by their local name, repeated elements lists of their
+// values, and attributes fields named "@" and the attribute name. Elements holding only
+// text become strings; the text of elements with attributes or children goes to "#text".
+// Legacy encodings such as ISO-8859-1 are converted as the XML declaration names them.
+func readXML(r io.Reader) ([]Input, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = xmlCharsetReader
+	var records []Input
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			// Declarations, comments and whitespace between documents
+			continue
+		}
+		value, err := xmlElementValue(dec, start)
+		if err != nil {
+			return nil, fmt.Errorf("XML element %s: %v", start.Name.Local, err)
+		}
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			// A root holding only text keeps its name
+			record = map[string]interface{}{start.Name.Local: value}
+		}
+		records = append(records, record)
+	}
+}
+
+// xmlElementValue decodes the content of an element up to its end into a string, or into a
+// map of its attributes and children
+func xmlElementValue(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+			// Namespaces are dropped along with element prefixes
+			continue
+		}
+		m["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	repeated := make(map[string]bool)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			value, err := xmlElementValue(dec, tok)
+			if err != nil {
+				return nil, err
+			}
+			name := tok.Name.Local
+			switch existing, ok := m[name]; {
+			case !ok:
+				m[name] = value
+			case repeated[name]:
+				m[name] = append(existing.([]interface{}), value)
+			default:
+				m[name] = []interface{}{existing, value}
+				repeated[name] = true
+			}
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			if len(m) == 0 {
+				return text.String(), nil
+			}
+			// Whitespace between child elements is not text
+			if s := text.String(); strings.TrimSpace(s) != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// xmlCharsetReader converts the documents whose declaration names an encoding other than
+// UTF-8 to UTF-8
+func xmlCharsetReader(label string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported XML encoding %q", label)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
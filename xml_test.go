@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadXML(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<!-- legacy export -->
+<order xmlns="urn:orders" xmlns:x="urn:x" id="17">
+  <customer> Jane </customer>
+  <x:created>2024-01-01T00:00:00Z</x:created>
+  <item sku="a1">2</item>
+  <item sku="b2">3</item>
+  <note/>
+  <tags><tag>web</tag></tags>
+</order>
+<status>ok</status>`
+	records, err := readInput(strings.NewReader(doc), inputOptions{format: "xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{
+		{
+			"@id":      "17",
+			"customer": " Jane ",
+			"created":  "2024-01-01T00:00:00Z",
+			"item": []interface{}{
+				map[string]interface{}{"@sku": "a1", "#text": "2"},
+				map[string]interface{}{"@sku": "b2", "#text": "3"},
+			},
+			"note": "",
+			"tags": map[string]interface{}{"tag": "web"},
+		},
+		{"status": "ok"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %#v, want %#v", records, want)
+	}
+
+	// ISO-8859-1 documents are converted to UTF-8
+	latin1 := append([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><r><city>`), 0xe9, '<', '/', 'c', 'i', 't', 'y', '>', '<', '/', 'r', '>')
+	records, err = readInput(bytes.NewReader(latin1), inputOptions{format: "xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if city := records[0]["city"]; city != "é" {
+		t.Errorf("city = %q, want é", city)
+	}
+
+	for _, doc := range []string{`<order><id>1</id>`, `<order></item></order>`} {
+		if _, err := readInput(strings.NewReader(doc), inputOptions{format: "xml"}); err == nil {
+			t.Errorf("readInput(%q) succeeded, want error", doc)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readYAML reads a stream of YAML documents, each a mapping decoded into a record. Numbers
+// and booleans become strings like the rest of the input, and timestamps stay the strings
+// they are written as, so that the timestamp coercion applies to them as to JSON strings
+func readYAML(r io.Reader) ([]Input, error) {
+	var records []Input
+	dec := yaml.NewDecoder(r)
+	for i := 1; ; i++ {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, err
+		}
+		value, err := yamlValue(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("YAML document %d: %v", i, err)
+		}
+		if value == nil {
+			// Skip empty documents, as between consecutive separators
+			continue
+		}
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("YAML document %d is not a mapping", i)
+		}
+		records = append(records, record)
+	}
+}
+
+// yamlValue converts a YAML node into a record value, with scalars other than null as strings
+func yamlValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, e := range n.Content {
+			v, err := yamlValue(e)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(n.Content)/2)
+		var merged []map[string]interface{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			v, err := yamlValue(value)
+			if err != nil {
+				return nil, err
+			}
+			if key.Tag == "!!merge" {
+				// Merge keys such as <<: *defaults copy the keys the mapping does not set
+				switch v := v.(type) {
+				case map[string]interface{}:
+					merged = append(merged, v)
+				case []interface{}:
+					for _, e := range v {
+						if e, ok := e.(map[string]interface{}); ok {
+							merged = append(merged, e)
+						}
+					}
+				}
+				continue
+			}
+			m[key.Value] = v
+		}
+		for _, defaults := range merged {
+			for k, v := range defaults {
+				if _, ok := m[k]; !ok {
+					m[k] = v
+				}
+			}
+		}
+		return m, nil
+	}
+
+	if n.Tag == "!!timestamp" {
+		return n.Value, nil
+	}
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			// Numbers have no infinities or NaN, so keep them as written
+			return n.Value, nil
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return v, nil
+}
+
+// encodeYAML encodes the output of a record as a YAML document of the values its JSON
+// encoding has, with the same key order: sorted, so that output is stable from run to run,
+// except within passthrough values, which keep their input order
+func encodeYAML(output Output) ([]byte, error) {
+	jsonData, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	// YAML is a superset of JSON, so the JSON decodes with its number literals as written
+	var doc yaml.Node
+	if err := yaml.Unmarshal(jsonData, &doc); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&doc)
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resetYAMLStyle clears the flow and quoting styles of nodes decoded from JSON, so that they
+// encode in block style with strings quoted only where YAML needs it
+func resetYAMLStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		resetYAMLStyle(c)
+	}
+}
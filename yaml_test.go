@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadYAML(t *testing.T) {
+	doc := `
+defaults: &defaults
+  region: eu
+  replicas: 2
+service:
+  <<: *defaults
+  name: " api "
+  replicas: 3
+  created: 2024-01-01T00:00:00Z
+  day: 2024-01-02
+  tags: [web, "7", 8]
+  ratio: .inf
+  hex: 0x1F
+  share: 0.25
+---
+---
+id: 2
+enabled: true
+missing: ~
+`
+	records, err := readInput(strings.NewReader(doc), inputOptions{format: "yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Numbers and booleans become strings, as the transformer skips native ones
+	want := []Input{
+		{
+			"defaults": map[string]interface{}{"region": "eu", "replicas": "2"},
+			"service": map[string]interface{}{
+				"region":   "eu",
+				"name":     " api ",
+				"replicas": "3",
+				"created":  "2024-01-01T00:00:00Z",
+				"day":      "2024-01-02",
+				"tags":     []interface{}{"web", "7", "8"},
+				"ratio":    ".inf",
+				"hex":      "31",
+				"share":    "0.25",
+			},
+		},
+		{"id": "2", "enabled": "true", "missing": nil},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readInput = %#v, want %#v", records, want)
+	}
+
+	for _, doc := range []string{"- a\n- b\n", "a: [\n", "a: *missing\n"} {
+		if _, err := readInput(strings.NewReader(doc), inputOptions{format: "yaml"}); err == nil {
+			t.Errorf("readInput(%q) succeeded, want error", doc)
+		}
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	output := Output{
+		{"name": "Ada", "active": "true", "joined": int64(1704067200)},
+		{"tags": []interface{}{"b", "a"}, "raw": json.RawMessage(`{"z": 1.50, "a": null}`), "zip": "02134", "empty": map[string]interface{}{}},
+	}
+	data, err := encodeOutput(output, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `---
+- active: "true"
+  joined: 1704067200
+  name: Ada
+- empty: {}
+  raw:
+    z: 1.50
+    a: null
+  tags:
+    - b
+    - a
+  zip: "02134"
+`
+	if string(data) != want {
+		t.Errorf("encodeOutput(yaml) =\n%s\nwant\n%s", data, want)
+	}
+}